@@ -0,0 +1,292 @@
+package main
+
+import (
+	"fmt"
+	"math/big"
+	"net/http"
+	"slices"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	types "github.com/snakehunterr/hacs_db_types"
+	api_errors "github.com/snakehunterr/hacs_db_types/errors"
+	validators "github.com/snakehunterr/hacs_db_types/validators"
+)
+
+const (
+	ShareRuleArea   = "area"
+	ShareRulePeople = "people"
+	ShareRuleEqual  = "equal"
+)
+
+// ExpenseShare marks an expense as shared between rooms. Remainder is the part
+// of the amount left over once every room got its exact share rounded down to
+// a whole kopeck; it was handed out a kopeck at a time, so the allocations
+// add up to the whole amount.
+type ExpenseShare struct {
+	ExpenseID   int64               `json:"expense_id"`
+	Rule        string              `json:"share_rule"`
+	Remainder   Money               `json:"share_remainder" swaggertype:"string"`
+	LastEdited  time.Time           `json:"last_edited"`
+	Allocations []ExpenseAllocation `json:"allocations"`
+}
+
+// ExpenseAllocation is the part of a shared expense passed on to one room.
+type ExpenseAllocation struct {
+	ID         int64     `json:"allocation_id"`
+	ExpenseID  int64     `json:"expense_id"`
+	RoomID     int64     `json:"room_id"`
+	Amount     Money     `json:"allocation_amount" swaggertype:"string"`
+	LastEdited time.Time `json:"last_edited"`
+}
+
+// allocateExpense splits amount between rooms according to rule by the
+// largest remainder method: every room gets its exact share rounded down to a
+// whole kopeck, then the kopecks left, returned as remainder, go one each to
+// the rooms with the largest fractions, the first of rs on a tie.
+func allocateExpense(expense_id int64, amount Money, rule string, rs []Room) (
+	as []ExpenseAllocation,
+	remainder Money,
+	apierr *api_errors.APIError,
+) {
+	weights := make([]int64, len(rs))
+	var total int64
+
+	for i, r := range rs {
+		switch rule {
+		case ShareRuleArea:
+			weights[i] = int64(r.Area)
+		case ShareRulePeople:
+			weights[i] = int64(r.PeopleCount)
+		case ShareRuleEqual:
+			weights[i] = 1
+		default:
+			return nil, 0, api_errors.NewErrIncorrectParam("share_rule")
+		}
+		total += weights[i]
+	}
+
+	if total <= 0 {
+		return nil, 0, api_errors.NewErrIncorrectParam("share_rule: rooms have nothing to distribute by")
+	}
+
+	var (
+		fractions = make([]*big.Int, len(rs))
+		part      = new(big.Int)
+	)
+
+	remainder = amount
+
+	as = make([]ExpenseAllocation, 0, len(rs))
+	for i, r := range rs {
+		fractions[i] = new(big.Int)
+		part.Mul(big.NewInt(int64(amount)), big.NewInt(weights[i]))
+		part.QuoRem(part, big.NewInt(total), fractions[i])
+		remainder -= Money(part.Int64())
+
+		as = append(as, ExpenseAllocation{
+			ExpenseID: expense_id,
+			RoomID:    r.ID,
+			Amount:    Money(part.Int64()),
+		})
+	}
+
+	// Each room lost less than a kopeck, so remainder is less than len(rs).
+	order := make([]int, len(rs))
+	for i := range order {
+		order[i] = i
+	}
+	slices.SortStableFunc(order, func(a, b int) int { return fractions[b].Cmp(fractions[a]) })
+
+	for _, i := range order[:remainder] {
+		as[i].Amount++
+	}
+
+	return as, remainder, nil
+}
+
+// ExpenseShareGet godoc
+// @Summary Get expense share
+// @Schemes http
+// @Description Get distribution rule, rounding remainder and per-room allocations of a shared expense
+// @Tags expense
+// @Param id path int true "Expense ID"
+// @Produce json
+// @Success 200 {object} ExpenseShare "ok"
+// @Failure 400 {object} types.APIResponse "Incorrect parameter"
+// @Failure 404 {object} types.APIResponse "No rows"
+// @Failure 500 {object} types.APIResponse "Internal server error"
+// @Security BearerAuth
+// @Router /expense/id/{id}/share [get]
+func (s *Server) RouteExpenseShareGet(g *gin.Context) {
+	id, apierr := validators.Int64("id", g.Param("id"), false)
+	if apierr != nil {
+		g.JSON(http.StatusBadRequest, types.APIResponse{Error: apierr})
+		return
+	}
+
+	share, err := s.Shares.ByExpenseID(g, id)
+	if err != nil {
+		repoError(g, err)
+		return
+	}
+
+	g.JSON(http.StatusOK, share)
+}
+
+// ExpenseShareRequest is the body of RouteExpenseSharePostCreate.
+type ExpenseShareRequest struct {
+	Rule string `json:"share_rule" bind:"required"`
+}
+
+// ExpenseShareCreate godoc
+// @Summary Share expense between rooms
+// @Schemes http
+// @Description Mark expense as shared and write per-room allocations between the rooms of the building of the expense, by their area or people count in effect on the expense date. Sharing an already shared expense replaces its allocations.
+// @Description Every room gets its share rounded down to a whole kopeck; the kopecks left, share_remainder, go one each to the rooms with the largest fractions.
+// @Tags expense
+// @Param id path int true "Expense ID"
+// @Param share_rule formData string true "Distribution rule" Enums(area, people, equal)
+// @Accept x-www-form-urlencoded,json
+// @Produce json
+// @Success 201 {object} ExpenseShare "New share"
+// @Header 201 {string} Location "URL of the share"
+// @Failure 400 {object} ValidationResponse "Incorrect parameter"
+// @Failure 404 {object} types.APIResponse "No rows"
+// @Failure 412 {object} types.APIResponse "Expense changed while sharing it"
+// @Failure 500 {object} types.APIResponse "Internal server error"
+// @Security BearerAuth
+// @Router /expense/id/{id}/share [post]
+func (s *Server) RouteExpenseSharePostCreate(g *gin.Context) {
+	var (
+		errs paramErrors
+		req  ExpenseShareRequest
+	)
+
+	expense_id, apierr := validators.Int64("id", g.Param("id"), false)
+	errs.add(apierr)
+	errs.add(bindRequest(g, &req)...)
+
+	if errs.respond(g) {
+		return
+	}
+
+	e, err := s.Expenses.ByID(g, expense_id, false)
+	if err != nil {
+		repoError(g, err)
+		return
+	}
+
+	rs, err := s.Rooms.ByBuildingID(g, e.BuildingID, false)
+	if err != nil {
+		repoError(g, err)
+		return
+	}
+
+	// Rooms share the expense by their area and people count on its day.
+	rs, err = s.roomsOn(g, rs, e.Date)
+	if err != nil {
+		repoError(g, err)
+		return
+	}
+
+	if len(rs) == 0 {
+		g.JSON(http.StatusNotFound, types.APIResponse{
+			Error: api_errors.NewErrSQLNoRows("No rooms in the building to share expense between"),
+		})
+		return
+	}
+
+	as, remainder, apierr := allocateExpense(expense_id, e.Amount, req.Rule, rs)
+	if apierr != nil {
+		g.JSON(http.StatusBadRequest, types.APIResponse{Error: apierr})
+		return
+	}
+
+	share := ExpenseShare{
+		ExpenseID:   expense_id,
+		Rule:        req.Rule,
+		Remainder:   remainder,
+		Allocations: as,
+	}
+
+	// The allocations only hold for the amount and date of e.
+	if err := s.Shares.Set(g, &share, etag(e)); err != nil {
+		repoError(g, err)
+		return
+	}
+
+	share, err = s.Shares.ByExpenseID(g, expense_id)
+	if err != nil {
+		repoError(g, err)
+		return
+	}
+
+	logInfo(fmt.Sprintf("Shared expense: %#v", share))
+	created(g, "share", share)
+}
+
+// ExpenseShareDelete godoc
+// @Summary Stop sharing expense
+// @Schemes http
+// @Description Remove share and all per-room allocations of an expense
+// @Tags expense
+// @Param id path int true "Expense ID"
+// @Produce json
+// @Success 200 {object} types.APIResponse "Deleted"
+// @Failure 400 {object} types.APIResponse "Incorrect parameter"
+// @Failure 404 {object} types.APIResponse "Expense is not shared"
+// @Failure 500 {object} types.APIResponse "Internal server error"
+// @Security BearerAuth
+// @Router /expense/id/{id}/share [delete]
+func (s *Server) RouteExpenseShareDelete(g *gin.Context) {
+	id, apierr := validators.Int64("id", g.Param("id"), false)
+	if apierr != nil {
+		g.JSON(http.StatusBadRequest, types.APIResponse{Error: apierr})
+		return
+	}
+
+	if err := s.Shares.Delete(g, id); err != nil {
+		repoError(g, err)
+		return
+	}
+
+	logInfo("Deleted share of expense with expense_id: ", id)
+	g.JSON(http.StatusOK, types.APIResponse{Message: "ok"})
+}
+
+// AllocationByRoomID godoc
+// @Summary Get expense allocations by room_id
+// @Schemes http
+// @Description Get all shared expense allocations of a room
+// @Tags expense
+// @Param id path int true "Room ID"
+// @Produce json
+// @Success 200 {array} ExpenseAllocation "ok"
+// @Failure 400 {object} types.APIResponse "Incorrect parameter"
+// @Failure 404 {object} types.APIResponse "No rows"
+// @Failure 500 {object} types.APIResponse "Internal server error"
+// @Security BearerAuth
+// @Router /expense/allocation/room/id/{id} [get]
+func (s *Server) RouteAllocationGetAllByRoomID(g *gin.Context) {
+	id, apierr := validators.Int64("id", g.Param("id"), false)
+	if apierr != nil {
+		g.JSON(http.StatusBadRequest, types.APIResponse{Error: apierr})
+		return
+	}
+
+	as, err := s.Shares.AllocationsByRoomID(g, id)
+	if err != nil {
+		repoError(g, err)
+		return
+	}
+
+	if len(as) == 0 {
+		g.JSON(http.StatusNotFound, types.APIResponse{
+			Error: api_errors.NewErrSQLNoRows("No rows"),
+		})
+		return
+	}
+
+	g.JSON(http.StatusOK, as)
+}
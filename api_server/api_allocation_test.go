@@ -0,0 +1,60 @@
+package main
+
+import (
+	"testing"
+)
+
+func TestAllocateExpense(t *testing.T) {
+	rooms := []Room{
+		{ID: 1, Area: 3333, PeopleCount: 1},
+		{ID: 2, Area: 3333, PeopleCount: 1},
+		{ID: 3, Area: 3334, PeopleCount: 2},
+	}
+
+	tests := []struct {
+		rule      string
+		amount    Money
+		want      []Money
+		remainder Money
+	}{
+		{ShareRuleEqual, 100, []Money{34, 33, 33}, 1},
+		{ShareRuleEqual, 99, []Money{33, 33, 33}, 0},
+		{ShareRulePeople, 101, []Money{25, 25, 51}, 1},
+		{ShareRuleArea, 100, []Money{33, 33, 34}, 1},
+		{ShareRuleArea, 1, []Money{0, 0, 1}, 1},
+		{ShareRuleArea, moneyMax, []Money{33_330_000_000_000, 33_330_000_000_000, 33_339_999_999_999}, 2},
+	}
+
+	for _, tt := range tests {
+		as, remainder, apierr := allocateExpense(7, tt.amount, tt.rule, rooms)
+		if apierr != nil {
+			t.Fatalf("%s %s: %v", tt.rule, tt.amount, apierr)
+		}
+		if remainder != tt.remainder {
+			t.Errorf("%s %s: remainder = %s, want %s", tt.rule, tt.amount, remainder, tt.remainder)
+		}
+
+		var sum Money
+		for i, a := range as {
+			sum += a.Amount
+			if a.ExpenseID != 7 || a.RoomID != rooms[i].ID || a.Amount != tt.want[i] {
+				t.Errorf("%s %s: allocation %d = %+v, want %s to room %d", tt.rule, tt.amount, i, a, tt.want[i], rooms[i].ID)
+			}
+		}
+		if sum != tt.amount {
+			t.Errorf("%s %s: allocations add up to %s", tt.rule, tt.amount, sum)
+		}
+	}
+}
+
+func TestAllocateExpenseNoWeight(t *testing.T) {
+	_, _, apierr := allocateExpense(1, 100, ShareRulePeople, []Room{{ID: 1}, {ID: 2}})
+	if apierr == nil {
+		t.Fatal("shared by people between empty rooms")
+	}
+
+	_, _, apierr = allocateExpense(1, 100, "volume", []Room{{ID: 1}})
+	if apierr == nil {
+		t.Fatal("shared by an unknown rule")
+	}
+}
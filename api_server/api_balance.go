@@ -0,0 +1,239 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"slices"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	types "github.com/snakehunterr/hacs_db_types"
+	validators "github.com/snakehunterr/hacs_db_types/validators"
+)
+
+// BalanceMonth is one month of a ledger. Expenses are the room's allocations of
+// shared expenses. A positive balance is a debt.
+type BalanceMonth struct {
+	Period         string `json:"period"`
+	OpeningBalance Money  `json:"opening_balance" swaggertype:"string"`
+	Charges        Money  `json:"charges" swaggertype:"string"`
+	Expenses       Money  `json:"expenses" swaggertype:"string"`
+	Payments       Money  `json:"payments" swaggertype:"string"`
+	ClosingBalance Money  `json:"closing_balance" swaggertype:"string"`
+}
+
+// Balance is the running ledger of a room or of all rooms a client pays for,
+// optionally only those of one building. ClosingBalance is what is still owed
+// after the last month. All amounts are in Currency, the currency of the
+// buildings of the rooms.
+type Balance struct {
+	RoomID         int64          `json:"room_id,omitempty"`
+	ClientID       int64          `json:"client_id,omitempty"`
+	BuildingID     int64          `json:"building_id,omitempty"`
+	Currency       string         `json:"currency"`
+	Months         []BalanceMonth `json:"months"`
+	ClosingBalance Money          `json:"closing_balance" swaggertype:"string"`
+}
+
+type balanceRow struct {
+	Period   time.Time
+	Charges  Money
+	Expenses Money
+	Payments Money
+}
+
+// balanceLedger folds monthly totals into running balances starting from zero.
+func balanceLedger(bs []balanceRow) (months []BalanceMonth, closing Money) {
+	months = make([]BalanceMonth, 0, len(bs))
+
+	for _, b := range bs {
+		m := BalanceMonth{
+			Period:         b.Period.Format(PERIOD_FORMAT),
+			OpeningBalance: closing,
+			Charges:        b.Charges,
+			Expenses:       b.Expenses,
+			Payments:       b.Payments,
+		}
+		closing += b.Charges + b.Expenses - b.Payments
+		m.ClosingBalance = closing
+
+		months = append(months, m)
+	}
+
+	return months, closing
+}
+
+// RoomBalance godoc
+// @Summary Get room balance
+// @Schemes http
+// @Description Get monthly opening balance, charges, shared expenses, payments and closing balance of a room. A positive balance is a debt.
+// @Tags room
+// @Param id path int true "Room ID"
+// @Produce json
+// @Success 200 {object} Balance "ok"
+// @Failure 400 {object} types.APIResponse "Incorrect parameter"
+// @Failure 404 {object} types.APIResponse "No rows"
+// @Failure 500 {object} types.APIResponse "Internal server error"
+// @Security BearerAuth
+// @Router /room/id/{id}/balance [get]
+func (s *Server) RouteRoomGetBalance(g *gin.Context) {
+	id, apierr := validators.Int64("id", g.Param("id"), false)
+	if apierr != nil {
+		g.JSON(http.StatusBadRequest, types.APIResponse{Error: apierr})
+		return
+	}
+
+	r, err := s.Rooms.ByID(g, id, false)
+	if err != nil {
+		repoError(g, err)
+		return
+	}
+
+	building, err := s.Buildings.ByID(g, r.BuildingID)
+	if err != nil {
+		repoError(g, err)
+		return
+	}
+
+	bs, err := s.Balances.RoomMonths(g, id)
+	if err != nil {
+		repoError(g, err)
+		return
+	}
+
+	b := Balance{RoomID: id, BuildingID: building.ID, Currency: building.Currency}
+	b.Months, b.ClosingBalance = balanceLedger(bs)
+
+	g.JSON(http.StatusOK, b)
+}
+
+// sumBalanceRows sums the monthly totals of several ledgers into one, ordered
+// by period.
+func sumBalanceRows(bs []balanceRow) []balanceRow {
+	slices.SortStableFunc(bs, func(a, b balanceRow) int { return a.Period.Compare(b.Period) })
+
+	var sums []balanceRow
+	for _, b := range bs {
+		n := len(sums)
+		if n == 0 || !sums[n-1].Period.Equal(b.Period) {
+			sums = append(sums, b)
+			continue
+		}
+
+		sums[n-1].Charges += b.Charges
+		sums[n-1].Expenses += b.Expenses
+		sums[n-1].Payments += b.Payments
+	}
+
+	return sums
+}
+
+// ClientBalance godoc
+// @Summary Get client balance
+// @Schemes http
+// @Description Get monthly opening balance, charges, shared expenses, payments and closing balance over the rooms a client is responsible for paying for today, or over those in one building. A positive balance is a debt.
+// @Description The payers of a room are responsible for paying for it, or, if it has none, its owners and co-owners. Each of them owes the whole balance of the room.
+// @Description Rooms in buildings with different currencies can't be summed: such a client needs building_id.
+// @Tags client
+// @Param id path int true "Client telegram ID"
+// @Param building_id query int false "Building ID"
+// @Produce json
+// @Success 200 {object} Balance "ok"
+// @Failure 400 {object} types.APIResponse "Incorrect parameter"
+// @Failure 404 {object} types.APIResponse "No rows"
+// @Failure 409 {object} types.APIResponse "Rooms in buildings with different currencies"
+// @Failure 500 {object} types.APIResponse "Internal server error"
+// @Security BearerAuth
+// @Router /client/id/{id}/balance [get]
+func (s *Server) RouteClientGetBalance(g *gin.Context) {
+	var errs paramErrors
+
+	id, apierr := validators.Int64("id", g.Param("id"), false)
+	errs.add(apierr)
+	building_id, apierr := optionalInt64(g, "building_id")
+	errs.add(apierr)
+
+	if errs.respond(g) {
+		return
+	}
+
+	if _, err := s.Clients.ByID(g, id, false); err != nil {
+		repoError(g, err)
+		return
+	}
+
+	rs, err := s.payingRooms(g, id, building_id)
+	if err != nil {
+		repoError(g, err)
+		return
+	}
+
+	currencies, err := s.roomCurrencies(g, rs)
+	if err != nil {
+		repoError(g, err)
+		return
+	}
+
+	if len(currencies) > 1 {
+		g.JSON(http.StatusConflict, types.APIResponse{
+			Error: NewErrConflict("building_id: the rooms of the client are in buildings with different currencies"),
+		})
+		return
+	}
+
+	// A client without rooms owes nothing, in the base currency.
+	currency := baseCurrency()
+	if len(currencies) == 1 {
+		currency = currencies[0]
+	}
+
+	var bs []balanceRow
+	for _, r := range rs {
+		months, err := s.Balances.RoomMonths(g, r.ID)
+		if err != nil {
+			repoError(g, err)
+			return
+		}
+		bs = append(bs, months...)
+	}
+
+	b := Balance{ClientID: id, BuildingID: building_id, Currency: currency}
+	b.Months, b.ClosingBalance = balanceLedger(sumBalanceRows(bs))
+
+	g.JSON(http.StatusOK, b)
+}
+
+// payingRooms returns the active rooms the client clientID is responsible for
+// paying for today, of the building buildingID only unless it is zero.
+func (s *Server) payingRooms(ctx context.Context, clientID, buildingID int64) ([]Room, error) {
+	crs, err := s.clientRooms(ctx, clientID, today(), false)
+	if err != nil {
+		return nil, err
+	}
+
+	var rs []Room
+	for _, cr := range crs {
+		if cr.Pays && (buildingID == 0 || cr.BuildingID == buildingID) {
+			rs = append(rs, cr.Room)
+		}
+	}
+
+	return rs, nil
+}
+
+// roomCurrencies returns the currencies of the buildings of the rooms rs.
+func (s *Server) roomCurrencies(ctx context.Context, rs []Room) ([]string, error) {
+	var currencies []string
+	for _, r := range rs {
+		b, err := s.Buildings.ByID(ctx, r.BuildingID)
+		if err != nil {
+			return nil, err
+		}
+
+		if !slices.Contains(currencies, b.Currency) {
+			currencies = append(currencies, b.Currency)
+		}
+	}
+
+	return currencies, nil
+}
@@ -0,0 +1,112 @@
+package main
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	types "github.com/snakehunterr/hacs_db_types"
+	api_errors "github.com/snakehunterr/hacs_db_types/errors"
+)
+
+// BotState is a value the telegram bot keeps between updates and restarts.
+// Expires is empty for values that never expire.
+type BotState struct {
+	Key        string     `json:"state_key"`
+	Value      string     `json:"state_value"`
+	Expires    *time.Time `json:"state_expires,omitempty"`
+	LastEdited time.Time  `json:"last_edited"`
+}
+
+// BotStateByKey godoc
+// @Summary Get bot state
+// @Schemes http
+// @Description Get bot state value by key. Expired values are not returned.
+// @Tags bot
+// @Param key path string true "State key"
+// @Produce json
+// @Success 200 {object} BotState "ok"
+// @Failure 404 {object} types.APIResponse "No rows"
+// @Failure 500 {object} types.APIResponse "Internal server error"
+// @Security BearerAuth
+// @Router /bot/state/{key} [get]
+func (s *Server) RouteBotStateGetByKey(g *gin.Context) {
+	state, err := s.BotStates.Get(g, g.Param("key"))
+	if err != nil {
+		repoError(g, err)
+		return
+	}
+
+	g.JSON(http.StatusOK, state)
+}
+
+// BotStateRequest is the body of RouteBotStatePostSet.
+type BotStateRequest struct {
+	Value string `json:"state_value" bind:"required,keepempty"`
+	TTL   int64  `json:"state_ttl"`
+}
+
+// BotStateSet godoc
+// @Summary Set bot state
+// @Schemes http
+// @Description Create or replace bot state value. Expired values are cleaned up on every call.
+// @Tags bot
+// @Param key path string true "State key"
+// @Param state_value formData string true "Value"
+// @Param state_ttl formData int false "Time to live in seconds, 0 or empty for no expiry"
+// @Accept x-www-form-urlencoded,json
+// @Produce json
+// @Success 200 {object} types.APIResponse "Updated"
+// @Failure 400 {object} ValidationResponse "Incorrect parameter"
+// @Failure 500 {object} types.APIResponse "Internal server error"
+// @Security BearerAuth
+// @Router /bot/state/{key} [post]
+func (s *Server) RouteBotStatePostSet(g *gin.Context) {
+	var (
+		errs paramErrors
+		req  BotStateRequest
+		key  = g.Param("key")
+	)
+
+	errs.add(bindRequest(g, &req)...)
+	if req.TTL < 0 {
+		errs.add(api_errors.NewErrIncorrectParam("state_ttl"))
+	}
+
+	if errs.respond(g) {
+		return
+	}
+
+	if err := s.BotStates.Set(g, key, req.Value, req.TTL); err != nil {
+		repoError(g, err)
+		return
+	}
+
+	g.JSON(http.StatusOK, types.APIResponse{Message: "ok"})
+}
+
+// BotStateDelete godoc
+// @Summary Delete bot state
+// @Schemes http
+// @Description Delete bot state value by key
+// @Tags bot
+// @Param key path string true "State key"
+// @Produce json
+// @Success 200 {object} types.APIResponse "Deleted"
+// @Failure 500 {object} types.APIResponse "Internal server error"
+// @Security BearerAuth
+// @Router /bot/state/{key} [delete]
+func (s *Server) RouteBotStateDelete(g *gin.Context) {
+	if err := s.BotStates.Delete(g, g.Param("key")); err != nil {
+		repoError(g, err)
+		return
+	}
+
+	g.JSON(http.StatusOK, types.APIResponse{Message: "ok"})
+}
+
+func (s *Server) botRoutes(r *gin.RouterGroup) {
+	r.GET("/state/:key", s.RouteBotStateGetByKey)
+	r.POST("/state/:key", s.RouteBotStatePostSet)
+	r.DELETE("/state/:key", s.RouteBotStateDelete)
+}
@@ -0,0 +1,285 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	types "github.com/snakehunterr/hacs_db_types"
+	api_errors "github.com/snakehunterr/hacs_db_types/errors"
+	validators "github.com/snakehunterr/hacs_db_types/validators"
+)
+
+// Building is a house managed by the deployment, e.g. one housing
+// cooperative. Rooms and expenses belong to a building, and its ledger is
+// kept in Currency.
+type Building struct {
+	ID         int64     `json:"building_id"`
+	Name       string    `json:"building_name"`
+	Address    string    `json:"building_address"`
+	Contact    string    `json:"building_contact"`
+	Currency   string    `json:"building_currency"`
+	LastEdited time.Time `json:"last_edited"`
+}
+
+// BuildingAll godoc
+// @Summary Get all buildings
+// @Schemes http
+// @Description Get all buildings
+// @Tags building
+// @Produce json
+// @Success 200 {array} Building "ok"
+// @Failure 404 {object} types.APIResponse "No rows"
+// @Failure 500 {object} types.APIResponse "Internal server error"
+// @Security BearerAuth
+// @Router /building/all [get]
+func (s *Server) RouteBuildingGetAll(g *gin.Context) {
+	bs, err := s.Buildings.All(g)
+	if err != nil {
+		repoError(g, err)
+		return
+	}
+
+	if len(bs) == 0 {
+		g.JSON(http.StatusNotFound, types.APIResponse{
+			Error: api_errors.NewErrSQLNoRows("No rows"),
+		})
+		return
+	}
+
+	g.JSON(http.StatusOK, bs)
+}
+
+// BuildingByID godoc
+// @Summary Get building by building_id
+// @Schemes http
+// @Description Get building by building_id
+// @Tags building
+// @Param id path int true "Building ID"
+// @Produce json
+// @Success 200 {object} Building "ok"
+// @Header 200 {string} ETag "Version of the row, for If-Match"
+// @Failure 400 {object} types.APIResponse "Incorrect parameter"
+// @Failure 404 {object} types.APIResponse "No rows"
+// @Failure 500 {object} types.APIResponse "Internal server error"
+// @Security BearerAuth
+// @Router /building/id/{id} [get]
+func (s *Server) RouteBuildingGetByID(g *gin.Context) {
+	id, apierr := validators.Int64("id", g.Param("id"), false)
+	if apierr != nil {
+		g.JSON(http.StatusBadRequest, types.APIResponse{Error: apierr})
+		return
+	}
+
+	b, err := s.Buildings.ByID(g, id)
+	if err != nil {
+		repoError(g, err)
+		return
+	}
+
+	g.Header("ETag", etag(b))
+	g.JSON(http.StatusOK, b)
+}
+
+// BuildingCreateRequest is the body of RouteBuildingPostCreate.
+type BuildingCreateRequest struct {
+	Name     string `json:"building_name" bind:"required"`
+	Address  string `json:"building_address"`
+	Contact  string `json:"building_contact"`
+	Currency string `json:"building_currency" bind:"currency"`
+}
+
+// BuildingCreate godoc
+// @Summary Create new building
+// @Schemes http
+// @Description Create new building
+// @Tags building
+// @Param building_name formData string true "Building name"
+// @Param building_address formData string false "Postal address"
+// @Param building_contact formData string false "Management contact, e.g. phone or e-mail"
+// @Param building_currency formData string false "ISO 4217 code of the currency of the ledger, the base currency by default"
+// @Accept x-www-form-urlencoded,json
+// @Produce json
+// @Success 201 {object} Building "New building"
+// @Header 201 {string} Location "URL of the new building"
+// @Header 201 {string} ETag "ETag of the new building"
+// @Failure 400 {object} ValidationResponse "Incorrect parameter"
+// @Failure 500 {object} types.APIResponse "Internal server error"
+// @Security BearerAuth
+// @Router /building/new [post]
+func (s *Server) RouteBuildingPostCreate(g *gin.Context) {
+	var (
+		errs paramErrors
+		req  BuildingCreateRequest
+	)
+
+	errs.add(bindRequest(g, &req)...)
+
+	if errs.respond(g) {
+		return
+	}
+
+	b := Building{
+		Name:     req.Name,
+		Address:  req.Address,
+		Contact:  req.Contact,
+		Currency: req.Currency,
+	}
+	if b.Currency == "" {
+		b.Currency = baseCurrency()
+	}
+
+	if err := s.Buildings.Create(g, &b); err != nil {
+		repoError(g, err)
+		return
+	}
+
+	logInfo(fmt.Sprintf("Created new building: %#v", b))
+	g.Header("ETag", etag(b))
+	created(g, fmt.Sprintf("id/%d", b.ID), b)
+}
+
+// BuildingPatchRequest is the body of RouteBuildingPatch.
+type BuildingPatchRequest struct {
+	Name    *string `json:"building_name"`
+	Address *string `json:"building_address" bind:"keepempty,nullable"`
+	Contact *string `json:"building_contact" bind:"keepempty,nullable"`
+
+	// Currency is only accepted unchanged, e.g. in a patch that repeats the
+	// whole building.
+	Currency *string `json:"building_currency" bind:"currency"`
+}
+
+// BuildingPatch godoc
+// @Summary Patch building
+// @Schemes http
+// @Description Patch building by building_id. The currency of a building can't be changed, as its amounts are kept in it.
+// @Tags building
+// @Param id path int true "Building ID"
+// @Param If-Match header string false "ETag of the row; the patch fails if the row has changed"
+// @Param building_name formData string false "Building name"
+// @Param building_address formData string false "Postal address"
+// @Param building_contact formData string false "Management contact, e.g. phone or e-mail"
+// @Param building_currency formData string false "ISO 4217 code of the currency of the building; only the current one is accepted"
+// @Accept x-www-form-urlencoded,json,application/merge-patch+json
+// @Produce json
+// @Success 200 {object} Building "Updated"
+// @Header 200 {string} ETag "Version of the row, for If-Match"
+// @Failure 400 {object} ValidationResponse "Incorrect parameter"
+// @Failure 404 {object} types.APIResponse "No rows"
+// @Failure 409 {object} types.APIResponse "Currency differs from the currency of the building"
+// @Failure 412 {object} types.APIResponse "Row has changed"
+// @Failure 500 {object} types.APIResponse "Internal server error"
+// @Security BearerAuth
+// @Router /building/id/{id} [patch]
+func (s *Server) RouteBuildingPatch(g *gin.Context) {
+	var (
+		errs paramErrors
+		req  BuildingPatchRequest
+	)
+
+	building_id, apierr := validators.Int64("id", g.Param("id"), false)
+	errs.add(apierr)
+	errs.add(bindRequest(g, &req)...)
+
+	if errs.respond(g) {
+		return
+	}
+
+	// The amounts of a building are kept in its currency, which never
+	// changes, so it is checked outside of the update.
+	if req.Currency != nil {
+		b, err := s.Buildings.ByID(g, building_id)
+		if err != nil {
+			repoError(g, err)
+			return
+		}
+
+		if b.Currency != *req.Currency {
+			g.JSON(http.StatusConflict, types.APIResponse{
+				Error: NewErrConflict("building_currency: the building keeps its ledger in " + b.Currency),
+			})
+			return
+		}
+	}
+
+	b, err := s.Buildings.Update(g, building_id, g.GetHeader("If-Match"), func(b *Building) {
+		if req.Name != nil {
+			b.Name = *req.Name
+		}
+		if req.Address != nil {
+			b.Address = *req.Address
+		}
+		if req.Contact != nil {
+			b.Contact = *req.Contact
+		}
+	})
+	if err != nil {
+		repoError(g, err)
+		return
+	}
+
+	g.Header("ETag", etag(b))
+	g.JSON(http.StatusOK, b)
+}
+
+// BuildingDelete godoc
+// @Summary Delete building
+// @Schemes http
+// @Description Delete building by building_id. A building with rooms, expenses or tariffs, deleted ones included, can't be deleted.
+// @Tags building
+// @Param id path int true "Building ID"
+// @Param If-Match header string false "ETag of the row; the delete fails if the row has changed"
+// @Produce json
+// @Success 200 {object} types.APIResponse "Deleted"
+// @Failure 400 {object} types.APIResponse "Incorrect parameter"
+// @Failure 404 {object} types.APIResponse "No rows"
+// @Failure 409 {object} types.APIResponse "Building has rooms, expenses or tariffs"
+// @Failure 412 {object} types.APIResponse "Row has changed"
+// @Failure 500 {object} types.APIResponse "Internal server error"
+// @Security BearerAuth
+// @Router /building/id/{id} [delete]
+func (s *Server) RouteBuildingDelete(g *gin.Context) {
+	id, apierr := validators.Int64("id", g.Param("id"), false)
+	if apierr != nil {
+		g.JSON(http.StatusBadRequest, types.APIResponse{Error: apierr})
+		return
+	}
+
+	if err := s.Buildings.Delete(g, id, g.GetHeader("If-Match")); err != nil {
+		repoError(g, err)
+		return
+	}
+
+	logInfo("Deleted building with building_id: ", id)
+	g.JSON(http.StatusOK, types.APIResponse{Message: "ok"})
+}
+
+// roomCurrency returns the currency of the building of the room roomID,
+// deleted or not. It fails with ErrInvalidReference for a missing room.
+func (s *Server) roomCurrency(ctx context.Context, roomID int64) (string, error) {
+	r, err := s.Rooms.ByID(ctx, roomID, true)
+	if errors.Is(err, ErrNotFound) {
+		return "", fmt.Errorf("%w: no room with room_id %d", ErrInvalidReference, roomID)
+	}
+	if err != nil {
+		return "", err
+	}
+
+	b, err := s.Buildings.ByID(ctx, r.BuildingID)
+	if err != nil {
+		return "", err
+	}
+	return b.Currency, nil
+}
+
+func (s *Server) buildingRoutes(r *gin.RouterGroup) {
+	r.GET("/all", s.RouteBuildingGetAll)
+	r.GET("/id/:id", s.RouteBuildingGetByID)
+	r.POST("/new", s.RouteBuildingPostCreate)
+	r.PATCH("/id/:id", s.RouteBuildingPatch)
+	r.DELETE("/id/:id", s.RouteBuildingDelete)
+}
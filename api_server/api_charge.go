@@ -0,0 +1,289 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	types "github.com/snakehunterr/hacs_db_types"
+	api_errors "github.com/snakehunterr/hacs_db_types/errors"
+	validators "github.com/snakehunterr/hacs_db_types/validators"
+)
+
+// Charge is the amount a room owes for one billing period, together with the
+// tariff version it was computed from.
+type Charge struct {
+	ID           int64     `json:"charge_id"`
+	RoomID       int64     `json:"room_id"`
+	TariffID     int64     `json:"tariff_id"`
+	Period       time.Time `json:"charge_period"`
+	AreaAmount   Money     `json:"charge_area_amount" swaggertype:"string"`
+	PeopleAmount Money     `json:"charge_people_amount" swaggertype:"string"`
+	FixedAmount  Money     `json:"charge_fixed_amount" swaggertype:"string"`
+	Amount       Money     `json:"charge_amount" swaggertype:"string"`
+	LastEdited   time.Time `json:"last_edited"`
+}
+
+// ChargeGenerateResult describes a charge generation run. TariffID is the
+// tariff given for every room, if any, and BuildingID the building whose rooms
+// were charged, if not all.
+type ChargeGenerateResult struct {
+	Period     string `json:"charge_period"`
+	TariffID   int64  `json:"tariff_id,omitempty"`
+	BuildingID int64  `json:"building_id,omitempty"`
+	Created    int64  `json:"created"`
+}
+
+// ChargeAll godoc
+// @Summary Get all charges
+// @Schemes http
+// @Description Get all charges, optionally only those of the rooms of a building
+// @Tags charge
+// @Param building_id query int false "Building ID"
+// @Produce json
+// @Success 200 {array} Charge "ok"
+// @Failure 400 {object} types.APIResponse "Incorrect parameter"
+// @Failure 404 {object} types.APIResponse "No rows"
+// @Failure 500 {object} types.APIResponse "Internal server error"
+// @Security BearerAuth
+// @Router /charge/all [get]
+func (s *Server) RouteChargeGetAll(g *gin.Context) {
+	building_id, apierr := optionalInt64(g, "building_id")
+	if apierr != nil {
+		g.JSON(http.StatusBadRequest, types.APIResponse{Error: apierr})
+		return
+	}
+
+	cs, err := s.Charges.All(g, building_id)
+	if err != nil {
+		repoError(g, err)
+		return
+	}
+
+	if len(cs) == 0 {
+		g.JSON(http.StatusNotFound, types.APIResponse{
+			Error: api_errors.NewErrSQLNoRows("No rows"),
+		})
+		return
+	}
+
+	g.JSON(http.StatusOK, cs)
+}
+
+// ChargeByID godoc
+// @Summary Get charge by charge_id
+// @Schemes http
+// @Description Get charge by charge_id
+// @Tags charge
+// @Param id path int true "Charge ID"
+// @Produce json
+// @Success 200 {object} Charge "ok"
+// @Failure 400 {object} types.APIResponse "Incorrect parameter"
+// @Failure 404 {object} types.APIResponse "No rows"
+// @Failure 500 {object} types.APIResponse "Internal server error"
+// @Security BearerAuth
+// @Router /charge/id/{id} [get]
+func (s *Server) RouteChargeGetByID(g *gin.Context) {
+	id, apierr := validators.Int64("id", g.Param("id"), false)
+	if apierr != nil {
+		g.JSON(http.StatusBadRequest, types.APIResponse{Error: apierr})
+		return
+	}
+
+	c, err := s.Charges.ByID(g, id)
+	if err != nil {
+		repoError(g, err)
+		return
+	}
+
+	g.JSON(http.StatusOK, c)
+}
+
+// ChargeByRoomID godoc
+// @Summary Get all charges by room_id
+// @Schemes http
+// @Description Get all charges by room_id
+// @Tags charge
+// @Param id path int true "Room ID"
+// @Produce json
+// @Success 200 {array} Charge "ok"
+// @Failure 400 {object} types.APIResponse "Incorrect parameter"
+// @Failure 404 {object} types.APIResponse "No rows"
+// @Failure 500 {object} types.APIResponse "Internal server error"
+// @Security BearerAuth
+// @Router /charge/room/id/{id} [get]
+func (s *Server) RouteChargeGetAllByRoomID(g *gin.Context) {
+	id, apierr := validators.Int64("id", g.Param("id"), false)
+	if apierr != nil {
+		g.JSON(http.StatusBadRequest, types.APIResponse{Error: apierr})
+		return
+	}
+
+	cs, err := s.Charges.ByRoomID(g, id)
+	if err != nil {
+		repoError(g, err)
+		return
+	}
+
+	if len(cs) == 0 {
+		g.JSON(http.StatusNotFound, types.APIResponse{
+			Error: api_errors.NewErrSQLNoRows("No rows"),
+		})
+		return
+	}
+
+	g.JSON(http.StatusOK, cs)
+}
+
+// ChargeByPeriod godoc
+// @Summary Get all charges by period
+// @Schemes http
+// @Description Get all charges for a billing period, optionally only those of the rooms of a building
+// @Tags charge
+// @Param period path string true "Period 'yyyy-mm'"
+// @Param building_id query int false "Building ID"
+// @Produce json
+// @Success 200 {array} Charge "ok"
+// @Failure 400 {object} ValidationResponse "Incorrect parameter"
+// @Failure 404 {object} types.APIResponse "No rows"
+// @Failure 500 {object} types.APIResponse "Internal server error"
+// @Security BearerAuth
+// @Router /charge/period/{period} [get]
+func (s *Server) RouteChargeGetAllByPeriod(g *gin.Context) {
+	var errs paramErrors
+
+	period, apierr := validatePeriod("period", g.Param("period"), false)
+	errs.add(apierr)
+	building_id, apierr := optionalInt64(g, "building_id")
+	errs.add(apierr)
+
+	if errs.respond(g) {
+		return
+	}
+
+	cs, err := s.Charges.ByPeriod(g, period, building_id)
+	if err != nil {
+		repoError(g, err)
+		return
+	}
+
+	if len(cs) == 0 {
+		g.JSON(http.StatusNotFound, types.APIResponse{
+			Error: api_errors.NewErrSQLNoRows("No rows"),
+		})
+		return
+	}
+
+	g.JSON(http.StatusOK, cs)
+}
+
+// ChargeGenerateRequest is the body of RouteChargePostGenerate.
+type ChargeGenerateRequest struct {
+	Period     time.Time `json:"charge_period" bind:"required,period"`
+	TariffID   int64     `json:"tariff_id"`
+	BuildingID int64     `json:"building_id"`
+}
+
+// ChargeGenerate godoc
+// @Summary Generate monthly charges
+// @Schemes http
+// @Description Create a charge for every room, or every room of a building, for the given period. Rooms already charged for the period are skipped, so repeating the call is safe.
+// @Description Each room is charged at the tariff in effect for its building, unless tariff_id is given. A tariff of a building only charges the rooms of that building.
+// @Description The people count and area of a room are those in effect on the first day of the period; rooms without any by then are skipped.
+// @Tags charge
+// @Param charge_period formData string true "Period 'yyyy-mm'"
+// @Param tariff_id formData int false "Tariff ID, defaults to the tariff in effect for the building of each room"
+// @Param building_id formData int false "Building ID, all buildings by default"
+// @Accept x-www-form-urlencoded,json
+// @Produce json
+// @Success 201 {object} ChargeGenerateResult "ok"
+// @Header 201 {string} Location "URL of the charges of the period"
+// @Failure 400 {object} ValidationResponse "Incorrect parameter"
+// @Failure 404 {object} types.APIResponse "No tariff"
+// @Failure 500 {object} types.APIResponse "Internal server error"
+// @Security BearerAuth
+// @Router /charge/generate [post]
+func (s *Server) RouteChargePostGenerate(g *gin.Context) {
+	var (
+		errs paramErrors
+		req  ChargeGenerateRequest
+	)
+
+	errs.add(bindRequest(g, &req)...)
+
+	if errs.respond(g) {
+		return
+	}
+
+	if req.TariffID != 0 {
+		t, err := s.Tariffs.ByID(g, req.TariffID)
+		if err != nil {
+			repoError(g, err)
+			return
+		}
+
+		if t.BuildingID != nil {
+			if req.BuildingID != 0 && req.BuildingID != *t.BuildingID {
+				g.JSON(http.StatusBadRequest, ValidationResponse{
+					Error: api_errors.NewErrIncorrectParam("tariff_id: a tariff of another building"),
+				})
+				return
+			}
+			req.BuildingID = *t.BuildingID
+		}
+	}
+
+	r := ChargeGenerateResult{
+		Period:     req.Period.Format(PERIOD_FORMAT),
+		TariffID:   req.TariffID,
+		BuildingID: req.BuildingID,
+	}
+
+	var err error
+	if r.Created, err = s.Charges.Generate(g, req.Period, req.TariffID, req.BuildingID); err != nil {
+		repoError(g, err)
+		return
+	}
+
+	logInfo(fmt.Sprintf("Generated charges: %#v", r))
+	created(g, "period/"+r.Period, r)
+}
+
+// ChargeDelete godoc
+// @Summary Delete charge
+// @Schemes http
+// @Description Delete charge by charge_id
+// @Tags charge
+// @Param id path int true "Charge ID"
+// @Produce json
+// @Success 200 {object} types.APIResponse "Deleted"
+// @Failure 400 {object} types.APIResponse "Incorrect parameter"
+// @Failure 404 {object} types.APIResponse "No rows"
+// @Failure 500 {object} types.APIResponse "Internal server error"
+// @Security BearerAuth
+// @Router /charge/id/{id} [delete]
+func (s *Server) RouteChargeDelete(g *gin.Context) {
+	id, apierr := validators.Int64("id", g.Param("id"), false)
+	if apierr != nil {
+		g.JSON(http.StatusBadRequest, types.APIResponse{Error: apierr})
+		return
+	}
+
+	if err := s.Charges.Delete(g, id); err != nil {
+		repoError(g, err)
+		return
+	}
+
+	logInfo("Deleted record charge with charge_id: ", id)
+	g.JSON(http.StatusOK, types.APIResponse{Message: "ok"})
+}
+
+func (s *Server) chargeRoutes(r *gin.RouterGroup) {
+	r.GET("/all", s.RouteChargeGetAll)
+	r.GET("/id/:id", s.RouteChargeGetByID)
+	r.GET("/room/id/:id", s.RouteChargeGetAllByRoomID)
+	r.GET("/period/:period", s.RouteChargeGetAllByPeriod)
+	r.POST("/generate", s.RouteChargePostGenerate)
+	r.DELETE("/id/:id", s.RouteChargeDelete)
+}
@@ -1,11 +1,11 @@
 package main
 
 import (
-	"database/sql"
-	_ "embed"
 	"errors"
 	"fmt"
 	"net/http"
+	"strconv"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	types "github.com/snakehunterr/hacs_db_types"
@@ -13,79 +13,111 @@ import (
 	validators "github.com/snakehunterr/hacs_db_types/validators"
 )
 
-func clientScanRow(c *types.Client, row *sql.Row) error {
-	return row.Scan(&c.ID, &c.Name, &c.IsAdmin, &c.LastEdited)
+// Client is a client that may be soft deleted. Deleting a client also
+// deletes its rooms, and restoring it restores them.
+type Client struct {
+	types.Client
+	DeletedAt *time.Time `json:"deleted_at,omitempty"`
 }
 
-func clientScanRows(cs *[]types.Client, rows *sql.Rows) error {
-	if cs == nil {
-		return errors.New("*[]types.Client is nil")
-	}
-	_cs := *cs
-	for rows.Next() {
-		var c types.Client
-
-		if err := rows.Scan(&c.ID, &c.Name, &c.IsAdmin, &c.LastEdited); err != nil {
-			return err
-		}
-
-		_cs = append(_cs, c)
-	}
-	*cs = _cs
-	return nil
+func (c Client) active() bool {
+	return c.DeletedAt == nil
 }
 
-//go:embed sql/client/client_get_all.sql
-var SQLClientGetAllQuery string
+// clientListFields are the sort fields of /client/all.
+var clientListFields = listFields[Client]{
+	id:   "client_id",
+	idOf: func(c Client) int64 { return c.ID },
+	sorts: map[string]sortField[Client]{
+		"client_id":   {"client_id", sortInt, func(c Client) any { return c.ID }},
+		"client_name": {"client_name", sortString, func(c Client) any { return c.Name }},
+		"last_edited": {"last_edited", sortTime, func(c Client) any { return c.LastEdited }},
+	},
+}
 
 // ClientAll godoc
 // @Summary Get all clients
 // @Schemes http
-// @Description Get all clients
+// @Description Get a page of clients, optionally filtered by name, admin flag and building
 // @Tags client
+// @Param name query string false "Part of the client name"
+// @Param is_admin query bool false "Only admins, or only residents"
+// @Param building_id query int false "Only clients with a room in the building"
+// @Param include_deleted query bool false "Include soft deleted rows"
+// @Param sort query string false "Sort field: client_id, client_name, last_edited; client_id by default"
+// @Param order query string false "Sort order: asc (default) or desc"
+// @Param limit query int false "Page size, 50 by default, up to 500"
+// @Param cursor query string false "X-Next-Cursor of the previous page"
 // @Produce json
-// @Success 200 {array} types.Client "ok"
-// @Failure 404 {object} types.APIResponse "No rows"
+// @Success 200 {array} Client "ok"
+// @Header 200 {integer} X-Total-Count "Number of matching rows over all pages"
+// @Header 200 {string} X-Next-Cursor "Cursor of the next page, absent on the last page"
+// @Failure 400 {object} types.APIResponse "Incorrect parameter"
 // @Failure 500 {object} types.APIResponse "Internal server error"
+// @Security BearerAuth
 // @Router /client/all [get]
-func RouteClientGetAll(g *gin.Context) {
-	var cs []types.Client
+func (s *Server) RouteClientGetAll(g *gin.Context) {
+	var (
+		f      ClientFilter
+		q      PageQuery
+		apierr *api_errors.APIError
+	)
 
-	code, err := queryRows(&cs, clientScanRows, SQLClientGetAllQuery)
-	if err != nil {
-		g.JSON(code, types.APIResponse{Error: err})
+	q, apierr = clientListFields.pageQuery(g)
+	if apierr != nil {
+		goto skip
+	}
+
+	f.Name = g.Query("name")
+	f.IsAdmin, apierr = optionalBool(g, "is_admin")
+	if apierr != nil {
+		goto skip
+	}
+
+	f.BuildingID, apierr = optionalInt64(g, "building_id")
+	if apierr != nil {
+		goto skip
+	}
+
+	f.IncludeDeleted, apierr = includeDeleted(g)
+
+skip:
+	if apierr != nil {
+		g.JSON(http.StatusBadRequest, types.APIResponse{Error: apierr})
 		return
 	}
 
-	if len(cs) == 0 {
-		g.JSON(http.StatusNotFound, types.APIResponse{
-			Error: api_errors.NewErrSQLNoRows("No rows"),
-		})
+	page, err := s.Clients.List(g, f, q)
+	if err != nil {
+		repoError(g, err)
 		return
 	}
 
-	g.JSON(code, cs)
+	listJSON(g, page)
 }
 
-//go:embed sql/client/client_get_admins.sql
-var SQLClientGetAdminsQuery string
-
 // ClientAllAdmins godoc
 // @Summary Get all admin clients
 // @Schemes http
 // @Description Get all admin clients
 // @Tags client
+// @Param include_deleted query bool false "Include soft deleted rows"
 // @Produce json
-// @Success 200 {array} types.Client "ok"
+// @Success 200 {array} Client "ok"
 // @Failure 404 {object} types.APIResponse "No rows"
 // @Failure 500 {object} types.APIResponse "Internal server error"
+// @Security BearerAuth
 // @Router /client/admins [get]
-func RouteClientGetAdmins(g *gin.Context) {
-	var cs []types.Client
+func (s *Server) RouteClientGetAdmins(g *gin.Context) {
+	include_deleted, apierr := includeDeleted(g)
+	if apierr != nil {
+		g.JSON(http.StatusBadRequest, types.APIResponse{Error: apierr})
+		return
+	}
 
-	code, err := queryRows(&cs, clientScanRows, SQLClientGetAdminsQuery)
+	cs, err := s.Clients.Admins(g, include_deleted)
 	if err != nil {
-		g.JSON(code, types.APIResponse{Error: err})
+		repoError(g, err)
 		return
 	}
 
@@ -96,62 +128,71 @@ func RouteClientGetAdmins(g *gin.Context) {
 		return
 	}
 
-	g.JSON(code, cs)
+	g.JSON(http.StatusOK, cs)
 }
 
-//go:embed sql/client/client_get_by_id.sql
-var SQLClientGetByIDQuery string
-
 // ClientByTelegramID godoc
 // @Summary Get client by telegram ID
 // @Schemes http
 // @Description Get client by telegram ID
 // @Tags client
 // @Param id path int true "Telegram ID"
+// @Param include_deleted query bool false "Include soft deleted rows"
 // @Produce json
-// @Success 200 {object} types.Client "ok"
+// @Success 200 {object} Client "ok"
+// @Header 200 {string} ETag "Version of the row, for If-Match"
 // @Failure 400 {object} types.APIResponse "Incorrect parameter"
 // @Failure 404 {object} types.APIResponse "No rows"
 // @Failure 500 {object} types.APIResponse "Internal server error"
+// @Security BearerAuth
 // @Router /client/id/{id} [get]
-func RouteClientGetByID(g *gin.Context) {
-	id := g.Param("id")
-	if _, err := validators.Int64("id", id, false); err != nil {
-		g.JSON(http.StatusBadRequest, types.APIResponse{Error: err})
+func (s *Server) RouteClientGetByID(g *gin.Context) {
+	include_deleted, apierr := includeDeleted(g)
+	if apierr != nil {
+		g.JSON(http.StatusBadRequest, types.APIResponse{Error: apierr})
 		return
 	}
 
-	var c types.Client
-	code, err := queryRow(&c, clientScanRow, SQLClientGetByIDQuery, id)
+	id, apierr := validators.Int64("id", g.Param("id"), false)
+	if apierr != nil {
+		g.JSON(http.StatusBadRequest, types.APIResponse{Error: apierr})
+		return
+	}
+
+	c, err := s.Clients.ByID(g, id, include_deleted)
 	if err != nil {
-		g.JSON(code, types.APIResponse{Error: err})
+		repoError(g, err)
 		return
 	}
 
-	g.JSON(code, c)
+	g.Header("ETag", etag(c))
+	g.JSON(http.StatusOK, c)
 }
 
-//go:embed sql/client/client_get_by_name.sql
-var SQLClientGetByNameQuery string
-
 // ClientByName godoc
 // @Summary Get clients by client_name
 // @Schemes http
 // @Description Get clients by client_name
 // @Tags client
 // @Param name path string true "Client name"
+// @Param include_deleted query bool false "Include soft deleted rows"
 // @Produce json
-// @Success 200 {array} types.Client "ok"
+// @Success 200 {array} Client "ok"
 // @Failure 400 {object} types.APIResponse "Incorrect parameter"
 // @Failure 404 {object} types.APIResponse "No rows"
 // @Failure 500 {object} types.APIResponse "Internal server error"
+// @Security BearerAuth
 // @Router /client/name/{name} [get]
-func RouteClientGetByName(g *gin.Context) {
-	var cs []types.Client
+func (s *Server) RouteClientGetByName(g *gin.Context) {
+	include_deleted, apierr := includeDeleted(g)
+	if apierr != nil {
+		g.JSON(http.StatusBadRequest, types.APIResponse{Error: apierr})
+		return
+	}
 
-	code, err := queryRows(&cs, clientScanRows, SQLClientGetByNameQuery, g.Param("name"))
+	cs, err := s.Clients.ByName(g, g.Param("name"), include_deleted)
 	if err != nil {
-		g.JSON(code, types.APIResponse{Error: err})
+		repoError(g, err)
 		return
 	}
 
@@ -162,11 +203,14 @@ func RouteClientGetByName(g *gin.Context) {
 		return
 	}
 
-	g.JSON(code, cs)
+	g.JSON(http.StatusOK, cs)
 }
 
-//go:embed sql/client/client_insert.sql
-var SQLClientPostCreateQuery string
+// ClientCreateRequest is the body of RouteClientPostCreate.
+type ClientCreateRequest struct {
+	Name    string `json:"client_name" bind:"required"`
+	IsAdmin bool   `json:"is_admin" bind:"required"`
+}
 
 // ClientCreate godoc
 // @Summary Create new client
@@ -176,84 +220,70 @@ var SQLClientPostCreateQuery string
 // @Param id path int true "Client telegram ID"
 // @Param client_name formData string true "Client name"
 // @Param is_admin formData bool true "Is admin"
+// @Accept x-www-form-urlencoded,json
 // @Produce json
-// @Success 201 {object} types.Client "New client"
-// @Failure 400 {object} types.APIResponse "Incorrect parameter"
+// @Success 201 {object} Client "New client"
+// @Header 201 {string} Location "URL of the new client"
+// @Header 201 {string} ETag "ETag of the new client"
+// @Failure 400 {object} ValidationResponse "Incorrect parameter"
+// @Failure 409 {object} types.APIResponse "Client already exists"
 // @Failure 500 {object} types.APIResponse "Internal server error"
+// @Security BearerAuth
 // @Router /client/id/{id} [post]
-func RouteClientPostCreate(g *gin.Context) {
+func (s *Server) RouteClientPostCreate(g *gin.Context) {
 	var (
-		apierr      *api_errors.APIError
-		client_id   int64
-		client_name string
-		is_admin    bool
+		errs paramErrors
+		req  ClientCreateRequest
 	)
 
-	client_id, apierr = validators.Int64("id", g.Param("id"), false)
-	if apierr != nil {
-		goto skip
-	}
-
-	client_name = g.PostForm("client_name")
-	if len(client_name) == 0 {
-		apierr = api_errors.NewErrEmptyParam("client_name")
-		goto skip
-	}
+	client_id, apierr := validators.Int64("id", g.Param("id"), false)
+	errs.add(apierr)
+	errs.add(bindRequest(g, &req)...)
 
-	is_admin, apierr = validators.Bool("is_admin", g.PostForm("is_admin"), true)
-
-skip:
-	if apierr != nil {
-		g.JSON(http.StatusBadRequest, types.APIResponse{
-			Error: apierr,
-		})
+	if errs.respond(g) {
 		return
 	}
 
-	_, err := db.Exec(SQLClientPostCreateQuery, client_id, client_name, is_admin)
-	if err != nil {
-		logError("db.Exec():", err)
-		g.JSON(http.StatusInternalServerError, types.APIResponse{
-			Error: api_errors.NewErrSQLInternalError(err.Error()),
-		})
+	c := Client{Client: types.Client{
+		ID:      client_id,
+		Name:    req.Name,
+		IsAdmin: req.IsAdmin,
+	}}
+
+	if err := s.Clients.Create(g, &c); err != nil {
+		repoError(g, err)
 		return
 	}
 
-	logInfo(fmt.Sprintf("Created new client: %#v", types.Client{
-		ID:      client_id,
-		Name:    client_name,
-		IsAdmin: is_admin,
-	}))
-	g.JSON(http.StatusCreated, types.APIResponse{Message: "ok"})
+	logInfo(fmt.Sprintf("Created new client: %#v", c.Client))
+	g.Header("ETag", etag(c))
+	created(g, strconv.FormatInt(c.ID, 10), c)
 }
 
-//go:embed sql/client/client_delete.sql
-var SQLClientDeleteQuery string
-
 // ClientDelete godoc
 // @Summary Delete client
 // @Schemes http
-// @Description Delete client by telegram ID
+// @Description Soft delete client by telegram ID together with its rooms. Use restore to undo.
 // @Tags client
 // @Param id path int true "Client telegram ID"
+// @Param If-Match header string false "ETag of the row; the delete fails if the row has changed"
 // @Produce json
 // @Success 200 {object} types.APIResponse "Deleted"
 // @Failure 400 {object} types.APIResponse "Incorrect parameter"
+// @Failure 404 {object} types.APIResponse "No client"
+// @Failure 412 {object} types.APIResponse "Row has changed"
 // @Failure 500 {object} types.APIResponse "Internal server error"
+// @Security BearerAuth
 // @Router /client/id/{id} [delete]
-func RouteClientDelete(g *gin.Context) {
+func (s *Server) RouteClientDelete(g *gin.Context) {
 	id, apierr := validators.Int64("id", g.Param("id"), false)
 	if apierr != nil {
 		g.JSON(http.StatusBadRequest, types.APIResponse{Error: apierr})
 		return
 	}
 
-	_, err := db.Exec(SQLClientDeleteQuery, id)
-	if err != nil {
-		logError("db.Exec():", err)
-		g.JSON(http.StatusInternalServerError, types.APIResponse{
-			Error: api_errors.NewErrSQLInternalError(err.Error()),
-		})
+	if err := s.Clients.Delete(g, id, g.GetHeader("If-Match")); err != nil {
+		repoError(g, err)
 		return
 	}
 
@@ -261,8 +291,47 @@ func RouteClientDelete(g *gin.Context) {
 	g.JSON(http.StatusOK, types.APIResponse{Message: "ok"})
 }
 
-//go:embed sql/client/client_patch.sql
-var SQLClientPatchQuery string
+// ClientRestore godoc
+// @Summary Restore client
+// @Schemes http
+// @Description Restore soft deleted client together with the rooms deleted with it
+// @Tags client
+// @Param id path int true "Client telegram ID"
+// @Produce json
+// @Success 200 {object} types.APIResponse "Restored"
+// @Failure 400 {object} types.APIResponse "Incorrect parameter"
+// @Failure 404 {object} types.APIResponse "No deleted client"
+// @Failure 500 {object} types.APIResponse "Internal server error"
+// @Security BearerAuth
+// @Router /client/id/{id}/restore [post]
+func (s *Server) RouteClientRestore(g *gin.Context) {
+	id, apierr := validators.Int64("id", g.Param("id"), false)
+	if apierr != nil {
+		g.JSON(http.StatusBadRequest, types.APIResponse{Error: apierr})
+		return
+	}
+
+	if err := s.Clients.Restore(g, id); err != nil {
+		if errors.Is(err, ErrNotFound) {
+			g.JSON(http.StatusNotFound, types.APIResponse{
+				Error: api_errors.NewErrSQLNoRows("No deleted client"),
+			})
+			return
+		}
+
+		repoError(g, err)
+		return
+	}
+
+	logInfo("Restored client with client_id:", id)
+	g.JSON(http.StatusOK, types.APIResponse{Message: "ok"})
+}
+
+// ClientPatchRequest is the body of RouteClientPatch.
+type ClientPatchRequest struct {
+	Name    *string `json:"client_name"`
+	IsAdmin *bool   `json:"is_admin"`
+}
 
 // ClientPatch godoc
 // @Summary Patch client
@@ -270,85 +339,58 @@ var SQLClientPatchQuery string
 // @Description Patch client by client_id
 // @Tags client
 // @Param id path int true "Client ID"
+// @Param If-Match header string false "ETag of the row; the patch fails if the row has changed"
 // @Param client_name formData string false "Client name"
 // @Param is_admin formData bool false "is admin"
+// @Accept x-www-form-urlencoded,json,application/merge-patch+json
 // @Produce json
-// @Success 200 {object} types.APIResponse "Updated"
-// @Failure 400 {object} types.APIResponse "Incorrect parameter"
+// @Success 200 {object} Client "Updated"
+// @Header 200 {string} ETag "Version of the row, for If-Match"
+// @Failure 400 {object} ValidationResponse "Incorrect parameter"
 // @Failure 404 {object} types.APIResponse "No rows"
+// @Failure 412 {object} types.APIResponse "Row has changed"
 // @Failure 500 {object} types.APIResponse "Internal server error"
+// @Security BearerAuth
 // @Router /client/id/{id} [patch]
-func RouteClientPatch(g *gin.Context) {
+func (s *Server) RouteClientPatch(g *gin.Context) {
 	var (
-		apierr    *api_errors.APIError
-		client_id int64
-		temp      string
-		cache     = map[string]any{}
+		errs paramErrors
+		req  ClientPatchRequest
 	)
 
-	client_id, apierr = validators.Int64("id", g.Param("id"), false)
-	if apierr != nil {
-		goto skip
-	}
+	client_id, apierr := validators.Int64("id", g.Param("id"), false)
+	errs.add(apierr)
+	errs.add(bindRequest(g, &req)...)
 
-	temp = g.PostForm("client_name")
-	if temp != "" {
-		cache["client_name"] = temp
-	}
-
-	temp = g.PostForm("is_admin")
-	if temp != "" {
-		var is_admin bool
-
-		is_admin, apierr = validators.Bool("is_admin", temp, false)
-		if apierr != nil {
-			goto skip
-		}
-		cache["is_admin"] = is_admin
-	}
-
-skip:
-	if apierr != nil {
-		g.JSON(http.StatusBadRequest, types.APIResponse{
-			Error: apierr,
-		})
+	if errs.respond(g) {
 		return
 	}
 
-	var c types.Client
-	code, apierr := queryRow(&c, clientScanRow, SQLClientGetByIDQuery, client_id)
-	if apierr != nil {
-		g.JSON(code, types.APIResponse{Error: apierr})
-		return
-	}
-
-	if _, ok := cache["client_name"]; !ok {
-		cache["client_name"] = c.Name
-	}
-	if _, ok := cache["is_admin"]; !ok {
-		cache["is_admin"] = c.IsAdmin
-	}
-
-	_, err := db.Exec(SQLClientPatchQuery, cache["client_name"], cache["is_admin"], client_id)
+	c, err := s.Clients.Update(g, client_id, g.GetHeader("If-Match"), func(c *Client) {
+		if req.Name != nil {
+			c.Name = *req.Name
+		}
+		if req.IsAdmin != nil {
+			c.IsAdmin = *req.IsAdmin
+		}
+	})
 	if err != nil {
-		logError("db.Exec():", err)
-		g.JSON(http.StatusInternalServerError, types.APIResponse{
-			Error: api_errors.NewErrSQLInternalError(err.Error()),
-		})
+		repoError(g, err)
 		return
 	}
 
-	g.JSON(http.StatusOK, types.APIResponse{Message: "ok"})
+	g.Header("ETag", etag(c))
+	g.JSON(http.StatusOK, c)
 }
 
-func init() {
-	r := api.Group("/client")
-
-	r.GET("/all", RouteClientGetAll)
-	r.GET("/admins", RouteClientGetAdmins)
-	r.GET("/name/:name", RouteClientGetByName)
-	r.GET("/id/:id", RouteClientGetByID)
-	r.POST("/id/:id", RouteClientPostCreate)
-	r.DELETE("/id/:id", RouteClientDelete)
-	r.PATCH("/id/:id", RouteClientPatch)
+func (s *Server) clientRoutes(r *gin.RouterGroup) {
+	r.GET("/all", s.RouteClientGetAll)
+	r.GET("/admins", s.RouteClientGetAdmins)
+	r.GET("/name/:name", s.RouteClientGetByName)
+	r.GET("/id/:id", s.RouteClientGetByID)
+	r.POST("/id/:id", s.RouteClientPostCreate)
+	r.DELETE("/id/:id", s.RouteClientDelete)
+	r.POST("/id/:id/restore", s.RouteClientRestore)
+	r.PATCH("/id/:id", s.RouteClientPatch)
+	r.GET("/id/:id/balance", s.RouteClientGetBalance)
 }
@@ -1,11 +1,11 @@
 package main
 
 import (
-	"database/sql"
-	_ "embed"
 	"errors"
 	"fmt"
 	"net/http"
+	"slices"
+	"strings"
 	"time"
 
 	"github.com/gin-gonic/gin"
@@ -14,124 +14,275 @@ import (
 	validators "github.com/snakehunterr/hacs_db_types/validators"
 )
 
-func expenseScanRow(e *types.Expense, row *sql.Row) error {
-	return row.Scan(&e.ID, &e.Date, &e.Amount, &e.LastEdited)
+// Expense categories. Expenses that fit no other category are
+// ExpenseCategoryOther.
+const (
+	ExpenseCategoryCleaning          = "cleaning"
+	ExpenseCategoryRepairs           = "repairs"
+	ExpenseCategoryCommonElectricity = "common_electricity"
+	ExpenseCategoryCommonWater       = "common_water"
+	ExpenseCategoryHeating           = "heating"
+	ExpenseCategoryElevator          = "elevator"
+	ExpenseCategoryWaste             = "waste"
+	ExpenseCategorySecurity          = "security"
+	ExpenseCategoryInsurance         = "insurance"
+	ExpenseCategoryManagement        = "management"
+	ExpenseCategoryOther             = "other"
+)
+
+var expenseCategories = []string{
+	ExpenseCategoryCleaning,
+	ExpenseCategoryRepairs,
+	ExpenseCategoryCommonElectricity,
+	ExpenseCategoryCommonWater,
+	ExpenseCategoryHeating,
+	ExpenseCategoryElevator,
+	ExpenseCategoryWaste,
+	ExpenseCategorySecurity,
+	ExpenseCategoryInsurance,
+	ExpenseCategoryManagement,
+	ExpenseCategoryOther,
 }
 
-func expenseScanRows(es *[]types.Expense, rows *sql.Rows) error {
-	if es == nil {
-		return errors.New("*[]types.Expense is nil")
+// validateExpenseCategory is the validator of expense category parameters.
+func validateExpenseCategory(name, value string, emptyCheck bool) (string, *api_errors.APIError) {
+	if emptyCheck && len(value) == 0 {
+		return "", api_errors.NewErrEmptyParam(name)
 	}
 
-	var _es []types.Expense
-	for rows.Next() {
-		var e types.Expense
+	if !slices.Contains(expenseCategories, value) {
+		return "", api_errors.NewErrIncorrectParam(name + ": one of " + strings.Join(expenseCategories, ", "))
+	}
+	return value, nil
+}
 
-		if err := rows.Scan(&e.ID, &e.Date, &e.Amount, &e.LastEdited); err != nil {
-			return err
-		}
+// Expense has the fields of types.Expense, with the amount as Money, and the
+// details needed for audits. DocumentRef is an optional reference to an
+// invoice or receipt. The amount is in the currency of the building.
+type Expense struct {
+	ID          int64      `json:"expense_id"`
+	BuildingID  int64      `json:"building_id"`
+	Date        time.Time  `json:"expense_date"`
+	Amount      Money      `json:"expense_amount" swaggertype:"string"`
+	LastEdited  time.Time  `json:"last_edited"`
+	Category    string     `json:"expense_category"`
+	Vendor      string     `json:"expense_vendor"`
+	Description string     `json:"expense_description"`
+	DocumentRef string     `json:"expense_document_ref"`
+	DeletedAt   *time.Time `json:"deleted_at,omitempty"`
+}
 
-		_es = append(_es, e)
-	}
+func (e Expense) active() bool {
+	return e.DeletedAt == nil
+}
 
-	*es = _es
-	return nil
+// ExpenseCategoryTotal is the number and sum of the expenses of a building in
+// one category, in the currency of the building.
+type ExpenseCategoryTotal struct {
+	BuildingID int64  `json:"building_id"`
+	Category   string `json:"expense_category"`
+	Count      int64  `json:"count"`
+	Amount     Money  `json:"expense_amount" swaggertype:"string"`
+	Currency   string `json:"currency"`
 }
 
-//go:embed sql/expense/expense_get_all.sql
-var SQLExpenseGetAllQuery string
+// expenseListFields are the sort fields of /expense/all.
+var expenseListFields = listFields[Expense]{
+	id:   "expense_id",
+	idOf: func(e Expense) int64 { return e.ID },
+	sorts: map[string]sortField[Expense]{
+		"expense_id":       {"expense_id", sortInt, func(e Expense) any { return e.ID }},
+		"building_id":      {"building_id", sortInt, func(e Expense) any { return e.BuildingID }},
+		"expense_date":     {"expense_date", sortTime, func(e Expense) any { return e.Date }},
+		"expense_amount":   {"expense_amount", sortMoney, func(e Expense) any { return e.Amount }},
+		"expense_category": {"expense_category", sortString, func(e Expense) any { return e.Category }},
+		"expense_vendor":   {"expense_vendor", sortString, func(e Expense) any { return e.Vendor }},
+		"last_edited":      {"last_edited", sortTime, func(e Expense) any { return e.LastEdited }},
+	},
+}
 
 // ExpenseAll godoc
 // @Summary Get all expenses
 // @Schemes http
-// @Description Get all expenses
+// @Description Get a page of expenses, optionally filtered by building, category, vendor and amount
 // @Tags expense
+// @Param building_id query int false "Building ID"
+// @Param category query string false "Expense category" Enums(cleaning, repairs, common_electricity, common_water, heating, elevator, waste, security, insurance, management, other)
+// @Param vendor query string false "Expense vendor"
+// @Param min_amount query string false "Minimum expense amount, e.g. '120.50'"
+// @Param max_amount query string false "Maximum expense amount, e.g. '120.50'"
+// @Param include_deleted query bool false "Include soft deleted rows"
+// @Param sort query string false "Sort field: expense_id, building_id, expense_date, expense_amount, expense_category, expense_vendor, last_edited; expense_id by default"
+// @Param order query string false "Sort order: asc (default) or desc"
+// @Param limit query int false "Page size, 50 by default, up to 500"
+// @Param cursor query string false "X-Next-Cursor of the previous page"
 // @Produce json
-// @Success 200 {array} types.Expense "ok"
-// @Failure 404 {object} types.APIResponse "No rows"
+// @Success 200 {array} Expense "ok"
+// @Header 200 {integer} X-Total-Count "Number of matching rows over all pages"
+// @Header 200 {string} X-Next-Cursor "Cursor of the next page, absent on the last page"
+// @Failure 400 {object} types.APIResponse "Incorrect parameter"
 // @Failure 500 {object} types.APIResponse "Internal server error"
+// @Security BearerAuth
 // @Router /expense/all [get]
-func RouteExpenseGetAll(g *gin.Context) {
-	var es []types.Expense
+func (s *Server) RouteExpenseGetAll(g *gin.Context) {
+	var (
+		f      ExpenseFilter
+		q      PageQuery
+		apierr *api_errors.APIError
+	)
+
+	q, apierr = expenseListFields.pageQuery(g)
+	if apierr != nil {
+		goto skip
+	}
+
+	f.BuildingID, apierr = optionalInt64(g, "building_id")
+	if apierr != nil {
+		goto skip
+	}
 
-	code, err := queryRows(&es, expenseScanRows, SQLExpenseGetAllQuery)
+	if category := g.Query("category"); category != "" {
+		f.Category, apierr = validateExpenseCategory("category", category, false)
+		if apierr != nil {
+			goto skip
+		}
+	}
+	f.Vendor = g.Query("vendor")
+
+	f.MinAmount, apierr = optionalMoney(g, "min_amount")
+	if apierr != nil {
+		goto skip
+	}
+
+	f.MaxAmount, apierr = optionalMoney(g, "max_amount")
+	if apierr != nil {
+		goto skip
+	}
+
+	f.IncludeDeleted, apierr = includeDeleted(g)
+
+skip:
+	if apierr != nil {
+		g.JSON(http.StatusBadRequest, types.APIResponse{Error: apierr})
+		return
+	}
+
+	page, err := s.Expenses.List(g, f, q)
 	if err != nil {
-		g.JSON(code, types.APIResponse{Error: err})
+		repoError(g, err)
 		return
 	}
 
-	if len(es) == 0 {
+	listJSON(g, page)
+}
+
+// ExpenseCategoryTotals godoc
+// @Summary Get expense totals per category
+// @Schemes http
+// @Description Get number and sum of expenses per building and category, optionally for one building and one vendor
+// @Tags expense
+// @Param building_id query int false "Building ID"
+// @Param vendor query string false "Expense vendor"
+// @Produce json
+// @Success 200 {array} ExpenseCategoryTotal "ok"
+// @Failure 400 {object} types.APIResponse "Incorrect parameter"
+// @Failure 404 {object} types.APIResponse "No rows"
+// @Failure 500 {object} types.APIResponse "Internal server error"
+// @Security BearerAuth
+// @Router /expense/category/totals [get]
+func (s *Server) RouteExpenseGetCategoryTotals(g *gin.Context) {
+	building_id, apierr := optionalInt64(g, "building_id")
+	if apierr != nil {
+		g.JSON(http.StatusBadRequest, types.APIResponse{Error: apierr})
+		return
+	}
+
+	ts, err := s.Expenses.CategoryTotals(g, building_id, g.Query("vendor"))
+	if err != nil {
+		repoError(g, err)
+		return
+	}
+
+	if len(ts) == 0 {
 		g.JSON(http.StatusNotFound, types.APIResponse{
 			Error: api_errors.NewErrSQLNoRows("No rows"),
 		})
 		return
 	}
 
-	g.JSON(http.StatusOK, es)
+	g.JSON(http.StatusOK, ts)
 }
 
-//go:embed sql/expense/expense_get_by_expense_id.sql
-var SQLExpenseGetByIDQuery string
-
 // ExpenseByID godoc
 // @Summary Get expense by expense_id
 // @Schemes http
 // @Description Get expense by expense_id
 // @Param id path int true "Expense ID"
 // @Tags expense
+// @Param include_deleted query bool false "Include soft deleted rows"
 // @Produce json
-// @Success 200 {object} types.Expense "ok"
+// @Success 200 {object} Expense "ok"
+// @Header 200 {string} ETag "Version of the row, for If-Match"
 // @Failure 400 {object} types.APIResponse "Incorrect parameter"
 // @Failure 404 {object} types.APIResponse "No rows"
 // @Failure 500 {object} types.APIResponse "Internal server error"
+// @Security BearerAuth
 // @Router /expense/id/{id} [get]
-func RouteExpenseGetByExpenseID(g *gin.Context) {
-	id := g.Param("id")
-	if _, err := validators.Int64("id", id, false); err != nil {
-		g.JSON(http.StatusBadRequest, types.APIResponse{Error: err})
+func (s *Server) RouteExpenseGetByExpenseID(g *gin.Context) {
+	include_deleted, apierr := includeDeleted(g)
+	if apierr != nil {
+		g.JSON(http.StatusBadRequest, types.APIResponse{Error: apierr})
 		return
 	}
 
-	var e types.Expense
-	code, err := queryRow(&e, expenseScanRow, SQLExpenseGetByIDQuery, id)
+	id, apierr := validators.Int64("id", g.Param("id"), false)
+	if apierr != nil {
+		g.JSON(http.StatusBadRequest, types.APIResponse{Error: apierr})
+		return
+	}
+
+	e, err := s.Expenses.ByID(g, id, include_deleted)
 	if err != nil {
-		g.JSON(code, types.APIResponse{Error: err})
+		repoError(g, err)
 		return
 	}
 
+	g.Header("ETag", etag(e))
 	g.JSON(http.StatusOK, e)
 }
 
-//go:embed sql/expense/expense_get_by_date.sql
-var SQLExpenseGetByDateQuery string
-
 // ExpenseByDate godoc
 // @Summary Get expenses by expense_date
 // @Schemes http
 // @Description Get expenses by expense_date
 // @Param date path string true "Expense date"
 // @Tags expense
+// @Param include_deleted query bool false "Include soft deleted rows"
 // @Produce json
-// @Success 200 {array} types.Expense "ok"
+// @Success 200 {array} Expense "ok"
 // @Failure 400 {object} types.APIResponse "Incorrect parameter"
 // @Failure 404 {object} types.APIResponse "No rows"
 // @Failure 500 {object} types.APIResponse "Internal server error"
+// @Security BearerAuth
 // @Router /expense/date/{date} [get]
-func RouteExpenseGetByDate(g *gin.Context) {
-	date, err := validators.Date("date", g.Param("date"), false)
-	if err != nil {
-		g.JSON(http.StatusBadRequest, types.APIResponse{Error: err})
+func (s *Server) RouteExpenseGetByDate(g *gin.Context) {
+	include_deleted, apierr := includeDeleted(g)
+	if apierr != nil {
+		g.JSON(http.StatusBadRequest, types.APIResponse{Error: apierr})
 		return
 	}
 
-	var es []types.Expense
-	code, err := queryRows(
-		&es, expenseScanRows,
-		SQLExpenseGetByDateQuery,
-		date.Year(), date.Month(), date.Day(),
-	)
+	date, apierr := validators.Date("date", g.Param("date"), false)
+	if apierr != nil {
+		g.JSON(http.StatusBadRequest, types.APIResponse{Error: apierr})
+		return
+	}
+
+	start, end := dayRange(date)
+
+	es, err := s.Expenses.ByDateRange(g, start, end, include_deleted)
 	if err != nil {
-		g.JSON(code, types.APIResponse{Error: err})
+		repoError(g, err)
 		return
 	}
 
@@ -148,32 +299,32 @@ func RouteExpenseGetByDate(g *gin.Context) {
 // ExpenseByDateRange godoc
 // @Summary Get expenses by date range
 // @Schemes http
-// @Description Get expenses by date range
-// @Param date_start formData string true "Expense date start"
-// @Param date_end formData string true "Expense date end"
+// @Description Get expenses made from date_start up to, but not including, date_end
+// @Param date_start query string true "Expense date start"
+// @Param date_end query string true "Expense date end, excluded"
+// @Param include_deleted query bool false "Include soft deleted rows"
 // @Tags expense
 // @Produce json
-// @Success 200 {array} types.Expense "ok"
-// @Failure 400 {objcet} types.APIResponse "Incorrect parameter"
-// @Failure 404 {objcet} types.APIResponse "No rows"
-// @Failure 500 {objcet} types.APIResponse "Internal server error"
-// @Router /expense/date/range [post]
-func RouteExpenseGetByDateRange(g *gin.Context) {
+// @Success 200 {array} Expense "ok"
+// @Failure 400 {object} types.APIResponse "Incorrect parameter"
+// @Failure 404 {object} types.APIResponse "No rows"
+// @Failure 500 {object} types.APIResponse "Internal server error"
+// @Security BearerAuth
+// @Router /expense/date/range [get]
+func (s *Server) RouteExpenseGetByDateRange(g *gin.Context) {
 	var (
-		apierr     *api_errors.APIError
-		date_start time.Time
-		date_end   time.Time
+		apierr          *api_errors.APIError
+		date_start      time.Time
+		date_end        time.Time
+		include_deleted bool
 	)
 
-	date_start, apierr = validators.Date("date_start", g.PostForm("date_start"), true)
+	date_start, date_end, apierr = validateDateRange(g)
 	if apierr != nil {
 		goto skip
 	}
 
-	date_end, apierr = validators.Date("date_end", g.PostForm("date_end"), true)
-	if apierr != nil {
-		goto skip
-	}
+	include_deleted, apierr = includeDeleted(g)
 
 skip:
 	if apierr != nil {
@@ -181,123 +332,119 @@ skip:
 		return
 	}
 
-	var es []types.Expense
-	code, err := queryRows(&es, expenseScanRows, SQLExpenseGetAllQuery)
+	es, err := s.Expenses.ByDateRange(g, date_start, date_end, include_deleted)
 	if err != nil {
-		g.JSON(code, types.APIResponse{Error: err})
+		repoError(g, err)
 		return
 	}
 
-	var _es []types.Expense
-	for _, e := range es {
-		if !e.Date.After(date_start) && !e.Date.Equal(date_start) {
-			continue
-		}
-		if !date_end.After(e.Date) && !e.Date.Equal(date_end) {
-			continue
-		}
-		_es = append(_es, e)
-	}
-
-	if len(_es) == 0 {
+	if len(es) == 0 {
 		g.JSON(http.StatusNotFound, types.APIResponse{
 			Error: api_errors.NewErrSQLNoRows("No rows"),
 		})
 		return
 	}
 
-	g.JSON(http.StatusOK, _es)
+	g.JSON(http.StatusOK, es)
 }
 
-//go:embed sql/expense/expense_insert.sql
-var SQLExpensePostCreateQuery string
+// ExpenseCreateRequest is the body of RouteExpensePostCreate. A missing
+// category is ExpenseCategoryOther, an empty one is rejected.
+type ExpenseCreateRequest struct {
+	BuildingID  int64     `json:"building_id" bind:"required"`
+	Date        time.Time `json:"expense_date" bind:"required"`
+	Amount      Money     `json:"expense_amount" bind:"required"`
+	Category    *string   `json:"expense_category" bind:"keepempty"`
+	Vendor      string    `json:"expense_vendor"`
+	Description string    `json:"expense_description"`
+	DocumentRef string    `json:"expense_document_ref"`
+}
 
 // ExpenseCreate godoc
 // @Summary Create new expense
 // @Schemes http
-// @Description Create new expense
+// @Description Create new expense of a building, in the currency of the building
 // @Tags expense
+// @Param building_id formData int true "Building ID"
 // @Param expense_date formData string false "Expense date"
-// @Param expense_amount formData number true "Expense amount"
+// @Param expense_amount formData string true "Expense amount, e.g. '120.50'"
+// @Param expense_category formData string false "Expense category, 'other' by default" Enums(cleaning, repairs, common_electricity, common_water, heating, elevator, waste, security, insurance, management, other)
+// @Param expense_vendor formData string false "Vendor"
+// @Param expense_description formData string false "Description"
+// @Param expense_document_ref formData string false "Invoice or receipt reference"
+// @Accept x-www-form-urlencoded,json
 // @Produce json
-// @Success 201 {object} types.Expense "New expense"
-// @Failure 400 {object} types.APIResponse "Incorrect parameter"
+// @Success 201 {object} Expense "New expense"
+// @Header 201 {string} Location "URL of the new expense"
+// @Header 201 {string} ETag "ETag of the new expense"
+// @Failure 400 {object} ValidationResponse "Incorrect parameter"
+// @Failure 422 {object} types.APIResponse "No such building"
 // @Failure 500 {object} types.APIResponse "Internal server error"
+// @Security BearerAuth
 // @Router /expense/new [post]
-func RouteExpensePostCreate(g *gin.Context) {
+func (s *Server) RouteExpensePostCreate(g *gin.Context) {
 	var (
-		apierr         *api_errors.APIError
-		expense_date   time.Time
-		expense_amount float64
+		errs paramErrors
+		req  ExpenseCreateRequest
 	)
 
-	expense_date, apierr = validators.Date("expense_date", g.PostForm("expense_date"), true)
-	if apierr != nil {
-		goto skip
-	}
-
-	expense_amount, apierr = validators.Float64("expense_amount", g.PostForm("expense_amount"), true)
+	errs.add(bindRequest(g, &req)...)
 
-skip:
-	if apierr != nil {
-		g.JSON(http.StatusBadRequest, types.APIResponse{Error: apierr})
-		return
+	category := ExpenseCategoryOther
+	if req.Category != nil {
+		var apierr *api_errors.APIError
+		category, apierr = validateExpenseCategory("expense_category", *req.Category, true)
+		errs.add(apierr)
 	}
 
-	res, err := db.Exec(SQLExpensePostCreateQuery, expense_date, expense_amount)
-	if err != nil {
-		logError("db.Exec():", err)
-		g.JSON(http.StatusInternalServerError, types.APIResponse{
-			Error: api_errors.NewErrSQLInternalError(err.Error()),
-		})
+	if errs.respond(g) {
 		return
 	}
 
-	id, err := res.LastInsertId()
-	if err != nil {
-		logError("res.LastInsertId():", err)
-		g.JSON(http.StatusInternalServerError, types.APIResponse{
-			Error: api_errors.NewErrSQLInternalError(err.Error()),
-		})
-		return
+	e := Expense{
+		BuildingID:  req.BuildingID,
+		Date:        req.Date,
+		Amount:      req.Amount,
+		Category:    category,
+		Vendor:      req.Vendor,
+		Description: req.Description,
+		DocumentRef: req.DocumentRef,
 	}
 
-	e := types.Expense{
-		ID:     id,
-		Date:   expense_date,
-		Amount: expense_amount,
+	if err := s.Expenses.Create(g, &e); err != nil {
+		repoError(g, err)
+		return
 	}
 
 	logInfo(fmt.Sprintf("Created new expense: %#v", e))
-	g.JSON(http.StatusCreated, e)
+	g.Header("ETag", etag(e))
+	created(g, fmt.Sprintf("id/%d", e.ID), e)
 }
 
-//go:embed sql/expense/expense_delete.sql
-var SQLExpenseDeleteQuery string
-
 // ExpenseDelete godoc
 // @Summary Delete expense by expense_id
 // @Schemes http
-// @Description Delete expense by expense_id
+// @Description Soft delete expense by expense_id. Use restore to undo.
 // @Tags expense
 // @Param id path int true "Expense ID"
+// @Param If-Match header string false "ETag of the row; the delete fails if the row has changed"
 // @Produce json
 // @Success 200 {object} types.APIResponse "Deleted"
 // @Failure 400 {object} types.APIResponse "Incorrect parameter"
+// @Failure 404 {object} types.APIResponse "No expense"
+// @Failure 412 {object} types.APIResponse "Row has changed"
 // @Failure 500 {object} types.APIResponse "Internal server error"
+// @Security BearerAuth
 // @Router /expense/id/{id} [delete]
-func RouteExpenseDelete(g *gin.Context) {
-	id := g.Param("id")
-	if _, err := validators.Int64("id", id, false); err != nil {
-		g.JSON(http.StatusBadRequest, types.APIResponse{Error: err})
+func (s *Server) RouteExpenseDelete(g *gin.Context) {
+	id, apierr := validators.Int64("id", g.Param("id"), false)
+	if apierr != nil {
+		g.JSON(http.StatusBadRequest, types.APIResponse{Error: apierr})
 		return
 	}
 
-	if _, err := db.Exec(SQLExpenseDeleteQuery, id); err != nil {
-		logError("db.Exec():", err)
-		g.JSON(http.StatusInternalServerError, types.APIResponse{
-			Error: api_errors.NewErrSQLInternalError(err.Error()),
-		})
+	if err := s.Expenses.Delete(g, id, g.GetHeader("If-Match")); err != nil {
+		repoError(g, err)
 		return
 	}
 
@@ -305,103 +452,138 @@ func RouteExpenseDelete(g *gin.Context) {
 	g.JSON(http.StatusOK, types.APIResponse{Message: "ok"})
 }
 
-//go:embed sql/expense/expense_patch.sql
-var SQLExpensePatchQuery string
+// ExpenseRestore godoc
+// @Summary Restore expense
+// @Schemes http
+// @Description Restore soft deleted expense by expense_id
+// @Tags expense
+// @Param id path int true "Expense ID"
+// @Produce json
+// @Success 200 {object} types.APIResponse "Restored"
+// @Failure 400 {object} types.APIResponse "Incorrect parameter"
+// @Failure 404 {object} types.APIResponse "No deleted expense"
+// @Failure 500 {object} types.APIResponse "Internal server error"
+// @Security BearerAuth
+// @Router /expense/id/{id}/restore [post]
+func (s *Server) RouteExpenseRestore(g *gin.Context) {
+	id, apierr := validators.Int64("id", g.Param("id"), false)
+	if apierr != nil {
+		g.JSON(http.StatusBadRequest, types.APIResponse{Error: apierr})
+		return
+	}
+
+	if err := s.Expenses.Restore(g, id); err != nil {
+		if errors.Is(err, ErrNotFound) {
+			g.JSON(http.StatusNotFound, types.APIResponse{
+				Error: api_errors.NewErrSQLNoRows("No deleted expense"),
+			})
+			return
+		}
+
+		repoError(g, err)
+		return
+	}
+
+	logInfo("Restored expense with expense_id: ", id)
+	g.JSON(http.StatusOK, types.APIResponse{Message: "ok"})
+}
+
+// ExpensePatchRequest is the body of RouteExpensePatch. An empty or, in a
+// merge patch, null vendor, description or document reference clears it.
+type ExpensePatchRequest struct {
+	Date        *time.Time `json:"expense_date"`
+	Amount      *Money     `json:"expense_amount"`
+	Category    *string    `json:"expense_category"`
+	Vendor      *string    `json:"expense_vendor" bind:"keepempty,nullable"`
+	Description *string    `json:"expense_description" bind:"keepempty,nullable"`
+	DocumentRef *string    `json:"expense_document_ref" bind:"keepempty,nullable"`
+}
 
 // ExpensePatch godoc
 // @Summary Patch expense
 // @Schemes http
-// @Description Patch expense by expense_id
+// @Description Patch expense by expense_id. An expense can't be moved to another building.
+// @Description The amount and date of a shared expense can't be changed: stop sharing it first, then share it again.
 // @Tags expense
 // @Param id path int true "Expense ID"
+// @Param If-Match header string false "ETag of the row; the patch fails if the row has changed"
 // @Param expense_date formData string false "Date 'yyyy-mm-dd hh:mm:ss'"
-// @Param expense_amount formData number false "Amount"
+// @Param expense_amount formData string false "Amount, e.g. '120.50'"
+// @Param expense_category formData string false "Expense category" Enums(cleaning, repairs, common_electricity, common_water, heating, elevator, waste, security, insurance, management, other)
+// @Param expense_vendor formData string false "Vendor, empty value clears it"
+// @Param expense_description formData string false "Description, empty value clears it"
+// @Param expense_document_ref formData string false "Invoice or receipt reference, empty value clears it"
+// @Accept x-www-form-urlencoded,json,application/merge-patch+json
 // @Produce json
-// @Success 200 {object} types.APIResponse "Updated"
-// @Failure 400 {object} types.APIResponse "Incorrect parameter"
+// @Success 200 {object} Expense "Updated"
+// @Header 200 {string} ETag "Version of the row, for If-Match"
+// @Failure 400 {object} ValidationResponse "Incorrect parameter"
 // @Failure 404 {object} types.APIResponse "No rows"
+// @Failure 409 {object} types.APIResponse "Amount or date of a shared expense"
+// @Failure 412 {object} types.APIResponse "Row has changed"
 // @Failure 500 {object} types.APIResponse "Internal server error"
+// @Security BearerAuth
 // @Router /expense/id/{id} [patch]
-func RouteExpensePatch(g *gin.Context) {
+func (s *Server) RouteExpensePatch(g *gin.Context) {
 	var (
-		apierr     *api_errors.APIError
-		expense_id int64
-		temp       string
-		cache      = map[string]any{}
+		errs paramErrors
+		req  ExpensePatchRequest
 	)
 
-	expense_id, apierr = validators.Int64("id", g.Param("id"), false)
-	if apierr != nil {
-		goto skip
-	}
-
-	temp = g.PostForm("expense_date")
-	if temp != "" {
-		var date time.Time
+	expense_id, apierr := validators.Int64("id", g.Param("id"), false)
+	errs.add(apierr)
+	errs.add(bindRequest(g, &req)...)
 
-		date, apierr = validators.Date("expense_date", temp, false)
-		if apierr != nil {
-			goto skip
-		}
-		cache["expense_date"] = date.Format(validators.DATE_FORMAT)
-	}
-
-	temp = g.PostForm("expense_amount")
-	if temp != "" {
-		var amount float64
-
-		amount, apierr = validators.Float64("expense_amount", temp, false)
-		if apierr != nil {
-			goto skip
-		}
-		cache["expense_amount"] = amount
+	if req.Category != nil {
+		_, apierr = validateExpenseCategory("expense_category", *req.Category, false)
+		errs.add(apierr)
 	}
 
-skip:
-	if apierr != nil {
-		g.JSON(http.StatusBadRequest, types.APIResponse{Error: apierr})
-		return
-	}
-
-	var e types.Expense
-	code, apierr := queryRow(&e, expenseScanRow, SQLExpenseGetByIDQuery, expense_id)
-	if apierr != nil {
-		g.JSON(code, types.APIResponse{Error: apierr})
+	if errs.respond(g) {
 		return
 	}
 
-	if _, ok := cache["expense_date"]; !ok {
-		cache["expense_date"] = e.Date.Format(validators.DATE_FORMAT)
-	}
-	if _, ok := cache["expense_amount"]; !ok {
-		cache["expense_amount"] = e.Amount
-	}
-
-	_, err := db.Exec(
-		SQLExpensePatchQuery,
-		cache["expense_date"],
-		cache["expense_amount"],
-		expense_id,
-	)
+	e, err := s.Expenses.Update(g, expense_id, g.GetHeader("If-Match"), func(e *Expense) {
+		if req.Date != nil {
+			e.Date = *req.Date
+		}
+		if req.Amount != nil {
+			e.Amount = *req.Amount
+		}
+		if req.Category != nil {
+			e.Category = *req.Category
+		}
+		if req.Vendor != nil {
+			e.Vendor = *req.Vendor
+		}
+		if req.Description != nil {
+			e.Description = *req.Description
+		}
+		if req.DocumentRef != nil {
+			e.DocumentRef = *req.DocumentRef
+		}
+	})
 	if err != nil {
-		logError("db.Exec():", err)
-		g.JSON(http.StatusInternalServerError, types.APIResponse{
-			Error: api_errors.NewErrSQLInternalError(err.Error()),
-		})
+		repoError(g, err)
 		return
 	}
 
-	g.JSON(http.StatusOK, types.APIResponse{Message: "ok"})
+	g.Header("ETag", etag(e))
+	g.JSON(http.StatusOK, e)
 }
 
-func init() {
-	r := api.Group("/expense")
-
-	r.GET("/all", RouteExpenseGetAll)
-	r.GET("/date/:date", RouteExpenseGetByDate)
-	r.POST("/date/range", RouteExpenseGetByDateRange)
-	r.POST("/new", RouteExpensePostCreate)
-	r.GET("/id/:id", RouteExpenseGetByExpenseID)
-	r.DELETE("/id/:id", RouteExpenseDelete)
-	r.PATCH("/id/:id", RouteExpensePatch)
+func (s *Server) expenseRoutes(r *gin.RouterGroup) {
+	r.GET("/all", s.RouteExpenseGetAll)
+	r.GET("/category/totals", s.RouteExpenseGetCategoryTotals)
+	r.GET("/date/range", s.RouteExpenseGetByDateRange)
+	r.GET("/date/:date", s.RouteExpenseGetByDate)
+	r.POST("/new", s.RouteExpensePostCreate)
+	r.GET("/id/:id", s.RouteExpenseGetByExpenseID)
+	r.DELETE("/id/:id", s.RouteExpenseDelete)
+	r.POST("/id/:id/restore", s.RouteExpenseRestore)
+	r.PATCH("/id/:id", s.RouteExpensePatch)
+	r.GET("/id/:id/share", s.RouteExpenseShareGet)
+	r.POST("/id/:id/share", s.RouteExpenseSharePostCreate)
+	r.DELETE("/id/:id/share", s.RouteExpenseShareDelete)
+	r.GET("/allocation/room/id/:id", s.RouteAllocationGetAllByRoomID)
 }
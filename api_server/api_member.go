@@ -0,0 +1,494 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	types "github.com/snakehunterr/hacs_db_types"
+	api_errors "github.com/snakehunterr/hacs_db_types/errors"
+	validators "github.com/snakehunterr/hacs_db_types/validators"
+)
+
+// Roles of a room member. Owners and co-owners hold Share of the ownership.
+// The payers of a room pay for it; a room without payers is paid for by its
+// owners and co-owners.
+const (
+	MemberOwner   = "owner"
+	MemberCoOwner = "co-owner"
+	MemberTenant  = "tenant"
+	MemberPayer   = "payer"
+)
+
+var memberRoles = []string{MemberOwner, MemberCoOwner, MemberTenant, MemberPayer}
+
+// RoomMember links a client to a room in a role from From up to, but not
+// including, Until. A nil From or Until leaves the membership open on that
+// side.
+type RoomMember struct {
+	ID         int64        `json:"member_id"`
+	RoomID     int64        `json:"room_id"`
+	ClientID   int64        `json:"client_id"`
+	Role       string       `json:"member_role"`
+	Share      *MemberShare `json:"member_share,omitempty" swaggertype:"string"`
+	From       *time.Time   `json:"member_from,omitempty"`
+	Until      *time.Time   `json:"member_until,omitempty"`
+	LastEdited time.Time    `json:"last_edited"`
+}
+
+// activeAt reports whether the membership holds on day.
+func (m RoomMember) activeAt(day time.Time) bool {
+	return (m.From == nil || !day.Before(*m.From)) && (m.Until == nil || day.Before(*m.Until))
+}
+
+func (m RoomMember) owns() bool {
+	return m.Role == MemberOwner || m.Role == MemberCoOwner
+}
+
+// overlaps reports whether m and other hold on a common day.
+func (m RoomMember) overlaps(other RoomMember) bool {
+	return (m.Until == nil || other.From == nil || other.From.Before(*m.Until)) &&
+		(other.Until == nil || m.From == nil || m.From.Before(*other.Until))
+}
+
+// validate checks the role, share and dates of m.
+func (m RoomMember) validate() (apierrs []*api_errors.APIError) {
+	if !slices.Contains(memberRoles, m.Role) {
+		apierrs = append(apierrs, api_errors.NewErrIncorrectParam("member_role: one of owner, co-owner, tenant, payer"))
+	}
+	if m.Share != nil && !m.owns() {
+		apierrs = append(apierrs, api_errors.NewErrIncorrectParam("member_share: only owners and co-owners have a share"))
+	}
+	if m.Share != nil && (*m.Share <= 0 || *m.Share > memberShareWhole) {
+		apierrs = append(apierrs, api_errors.NewErrIncorrectParam("member_share: a fraction over 0 up to 1"))
+	}
+	if m.From != nil && m.Until != nil && !m.From.Before(*m.Until) {
+		apierrs = append(apierrs, api_errors.NewErrIncorrectParam("member_until: must be after member_from"))
+	}
+	return apierrs
+}
+
+// responsible returns the clients that pay for a room with the members ms on
+// day: its payers or, without payers, its owners and co-owners.
+func responsible(ms []RoomMember, day time.Time) []int64 {
+	var payers, owners []int64
+
+	for _, m := range ms {
+		if !m.activeAt(day) {
+			continue
+		}
+
+		switch {
+		case m.Role == MemberPayer:
+			payers = append(payers, m.ClientID)
+		case m.owns():
+			owners = append(owners, m.ClientID)
+		}
+	}
+
+	if len(payers) > 0 {
+		return payers
+	}
+	return owners
+}
+
+// checkMember checks m against the members ms of its room. It fails with
+// ErrDuplicate when the client of m already has the role of m on a day m
+// holds, and with an *api_errors.APIError when the shares of the room would
+// add up to more than 1 on such a day.
+func checkMember(m RoomMember, ms []RoomMember) error {
+	var others []RoomMember
+	for _, other := range ms {
+		if other.ID == m.ID || !m.overlaps(other) {
+			continue
+		}
+		if other.ClientID == m.ClientID && other.Role == m.Role {
+			return fmt.Errorf(
+				"%w: client_id %d is already %s of room_id %d in member_id %d",
+				ErrDuplicate, m.ClientID, m.Role, m.RoomID, other.ID,
+			)
+		}
+		others = append(others, other)
+	}
+
+	if m.Share == nil {
+		return nil
+	}
+
+	// The sum of the shares only grows on the days memberships start, so it
+	// is enough to sum them on those days, the open start included.
+	days := []time.Time{{}}
+	for _, other := range append(others, m) {
+		if other.From != nil {
+			days = append(days, *other.From)
+		}
+	}
+
+	for _, day := range days {
+		if !m.activeAt(day) {
+			continue
+		}
+
+		total := *m.Share
+		for _, other := range others {
+			if other.Share != nil && other.activeAt(day) {
+				total += *other.Share
+			}
+		}
+
+		if total > memberShareWhole {
+			return api_errors.NewErrIncorrectParam(fmt.Sprintf(
+				"member_share: the shares of room_id %d would add up to %s", m.RoomID, total,
+			))
+		}
+	}
+	return nil
+}
+
+// ClientRoom is a room a client is a member of, with the roles of the client
+// in it. Pays is set for the clients responsible for paying for the room.
+type ClientRoom struct {
+	Room
+	Roles []string     `json:"member_roles"`
+	Share *MemberShare `json:"member_share,omitempty" swaggertype:"string"`
+	Pays  bool         `json:"member_pays"`
+}
+
+// clientRooms returns the rooms the client clientID is a member of on day,
+// ordered by room_id.
+func (s *Server) clientRooms(ctx context.Context, clientID int64, day time.Time, includeDeleted bool) ([]ClientRoom, error) {
+	ms, err := s.Members.ByClientID(ctx, clientID)
+	if err != nil {
+		return nil, err
+	}
+
+	var crs []ClientRoom
+	for _, m := range ms {
+		if !m.activeAt(day) {
+			continue
+		}
+
+		if n := len(crs); n > 0 && crs[n-1].ID == m.RoomID {
+			crs[n-1].Roles = append(crs[n-1].Roles, m.Role)
+			if m.Share != nil {
+				share := *m.Share
+				if crs[n-1].Share != nil {
+					share += *crs[n-1].Share
+				}
+				crs[n-1].Share = &share
+			}
+			continue
+		}
+
+		r, err := s.Rooms.ByID(ctx, m.RoomID, includeDeleted)
+		if errors.Is(err, ErrNotFound) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		room_members, err := s.Members.ByRoomID(ctx, m.RoomID)
+		if err != nil {
+			return nil, err
+		}
+
+		crs = append(crs, ClientRoom{
+			Room:  r,
+			Roles: []string{m.Role},
+			Share: m.Share,
+			Pays:  slices.Contains(responsible(room_members, day), clientID),
+		})
+	}
+
+	return crs, nil
+}
+
+// RoomMembers godoc
+// @Summary Get room members
+// @Schemes http
+// @Description Get the owners, co-owners, tenants and payers of a room, past and future ones included unless day is given
+// @Tags room
+// @Param id path int true "Room ID"
+// @Param day query string false "Day 'yyyy-mm-dd': only the members on that day"
+// @Produce json
+// @Success 200 {array} RoomMember "ok"
+// @Failure 400 {object} types.APIResponse "Incorrect parameter"
+// @Failure 404 {object} types.APIResponse "No rows"
+// @Failure 500 {object} types.APIResponse "Internal server error"
+// @Security BearerAuth
+// @Router /room/id/{id}/members [get]
+func (s *Server) RouteRoomGetMembers(g *gin.Context) {
+	var (
+		errs paramErrors
+		day  time.Time
+	)
+
+	room_id, apierr := validators.Int64("id", g.Param("id"), false)
+	errs.add(apierr)
+	if temp := g.Query("day"); temp != "" {
+		day, apierr = validateDay("day", temp, false)
+		errs.add(apierr)
+	}
+
+	if errs.respond(g) {
+		return
+	}
+
+	ms, err := s.Members.ByRoomID(g, room_id)
+	if err != nil {
+		repoError(g, err)
+		return
+	}
+
+	if !day.IsZero() {
+		ms = slices.DeleteFunc(ms, func(m RoomMember) bool { return !m.activeAt(day) })
+	}
+
+	if len(ms) == 0 {
+		g.JSON(http.StatusNotFound, types.APIResponse{
+			Error: api_errors.NewErrSQLNoRows("No rows"),
+		})
+		return
+	}
+
+	g.JSON(http.StatusOK, ms)
+}
+
+// RoomMemberByID godoc
+// @Summary Get room member by member_id
+// @Schemes http
+// @Description Get room member by member_id
+// @Tags room
+// @Param id path int true "Member ID"
+// @Produce json
+// @Success 200 {object} RoomMember "ok"
+// @Header 200 {string} ETag "Version of the row, for If-Match"
+// @Failure 400 {object} types.APIResponse "Incorrect parameter"
+// @Failure 404 {object} types.APIResponse "No rows"
+// @Failure 500 {object} types.APIResponse "Internal server error"
+// @Security BearerAuth
+// @Router /room/member/id/{id} [get]
+func (s *Server) RouteRoomMemberGetByID(g *gin.Context) {
+	id, apierr := validators.Int64("id", g.Param("id"), false)
+	if apierr != nil {
+		g.JSON(http.StatusBadRequest, types.APIResponse{Error: apierr})
+		return
+	}
+
+	m, err := s.Members.ByID(g, id)
+	if err != nil {
+		repoError(g, err)
+		return
+	}
+
+	g.Header("ETag", etag(m))
+	g.JSON(http.StatusOK, m)
+}
+
+// MemberCreateRequest is the body of RouteRoomMemberPostCreate.
+type MemberCreateRequest struct {
+	ClientID int64        `json:"client_id" bind:"required"`
+	Role     string       `json:"member_role" bind:"required"`
+	Share    *MemberShare `json:"member_share"`
+	From     *time.Time   `json:"member_from" bind:"day"`
+	Until    *time.Time   `json:"member_until" bind:"day"`
+}
+
+// RoomMemberCreate godoc
+// @Summary Add room member
+// @Schemes http
+// @Description Add an owner, co-owner, tenant or payer to a room. A client can't hold the same role in a room twice on a day.
+// @Tags room
+// @Param id path int true "Room ID"
+// @Param client_id formData int true "Client ID"
+// @Param member_role formData string true "Role: owner, co-owner, tenant or payer"
+// @Param member_share formData string false "Share of the ownership over 0 up to 1 with at most 4 decimals, of owners and co-owners only. The shares of a room can't add up to more than 1 on a day."
+// @Param member_from formData string false "Day 'yyyy-mm-dd' from which the membership holds"
+// @Param member_until formData string false "Day 'yyyy-mm-dd' from which the membership no longer holds"
+// @Accept x-www-form-urlencoded,json
+// @Produce json
+// @Success 201 {object} RoomMember "New member"
+// @Header 201 {string} Location "URL of the new member"
+// @Header 201 {string} ETag "ETag of the new member"
+// @Failure 400 {object} ValidationResponse "Incorrect parameter"
+// @Failure 409 {object} types.APIResponse "Client already has the role in the room"
+// @Failure 422 {object} types.APIResponse "No such room or client"
+// @Failure 500 {object} types.APIResponse "Internal server error"
+// @Security BearerAuth
+// @Router /room/id/{id}/members [post]
+func (s *Server) RouteRoomMemberPostCreate(g *gin.Context) {
+	var (
+		errs paramErrors
+		req  MemberCreateRequest
+	)
+
+	room_id, apierr := validators.Int64("id", g.Param("id"), false)
+	errs.add(apierr)
+	errs.add(bindRequest(g, &req)...)
+
+	if errs.respond(g) {
+		return
+	}
+
+	m := RoomMember{
+		RoomID:   room_id,
+		ClientID: req.ClientID,
+		Role:     req.Role,
+		Share:    req.Share,
+		From:     req.From,
+		Until:    req.Until,
+	}
+
+	errs.add(m.validate()...)
+	if errs.respond(g) {
+		return
+	}
+
+	err := s.Members.Create(g, &m, checkMember)
+	if apierr, ok := err.(*api_errors.APIError); ok {
+		g.JSON(http.StatusBadRequest, types.APIResponse{Error: apierr})
+		return
+	}
+	if err != nil {
+		repoError(g, err)
+		return
+	}
+
+	logInfo(fmt.Sprintf("Created room member: %#v", m))
+	g.Header("ETag", etag(m))
+	created(g, "../../member/id/"+strconv.FormatInt(m.ID, 10), m)
+}
+
+// MemberPatchRequest is the body of RouteRoomMemberPatch.
+type MemberPatchRequest struct {
+	Role  *string      `json:"member_role"`
+	Share *MemberShare `json:"member_share" bind:"nullable"`
+	From  *time.Time   `json:"member_from" bind:"day,nullable"`
+	Until *time.Time   `json:"member_until" bind:"day,nullable"`
+}
+
+// RoomMemberPatch godoc
+// @Summary Patch room member
+// @Schemes http
+// @Description Patch the role, share and dates of a room member by member_id. A null of a merge patch clears the share or a date; a member_share of 0 also clears the share.
+// @Tags room
+// @Param id path int true "Member ID"
+// @Param If-Match header string false "ETag of the row; the patch fails if the row has changed"
+// @Param member_role formData string false "Role: owner, co-owner, tenant or payer"
+// @Param member_share formData string false "Share of the ownership over 0 up to 1 with at most 4 decimals, of owners and co-owners only. The shares of a room can't add up to more than 1 on a day."
+// @Param member_from formData string false "Day 'yyyy-mm-dd' from which the membership holds"
+// @Param member_until formData string false "Day 'yyyy-mm-dd' from which the membership no longer holds"
+// @Accept x-www-form-urlencoded,json,application/merge-patch+json
+// @Produce json
+// @Success 200 {object} RoomMember "Updated"
+// @Header 200 {string} ETag "Version of the row, for If-Match"
+// @Failure 400 {object} ValidationResponse "Incorrect parameter"
+// @Failure 404 {object} types.APIResponse "No rows"
+// @Failure 409 {object} types.APIResponse "Client already has the role in the room"
+// @Failure 412 {object} types.APIResponse "Row has changed"
+// @Failure 500 {object} types.APIResponse "Internal server error"
+// @Security BearerAuth
+// @Router /room/member/id/{id} [patch]
+func (s *Server) RouteRoomMemberPatch(g *gin.Context) {
+	var (
+		errs paramErrors
+		req  MemberPatchRequest
+	)
+
+	member_id, apierr := validators.Int64("id", g.Param("id"), false)
+	errs.add(apierr)
+	errs.add(bindRequest(g, &req)...)
+
+	if errs.respond(g) {
+		return
+	}
+
+	// A cleared date arrives as a pointer to the zero time.
+	nullDay := func(p *time.Time) *time.Time {
+		if p.IsZero() {
+			return nil
+		}
+		return p
+	}
+
+	patch := func(m *RoomMember) {
+		if req.Role != nil {
+			m.Role = *req.Role
+		}
+		if req.Share != nil {
+			m.Share = req.Share
+			if *req.Share == 0 {
+				m.Share = nil
+			}
+		}
+		if req.From != nil {
+			m.From = nullDay(req.From)
+		}
+		if req.Until != nil {
+			m.Until = nullDay(req.Until)
+		}
+	}
+
+	m, err := s.Members.ByID(g, member_id)
+	if err != nil {
+		repoError(g, err)
+		return
+	}
+
+	patch(&m)
+
+	errs.add(m.validate()...)
+	if errs.respond(g) {
+		return
+	}
+
+	m, err = s.Members.Update(g, member_id, g.GetHeader("If-Match"), patch, checkMember)
+	if apierr, ok := err.(*api_errors.APIError); ok {
+		g.JSON(http.StatusBadRequest, types.APIResponse{Error: apierr})
+		return
+	}
+	if err != nil {
+		repoError(g, err)
+		return
+	}
+
+	g.Header("ETag", etag(m))
+	g.JSON(http.StatusOK, m)
+}
+
+// RoomMemberDelete godoc
+// @Summary Delete room member
+// @Schemes http
+// @Description Delete a room member by member_id. To keep the history of a room, set member_until instead.
+// @Tags room
+// @Param id path int true "Member ID"
+// @Param If-Match header string false "ETag of the row; the delete fails if the row has changed"
+// @Produce json
+// @Success 200 {object} types.APIResponse "Deleted"
+// @Failure 400 {object} types.APIResponse "Incorrect parameter"
+// @Failure 404 {object} types.APIResponse "No rows"
+// @Failure 412 {object} types.APIResponse "Row has changed"
+// @Failure 500 {object} types.APIResponse "Internal server error"
+// @Security BearerAuth
+// @Router /room/member/id/{id} [delete]
+func (s *Server) RouteRoomMemberDelete(g *gin.Context) {
+	id, apierr := validators.Int64("id", g.Param("id"), false)
+	if apierr != nil {
+		g.JSON(http.StatusBadRequest, types.APIResponse{Error: apierr})
+		return
+	}
+
+	if err := s.Members.Delete(g, id, g.GetHeader("If-Match")); err != nil {
+		repoError(g, err)
+		return
+	}
+
+	logInfo("Deleted room member with member_id: ", id)
+	g.JSON(http.StatusOK, types.APIResponse{Message: "ok"})
+}
@@ -0,0 +1,651 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	types "github.com/snakehunterr/hacs_db_types"
+	api_errors "github.com/snakehunterr/hacs_db_types/errors"
+	validators "github.com/snakehunterr/hacs_db_types/validators"
+)
+
+const (
+	MeterKindColdWater   = "cold_water"
+	MeterKindHotWater    = "hot_water"
+	MeterKindElectricity = "electricity"
+	MeterKindGas         = "gas"
+)
+
+// MeterAbnormalFactor is how many times the daily consumption of a new reading
+// may exceed the meter's average daily consumption before it is flagged.
+const MeterAbnormalFactor = 3
+
+type Meter struct {
+	ID         int64     `json:"meter_id"`
+	RoomID     int64     `json:"room_id"`
+	Kind       string    `json:"meter_kind"`
+	Serial     string    `json:"meter_serial"`
+	LastEdited time.Time `json:"last_edited"`
+}
+
+// MeterReading is a submitted meter value. Consumption is the difference with
+// the previous reading of the same meter.
+type MeterReading struct {
+	ID          int64      `json:"reading_id"`
+	MeterID     int64      `json:"meter_id"`
+	Date        time.Time  `json:"reading_date"`
+	Value       MeterValue `json:"reading_value" swaggertype:"string"`
+	Consumption MeterValue `json:"reading_consumption" swaggertype:"string"`
+	Abnormal    bool       `json:"reading_abnormal"`
+	LastEdited  time.Time  `json:"last_edited"`
+}
+
+// MeterConsumption is the consumption of one meter in a billing period,
+// measured between the last reading before the period and the last reading
+// within it.
+type MeterConsumption struct {
+	MeterID     int64      `json:"meter_id"`
+	Kind        string     `json:"meter_kind"`
+	Period      string     `json:"period"`
+	StartValue  MeterValue `json:"start_value" swaggertype:"string"`
+	EndValue    MeterValue `json:"end_value" swaggertype:"string"`
+	Consumption MeterValue `json:"consumption" swaggertype:"string"`
+}
+
+func isMeterKind(kind string) bool {
+	switch kind {
+	case MeterKindColdWater, MeterKindHotWater, MeterKindElectricity, MeterKindGas:
+		return true
+	}
+	return false
+}
+
+// checkMeterReading validates a new reading against the meter's history, which
+// must be sorted by date, and fills its consumption and abnormal flag.
+func checkMeterReading(r *MeterReading, history []MeterReading) *api_errors.APIError {
+	if len(history) == 0 {
+		return nil
+	}
+
+	last := history[len(history)-1]
+	if !r.Date.After(last.Date) {
+		return api_errors.NewErrIncorrectParam("reading_date: must be after the last reading")
+	}
+	if r.Value < last.Value {
+		return api_errors.NewErrIncorrectParam("reading_value: must not be less than the last reading")
+	}
+
+	r.Consumption = r.Value - last.Value
+
+	first := history[0]
+	span := last.Date.Sub(first.Date)
+	if len(history) < 2 || span <= 0 || last.Value == first.Value {
+		return nil
+	}
+
+	// The consumption over the time since the last reading is compared with
+	// MeterAbnormalFactor times the average over the history, both sides
+	// multiplied by both durations, so values are compared exactly.
+	current := new(big.Int).Mul(big.NewInt(int64(r.Consumption)), big.NewInt(int64(span)))
+	average := new(big.Int).Mul(big.NewInt(int64(last.Value-first.Value)), big.NewInt(int64(r.Date.Sub(last.Date))))
+	average.Mul(average, big.NewInt(MeterAbnormalFactor))
+	r.Abnormal = current.Cmp(average) > 0
+
+	return nil
+}
+
+// repeatedMeterReading returns the last reading of history if r repeats it: it
+// has the same value on the same day, e.g. a submission sent twice.
+func repeatedMeterReading(r MeterReading, history []MeterReading) (MeterReading, bool) {
+	if len(history) == 0 {
+		return MeterReading{}, false
+	}
+
+	last := history[len(history)-1]
+	day := func(t time.Time) string { return t.UTC().Format(DAY_FORMAT) }
+	return last, r.Value == last.Value && day(r.Date) == day(last.Date)
+}
+
+// meterConsumption computes consumption of a meter in period from its readings
+// sorted by date. ok is false when there are no readings within the period.
+func meterConsumption(m Meter, period time.Time, readings []MeterReading) (c MeterConsumption, ok bool) {
+	end := period.AddDate(0, 1, 0)
+
+	c = MeterConsumption{
+		MeterID: m.ID,
+		Kind:    m.Kind,
+		Period:  period.Format(PERIOD_FORMAT),
+	}
+
+	started := false
+	for _, r := range readings {
+		switch {
+		case r.Date.Before(period):
+			c.StartValue = r.Value
+			started = true
+
+		case r.Date.Before(end):
+			if !started {
+				c.StartValue = r.Value
+				started = true
+			}
+			c.EndValue = r.Value
+			ok = true
+		}
+	}
+
+	if ok {
+		c.Consumption = c.EndValue - c.StartValue
+	}
+
+	return c, ok
+}
+
+// MeterAll godoc
+// @Summary Get all meters
+// @Schemes http
+// @Description Get all meters, optionally only those of the rooms of a building
+// @Tags meter
+// @Param building_id query int false "Building ID"
+// @Produce json
+// @Success 200 {array} Meter "ok"
+// @Failure 400 {object} types.APIResponse "Incorrect parameter"
+// @Failure 404 {object} types.APIResponse "No rows"
+// @Failure 500 {object} types.APIResponse "Internal server error"
+// @Security BearerAuth
+// @Router /meter/all [get]
+func (s *Server) RouteMeterGetAll(g *gin.Context) {
+	building_id, apierr := optionalInt64(g, "building_id")
+	if apierr != nil {
+		g.JSON(http.StatusBadRequest, types.APIResponse{Error: apierr})
+		return
+	}
+
+	ms, err := s.Meters.All(g, building_id)
+	if err != nil {
+		repoError(g, err)
+		return
+	}
+
+	if len(ms) == 0 {
+		g.JSON(http.StatusNotFound, types.APIResponse{
+			Error: api_errors.NewErrSQLNoRows("No rows"),
+		})
+		return
+	}
+
+	g.JSON(http.StatusOK, ms)
+}
+
+// MeterByID godoc
+// @Summary Get meter by meter_id
+// @Schemes http
+// @Description Get meter by meter_id
+// @Tags meter
+// @Param id path int true "Meter ID"
+// @Produce json
+// @Success 200 {object} Meter "ok"
+// @Failure 400 {object} types.APIResponse "Incorrect parameter"
+// @Failure 404 {object} types.APIResponse "No rows"
+// @Failure 500 {object} types.APIResponse "Internal server error"
+// @Security BearerAuth
+// @Router /meter/id/{id} [get]
+func (s *Server) RouteMeterGetByID(g *gin.Context) {
+	id, apierr := validators.Int64("id", g.Param("id"), false)
+	if apierr != nil {
+		g.JSON(http.StatusBadRequest, types.APIResponse{Error: apierr})
+		return
+	}
+
+	m, err := s.Meters.ByID(g, id)
+	if err != nil {
+		repoError(g, err)
+		return
+	}
+
+	g.JSON(http.StatusOK, m)
+}
+
+// MeterByRoomID godoc
+// @Summary Get meters by room_id
+// @Schemes http
+// @Description Get meters by room_id
+// @Tags meter
+// @Param id path int true "Room ID"
+// @Produce json
+// @Success 200 {array} Meter "ok"
+// @Failure 400 {object} types.APIResponse "Incorrect parameter"
+// @Failure 404 {object} types.APIResponse "No rows"
+// @Failure 500 {object} types.APIResponse "Internal server error"
+// @Security BearerAuth
+// @Router /meter/room/id/{id} [get]
+func (s *Server) RouteMeterGetAllByRoomID(g *gin.Context) {
+	id, apierr := validators.Int64("id", g.Param("id"), false)
+	if apierr != nil {
+		g.JSON(http.StatusBadRequest, types.APIResponse{Error: apierr})
+		return
+	}
+
+	ms, err := s.Meters.ByRoomID(g, id)
+	if err != nil {
+		repoError(g, err)
+		return
+	}
+
+	if len(ms) == 0 {
+		g.JSON(http.StatusNotFound, types.APIResponse{
+			Error: api_errors.NewErrSQLNoRows("No rows"),
+		})
+		return
+	}
+
+	g.JSON(http.StatusOK, ms)
+}
+
+// MeterCreateRequest is the body of RouteMeterPostCreate.
+type MeterCreateRequest struct {
+	RoomID int64  `json:"room_id" bind:"required"`
+	Kind   string `json:"meter_kind" bind:"required"`
+	Serial string `json:"meter_serial" bind:"required"`
+}
+
+// MeterCreate godoc
+// @Summary Create new meter
+// @Schemes http
+// @Description Create new meter in a room
+// @Tags meter
+// @Param room_id formData int true "Room ID"
+// @Param meter_kind formData string true "Meter kind" Enums(cold_water, hot_water, electricity, gas)
+// @Param meter_serial formData string true "Serial number"
+// @Accept x-www-form-urlencoded,json
+// @Produce json
+// @Success 201 {object} Meter "New meter"
+// @Header 201 {string} Location "URL of the new meter"
+// @Failure 400 {object} ValidationResponse "Incorrect parameter"
+// @Failure 409 {object} types.APIResponse "Meter with the same kind and serial exists"
+// @Failure 422 {object} types.APIResponse "No such room"
+// @Failure 500 {object} types.APIResponse "Internal server error"
+// @Security BearerAuth
+// @Router /meter/new [post]
+func (s *Server) RouteMeterPostCreate(g *gin.Context) {
+	var (
+		errs paramErrors
+		req  MeterCreateRequest
+	)
+
+	errs.add(bindRequest(g, &req)...)
+	if req.Kind != "" && !isMeterKind(req.Kind) {
+		errs.add(api_errors.NewErrIncorrectParam("meter_kind"))
+	}
+
+	if errs.respond(g) {
+		return
+	}
+
+	m := Meter{RoomID: req.RoomID, Kind: req.Kind, Serial: req.Serial}
+	if err := s.Meters.Create(g, &m); err != nil {
+		repoError(g, err)
+		return
+	}
+
+	logInfo(fmt.Sprintf("Created new meter: %#v", m))
+	created(g, fmt.Sprintf("id/%d", m.ID), m)
+}
+
+// MeterDelete godoc
+// @Summary Delete meter
+// @Schemes http
+// @Description Delete meter and all its readings by meter_id
+// @Tags meter
+// @Param id path int true "Meter ID"
+// @Produce json
+// @Success 200 {object} types.APIResponse "Deleted"
+// @Failure 400 {object} types.APIResponse "Incorrect parameter"
+// @Failure 404 {object} types.APIResponse "No rows"
+// @Failure 500 {object} types.APIResponse "Internal server error"
+// @Security BearerAuth
+// @Router /meter/id/{id} [delete]
+func (s *Server) RouteMeterDelete(g *gin.Context) {
+	id, apierr := validators.Int64("id", g.Param("id"), false)
+	if apierr != nil {
+		g.JSON(http.StatusBadRequest, types.APIResponse{Error: apierr})
+		return
+	}
+
+	if err := s.Meters.Delete(g, id); err != nil {
+		repoError(g, err)
+		return
+	}
+
+	logInfo("Deleted meter with meter_id: ", id)
+	g.JSON(http.StatusOK, types.APIResponse{Message: "ok"})
+}
+
+// MeterReadings godoc
+// @Summary Get meter readings
+// @Schemes http
+// @Description Get all readings of a meter sorted by date
+// @Tags meter
+// @Param id path int true "Meter ID"
+// @Produce json
+// @Success 200 {array} MeterReading "ok"
+// @Failure 400 {object} types.APIResponse "Incorrect parameter"
+// @Failure 404 {object} types.APIResponse "No rows"
+// @Failure 500 {object} types.APIResponse "Internal server error"
+// @Security BearerAuth
+// @Router /meter/id/{id}/readings [get]
+func (s *Server) RouteMeterReadingGetAllByMeterID(g *gin.Context) {
+	id, apierr := validators.Int64("id", g.Param("id"), false)
+	if apierr != nil {
+		g.JSON(http.StatusBadRequest, types.APIResponse{Error: apierr})
+		return
+	}
+
+	rs, err := s.Meters.Readings(g, id)
+	if err != nil {
+		repoError(g, err)
+		return
+	}
+
+	if len(rs) == 0 {
+		g.JSON(http.StatusNotFound, types.APIResponse{
+			Error: api_errors.NewErrSQLNoRows("No rows"),
+		})
+		return
+	}
+
+	g.JSON(http.StatusOK, rs)
+}
+
+// MeterReadingCreateRequest is the body of RouteMeterReadingPostCreate.
+type MeterReadingCreateRequest struct {
+	Value MeterValue `json:"reading_value" bind:"required"`
+	Date  *time.Time `json:"reading_date"`
+}
+
+// MeterReadingCreate godoc
+// @Summary Submit meter reading
+// @Schemes http
+// @Description Submit a meter reading. Readings must be newer than and not less than the last one; jumps far above the meter's average consumption are flagged as abnormal.
+// @Description A reading with the value of the last one on the same day is not stored again: the last reading is returned instead.
+// @Tags meter
+// @Param id path int true "Meter ID"
+// @Param reading_value formData string true "Meter value with at most 3 decimals, e.g. '10.125'"
+// @Param reading_date formData string false "Date 'yyyy-mm-dd hh:mm:ss', now by default"
+// @Accept x-www-form-urlencoded,json
+// @Produce json
+// @Success 200 {object} MeterReading "Last reading, repeated"
+// @Success 201 {object} MeterReading "New reading"
+// @Header 201 {string} Location "URL of the new reading"
+// @Failure 400 {object} ValidationResponse "Incorrect parameter"
+// @Failure 404 {object} types.APIResponse "No rows"
+// @Failure 500 {object} types.APIResponse "Internal server error"
+// @Security BearerAuth
+// @Router /meter/id/{id}/reading [post]
+func (s *Server) RouteMeterReadingPostCreate(g *gin.Context) {
+	var (
+		errs paramErrors
+		req  MeterReadingCreateRequest
+		r    MeterReading
+	)
+
+	meter_id, apierr := validators.Int64("id", g.Param("id"), false)
+	errs.add(apierr)
+	errs.add(bindRequest(g, &req)...)
+
+	if errs.respond(g) {
+		return
+	}
+
+	r.MeterID = meter_id
+	r.Value = req.Value
+	r.Date = time.Now().UTC().Truncate(time.Second)
+	if req.Date != nil {
+		r.Date = *req.Date
+	}
+
+	// The reading is checked against the history of its meter while the
+	// meter is locked, so concurrent submissions are checked against each
+	// other.
+	err := s.Meters.AddReading(g, &r, func(r *MeterReading, history []MeterReading) error {
+		if last, ok := repeatedMeterReading(*r, history); ok {
+			*r = last
+			return ErrDuplicate
+		}
+		if apierr := checkMeterReading(r, history); apierr != nil {
+			return apierr
+		}
+		return nil
+	})
+	if errors.Is(err, ErrDuplicate) {
+		logInfo("Repeated meter reading with reading_id: ", r.ID)
+		g.JSON(http.StatusOK, r)
+		return
+	}
+	if apierr, ok := err.(*api_errors.APIError); ok {
+		g.JSON(http.StatusBadRequest, types.APIResponse{Error: apierr})
+		return
+	}
+	if err != nil {
+		repoError(g, err)
+		return
+	}
+
+	logInfo(fmt.Sprintf("Created new meter reading: %#v", r))
+	created(g, fmt.Sprintf("../../reading/id/%d", r.ID), r)
+}
+
+// MeterReadingByID godoc
+// @Summary Get meter reading by reading_id
+// @Schemes http
+// @Description Get meter reading by reading_id
+// @Tags meter
+// @Param id path int true "Reading ID"
+// @Produce json
+// @Success 200 {object} MeterReading "ok"
+// @Failure 400 {object} types.APIResponse "Incorrect parameter"
+// @Failure 404 {object} types.APIResponse "No rows"
+// @Failure 500 {object} types.APIResponse "Internal server error"
+// @Security BearerAuth
+// @Router /meter/reading/id/{id} [get]
+func (s *Server) RouteMeterReadingGetByID(g *gin.Context) {
+	id, apierr := validators.Int64("id", g.Param("id"), false)
+	if apierr != nil {
+		g.JSON(http.StatusBadRequest, types.APIResponse{Error: apierr})
+		return
+	}
+
+	r, err := s.Meters.Reading(g, id)
+	if err != nil {
+		repoError(g, err)
+		return
+	}
+
+	g.JSON(http.StatusOK, r)
+}
+
+// MeterReadingDelete godoc
+// @Summary Delete meter reading
+// @Schemes http
+// @Description Delete meter reading by reading_id. Only the last reading of a meter can be deleted.
+// @Tags meter
+// @Param id path int true "Reading ID"
+// @Produce json
+// @Success 200 {object} types.APIResponse "Deleted"
+// @Failure 400 {object} types.APIResponse "Incorrect parameter"
+// @Failure 404 {object} types.APIResponse "No rows"
+// @Failure 500 {object} types.APIResponse "Internal server error"
+// @Security BearerAuth
+// @Router /meter/reading/id/{id} [delete]
+func (s *Server) RouteMeterReadingDelete(g *gin.Context) {
+	id, apierr := validators.Int64("id", g.Param("id"), false)
+	if apierr != nil {
+		g.JSON(http.StatusBadRequest, types.APIResponse{Error: apierr})
+		return
+	}
+
+	r, err := s.Meters.Reading(g, id)
+	if err != nil {
+		repoError(g, err)
+		return
+	}
+
+	rs, err := s.Meters.Readings(g, r.MeterID)
+	if err != nil {
+		repoError(g, err)
+		return
+	}
+
+	if len(rs) == 0 || rs[len(rs)-1].ID != id {
+		g.JSON(http.StatusBadRequest, types.APIResponse{
+			Error: api_errors.NewErrIncorrectParam("id: only the last reading of a meter can be deleted"),
+		})
+		return
+	}
+
+	if err := s.Meters.DeleteReading(g, id); err != nil {
+		repoError(g, err)
+		return
+	}
+
+	logInfo("Deleted meter reading with reading_id: ", id)
+	g.JSON(http.StatusOK, types.APIResponse{Message: "ok"})
+}
+
+// MeterConsumption godoc
+// @Summary Get meter consumption for a period
+// @Schemes http
+// @Description Get consumption of a meter in a billing period
+// @Tags meter
+// @Param id path int true "Meter ID"
+// @Param period path string true "Period 'yyyy-mm'"
+// @Produce json
+// @Success 200 {object} MeterConsumption "ok"
+// @Failure 400 {object} types.APIResponse "Incorrect parameter"
+// @Failure 404 {object} types.APIResponse "No rows"
+// @Failure 500 {object} types.APIResponse "Internal server error"
+// @Security BearerAuth
+// @Router /meter/id/{id}/consumption/{period} [get]
+func (s *Server) RouteMeterGetConsumption(g *gin.Context) {
+	var (
+		apierr *api_errors.APIError
+		id     int64
+		period time.Time
+	)
+
+	id, apierr = validators.Int64("id", g.Param("id"), false)
+	if apierr != nil {
+		goto skip
+	}
+
+	period, apierr = validatePeriod("period", g.Param("period"), false)
+
+skip:
+	if apierr != nil {
+		g.JSON(http.StatusBadRequest, types.APIResponse{Error: apierr})
+		return
+	}
+
+	m, err := s.Meters.ByID(g, id)
+	if err != nil {
+		repoError(g, err)
+		return
+	}
+
+	rs, err := s.Meters.Readings(g, id)
+	if err != nil {
+		repoError(g, err)
+		return
+	}
+
+	c, ok := meterConsumption(m, period, rs)
+	if !ok {
+		g.JSON(http.StatusNotFound, types.APIResponse{
+			Error: api_errors.NewErrSQLNoRows("No readings in period"),
+		})
+		return
+	}
+
+	g.JSON(http.StatusOK, c)
+}
+
+// RoomConsumption godoc
+// @Summary Get room consumption for a period
+// @Schemes http
+// @Description Get consumption of every meter of a room in a billing period. Meters without readings in the period are omitted.
+// @Tags meter
+// @Param id path int true "Room ID"
+// @Param period path string true "Period 'yyyy-mm'"
+// @Produce json
+// @Success 200 {array} MeterConsumption "ok"
+// @Failure 400 {object} types.APIResponse "Incorrect parameter"
+// @Failure 404 {object} types.APIResponse "No rows"
+// @Failure 500 {object} types.APIResponse "Internal server error"
+// @Security BearerAuth
+// @Router /meter/room/id/{id}/consumption/{period} [get]
+func (s *Server) RouteMeterGetRoomConsumption(g *gin.Context) {
+	var (
+		apierr *api_errors.APIError
+		id     int64
+		period time.Time
+	)
+
+	id, apierr = validators.Int64("id", g.Param("id"), false)
+	if apierr != nil {
+		goto skip
+	}
+
+	period, apierr = validatePeriod("period", g.Param("period"), false)
+
+skip:
+	if apierr != nil {
+		g.JSON(http.StatusBadRequest, types.APIResponse{Error: apierr})
+		return
+	}
+
+	ms, err := s.Meters.ByRoomID(g, id)
+	if err != nil {
+		repoError(g, err)
+		return
+	}
+
+	var cs []MeterConsumption
+	for _, m := range ms {
+		rs, err := s.Meters.Readings(g, m.ID)
+		if err != nil {
+			repoError(g, err)
+			return
+		}
+
+		if c, ok := meterConsumption(m, period, rs); ok {
+			cs = append(cs, c)
+		}
+	}
+
+	if len(cs) == 0 {
+		g.JSON(http.StatusNotFound, types.APIResponse{
+			Error: api_errors.NewErrSQLNoRows("No rows"),
+		})
+		return
+	}
+
+	g.JSON(http.StatusOK, cs)
+}
+
+func (s *Server) meterRoutes(r *gin.RouterGroup) {
+	r.GET("/all", s.RouteMeterGetAll)
+	r.POST("/new", s.RouteMeterPostCreate)
+	r.GET("/id/:id", s.RouteMeterGetByID)
+	r.DELETE("/id/:id", s.RouteMeterDelete)
+	r.GET("/id/:id/readings", s.RouteMeterReadingGetAllByMeterID)
+	r.POST("/id/:id/reading", s.RouteMeterReadingPostCreate)
+	r.GET("/id/:id/consumption/:period", s.RouteMeterGetConsumption)
+	r.GET("/reading/id/:id", s.RouteMeterReadingGetByID)
+	r.DELETE("/reading/id/:id", s.RouteMeterReadingDelete)
+	r.GET("/room/id/:id", s.RouteMeterGetAllByRoomID)
+	r.GET("/room/id/:id/consumption/:period", s.RouteMeterGetRoomConsumption)
+}
@@ -1,11 +1,9 @@
 package main
 
 import (
-	"database/sql"
-	_ "embed"
+	"cmp"
 	"errors"
 	"fmt"
-	"log"
 	"net/http"
 	"time"
 
@@ -15,88 +13,170 @@ import (
 	validators "github.com/snakehunterr/hacs_db_types/validators"
 )
 
-func paymentScanRow(p *types.Payment, row *sql.Row) error {
-	return row.Scan(&p.ID, &p.ClientID, &p.RoomID, &p.Date, &p.Amount, &p.LastEdited)
+// Payment has the fields of types.Payment, with the amount as Money, and the
+// charge ID of the payment provider for payments made through Telegram.
+type Payment struct {
+	ID               int64      `json:"payment_id"`
+	ClientID         int64      `json:"client_id"`
+	RoomID           int64      `json:"room_id"`
+	Date             time.Time  `json:"payment_date"`
+	Amount           Money      `json:"payment_amount" swaggertype:"string"`
+	LastEdited       time.Time  `json:"last_edited"`
+	ProviderChargeID string     `json:"payment_provider_charge_id"`
+	ExternalID       string     `json:"payment_external_id,omitempty"`
+	DeletedAt        *time.Time `json:"deleted_at,omitempty"`
+
+	// Amount is in LedgerCurrency, the currency of the building of the room.
+	// Currency is the currency the payment was received in; a payment in
+	// another currency than the ledger keeps the amount received and the rate
+	// it was converted at.
+	LedgerCurrency string        `json:"ledger_currency"`
+	Currency       string        `json:"payment_currency"`
+	OriginalAmount *Money        `json:"payment_original_amount,omitempty" swaggertype:"string"`
+	ExchangeRate   *ExchangeRate `json:"payment_exchange_rate,omitempty" swaggertype:"string"`
 }
 
-func paymentScanRows(ps *[]types.Payment, rows *sql.Rows) error {
-	if ps == nil {
-		return errors.New("*[]types.Payment is nil")
+// received returns the amount in the currency the payment was received in.
+func (p Payment) received() Money {
+	if p.OriginalAmount != nil {
+		return *p.OriginalAmount
 	}
+	return p.Amount
+}
 
-	_ps := *ps
-	for rows.Next() {
-		var p types.Payment
-
-		if err := rows.Scan(&p.ID, &p.ClientID, &p.RoomID, &p.Date, &p.Amount, &p.LastEdited); err != nil {
-			return err
-		}
-
-		_ps = append(_ps, p)
-	}
+// convert sets the amount of a payment received in a foreign currency from
+// the received amount and the exchange rate.
+func (p *Payment) convert(received Money, rate ExchangeRate) {
+	p.OriginalAmount = &received
+	p.ExchangeRate = &rate
+	p.Amount = rate.convert(received)
+}
 
-	*ps = _ps
-	return nil
+func (p Payment) active() bool {
+	return p.DeletedAt == nil
 }
 
-//go:embed sql/payment/payment_get_all.sql
-var SQLPaymentGetAllQuery string
+// paymentListFields are the sort fields of /payment/all.
+var paymentListFields = listFields[Payment]{
+	id:   "payment_id",
+	idOf: func(p Payment) int64 { return p.ID },
+	sorts: map[string]sortField[Payment]{
+		"payment_id":     {"payment_id", sortInt, func(p Payment) any { return p.ID }},
+		"client_id":      {"client_id", sortInt, func(p Payment) any { return p.ClientID }},
+		"room_id":        {"room_id", sortInt, func(p Payment) any { return p.RoomID }},
+		"payment_date":   {"payment_date", sortTime, func(p Payment) any { return p.Date }},
+		"payment_amount": {"payment_amount", sortMoney, func(p Payment) any { return p.Amount }},
+		"last_edited":    {"last_edited", sortTime, func(p Payment) any { return p.LastEdited }},
+	},
+}
 
 // PaymentAll godoc
 // @Summary Get all payments
 // @Schemes http
-// @Description Get all payments
+// @Description Get a page of payments, optionally filtered by building, client, room and amount
 // @Tags payment
+// @Param building_id query int false "Building ID"
+// @Param client_id query int false "Client telegram ID"
+// @Param room_id query int false "Room ID"
+// @Param min_amount query string false "Minimum payment amount, e.g. '120.50'"
+// @Param max_amount query string false "Maximum payment amount, e.g. '120.50'"
+// @Param include_deleted query bool false "Include soft deleted rows"
+// @Param sort query string false "Sort field: payment_id, client_id, room_id, payment_date, payment_amount, last_edited; payment_id by default"
+// @Param order query string false "Sort order: asc (default) or desc"
+// @Param limit query int false "Page size, 50 by default, up to 500"
+// @Param cursor query string false "X-Next-Cursor of the previous page"
 // @Produce json
-// @Success 200 {array} types.Payment "ok"
-// @Failure 404 {object} types.APIResponse "No rows"
+// @Success 200 {array} Payment "ok"
+// @Header 200 {integer} X-Total-Count "Number of matching rows over all pages"
+// @Header 200 {string} X-Next-Cursor "Cursor of the next page, absent on the last page"
+// @Failure 400 {object} types.APIResponse "Incorrect parameter"
 // @Failure 500 {object} types.APIResponse "Internal server error"
+// @Security BearerAuth
 // @Router /payment/all [get]
-func RoutePaymentGetAll(g *gin.Context) {
-	var ps []types.Payment
+func (s *Server) RoutePaymentGetAll(g *gin.Context) {
+	var (
+		f      PaymentFilter
+		q      PageQuery
+		apierr *api_errors.APIError
+	)
 
-	code, err := queryRows(&ps, paymentScanRows, SQLPaymentGetAllQuery)
-	if err != nil {
-		g.JSON(code, types.APIResponse{Error: err})
+	q, apierr = paymentListFields.pageQuery(g)
+	if apierr != nil {
+		goto skip
+	}
+
+	f.BuildingID, apierr = optionalInt64(g, "building_id")
+	if apierr != nil {
+		goto skip
+	}
+
+	f.ClientID, apierr = optionalInt64(g, "client_id")
+	if apierr != nil {
+		goto skip
+	}
+
+	f.RoomID, apierr = optionalInt64(g, "room_id")
+	if apierr != nil {
+		goto skip
+	}
+
+	f.MinAmount, apierr = optionalMoney(g, "min_amount")
+	if apierr != nil {
+		goto skip
+	}
+
+	f.MaxAmount, apierr = optionalMoney(g, "max_amount")
+	if apierr != nil {
+		goto skip
+	}
+
+	f.IncludeDeleted, apierr = includeDeleted(g)
+
+skip:
+	if apierr != nil {
+		g.JSON(http.StatusBadRequest, types.APIResponse{Error: apierr})
 		return
 	}
 
-	if len(ps) == 0 {
-		g.JSON(http.StatusNotFound, types.APIResponse{
-			Error: api_errors.NewErrSQLNoRows("No rows"),
-		})
+	page, err := s.Payments.List(g, f, q)
+	if err != nil {
+		repoError(g, err)
 		return
 	}
 
-	g.JSON(http.StatusOK, ps)
+	listJSON(g, page)
 }
 
-//go:embed sql/payment/payment_get_by_client_id.sql
-var SQLPaymentGetByClientIDQuery string
-
 // PaymentAllByClientID godoc
 // @Summary Get all payments by client_id
 // @Schemes http
 // @Description Get all payments by client_id
 // @Param id path int true "Client ID"
 // @Tags payment
+// @Param include_deleted query bool false "Include soft deleted rows"
 // @Produce json
-// @Success 200 {array} types.Payment "ok"
+// @Success 200 {array} Payment "ok"
 // @Failure 400 {object} types.APIResponse "Incorrect parameter"
 // @Failure 404 {object} types.APIResponse "No rows"
 // @Failure 500 {object} types.APIResponse "Internal server error"
+// @Security BearerAuth
 // @Router /payment/client/id/{id} [get]
-func RoutePaymentGetAllByClientID(g *gin.Context) {
-	id := g.Param("id")
-	if _, err := validators.Int64("id", id, false); err != nil {
-		g.JSON(http.StatusBadRequest, types.APIResponse{Error: err})
+func (s *Server) RoutePaymentGetAllByClientID(g *gin.Context) {
+	include_deleted, apierr := includeDeleted(g)
+	if apierr != nil {
+		g.JSON(http.StatusBadRequest, types.APIResponse{Error: apierr})
 		return
 	}
 
-	var ps []types.Payment
-	code, err := queryRows(&ps, paymentScanRows, SQLPaymentGetByClientIDQuery, id)
+	id, apierr := validators.Int64("id", g.Param("id"), false)
+	if apierr != nil {
+		g.JSON(http.StatusBadRequest, types.APIResponse{Error: apierr})
+		return
+	}
+
+	ps, err := s.Payments.ByClientID(g, id, include_deleted)
 	if err != nil {
-		g.JSON(code, types.APIResponse{Error: err})
+		repoError(g, err)
 		return
 	}
 
@@ -110,32 +190,36 @@ func RoutePaymentGetAllByClientID(g *gin.Context) {
 	g.JSON(http.StatusOK, ps)
 }
 
-//go:embed sql/payment/payment_get_by_room_id.sql
-var SQLPaymentGetByRoomIDQuery string
-
 // PaymentGetAllByRoomID godoc
 // @Summary Get all payments by room_id
 // @Schemes http
 // @Description Get all payments by room_id
 // @Param id path int true "Room ID"
 // @Tags payment
+// @Param include_deleted query bool false "Include soft deleted rows"
 // @Produce json
-// @Success 200 {array} types.Payment "ok"
+// @Success 200 {array} Payment "ok"
 // @Failure 400 {object} types.APIResponse "Incorrect parameter"
 // @Failure 404 {object} types.APIResponse "No rows"
 // @Failure 500 {object} types.APIResponse "Internal server error"
+// @Security BearerAuth
 // @Router /payment/room/id/{id} [get]
-func RoutePaymentGetAllByRoomID(g *gin.Context) {
-	id := g.Param("id")
-	if _, err := validators.Int64("id", id, false); err != nil {
-		g.JSON(http.StatusBadRequest, types.APIResponse{Error: err})
+func (s *Server) RoutePaymentGetAllByRoomID(g *gin.Context) {
+	include_deleted, apierr := includeDeleted(g)
+	if apierr != nil {
+		g.JSON(http.StatusBadRequest, types.APIResponse{Error: apierr})
+		return
+	}
+
+	id, apierr := validators.Int64("id", g.Param("id"), false)
+	if apierr != nil {
+		g.JSON(http.StatusBadRequest, types.APIResponse{Error: apierr})
 		return
 	}
 
-	var ps []types.Payment
-	code, err := queryRows(&ps, paymentScanRows, SQLPaymentGetByRoomIDQuery, id)
+	ps, err := s.Payments.ByRoomID(g, id, include_deleted)
 	if err != nil {
-		g.JSON(code, types.APIResponse{Error: err})
+		repoError(g, err)
 		return
 	}
 
@@ -149,64 +233,76 @@ func RoutePaymentGetAllByRoomID(g *gin.Context) {
 	g.JSON(http.StatusOK, ps)
 }
 
-//go:embed sql/payment/payment_get_by_id.sql
-var SQLPaymentGetByPaymentIDQuery string
-
 // PaymentGetByID godoc
 // @Summary Get payment by payment_id
 // @Schemes http
 // @Description Get payment by payment_id
 // @Param id path int true "Payment ID"
 // @Tags payment
+// @Param include_deleted query bool false "Include soft deleted rows"
 // @Produce json
-// @Success 200 {object} types.Payment "ok"
+// @Success 200 {object} Payment "ok"
+// @Header 200 {string} ETag "Version of the row, for If-Match"
 // @Failure 400 {object} types.APIResponse "Incorrect parameter"
 // @Failure 404 {object} types.APIResponse "No rows"
 // @Failure 500 {object} types.APIResponse "Internal server error"
+// @Security BearerAuth
 // @Router /payment/id/{id} [get]
-func RoutePaymentGetByID(g *gin.Context) {
-	id := g.Param("id")
-	if _, err := validators.Int64("id", id, false); err != nil {
-		g.JSON(http.StatusBadRequest, types.APIResponse{Error: err})
+func (s *Server) RoutePaymentGetByID(g *gin.Context) {
+	include_deleted, apierr := includeDeleted(g)
+	if apierr != nil {
+		g.JSON(http.StatusBadRequest, types.APIResponse{Error: apierr})
+		return
+	}
+
+	id, apierr := validators.Int64("id", g.Param("id"), false)
+	if apierr != nil {
+		g.JSON(http.StatusBadRequest, types.APIResponse{Error: apierr})
 		return
 	}
 
-	var p types.Payment
-	code, err := queryRow(&p, paymentScanRow, SQLPaymentGetByPaymentIDQuery, id)
+	p, err := s.Payments.ByID(g, id, include_deleted)
 	if err != nil {
-		g.JSON(code, types.APIResponse{Error: err})
+		repoError(g, err)
 		return
 	}
 
+	g.Header("ETag", etag(p))
 	g.JSON(http.StatusOK, p)
 }
 
-//go:embed sql/payment/payment_get_by_date.sql
-var SQLPaymentGetByDateQuery string
-
 // PaymentByDate godoc
 // @Summary Get payment by date
 // @Schemes http
 // @Description Get payment by date
 // @Param date path string true "Date 'yyyy-mm-dd hh:mm:ss'"
 // @Tags payment
+// @Param include_deleted query bool false "Include soft deleted rows"
 // @Produce json
-// @Success 200 {array} types.Payment "ok"
+// @Success 200 {array} Payment "ok"
 // @Failure 400 {object} types.APIResponse "Incorrect parameter"
 // @Failure 404 {object} types.APIResponse "No rows"
 // @Failure 500 {object} types.APIResponse "Internal server error"
+// @Security BearerAuth
 // @Router /payment/date/{date} [get]
-func RoutePaymentGetByDate(g *gin.Context) {
-	date, err := validators.Date("date", g.Param("date"), false)
-	if err != nil {
-		g.JSON(http.StatusBadRequest, types.APIResponse{Error: err})
+func (s *Server) RoutePaymentGetByDate(g *gin.Context) {
+	include_deleted, apierr := includeDeleted(g)
+	if apierr != nil {
+		g.JSON(http.StatusBadRequest, types.APIResponse{Error: apierr})
 		return
 	}
 
-	var ps []types.Payment
-	code, err := queryRows(&ps, paymentScanRows, SQLPaymentGetByDateQuery, date.Year(), date.Month(), date.Day())
+	date, apierr := validators.Date("date", g.Param("date"), false)
+	if apierr != nil {
+		g.JSON(http.StatusBadRequest, types.APIResponse{Error: apierr})
+		return
+	}
+
+	start, end := dayRange(date)
+
+	ps, err := s.Payments.ByDateRange(g, start, end, include_deleted)
 	if err != nil {
-		g.JSON(code, types.APIResponse{Error: err})
+		repoError(g, err)
 		return
 	}
 
@@ -221,177 +317,234 @@ func RoutePaymentGetByDate(g *gin.Context) {
 }
 
 // PaymentByDateRange godoc
-// @Summary Get payment by date range
+// @Summary Get payments by date range
 // @Schemes http
-// @Description Get payment by date range
-// @Param date_start formData string true "Date 'yyyy-mm-dd hh:mm:ss'"
-// @Param date_end formData string true "Date 'yyyy-mm-dd hh:mm:ss'"
+// @Description Get payments made from date_start up to, but not including, date_end
+// @Param date_start query string true "Date 'yyyy-mm-dd hh:mm:ss'"
+// @Param date_end query string true "Date 'yyyy-mm-dd hh:mm:ss'"
+// @Param include_deleted query bool false "Include soft deleted rows"
 // @Tags payment
 // @Produce json
-// @Success 200 {array} types.Payment "ok"
+// @Success 200 {array} Payment "ok"
 // @Failure 400 {object} types.APIResponse "Incorrect parameter"
 // @Failure 404 {object} types.APIResponse "No rows"
 // @Failure 500 {object} types.APIResponse "Internal server error"
-// @Router /payment/date/range [post]
-func RoutePaymentGetByDateRange(g *gin.Context) {
+// @Security BearerAuth
+// @Router /payment/date/range [get]
+func (s *Server) RoutePaymentGetByDateRange(g *gin.Context) {
 	var (
-		date_start time.Time
-		date_end   time.Time
-		err        *api_errors.APIError
+		date_start      time.Time
+		date_end        time.Time
+		include_deleted bool
+		apierr          *api_errors.APIError
 	)
-	date_start, err = validators.Date("date_start", g.PostForm("date_start"), true)
-	if err != nil {
+	date_start, date_end, apierr = validateDateRange(g)
+	if apierr != nil {
 		goto skip
 	}
 
-	date_end, err = validators.Date("date_end", g.PostForm("date_end"), true)
-	if err != nil {
-		goto skip
-	}
+	include_deleted, apierr = includeDeleted(g)
 
 skip:
-	if err != nil {
-		g.JSON(http.StatusBadRequest, types.APIResponse{Error: err})
+	if apierr != nil {
+		g.JSON(http.StatusBadRequest, types.APIResponse{Error: apierr})
 		return
 	}
 
-	var ps []types.Payment
-	code, err := queryRows(
-		&ps, paymentScanRows,
-		SQLPaymentGetAllQuery,
-	)
+	ps, err := s.Payments.ByDateRange(g, date_start, date_end, include_deleted)
 	if err != nil {
-		g.JSON(code, types.APIResponse{Error: err})
+		repoError(g, err)
 		return
 	}
 
-	var _ps []types.Payment
-	for _, p := range ps {
-		if !p.Date.After(date_start) && !p.Date.Equal(date_start) {
-			continue
-		}
-		if !date_end.After(p.Date) && !p.Date.Equal(date_end) {
-			continue
-		}
-		_ps = append(_ps, p)
-	}
-
-	if len(_ps) == 0 {
+	if len(ps) == 0 {
 		g.JSON(http.StatusNotFound, types.APIResponse{
 			Error: api_errors.NewErrSQLNoRows("No rows"),
 		})
 		return
 	}
 
-	g.JSON(http.StatusOK, _ps)
+	g.JSON(http.StatusOK, ps)
+}
+
+// samePayment reports whether a repeated create request describes the payment
+// already stored under its external ID.
+func samePayment(a, b Payment) bool {
+	return a.ClientID == b.ClientID &&
+		a.RoomID == b.RoomID &&
+		a.Date.Equal(b.Date) &&
+		a.Currency == b.Currency &&
+		a.received() == b.received() &&
+		a.ProviderChargeID == b.ProviderChargeID
 }
 
-//go:embed sql/payment/payment_insert.sql
-var SQLPaymentPostCreateQuery string
+// PaymentCreateRequest is the body of RoutePaymentPostCreate.
+type PaymentCreateRequest struct {
+	ClientID         int64     `json:"client_id" bind:"required"`
+	RoomID           int64     `json:"room_id" bind:"required"`
+	Date             time.Time `json:"payment_date" bind:"required"`
+	Amount           Money     `json:"payment_amount" bind:"required"`
+	ProviderChargeID string    `json:"payment_provider_charge_id"`
+	ExternalID       string    `json:"payment_external_id"`
+	Currency         string    `json:"payment_currency" bind:"currency"`
+}
 
 // PaymentCreate godoc
 // @Summary Create new payment
 // @Schemes http
-// @Description Create new payment
+// @Description Create new payment. A payment with an external ID is created only once: repeating the request returns the original payment, and a different payment under the same ID is rejected.
+// @Description A payment in a currency other than the currency of the building of the room is converted at the exchange rates in effect on its date.
+// @Param Idempotency-Key header string false "External ID, same as payment_external_id"
 // @Param client_id formData int true "Client ID"
 // @Param room_id formData int true "Room ID"
 // @Param payment_date formData string false "Date 'yyyy-mm-dd hh:mm:ss'"
-// @Param payment_amount formData number true "Amount"
+// @Param payment_amount formData string true "Amount in payment_currency, e.g. '120.50'"
+// @Param payment_provider_charge_id formData string false "Payment provider charge ID"
+// @Param payment_external_id formData string false "External transaction ID"
+// @Param payment_currency formData string false "ISO 4217 code of the currency received, the currency of the building by default"
 // @Tags payment
+// @Accept x-www-form-urlencoded,json
 // @Produce json
-// @Success 201 {object} types.Payment "New payment"
-// @Failure 400 {object} types.APIResponse "Incorrect parameter"
+// @Success 200 {object} Payment "Existing payment with the same external ID"
+// @Success 201 {object} Payment "New payment"
+// @Header 201 {string} Location "URL of the new payment"
+// @Header 201 {string} ETag "ETag of the new payment"
+// @Failure 400 {object} ValidationResponse "Incorrect parameter"
+// @Failure 409 {object} types.APIResponse "External ID used by another payment"
+// @Failure 422 {object} types.APIResponse "No such client or room, or no exchange rate of the currency"
 // @Failure 500 {object} types.APIResponse "Internal server error"
+// @Security BearerAuth
 // @Router /payment/new [post]
-func RoutePaymentPostCreate(g *gin.Context) {
+func (s *Server) RoutePaymentPostCreate(g *gin.Context) {
 	var (
-		apierr         *api_errors.APIError
-		client_id      int64
-		room_id        int64
-		payment_date   time.Time
-		payment_amount float64
+		errs   paramErrors
+		req    PaymentCreateRequest
+		header = g.GetHeader("Idempotency-Key")
 	)
 
-	client_id, apierr = validators.Int64("client_id", g.PostForm("client_id"), true)
-	if apierr != nil {
-		goto skip
-	}
+	errs.add(bindRequest(g, &req)...)
 
-	room_id, apierr = validators.Int64("room_id", g.PostForm("room_id"), true)
-	if apierr != nil {
-		goto skip
+	external_id := req.ExternalID
+	if header != "" {
+		if external_id != "" && external_id != header {
+			errs.add(api_errors.NewErrIncorrectParam("payment_external_id: differs from Idempotency-Key"))
+		}
+		external_id = header
 	}
 
-	payment_date, apierr = validators.Date("payment_date", g.PostForm("payment_date"), true)
-	if apierr != nil {
-		goto skip
+	if errs.respond(g) {
+		return
 	}
 
-	payment_amount, apierr = validators.Float64("payment_amount", g.PostForm("payment_amount"), true)
-
-skip:
-	if apierr != nil {
-		g.JSON(http.StatusBadRequest, types.APIResponse{Error: apierr})
-		return
+	p := Payment{
+		ClientID:         req.ClientID,
+		RoomID:           req.RoomID,
+		Date:             req.Date,
+		Amount:           req.Amount,
+		ProviderChargeID: req.ProviderChargeID,
+		ExternalID:       external_id,
 	}
 
-	res, err := db.Exec(SQLPaymentPostCreateQuery, client_id, room_id, payment_date, payment_amount)
+	ledger, err := s.roomCurrency(g, p.RoomID)
 	if err != nil {
-		log.Println("[ERROR] RoutePaymentPostCreate db.Exec():", err)
-		g.JSON(http.StatusInternalServerError, types.APIResponse{
-			Error: api_errors.NewErrSQLInternalError(err.Error()),
-		})
+		repoError(g, err)
 		return
 	}
+	p.LedgerCurrency = ledger
+	p.Currency = cmp.Or(req.Currency, ledger)
 
-	payment_id, err := res.LastInsertId()
-	if err != nil {
-		logError("sql.Result LastInsertId(): ", err)
-		g.JSON(http.StatusInternalServerError, types.APIResponse{
-			Error: api_errors.NewErrSQLInternalError(err.Error()),
-		})
-		return
+	if external_id != "" {
+		orig, err := s.Payments.ByExternalID(g, external_id)
+		switch {
+		case err == nil:
+			paymentReplay(g, p, orig)
+			return
+
+		case !errors.Is(err, ErrNotFound):
+			repoError(g, err)
+			return
+		}
 	}
 
-	p := types.Payment{
-		ID:       payment_id,
-		ClientID: client_id,
-		RoomID:   room_id,
-		Date:     payment_date,
-		Amount:   payment_amount,
+	if p.Currency != ledger {
+		rate, err := s.exchangeRate(g, p.Currency, ledger, p.Date)
+		if err != nil {
+			if errors.Is(err, ErrNotFound) {
+				g.JSON(http.StatusUnprocessableEntity, types.APIResponse{
+					Error: NewErrInvalidReference("payment_currency: no exchange rate of " + p.Currency + " to " + ledger + " on payment_date"),
+				})
+				return
+			}
+
+			logError("exchangeRate():", err)
+			g.JSON(http.StatusInternalServerError, types.APIResponse{
+				Error: api_errors.NewErrSQLInternalError(err.Error()),
+			})
+			return
+		}
+
+		p.convert(req.Amount, rate)
+	}
+
+	if err := s.Payments.Create(g, &p); err != nil {
+		// A concurrent request with the same external ID won the insert.
+		if external_id != "" && errors.Is(err, ErrDuplicate) {
+			orig, err := s.Payments.ByExternalID(g, external_id)
+			if err != nil {
+				repoError(g, err)
+				return
+			}
+
+			paymentReplay(g, p, orig)
+			return
+		}
+
+		repoError(g, err)
+		return
 	}
 
 	logInfo(fmt.Sprintf("Created new payment: %#v", p))
-	g.JSON(http.StatusCreated, p)
+	g.Header("ETag", etag(p))
+	created(g, fmt.Sprintf("id/%d", p.ID), p)
 }
 
-//go:embed sql/payment/payment_delete.sql
-var SQLPaymentDeleteQuery string
+// paymentReplay answers a create request whose external ID is already taken.
+func paymentReplay(g *gin.Context, p, orig Payment) {
+	if !samePayment(p, orig) {
+		g.JSON(http.StatusConflict, types.APIResponse{
+			Error: NewErrConflict("payment_external_id: already used by a different payment"),
+		})
+		return
+	}
+
+	logInfo("Replayed payment with payment_external_id: ", orig.ExternalID)
+	g.JSON(http.StatusOK, orig)
+}
 
 // PaymentDelete godoc
 // @Summary Delete payment
 // @Schemes http
-// @Description Delete payment by payment_id
+// @Description Soft delete payment by payment_id. Use restore to undo.
 // @Param id path int true "Payment ID"
+// @Param If-Match header string false "ETag of the row; the delete fails if the row has changed"
 // @Tags payment
 // @Produce json
 // @Success 200 {object} types.APIResponse "Deleted"
 // @Failure 400 {object} types.APIResponse "Incorrect parameter"
+// @Failure 404 {object} types.APIResponse "No payment"
+// @Failure 412 {object} types.APIResponse "Row has changed"
 // @Failure 500 {object} types.APIResponse "Internal server error"
+// @Security BearerAuth
 // @Router /payment/id/{id} [delete]
-func RoutePaymentDelete(g *gin.Context) {
-	id := g.Param("id")
-	if _, err := validators.Int64("id", id, false); err != nil {
-		g.JSON(http.StatusBadRequest, types.APIResponse{Error: err})
+func (s *Server) RoutePaymentDelete(g *gin.Context) {
+	id, apierr := validators.Int64("id", g.Param("id"), false)
+	if apierr != nil {
+		g.JSON(http.StatusBadRequest, types.APIResponse{Error: apierr})
 		return
 	}
 
-	_, err := db.Exec(SQLPaymentDeleteQuery, id)
-	if err != nil {
-		logError("db.Exec():", err)
-		g.JSON(http.StatusInternalServerError, types.APIResponse{
-			Error: api_errors.NewErrSQLInternalError(err.Error()),
-		})
+	if err := s.Payments.Delete(g, id, g.GetHeader("If-Match")); err != nil {
+		repoError(g, err)
 		return
 	}
 
@@ -399,140 +552,122 @@ func RoutePaymentDelete(g *gin.Context) {
 	g.JSON(http.StatusOK, types.APIResponse{Message: "ok"})
 }
 
-//go:embed sql/payment/payment_patch.sql
-var SQLPaymentPatchQuery string
+// PaymentRestore godoc
+// @Summary Restore payment
+// @Schemes http
+// @Description Restore soft deleted payment by payment_id
+// @Tags payment
+// @Param id path int true "Payment ID"
+// @Produce json
+// @Success 200 {object} types.APIResponse "Restored"
+// @Failure 400 {object} types.APIResponse "Incorrect parameter"
+// @Failure 404 {object} types.APIResponse "No deleted payment"
+// @Failure 500 {object} types.APIResponse "Internal server error"
+// @Security BearerAuth
+// @Router /payment/id/{id}/restore [post]
+func (s *Server) RoutePaymentRestore(g *gin.Context) {
+	id, apierr := validators.Int64("id", g.Param("id"), false)
+	if apierr != nil {
+		g.JSON(http.StatusBadRequest, types.APIResponse{Error: apierr})
+		return
+	}
+
+	if err := s.Payments.Restore(g, id); err != nil {
+		if errors.Is(err, ErrNotFound) {
+			g.JSON(http.StatusNotFound, types.APIResponse{
+				Error: api_errors.NewErrSQLNoRows("No deleted payment"),
+			})
+			return
+		}
+
+		repoError(g, err)
+		return
+	}
+
+	logInfo("Restored payment with payment_id: ", id)
+	g.JSON(http.StatusOK, types.APIResponse{Message: "ok"})
+}
+
+// PaymentPatchRequest is the body of RoutePaymentPatch.
+type PaymentPatchRequest struct {
+	ClientID *int64     `json:"client_id"`
+	RoomID   *int64     `json:"room_id"`
+	Date     *time.Time `json:"payment_date"`
+	Amount   *Money     `json:"payment_amount"`
+}
 
 // PaymentPatch godoc
 // @Summary Patch payment
 // @Schemes http
-// @Description Patch payment by payment_id
+// @Description Patch payment by payment_id. The amount of a payment in a foreign currency is in that currency and converted at the rate the payment was converted at.
+// @Description A payment can't be moved to a room of a building with another currency.
 // @Tags payment
 // @Param id path int true "Payment ID"
+// @Param If-Match header string false "ETag of the row; the patch fails if the row has changed"
 // @Param client_id formData int false "Client ID"
 // @Param room_id formData int false "Room ID"
 // @Param payment_date formData string false "Date 'yyyy-mm-dd hh:mm:ss'"
-// @Param payment_amount formData number false "Payment amount"
+// @Param payment_amount formData string false "Amount in payment_currency, e.g. '120.50'"
+// @Accept x-www-form-urlencoded,json,application/merge-patch+json
 // @Produce json
-// @Success 200 {object} types.APIResponse "Updated"
-// @Failure 400 {object} types.APIResponse "Incorrect parameter"
+// @Success 200 {object} Payment "Updated"
+// @Header 200 {string} ETag "Version of the row, for If-Match"
+// @Failure 400 {object} ValidationResponse "Incorrect parameter"
 // @Failure 404 {object} types.APIResponse "No rows"
+// @Failure 409 {object} types.APIResponse "Room in a building with another currency"
+// @Failure 412 {object} types.APIResponse "Row has changed"
+// @Failure 422 {object} types.APIResponse "No such client or room"
 // @Failure 500 {object} types.APIResponse "Internal server error"
+// @Security BearerAuth
 // @Router /payment/id/{id} [patch]
-func RoutePaymentPatch(g *gin.Context) {
+func (s *Server) RoutePaymentPatch(g *gin.Context) {
 	var (
-		apierr     *api_errors.APIError
-		payment_id int64
-		temp       string
-		cache      = map[string]any{}
+		errs paramErrors
+		req  PaymentPatchRequest
 	)
 
-	payment_id, apierr = validators.Int64("id", g.Param("id"), false)
-	if apierr != nil {
-		goto skip
-	}
+	payment_id, apierr := validators.Int64("id", g.Param("id"), false)
+	errs.add(apierr)
+	errs.add(bindRequest(g, &req)...)
 
-	temp = g.PostForm("client_id")
-	if temp != "" {
-		var v int64
-
-		v, apierr = validators.Int64("client_id", temp, false)
-		if apierr != nil {
-			goto skip
-		}
-
-		cache["client_id"] = v
+	if errs.respond(g) {
+		return
 	}
 
-	temp = g.PostForm("room_id")
-	if temp != "" {
-		var v int64
-
-		v, apierr = validators.Int64("room_id", temp, false)
-		if apierr != nil {
-			goto skip
+	p, err := s.Payments.Update(g, payment_id, g.GetHeader("If-Match"), func(p *Payment) {
+		if req.ClientID != nil {
+			p.ClientID = *req.ClientID
 		}
-
-		cache["room_id"] = v
-	}
-
-	temp = g.PostForm("payment_date")
-	if temp != "" {
-		var v time.Time
-
-		v, apierr = validators.Date("payment_date", temp, false)
-		if apierr != nil {
-			goto skip
+		if req.RoomID != nil {
+			p.RoomID = *req.RoomID
 		}
-
-		cache["payment_date"] = v.Format(validators.DATE_FORMAT)
-	}
-
-	temp = g.PostForm("payment_amount")
-	if temp != "" {
-		var v float64
-
-		v, apierr = validators.Float64("payment_amount", temp, false)
-		if apierr != nil {
-			goto skip
+		if req.Date != nil {
+			p.Date = *req.Date
 		}
-
-		cache["payment_amount"] = v
-	}
-
-skip:
-	if apierr != nil {
-		g.JSON(http.StatusBadRequest, types.APIResponse{Error: apierr})
-		return
-	}
-
-	var p types.Payment
-	code, apierr := queryRow(&p, paymentScanRow, SQLPaymentGetByPaymentIDQuery, payment_id)
-	if apierr != nil {
-		g.JSON(code, types.APIResponse{Error: apierr})
-		return
-	}
-
-	if _, ok := cache["client_id"]; !ok {
-		cache["client_id"] = p.ClientID
-	}
-	if _, ok := cache["room_id"]; !ok {
-		cache["room_id"] = p.RoomID
-	}
-	if _, ok := cache["payment_date"]; !ok {
-		cache["payment_date"] = p.Date.Format(validators.DATE_FORMAT)
-	}
-	if _, ok := cache["payment_amount"]; !ok {
-		cache["payment_amount"] = p.Amount
-	}
-
-	_, err := db.Exec(
-		SQLPaymentPatchQuery,
-		cache["client_id"],
-		cache["room_id"],
-		cache["payment_date"],
-		cache["payment_amount"],
-		payment_id,
-	)
+		if req.Amount != nil && p.ExchangeRate != nil {
+			p.convert(*req.Amount, *p.ExchangeRate)
+		} else if req.Amount != nil {
+			p.Amount = *req.Amount
+		}
+	})
 	if err != nil {
-		g.JSON(http.StatusInternalServerError, types.APIResponse{
-			Error: api_errors.NewErrSQLInternalError(err.Error()),
-		})
+		repoError(g, err)
 		return
 	}
 
-	g.JSON(http.StatusOK, types.APIResponse{Message: "ok"})
+	g.Header("ETag", etag(p))
+	g.JSON(http.StatusOK, p)
 }
 
-func init() {
-	r := api.Group("/payment")
-
-	r.GET("/all", RoutePaymentGetAll)
-	r.GET("/client/id/:id", RoutePaymentGetAllByClientID)
-	r.GET("/room/id/:id", RoutePaymentGetAllByRoomID)
-	r.GET("/date/:date", RoutePaymentGetByDate)
-	r.POST("/date/range", RoutePaymentGetByDateRange)
-	r.GET("/id/:id", RoutePaymentGetByID)
-	r.DELETE("/id/:id", RoutePaymentDelete)
-	r.PATCH("/id/:id", RoutePaymentPatch)
-	r.POST("/new", RoutePaymentPostCreate)
+func (s *Server) paymentRoutes(r *gin.RouterGroup) {
+	r.GET("/all", s.RoutePaymentGetAll)
+	r.GET("/client/id/:id", s.RoutePaymentGetAllByClientID)
+	r.GET("/room/id/:id", s.RoutePaymentGetAllByRoomID)
+	r.GET("/date/range", s.RoutePaymentGetByDateRange)
+	r.GET("/date/:date", s.RoutePaymentGetByDate)
+	r.GET("/id/:id", s.RoutePaymentGetByID)
+	r.DELETE("/id/:id", s.RoutePaymentDelete)
+	r.POST("/id/:id/restore", s.RoutePaymentRestore)
+	r.PATCH("/id/:id", s.RoutePaymentPatch)
+	r.POST("/new", s.RoutePaymentPostCreate)
 }
@@ -0,0 +1,235 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	types "github.com/snakehunterr/hacs_db_types"
+	api_errors "github.com/snakehunterr/hacs_db_types/errors"
+	validators "github.com/snakehunterr/hacs_db_types/validators"
+)
+
+// Rate is the exchange rate of a currency in the base currency from its date
+// until the next rate of the same currency. Payments are converted between
+// two currencies at the cross of their rates in effect on the payment date.
+type Rate struct {
+	ID         int64        `json:"rate_id"`
+	Currency   string       `json:"rate_currency"`
+	Date       time.Time    `json:"rate_date"`
+	Value      ExchangeRate `json:"rate_value" swaggertype:"string"`
+	LastEdited time.Time    `json:"last_edited"`
+}
+
+// RateAll godoc
+// @Summary Get all exchange rates
+// @Schemes http
+// @Description Get all exchange rates, optionally of one currency
+// @Tags rate
+// @Param currency query string false "ISO 4217 currency code"
+// @Produce json
+// @Success 200 {array} Rate "ok"
+// @Failure 400 {object} types.APIResponse "Incorrect parameter"
+// @Failure 404 {object} types.APIResponse "No rows"
+// @Failure 500 {object} types.APIResponse "Internal server error"
+// @Security BearerAuth
+// @Router /rate/all [get]
+func (s *Server) RouteRateGetAll(g *gin.Context) {
+	currency := g.Query("currency")
+	if currency != "" {
+		var apierr *api_errors.APIError
+		if currency, apierr = validateCurrency("currency", currency, false); apierr != nil {
+			g.JSON(http.StatusBadRequest, types.APIResponse{Error: apierr})
+			return
+		}
+	}
+
+	rs, err := s.Rates.All(g, currency)
+	if err != nil {
+		repoError(g, err)
+		return
+	}
+
+	if len(rs) == 0 {
+		g.JSON(http.StatusNotFound, types.APIResponse{
+			Error: api_errors.NewErrSQLNoRows("No rows"),
+		})
+		return
+	}
+
+	g.JSON(http.StatusOK, rs)
+}
+
+// RateByID godoc
+// @Summary Get exchange rate by rate_id
+// @Schemes http
+// @Description Get exchange rate by rate_id
+// @Tags rate
+// @Param id path int true "Rate ID"
+// @Produce json
+// @Success 200 {object} Rate "ok"
+// @Failure 400 {object} types.APIResponse "Incorrect parameter"
+// @Failure 404 {object} types.APIResponse "No rows"
+// @Failure 500 {object} types.APIResponse "Internal server error"
+// @Security BearerAuth
+// @Router /rate/id/{id} [get]
+func (s *Server) RouteRateGetByID(g *gin.Context) {
+	id, apierr := validators.Int64("id", g.Param("id"), false)
+	if apierr != nil {
+		g.JSON(http.StatusBadRequest, types.APIResponse{Error: apierr})
+		return
+	}
+
+	r, err := s.Rates.ByID(g, id)
+	if err != nil {
+		repoError(g, err)
+		return
+	}
+
+	g.JSON(http.StatusOK, r)
+}
+
+// exchangeRate returns the rate of currency from in currency to at date,
+// crossed through the base currency. It fails with ErrNotFound when either
+// of them has no rate in effect.
+func (s *Server) exchangeRate(ctx context.Context, from, to string, date time.Time) (ExchangeRate, error) {
+	var rates [2]ExchangeRate
+
+	for i, currency := range []string{from, to} {
+		rates[i] = exchangeRateOne
+		if currency == baseCurrency() {
+			continue
+		}
+
+		r, err := s.Rates.Effective(ctx, currency, date)
+		if err != nil {
+			return 0, err
+		}
+		rates[i] = r.Value
+	}
+
+	return rates[0].cross(rates[1]), nil
+}
+
+// RateEffective godoc
+// @Summary Get exchange rate in effect on a day
+// @Schemes http
+// @Description Get the latest rate of currency whose date is not after day
+// @Tags rate
+// @Param currency path string true "ISO 4217 currency code"
+// @Param day path string true "Day 'yyyy-mm-dd'"
+// @Produce json
+// @Success 200 {object} Rate "ok"
+// @Failure 400 {object} ValidationResponse "Incorrect parameter"
+// @Failure 404 {object} types.APIResponse "No rows"
+// @Failure 500 {object} types.APIResponse "Internal server error"
+// @Security BearerAuth
+// @Router /rate/currency/{currency}/day/{day} [get]
+func (s *Server) RouteRateGetEffective(g *gin.Context) {
+	var errs paramErrors
+
+	currency, apierr := validateCurrency("currency", g.Param("currency"), false)
+	errs.add(apierr)
+	day, apierr := validateDay("day", g.Param("day"), false)
+	errs.add(apierr)
+
+	if errs.respond(g) {
+		return
+	}
+
+	r, err := s.Rates.Effective(g, currency, day)
+	if err != nil {
+		repoError(g, err)
+		return
+	}
+
+	g.JSON(http.StatusOK, r)
+}
+
+// RateCreateRequest is the body of RouteRatePostCreate.
+type RateCreateRequest struct {
+	Currency string       `json:"rate_currency" bind:"required,currency"`
+	Date     time.Time    `json:"rate_date" bind:"required,day"`
+	Value    ExchangeRate `json:"rate_value" bind:"required"`
+}
+
+// RateCreate godoc
+// @Summary Create new exchange rate
+// @Schemes http
+// @Description Create new exchange rate of a currency in the base currency. Payments already converted keep the rate they were converted at.
+// @Tags rate
+// @Param rate_currency formData string true "ISO 4217 currency code, other than the base currency"
+// @Param rate_date formData string true "Day 'yyyy-mm-dd' from which the rate applies"
+// @Param rate_value formData string true "Price of one unit of the currency in the base currency, e.g. '0.012345'"
+// @Accept x-www-form-urlencoded,json
+// @Produce json
+// @Success 201 {object} Rate "New rate"
+// @Header 201 {string} Location "URL of the new rate"
+// @Failure 400 {object} ValidationResponse "Incorrect parameter"
+// @Failure 409 {object} types.APIResponse "Currency already has a rate on that day"
+// @Failure 500 {object} types.APIResponse "Internal server error"
+// @Security BearerAuth
+// @Router /rate/new [post]
+func (s *Server) RouteRatePostCreate(g *gin.Context) {
+	var (
+		errs paramErrors
+		req  RateCreateRequest
+	)
+
+	errs.add(bindRequest(g, &req)...)
+	if req.Currency != "" && req.Currency == baseCurrency() {
+		errs.add(api_errors.NewErrIncorrectParam("rate_currency: the base currency has no rate"))
+	}
+
+	if errs.respond(g) {
+		return
+	}
+
+	r := Rate{Currency: req.Currency, Date: req.Date, Value: req.Value}
+	if err := s.Rates.Create(g, &r); err != nil {
+		repoError(g, err)
+		return
+	}
+
+	logInfo(fmt.Sprintf("Created new exchange rate: %#v", r))
+	created(g, fmt.Sprintf("id/%d", r.ID), r)
+}
+
+// RateDelete godoc
+// @Summary Delete exchange rate
+// @Schemes http
+// @Description Delete exchange rate by rate_id. Payments already converted at it keep the rate.
+// @Tags rate
+// @Param id path int true "Rate ID"
+// @Produce json
+// @Success 200 {object} types.APIResponse "Deleted"
+// @Failure 400 {object} types.APIResponse "Incorrect parameter"
+// @Failure 404 {object} types.APIResponse "No rows"
+// @Failure 500 {object} types.APIResponse "Internal server error"
+// @Security BearerAuth
+// @Router /rate/id/{id} [delete]
+func (s *Server) RouteRateDelete(g *gin.Context) {
+	id, apierr := validators.Int64("id", g.Param("id"), false)
+	if apierr != nil {
+		g.JSON(http.StatusBadRequest, types.APIResponse{Error: apierr})
+		return
+	}
+
+	if err := s.Rates.Delete(g, id); err != nil {
+		repoError(g, err)
+		return
+	}
+
+	logInfo("Deleted exchange rate with rate_id: ", id)
+	g.JSON(http.StatusOK, types.APIResponse{Message: "ok"})
+}
+
+func (s *Server) rateRoutes(r *gin.RouterGroup) {
+	r.GET("/all", s.RouteRateGetAll)
+	r.GET("/id/:id", s.RouteRateGetByID)
+	r.GET("/currency/:currency/day/:day", s.RouteRateGetEffective)
+	r.POST("/new", s.RouteRatePostCreate)
+	r.DELETE("/id/:id", s.RouteRateDelete)
+}
@@ -1,12 +1,13 @@
 package main
 
 import (
-	"database/sql"
+	"cmp"
+	"context"
 	"errors"
 	"fmt"
 	"net/http"
-
-	_ "embed"
+	"strconv"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	types "github.com/snakehunterr/hacs_db_types"
@@ -14,120 +15,221 @@ import (
 	validators "github.com/snakehunterr/hacs_db_types/validators"
 )
 
-func roomScanRow(r *types.Room, row *sql.Row) error {
-	return row.Scan(&r.ID, &r.ClientID, &r.PeopleCount, &r.Area, &r.LastEdited)
+// Room has the fields of types.Room, with the area as Area, and is a room of
+// a building that may be soft deleted. Number is the number of the room
+// within its building, e.g. of a flat; ID is unique over all buildings.
+// PeopleCount and Area are those of the latest version of the attributes of
+// the room, in effect since AttributesFrom.
+type Room struct {
+	ID             int64      `json:"room_id"`
+	ClientID       int64      `json:"client_id"`
+	PeopleCount    uint8      `json:"room_people_count"`
+	Area           Area       `json:"room_area" swaggertype:"string"`
+	LastEdited     time.Time  `json:"last_edited"`
+	BuildingID     int64      `json:"building_id"`
+	Number         string     `json:"room_number"`
+	AttributesFrom time.Time  `json:"room_attributes_from"`
+	DeletedAt      *time.Time `json:"deleted_at,omitempty"`
 }
 
-func roomScanRows(rs *[]types.Room, rows *sql.Rows) error {
-	if rs == nil {
-		return errors.New("*[]types.Room is nil")
-	}
+func (r Room) active() bool {
+	return r.DeletedAt == nil
+}
 
-	_rs := *rs
-	for rows.Next() {
-		var r types.Room
+// RoomAttributes is a version of the people count and area of a room, in
+// effect from From until the next version of the room.
+type RoomAttributes struct {
+	ID          int64     `json:"attribute_id"`
+	RoomID      int64     `json:"room_id"`
+	From        time.Time `json:"room_attributes_from"`
+	PeopleCount uint8     `json:"room_people_count"`
+	Area        Area      `json:"room_area" swaggertype:"string"`
+	LastEdited  time.Time `json:"last_edited"`
+}
 
-		if err := rows.Scan(&r.ID, &r.ClientID, &r.PeopleCount, &r.Area, &r.LastEdited); err != nil {
-			return err
+// attributesOn returns the version of as, ordered by From, in effect on day.
+func attributesOn(as []RoomAttributes, day time.Time) (a RoomAttributes, ok bool) {
+	for _, v := range as {
+		if v.From.After(day) {
+			break
 		}
-
-		_rs = append(_rs, r)
+		a, ok = v, true
 	}
+	return a, ok
+}
 
-	*rs = _rs
-	return nil
+// roomsOn returns the rooms rs with the people count and area in effect on
+// day. Rooms without attributes by then are left out.
+func (s *Server) roomsOn(ctx context.Context, rs []Room, day time.Time) ([]Room, error) {
+	var on []Room
+	for _, r := range rs {
+		as, err := s.Rooms.Attributes(ctx, r.ID)
+		if err != nil {
+			return nil, err
+		}
+
+		if a, ok := attributesOn(as, day); ok {
+			r.PeopleCount, r.Area, r.AttributesFrom = a.PeopleCount, a.Area, a.From
+			on = append(on, r)
+		}
+	}
+	return on, nil
 }
 
-//go:embed sql/room/room_get_all.sql
-var SQLRoomGetAllQuery string
+// roomListFields are the sort fields of /room/all.
+var roomListFields = listFields[Room]{
+	id:   "room_id",
+	idOf: func(r Room) int64 { return r.ID },
+	sorts: map[string]sortField[Room]{
+		"room_id":           {"room_id", sortInt, func(r Room) any { return r.ID }},
+		"building_id":       {"building_id", sortInt, func(r Room) any { return r.BuildingID }},
+		"room_number":       {"room_number", sortString, func(r Room) any { return r.Number }},
+		"client_id":         {"client_id", sortInt, func(r Room) any { return r.ClientID }},
+		"room_area":         {"room_area", sortArea, func(r Room) any { return r.Area }},
+		"room_people_count": {"room_people_count", sortInt, func(r Room) any { return int64(r.PeopleCount) }},
+		"last_edited":       {"last_edited", sortTime, func(r Room) any { return r.LastEdited }},
+	},
+}
 
 // RoomAll godoc
 // @Summary Get all rooms
 // @Schemes http
-// @Description Get all rooms from MySQL
+// @Description Get a page of rooms, optionally filtered by building, client and area
 // @Tags room
+// @Param building_id query int false "Building ID"
+// @Param client_id query int false "Client telegram ID"
+// @Param min_area query number false "Minimum room area"
+// @Param max_area query number false "Maximum room area"
+// @Param include_deleted query bool false "Include soft deleted rows"
+// @Param sort query string false "Sort field: room_id, building_id, room_number, client_id, room_area, room_people_count, last_edited; room_id by default"
+// @Param order query string false "Sort order: asc (default) or desc"
+// @Param limit query int false "Page size, 50 by default, up to 500"
+// @Param cursor query string false "X-Next-Cursor of the previous page"
 // @Produce json
-// @Success 200 {array} types.Room "ok"
-// @Failure 404 {object} types.APIResponse "No rows"
+// @Success 200 {array} Room "ok"
+// @Header 200 {integer} X-Total-Count "Number of matching rows over all pages"
+// @Header 200 {string} X-Next-Cursor "Cursor of the next page, absent on the last page"
+// @Failure 400 {object} types.APIResponse "Incorrect parameter"
 // @Failure 500 {object} types.APIResponse "Internal server error"
+// @Security BearerAuth
 // @Router /room/all [get]
-func RouteRoomGetAll(g *gin.Context) {
-	var rs []types.Room
+func (s *Server) RouteRoomGetAll(g *gin.Context) {
+	var (
+		f      RoomFilter
+		q      PageQuery
+		apierr *api_errors.APIError
+	)
 
-	code, err := queryRows(&rs, roomScanRows, SQLRoomGetAllQuery)
-	if err != nil {
-		g.JSON(code, types.APIResponse{Error: err})
+	q, apierr = roomListFields.pageQuery(g)
+	if apierr != nil {
+		goto skip
+	}
+
+	f.BuildingID, apierr = optionalInt64(g, "building_id")
+	if apierr != nil {
+		goto skip
+	}
+
+	f.ClientID, apierr = optionalInt64(g, "client_id")
+	if apierr != nil {
+		goto skip
+	}
+
+	f.MinArea, apierr = optionalArea(g, "min_area")
+	if apierr != nil {
+		goto skip
+	}
+
+	f.MaxArea, apierr = optionalArea(g, "max_area")
+	if apierr != nil {
+		goto skip
+	}
+
+	f.IncludeDeleted, apierr = includeDeleted(g)
+
+skip:
+	if apierr != nil {
+		g.JSON(http.StatusBadRequest, types.APIResponse{Error: apierr})
 		return
 	}
 
-	if len(rs) == 0 {
-		g.JSON(http.StatusNotFound, types.APIResponse{
-			Error: api_errors.NewErrSQLNoRows("No rows"),
-		})
+	page, err := s.Rooms.List(g, f, q)
+	if err != nil {
+		repoError(g, err)
 		return
 	}
 
-	g.JSON(http.StatusOK, rs)
+	listJSON(g, page)
 }
 
-//go:embed sql/room/room_get_by_id.sql
-var SQLRoomGetByIDQuery string
-
 // RoomByID godoc
 // @Summary Get room by room_id
 // @Schemes http
 // @Description Get room by room_id
 // @Tags room
 // @Param id path int true "Room ID"
+// @Param include_deleted query bool false "Include soft deleted rows"
 // @Produce json
-// @Success 200 {object} types.Room "ok"
+// @Success 200 {object} Room "ok"
+// @Header 200 {string} ETag "Version of the row, for If-Match"
 // @Failure 400 {object} types.APIResponse "Incorrect parameter"
 // @Failure 404 {object} types.APIResponse "No rows"
 // @Failure 500 {object} types.APIResponse "Internal server error"
+// @Security BearerAuth
 // @Router /room/id/{id} [get]
-func RouteRoomGetByID(g *gin.Context) {
-	id := g.Param("id")
-	if _, apierr := validators.Int64("id", id, false); apierr != nil {
+func (s *Server) RouteRoomGetByID(g *gin.Context) {
+	include_deleted, apierr := includeDeleted(g)
+	if apierr != nil {
 		g.JSON(http.StatusBadRequest, types.APIResponse{Error: apierr})
 		return
 	}
 
-	var r types.Room
-	code, err := queryRow(&r, roomScanRow, SQLRoomGetByIDQuery, id)
+	id, apierr := validators.Int64("id", g.Param("id"), false)
+	if apierr != nil {
+		g.JSON(http.StatusBadRequest, types.APIResponse{Error: apierr})
+		return
+	}
+
+	r, err := s.Rooms.ByID(g, id, include_deleted)
 	if err != nil {
-		g.JSON(code, types.APIResponse{Error: err})
+		repoError(g, err)
 		return
 	}
 
+	g.Header("ETag", etag(r))
 	g.JSON(http.StatusOK, r)
 }
 
-//go:embed sql/room/room_get_by_client_id.sql
-var SQLRoomGetByClientIDQuery string
-
 // RoomByClientID godoc
 // @Summary Get rooms by client_id
 // @Schemes http
-// @Description Get rooms by client_id
+// @Description Get the rooms a client is a member of today, with the roles of the client in each and whether it is responsible for paying for it
 // @Tags room
 // @Param id path int true "Client ID"
+// @Param include_deleted query bool false "Include soft deleted rows"
 // @Produce json
-// @Success 200 {array} types.Room "ok"
+// @Success 200 {array} ClientRoom "ok"
 // @Failure 400 {object} types.APIResponse "Incorrect parameter"
 // @Failure 404 {object} types.APIResponse "No rows"
 // @Failure 500 {object} types.APIResponse "Internal server error"
+// @Security BearerAuth
 // @Router /room/client/id/{id} [get]
-func RouteRoomGetByClientID(g *gin.Context) {
-	id := g.Param("id")
-	if _, err := validators.Int64("id", id, false); err != nil {
-		g.JSON(http.StatusBadRequest, types.APIResponse{Error: err})
+func (s *Server) RouteRoomGetByClientID(g *gin.Context) {
+	include_deleted, apierr := includeDeleted(g)
+	if apierr != nil {
+		g.JSON(http.StatusBadRequest, types.APIResponse{Error: apierr})
+		return
+	}
+
+	id, apierr := validators.Int64("id", g.Param("id"), false)
+	if apierr != nil {
+		g.JSON(http.StatusBadRequest, types.APIResponse{Error: apierr})
 		return
 	}
 
-	var rs []types.Room
-	code, err := queryRows(&rs, roomScanRows, SQLRoomGetByClientIDQuery, id)
+	rs, err := s.clientRooms(g, id, today(), include_deleted)
 	if err != nil {
-		g.JSON(code, types.APIResponse{Error: err})
+		repoError(g, err)
 		return
 	}
 
@@ -141,97 +243,152 @@ func RouteRoomGetByClientID(g *gin.Context) {
 	g.JSON(http.StatusOK, rs)
 }
 
-//go:embed sql/room/room_insert.sql
-var SQLRoomPostCreateQuery string
+// RoomAttributes godoc
+// @Summary Get room attribute history
+// @Schemes http
+// @Description Get the versions of the people count and area of a room, oldest first. Each is in effect from room_attributes_from until the next one.
+// @Tags room
+// @Param id path int true "Room ID"
+// @Produce json
+// @Success 200 {array} RoomAttributes "ok"
+// @Failure 400 {object} types.APIResponse "Incorrect parameter"
+// @Failure 404 {object} types.APIResponse "No rows"
+// @Failure 500 {object} types.APIResponse "Internal server error"
+// @Security BearerAuth
+// @Router /room/id/{id}/attributes [get]
+func (s *Server) RouteRoomGetAttributes(g *gin.Context) {
+	id, apierr := validators.Int64("id", g.Param("id"), false)
+	if apierr != nil {
+		g.JSON(http.StatusBadRequest, types.APIResponse{Error: apierr})
+		return
+	}
+
+	as, err := s.Rooms.Attributes(g, id)
+	if err != nil {
+		repoError(g, err)
+		return
+	}
+
+	if len(as) == 0 {
+		g.JSON(http.StatusNotFound, types.APIResponse{
+			Error: api_errors.NewErrSQLNoRows("No rows"),
+		})
+		return
+	}
+
+	g.JSON(http.StatusOK, as)
+}
+
+// validateAttributesFrom checks the day from which new attributes of a room
+// are in effect: the room keeps its latest version, so it can't be a day to
+// come.
+func validateAttributesFrom(from time.Time) *api_errors.APIError {
+	if from.After(today()) {
+		return api_errors.NewErrIncorrectParam("room_attributes_from: can't be after today")
+	}
+	return nil
+}
+
+// RoomCreateRequest is the body of RouteRoomPostCreate. A missing number is
+// the room_id, and the attributes are in effect from the first day of the
+// current month by default.
+type RoomCreateRequest struct {
+	BuildingID     int64      `json:"building_id" bind:"required"`
+	Number         string     `json:"room_number"`
+	ClientID       int64      `json:"client_id" bind:"required"`
+	Area           Area       `json:"room_area" bind:"required"`
+	PeopleCount    uint8      `json:"room_people_count" bind:"required"`
+	AttributesFrom *time.Time `json:"room_attributes_from" bind:"day"`
+}
 
 // RoomCreate godoc
 // @Summary Create new room
 // @Schemes http
-// @Description Create new room
+// @Description Create new room. The client of the room becomes its first owner.
 // @Tags room
 // @Param id path int true "Room ID"
-// @Param client_id formData int true "Client ID"
+// @Param building_id formData int true "Building ID"
+// @Param room_number formData string false "Room number within the building, the room ID by default"
+// @Param client_id formData int true "ID of the client the room is registered to"
 // @Param room_people_count formData int true "People living in room"
-// @Param room_area formData number true "Room area"
+// @Param room_area formData string true "Room area in m², at most 2 decimals"
+// @Param room_attributes_from formData string false "Day 'yyyy-mm-dd' from which the people count and area are in effect, not after today; the first day of the current month by default"
+// @Accept x-www-form-urlencoded,json
 // @Produce json
-// @Success 201 {object} types.APIResponse "Created"
-// @Failure 400 {object} types.APIResponse "Missing parameter"
-// @Failure 400 {object} types.APIResponse "Incorrect parameter"
+// @Success 201 {object} Room "New room"
+// @Header 201 {string} Location "URL of the new room"
+// @Header 201 {string} ETag "ETag of the new room"
+// @Failure 400 {object} ValidationResponse "Missing parameter"
+// @Failure 400 {object} ValidationResponse "Incorrect parameter"
+// @Failure 409 {object} types.APIResponse "Room or room number already exists"
+// @Failure 422 {object} types.APIResponse "No such building or client"
 // @Failure 500 {object} types.APIResponse "Internal server error"
+// @Security BearerAuth
 // @Router /room/id/{id} [post]
-func RouteRoomPostCreate(g *gin.Context) {
+func (s *Server) RouteRoomPostCreate(g *gin.Context) {
 	var (
-		apierr       *api_errors.APIError
-		room_id      int64
-		client_id    int64
-		room_area    float64
-		people_count uint8
+		errs paramErrors
+		req  RoomCreateRequest
 	)
 
-	room_id, apierr = validators.Int64("id", g.Param("id"), false)
-	if apierr != nil {
-		goto skip
-	}
+	room_id, apierr := validators.Int64("id", g.Param("id"), false)
+	errs.add(apierr)
+	errs.add(bindRequest(g, &req)...)
 
-	client_id, apierr = validators.Int64("client_id", g.PostForm("client_id"), true)
-	if apierr != nil {
-		goto skip
+	from := thisMonth()
+	if req.AttributesFrom != nil {
+		from = *req.AttributesFrom
+		errs.add(validateAttributesFrom(from))
 	}
 
-	room_area, apierr = validators.Float64("room_area", g.PostForm("room_area"), true)
-	if apierr != nil {
-		goto skip
+	if errs.respond(g) {
+		return
 	}
 
-	people_count, apierr = validators.Uint8("room_people_count", g.PostForm("room_people_count"), true)
-
-skip:
-	if apierr != nil {
-		g.JSON(http.StatusBadRequest, types.APIResponse{
-			Error: apierr,
-		})
-		return
+	r := Room{
+		ID:             room_id,
+		ClientID:       req.ClientID,
+		Area:           req.Area,
+		PeopleCount:    req.PeopleCount,
+		BuildingID:     req.BuildingID,
+		Number:         cmp.Or(req.Number, strconv.FormatInt(room_id, 10)),
+		AttributesFrom: from,
 	}
 
-	_, err := db.Exec(SQLRoomPostCreateQuery, room_id, client_id, people_count, room_area)
-	if err != nil {
-		logError("db.Exec(): ", err)
-		g.JSON(http.StatusInternalServerError, types.APIResponse{
-			Error: api_errors.NewErrSQLInternalError(err.Error()),
-		})
+	if err := s.Rooms.Create(g, &r); err != nil {
+		repoError(g, err)
 		return
 	}
 
-	logInfo(fmt.Sprintf("Created room: %#v", types.Room{ID: room_id, ClientID: client_id, Area: room_area, PeopleCount: people_count}))
-	g.JSON(http.StatusCreated, types.APIResponse{Message: "ok"})
+	logInfo(fmt.Sprintf("Created room: %#v", r))
+	g.Header("ETag", etag(r))
+	created(g, strconv.FormatInt(r.ID, 10), r)
 }
 
-//go:embed sql/room/room_delete.sql
-var SQLRoomDeleteQuery string
-
 // RoomDelete godoc
 // @Summary Delete room by room_id
 // @Schemes http
-// @Description Delete room by room_id
+// @Description Soft delete room by room_id. Use restore to undo.
 // @Tags room
 // @Param id path int true "Room ID"
+// @Param If-Match header string false "ETag of the row; the delete fails if the row has changed"
 // @Produce json
 // @Success 200 {object} types.APIResponse "Deleted"
 // @Failure 400 {object} types.APIResponse "Incorrect parameter"
+// @Failure 404 {object} types.APIResponse "No room"
+// @Failure 412 {object} types.APIResponse "Row has changed"
 // @Failure 500 {object} types.APIResponse "Internal server error"
+// @Security BearerAuth
 // @Router /room/id/{id} [delete]
-func RouteRoomDelete(g *gin.Context) {
-	id := g.Param("id")
-	if _, err := validators.Int64("id", id, false); err != nil {
-		g.JSON(http.StatusBadRequest, types.APIResponse{Error: err})
+func (s *Server) RouteRoomDelete(g *gin.Context) {
+	id, apierr := validators.Int64("id", g.Param("id"), false)
+	if apierr != nil {
+		g.JSON(http.StatusBadRequest, types.APIResponse{Error: apierr})
+		return
 	}
 
-	_, err := db.Exec(SQLRoomDeleteQuery, id)
-	if err != nil {
-		logError("db.Exec():", err)
-		g.JSON(http.StatusInternalServerError, types.APIResponse{
-			Error: api_errors.NewErrSQLInternalError(err.Error()),
-		})
+	if err := s.Rooms.Delete(g, id, g.GetHeader("If-Match")); err != nil {
+		repoError(g, err)
 		return
 	}
 
@@ -239,118 +396,157 @@ func RouteRoomDelete(g *gin.Context) {
 	g.JSON(http.StatusOK, types.APIResponse{Message: "ok"})
 }
 
-//go:embed sql/room/room_patch.sql
-var SQLRoomPatchQuery string
+// RoomRestore godoc
+// @Summary Restore room
+// @Schemes http
+// @Description Restore soft deleted room by room_id. Rooms of a deleted client are restored with the client.
+// @Tags room
+// @Param id path int true "Room ID"
+// @Produce json
+// @Success 200 {object} types.APIResponse "Restored"
+// @Failure 400 {object} types.APIResponse "Incorrect parameter"
+// @Failure 404 {object} types.APIResponse "No deleted room"
+// @Failure 500 {object} types.APIResponse "Internal server error"
+// @Security BearerAuth
+// @Router /room/id/{id}/restore [post]
+func (s *Server) RouteRoomRestore(g *gin.Context) {
+	id, apierr := validators.Int64("id", g.Param("id"), false)
+	if apierr != nil {
+		g.JSON(http.StatusBadRequest, types.APIResponse{Error: apierr})
+		return
+	}
+
+	if err := s.Rooms.Restore(g, id); err != nil {
+		if errors.Is(err, ErrNotFound) {
+			g.JSON(http.StatusNotFound, types.APIResponse{
+				Error: api_errors.NewErrSQLNoRows("No deleted room"),
+			})
+			return
+		}
+
+		repoError(g, err)
+		return
+	}
+
+	logInfo("Restored room with room_id: ", id)
+	g.JSON(http.StatusOK, types.APIResponse{Message: "ok"})
+}
+
+// RoomPatchRequest is the body of RouteRoomPatch.
+type RoomPatchRequest struct {
+	Number         *string    `json:"room_number"`
+	ClientID       *int64     `json:"client_id"`
+	Area           *Area      `json:"room_area"`
+	PeopleCount    *uint8     `json:"room_people_count"`
+	AttributesFrom *time.Time `json:"room_attributes_from" bind:"day"`
+}
 
 // RoomPatch godoc
 // @Summary Patch room
 // @Schemes http
-// @Description Patch room by room_id
+// @Description Patch room by room_id. A room can't be moved to another building. Changing client_id leaves the members of the room as they are.
+// @Description A new room_area or room_people_count is recorded as a version of the attributes of the room in effect from room_attributes_from, replacing a version of that day. Fields left out keep their values of that day. A version before the latest one only corrects the history: the room keeps the values of its latest version.
 // @Tags room
 // @Param id path int true "Room ID"
-// @Param client_id formData int false "Client ID"
-// @Param room_area formData number false "Room area"
+// @Param If-Match header string false "ETag of the row; the patch fails if the row has changed"
+// @Param room_number formData string false "Room number within the building"
+// @Param client_id formData int false "ID of the client the room is registered to"
+// @Param room_area formData string false "Room area in m², at most 2 decimals"
 // @Param room_people_count formData int false "People living in room"
+// @Param room_attributes_from formData string false "Day 'yyyy-mm-dd' from which room_area and room_people_count are in effect, not after today; the first day of the current month by default"
+// @Accept x-www-form-urlencoded,json,application/merge-patch+json
 // @Produce json
-// @Success 200 {object} types.APIResponse "Updated"
-// @Failure 400 {object} types.APIResponse "Incorrect parameter"
+// @Success 200 {object} Room "Updated"
+// @Header 200 {string} ETag "Version of the row, for If-Match"
+// @Failure 400 {object} ValidationResponse "Incorrect parameter"
 // @Failure 404 {object} types.APIResponse "Record not founded in DB"
+// @Failure 409 {object} types.APIResponse "Room number already exists"
+// @Failure 412 {object} types.APIResponse "Row has changed"
 // @Failure 500 {object} types.APIResponse "Internal server error"
+// @Security BearerAuth
 // @Router /room/id/{id} [patch]
-func RouteRoomPatch(g *gin.Context) {
+func (s *Server) RouteRoomPatch(g *gin.Context) {
 	var (
-		apierr  *api_errors.APIError
-		room_id int64
-		temp    string
-		cache   = map[string]any{}
+		errs paramErrors
+		req  RoomPatchRequest
 	)
 
-	room_id, apierr = validators.Int64("id", g.Param("id"), false)
-	if apierr != nil {
-		goto skip
-	}
-
-	temp = g.PostForm("client_id")
-	if temp != "" {
-		var client_id int64
-
-		client_id, apierr = validators.Int64("client_id", temp, false)
-		if apierr != nil {
-			goto skip
-		}
-		cache["client_id"] = client_id
-	}
+	room_id, apierr := validators.Int64("id", g.Param("id"), false)
+	errs.add(apierr)
+	errs.add(bindRequest(g, &req)...)
 
-	temp = g.PostForm("room_area")
-	if temp != "" {
-		var room_area float64
+	attributes := req.Area != nil || req.PeopleCount != nil
+	from := thisMonth()
+	if req.AttributesFrom != nil {
+		from = *req.AttributesFrom
+		errs.add(validateAttributesFrom(from))
 
-		room_area, apierr = validators.Float64("room_area", temp, false)
-		if apierr != nil {
-			goto skip
+		if !attributes {
+			errs.add(api_errors.NewErrIncorrectParam("room_attributes_from: needs room_area or room_people_count"))
 		}
-		cache["room_area"] = room_area
 	}
 
-	temp = g.PostForm("room_people_count")
-	if temp != "" {
-		var people_count uint8
-
-		people_count, apierr = validators.Uint8("room_people_count", temp, false)
-		if apierr != nil {
-			goto skip
-		}
-		cache["people_count"] = people_count
-	}
-
-skip:
-	if apierr != nil {
-		g.JSON(http.StatusBadRequest, types.APIResponse{Error: apierr})
+	if errs.respond(g) {
 		return
 	}
 
-	var r types.Room
+	// The values left out are those in effect from the day of the new
+	// version, which are not the latest ones for a day in the past.
+	var base RoomAttributes
+	if attributes {
+		as, err := s.Rooms.Attributes(g, room_id)
+		if err != nil {
+			repoError(g, err)
+			return
+		}
 
-	if code, err := queryRow(&r, roomScanRow, SQLRoomGetByIDQuery, room_id); err != nil {
-		g.JSON(code, types.APIResponse{Error: err})
-		return
+		var ok bool
+		if base, ok = attributesOn(as, from); !ok && len(as) > 0 {
+			base = as[0]
+		}
 	}
 
-	if _, ok := cache["client_id"]; !ok {
-		cache["client_id"] = r.ClientID
-	}
-	if _, ok := cache["room_area"]; !ok {
-		cache["room_area"] = r.Area
-	}
-	if _, ok := cache["people_count"]; !ok {
-		cache["people_count"] = r.PeopleCount
-	}
+	r, err := s.Rooms.Update(g, room_id, g.GetHeader("If-Match"), func(r *Room) {
+		if req.Number != nil {
+			r.Number = *req.Number
+		}
+		if req.ClientID != nil {
+			r.ClientID = *req.ClientID
+		}
+		if !attributes {
+			return
+		}
 
-	_, err := db.Exec(
-		SQLRoomPatchQuery,
-		cache["client_id"],
-		cache["people_count"],
-		cache["room_area"],
-		room_id,
-	)
+		r.Area, r.PeopleCount, r.AttributesFrom = base.Area, base.PeopleCount, from
+		if req.Area != nil {
+			r.Area = *req.Area
+		}
+		if req.PeopleCount != nil {
+			r.PeopleCount = *req.PeopleCount
+		}
+	})
 	if err != nil {
-		logError("db.Exec():", err)
-		g.JSON(http.StatusInternalServerError, types.APIResponse{
-			Error: api_errors.NewErrSQLInternalError(err.Error()),
-		})
+		repoError(g, err)
 		return
 	}
 
-	g.JSON(http.StatusOK, types.APIResponse{Message: "ok"})
+	g.Header("ETag", etag(r))
+	g.JSON(http.StatusOK, r)
 }
 
-func init() {
-	r := api.Group("/room")
-
-	r.GET("/all", RouteRoomGetAll)
-	r.GET("/id/:id", RouteRoomGetByID)
-	r.POST("/id/:id", RouteRoomPostCreate)
-	r.DELETE("/id/:id", RouteRoomDelete)
-	r.PATCH("/id/:id", RouteRoomPatch)
-	r.GET("/client/id/:id", RouteRoomGetByClientID)
+func (s *Server) roomRoutes(r *gin.RouterGroup) {
+	r.GET("/all", s.RouteRoomGetAll)
+	r.GET("/id/:id", s.RouteRoomGetByID)
+	r.POST("/id/:id", s.RouteRoomPostCreate)
+	r.DELETE("/id/:id", s.RouteRoomDelete)
+	r.POST("/id/:id/restore", s.RouteRoomRestore)
+	r.PATCH("/id/:id", s.RouteRoomPatch)
+	r.GET("/id/:id/balance", s.RouteRoomGetBalance)
+	r.GET("/id/:id/attributes", s.RouteRoomGetAttributes)
+	r.GET("/client/id/:id", s.RouteRoomGetByClientID)
+	r.GET("/id/:id/members", s.RouteRoomGetMembers)
+	r.POST("/id/:id/members", s.RouteRoomMemberPostCreate)
+	r.GET("/member/id/:id", s.RouteRoomMemberGetByID)
+	r.PATCH("/member/id/:id", s.RouteRoomMemberPatch)
+	r.DELETE("/member/id/:id", s.RouteRoomMemberDelete)
 }
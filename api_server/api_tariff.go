@@ -0,0 +1,195 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	types "github.com/snakehunterr/hacs_db_types"
+	api_errors "github.com/snakehunterr/hacs_db_types/errors"
+	validators "github.com/snakehunterr/hacs_db_types/validators"
+)
+
+// Tariff is a single immutable version of the billing rates. A change of
+// rates is recorded as a new tariff, so tariff_id doubles as its version.
+// The rates are prices per m² and per person; charges round their products to
+// whole kopecks.
+//
+// A tariff of a building applies to its rooms only; one without BuildingID
+// applies to the rooms of every building that has no tariff of its own in
+// effect.
+type Tariff struct {
+	ID            int64     `json:"tariff_id"`
+	BuildingID    *int64    `json:"building_id,omitempty"`
+	AreaRate      UnitPrice `json:"tariff_area_rate" swaggertype:"string"`
+	PeopleRate    UnitPrice `json:"tariff_people_rate" swaggertype:"string"`
+	FixedFee      Money     `json:"tariff_fixed_fee" swaggertype:"string"`
+	EffectiveFrom time.Time `json:"tariff_effective_from"`
+	LastEdited    time.Time `json:"last_edited"`
+}
+
+// TariffAll godoc
+// @Summary Get all tariffs
+// @Schemes http
+// @Description Get all tariff versions, optionally only those that apply to a building: its own and those of every building
+// @Tags tariff
+// @Param building_id query int false "Building ID"
+// @Produce json
+// @Success 200 {array} Tariff "ok"
+// @Failure 400 {object} types.APIResponse "Incorrect parameter"
+// @Failure 404 {object} types.APIResponse "No rows"
+// @Failure 500 {object} types.APIResponse "Internal server error"
+// @Security BearerAuth
+// @Router /tariff/all [get]
+func (s *Server) RouteTariffGetAll(g *gin.Context) {
+	building_id, apierr := optionalInt64(g, "building_id")
+	if apierr != nil {
+		g.JSON(http.StatusBadRequest, types.APIResponse{Error: apierr})
+		return
+	}
+
+	ts, err := s.Tariffs.All(g, building_id)
+	if err != nil {
+		repoError(g, err)
+		return
+	}
+
+	if len(ts) == 0 {
+		g.JSON(http.StatusNotFound, types.APIResponse{
+			Error: api_errors.NewErrSQLNoRows("No rows"),
+		})
+		return
+	}
+
+	g.JSON(http.StatusOK, ts)
+}
+
+// TariffByID godoc
+// @Summary Get tariff by tariff_id
+// @Schemes http
+// @Description Get tariff by tariff_id
+// @Tags tariff
+// @Param id path int true "Tariff ID"
+// @Produce json
+// @Success 200 {object} Tariff "ok"
+// @Failure 400 {object} types.APIResponse "Incorrect parameter"
+// @Failure 404 {object} types.APIResponse "No rows"
+// @Failure 500 {object} types.APIResponse "Internal server error"
+// @Security BearerAuth
+// @Router /tariff/id/{id} [get]
+func (s *Server) RouteTariffGetByID(g *gin.Context) {
+	id, apierr := validators.Int64("id", g.Param("id"), false)
+	if apierr != nil {
+		g.JSON(http.StatusBadRequest, types.APIResponse{Error: apierr})
+		return
+	}
+
+	t, err := s.Tariffs.ByID(g, id)
+	if err != nil {
+		repoError(g, err)
+		return
+	}
+
+	g.JSON(http.StatusOK, t)
+}
+
+// TariffEffective godoc
+// @Summary Get tariff in effect for a period
+// @Schemes http
+// @Description Get the latest tariff whose effective date is not after the first day of period. With building_id, the latest tariff of the building wins over those of every building.
+// @Tags tariff
+// @Param period path string true "Period 'yyyy-mm'"
+// @Param building_id query int false "Building ID"
+// @Produce json
+// @Success 200 {object} Tariff "ok"
+// @Failure 400 {object} ValidationResponse "Incorrect parameter"
+// @Failure 404 {object} types.APIResponse "No rows"
+// @Failure 500 {object} types.APIResponse "Internal server error"
+// @Security BearerAuth
+// @Router /tariff/period/{period} [get]
+func (s *Server) RouteTariffGetEffective(g *gin.Context) {
+	var errs paramErrors
+
+	period, apierr := validatePeriod("period", g.Param("period"), false)
+	errs.add(apierr)
+	building_id, apierr := optionalInt64(g, "building_id")
+	errs.add(apierr)
+
+	if errs.respond(g) {
+		return
+	}
+
+	t, err := s.Tariffs.Effective(g, period, building_id)
+	if err != nil {
+		repoError(g, err)
+		return
+	}
+
+	g.JSON(http.StatusOK, t)
+}
+
+// TariffCreateRequest is the body of RouteTariffPostCreate. A tariff without
+// a building applies to every building.
+type TariffCreateRequest struct {
+	BuildingID    *int64    `json:"building_id"`
+	AreaRate      UnitPrice `json:"tariff_area_rate" bind:"required"`
+	PeopleRate    UnitPrice `json:"tariff_people_rate" bind:"required"`
+	FixedFee      Money     `json:"tariff_fixed_fee" bind:"required"`
+	EffectiveFrom time.Time `json:"tariff_effective_from" bind:"required,period"`
+}
+
+// TariffCreate godoc
+// @Summary Create new tariff version
+// @Schemes http
+// @Description Create new tariff version. Existing tariffs are never changed, so charges keep the rates they were computed with.
+// @Tags tariff
+// @Param building_id formData int false "Building ID, none for a tariff of every building"
+// @Param tariff_area_rate formData string true "Price per m² of room_area, at most 4 decimals"
+// @Param tariff_people_rate formData string true "Price per person of room_people_count, at most 4 decimals"
+// @Param tariff_fixed_fee formData string true "Fixed fee per room, e.g. '120.50'"
+// @Param tariff_effective_from formData string true "Period 'yyyy-mm' from which the tariff applies"
+// @Accept x-www-form-urlencoded,json
+// @Produce json
+// @Success 201 {object} Tariff "New tariff"
+// @Header 201 {string} Location "URL of the new tariff"
+// @Failure 400 {object} ValidationResponse "Incorrect parameter"
+// @Failure 422 {object} types.APIResponse "No such building"
+// @Failure 500 {object} types.APIResponse "Internal server error"
+// @Security BearerAuth
+// @Router /tariff/new [post]
+func (s *Server) RouteTariffPostCreate(g *gin.Context) {
+	var (
+		errs paramErrors
+		req  TariffCreateRequest
+	)
+
+	errs.add(bindRequest(g, &req)...)
+
+	if errs.respond(g) {
+		return
+	}
+
+	t := Tariff{
+		BuildingID:    req.BuildingID,
+		AreaRate:      req.AreaRate,
+		PeopleRate:    req.PeopleRate,
+		FixedFee:      req.FixedFee,
+		EffectiveFrom: req.EffectiveFrom,
+	}
+
+	if err := s.Tariffs.Create(g, &t); err != nil {
+		repoError(g, err)
+		return
+	}
+
+	logInfo(fmt.Sprintf("Created new tariff: %#v", t))
+	created(g, fmt.Sprintf("id/%d", t.ID), t)
+}
+
+func (s *Server) tariffRoutes(r *gin.RouterGroup) {
+	r.GET("/all", s.RouteTariffGetAll)
+	r.GET("/id/:id", s.RouteTariffGetByID)
+	r.GET("/period/:period", s.RouteTariffGetEffective)
+	r.POST("/new", s.RouteTariffPostCreate)
+}
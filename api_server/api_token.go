@@ -0,0 +1,180 @@
+package main
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	types "github.com/snakehunterr/hacs_db_types"
+	api_errors "github.com/snakehunterr/hacs_db_types/errors"
+	validators "github.com/snakehunterr/hacs_db_types/validators"
+)
+
+// APIToken lets a client call the API with the role of the client. Only the
+// hash of a token is stored; the token itself is returned once, on creation.
+type APIToken struct {
+	ID         int64     `json:"token_id"`
+	ClientID   int64     `json:"client_id"`
+	Name       string    `json:"token_name"`
+	Token      string    `json:"token,omitempty"`
+	LastEdited time.Time `json:"last_edited"`
+}
+
+func newToken() (string, error) {
+	bs := make([]byte, 32)
+	if _, err := rand.Read(bs); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(bs), nil
+}
+
+// TokenByID godoc
+// @Summary Get API token by token_id
+// @Schemes http
+// @Description Get API token by token_id. The token value is not returned.
+// @Tags token
+// @Param id path int true "Token ID"
+// @Produce json
+// @Success 200 {object} APIToken "ok"
+// @Failure 400 {object} types.APIResponse "Incorrect parameter"
+// @Failure 404 {object} types.APIResponse "No rows"
+// @Failure 500 {object} types.APIResponse "Internal server error"
+// @Security BearerAuth
+// @Router /token/id/{id} [get]
+func (s *Server) RouteTokenGetByID(g *gin.Context) {
+	id, apierr := validators.Int64("id", g.Param("id"), false)
+	if apierr != nil {
+		g.JSON(http.StatusBadRequest, types.APIResponse{Error: apierr})
+		return
+	}
+
+	t, err := s.Tokens.ByID(g, id)
+	if err != nil {
+		repoError(g, err)
+		return
+	}
+
+	g.JSON(http.StatusOK, t)
+}
+
+// TokenByClientID godoc
+// @Summary Get API tokens by client_id
+// @Schemes http
+// @Description Get API tokens of a client. Token values are not returned.
+// @Tags token
+// @Param id path int true "Client ID"
+// @Produce json
+// @Success 200 {array} APIToken "ok"
+// @Failure 400 {object} types.APIResponse "Incorrect parameter"
+// @Failure 404 {object} types.APIResponse "No rows"
+// @Failure 500 {object} types.APIResponse "Internal server error"
+// @Security BearerAuth
+// @Router /token/client/id/{id} [get]
+func (s *Server) RouteTokenGetByClientID(g *gin.Context) {
+	id, apierr := validators.Int64("id", g.Param("id"), false)
+	if apierr != nil {
+		g.JSON(http.StatusBadRequest, types.APIResponse{Error: apierr})
+		return
+	}
+
+	ts, err := s.Tokens.ByClientID(g, id)
+	if err != nil {
+		repoError(g, err)
+		return
+	}
+
+	if len(ts) == 0 {
+		g.JSON(http.StatusNotFound, types.APIResponse{
+			Error: api_errors.NewErrSQLNoRows("No rows"),
+		})
+		return
+	}
+
+	g.JSON(http.StatusOK, ts)
+}
+
+// TokenCreateRequest is the body of RouteTokenPostCreate.
+type TokenCreateRequest struct {
+	ClientID int64  `json:"client_id" bind:"required"`
+	Name     string `json:"token_name"`
+}
+
+// TokenCreate godoc
+// @Summary Create API token
+// @Schemes http
+// @Description Create API token for a client. The token is only returned by this call.
+// @Tags token
+// @Param client_id formData int true "Client ID"
+// @Param token_name formData string false "Token name"
+// @Accept x-www-form-urlencoded,json
+// @Produce json
+// @Success 201 {object} APIToken "New token"
+// @Header 201 {string} Location "URL of the new token"
+// @Failure 400 {object} ValidationResponse "Incorrect parameter"
+// @Failure 422 {object} types.APIResponse "No such client"
+// @Failure 500 {object} types.APIResponse "Internal server error"
+// @Security BearerAuth
+// @Router /token/new [post]
+func (s *Server) RouteTokenPostCreate(g *gin.Context) {
+	var req TokenCreateRequest
+	if errs := paramErrors(bindRequest(g, &req)); errs.respond(g) {
+		return
+	}
+
+	token, err := newToken()
+	if err != nil {
+		logError("newToken():", err)
+		g.JSON(http.StatusInternalServerError, types.APIResponse{
+			Error: api_errors.NewErrSQLInternalError(err.Error()),
+		})
+		return
+	}
+
+	t := APIToken{ClientID: req.ClientID, Name: req.Name, Token: token}
+	if err := s.Tokens.Create(g, &t); err != nil {
+		repoError(g, err)
+		return
+	}
+
+	logInfo(fmt.Sprintf("Created API token %d for client_id: %d", t.ID, t.ClientID))
+	created(g, fmt.Sprintf("id/%d", t.ID), t)
+}
+
+// TokenDelete godoc
+// @Summary Delete API token
+// @Schemes http
+// @Description Delete API token by token_id
+// @Tags token
+// @Param id path int true "Token ID"
+// @Produce json
+// @Success 200 {object} types.APIResponse "Deleted"
+// @Failure 400 {object} types.APIResponse "Incorrect parameter"
+// @Failure 404 {object} types.APIResponse "No rows"
+// @Failure 500 {object} types.APIResponse "Internal server error"
+// @Security BearerAuth
+// @Router /token/id/{id} [delete]
+func (s *Server) RouteTokenDelete(g *gin.Context) {
+	id, apierr := validators.Int64("id", g.Param("id"), false)
+	if apierr != nil {
+		g.JSON(http.StatusBadRequest, types.APIResponse{Error: apierr})
+		return
+	}
+
+	if err := s.Tokens.Delete(g, id); err != nil {
+		repoError(g, err)
+		return
+	}
+
+	logInfo("Deleted record api_token with token_id: ", id)
+	g.JSON(http.StatusOK, types.APIResponse{Message: "ok"})
+}
+
+func (s *Server) tokenRoutes(r *gin.RouterGroup) {
+	r.GET("/id/:id", s.RouteTokenGetByID)
+	r.GET("/client/id/:id", s.RouteTokenGetByClientID)
+	r.POST("/new", s.RouteTokenPostCreate)
+	r.DELETE("/id/:id", s.RouteTokenDelete)
+}
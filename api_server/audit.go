@@ -0,0 +1,334 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"database/sql"
+	_ "embed"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	types "github.com/snakehunterr/hacs_db_types"
+	api_errors "github.com/snakehunterr/hacs_db_types/errors"
+	validators "github.com/snakehunterr/hacs_db_types/validators"
+)
+
+// Every change of an entity is recorded in audit_log together with the row
+// images before and after it, in the transaction of the change itself.
+
+const (
+	AuditCreate  = "create"
+	AuditUpdate  = "update"
+	AuditDelete  = "delete"
+	AuditRestore = "restore"
+)
+
+const (
+	AuditLimitDefault = 100
+	AuditLimitMax     = 1000
+)
+
+type AuditRecord struct {
+	ID            int64           `json:"audit_id"`
+	Date          time.Time       `json:"audit_date"`
+	ActorRole     Role            `json:"actor_role"`
+	ActorClientID *int64          `json:"actor_client_id,omitempty"`
+	Action        string          `json:"audit_action"`
+	Entity        string          `json:"audit_entity"`
+	EntityID      string          `json:"audit_entity_id"`
+	Before        json.RawMessage `json:"audit_before,omitempty" swaggertype:"object"`
+	After         json.RawMessage `json:"audit_after,omitempty" swaggertype:"object"`
+	RequestID     string          `json:"request_id"`
+}
+
+const requestIDKey = "request_id"
+
+// RequestID takes the request id from the X-Request-ID header or makes a new
+// one, and sends it back in the same header.
+func RequestID(g *gin.Context) {
+	id := g.GetHeader("X-Request-ID")
+	if id == "" || len(id) > 64 {
+		bs := make([]byte, 16)
+		if _, err := rand.Read(bs); err != nil {
+			logError("RequestID rand.Read():", err)
+		}
+		id = hex.EncodeToString(bs)
+	}
+
+	g.Set(requestIDKey, id)
+	g.Header("X-Request-ID", id)
+}
+
+//go:embed sql/audit/audit_insert.sql
+var SQLAuditPostCreateQuery string
+
+// writeAudit records a change made in tx. The actor and request id are taken
+// from ctx, which is the *gin.Context of the request. A nil before or after
+// means the row did not exist at that point.
+func writeAudit(ctx context.Context, tx *sql.Tx, action, entity string, id, before, after any) error {
+	var images [2]any
+
+	for i, v := range []any{before, after} {
+		if v == nil {
+			continue
+		}
+
+		bs, err := json.Marshal(v)
+		if err != nil {
+			return err
+		}
+		images[i] = string(bs)
+	}
+
+	p, _ := ctx.Value(principalKey).(Principal)
+	request_id, _ := ctx.Value(requestIDKey).(string)
+
+	var client_id any
+	if p.ClientID != 0 {
+		client_id = p.ClientID
+	}
+
+	_, err := tx.Exec(
+		SQLAuditPostCreateQuery,
+		p.Role, client_id, action, entity, fmt.Sprint(id),
+		images[0], images[1], request_id,
+	)
+	return err
+}
+
+// auditEntity describes how to read an audited row by its id. The query of a
+// softDelete entity takes the include_deleted flag after the id.
+type auditEntity[T any] struct {
+	name       string
+	scan       func(*T, *sql.Row) error
+	query      string
+	softDelete bool
+}
+
+func (e auditEntity[T]) get(tx *sql.Tx, id any, lock bool) (*T, error) {
+	query := e.query
+	if lock {
+		query += " for update"
+	}
+
+	args := []any{id}
+	if e.softDelete {
+		args = append(args, true)
+	}
+
+	var v T
+	if err := e.scan(&v, tx.QueryRow(query, args...)); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &v, nil
+}
+
+// auditExec executes query on the row of e with the given id and records the
+// change in audit_log in the same transaction. For AuditCreate a nil id is
+// taken from the inserted row. Nothing is recorded if no row was affected.
+func auditExec[T any](
+	ctx context.Context,
+	db *sql.DB,
+	e auditEntity[T],
+	action string,
+	id any,
+	query string,
+	a ...any,
+) (sql.Result, error) {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	var before, after *T
+
+	if action != AuditCreate {
+		if before, err = e.get(tx, id, true); err != nil {
+			return nil, err
+		}
+	}
+
+	res, err := tx.Exec(query, a...)
+	if err != nil {
+		return nil, err
+	}
+
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return nil, err
+	}
+	if affected == 0 {
+		return res, tx.Commit()
+	}
+
+	if action == AuditCreate && id == nil {
+		if id, err = res.LastInsertId(); err != nil {
+			return nil, err
+		}
+	}
+
+	if after, err = e.get(tx, id, false); err != nil {
+		return nil, err
+	}
+
+	if err := writeAudit(ctx, tx, action, e.name, id, auditImage(before), auditImage(after)); err != nil {
+		return nil, err
+	}
+
+	return res, tx.Commit()
+}
+
+// auditTx is auditExec for changes of more than one statement: fn makes the
+// change in tx and reports whether anything was changed.
+func auditTx[T any](
+	ctx context.Context,
+	db *sql.DB,
+	e auditEntity[T],
+	action string,
+	id any,
+	fn func(tx *sql.Tx, before *T) (bool, error),
+) (bool, error) {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+
+	before, err := e.get(tx, id, true)
+	if err != nil {
+		return false, err
+	}
+
+	changed, err := fn(tx, before)
+	if err != nil || !changed {
+		return false, err
+	}
+
+	after, err := e.get(tx, id, false)
+	if err != nil {
+		return false, err
+	}
+
+	if err := writeAudit(ctx, tx, action, e.name, id, auditImage(before), auditImage(after)); err != nil {
+		return false, err
+	}
+
+	return true, tx.Commit()
+}
+
+// auditImage turns a missing row into an untyped nil for writeAudit.
+func auditImage[T any](v *T) any {
+	if v == nil {
+		return nil
+	}
+	return v
+}
+
+// Audit godoc
+// @Summary Get audit log
+// @Schemes http
+// @Description Get audit log records, newest first. All filters are optional.
+// @Tags audit
+// @Param entity query string false "Entity, e.g. payment"
+// @Param entity_id query string false "Entity ID"
+// @Param action query string false "Action: create, update, delete or restore"
+// @Param actor_role query string false "Actor role: service, admin or resident"
+// @Param actor_client_id query int false "Actor client ID"
+// @Param request_id query string false "Request ID"
+// @Param from query string false "From date 'yyyy-mm-dd hh:mm:ss', inclusive"
+// @Param to query string false "To date 'yyyy-mm-dd hh:mm:ss', exclusive"
+// @Param limit query int false "Max records, 100 by default, up to 1000"
+// @Produce json
+// @Success 200 {array} AuditRecord "ok"
+// @Failure 400 {object} types.APIResponse "Incorrect parameter"
+// @Failure 404 {object} types.APIResponse "No rows"
+// @Failure 500 {object} types.APIResponse "Internal server error"
+// @Security BearerAuth
+// @Router /audit [get]
+func (s *Server) RouteAuditGet(g *gin.Context) {
+	var (
+		apierr *api_errors.APIError
+		f      = AuditFilter{
+			Entity:    g.Query("entity"),
+			EntityID:  g.Query("entity_id"),
+			Action:    g.Query("action"),
+			Role:      g.Query("actor_role"),
+			RequestID: g.Query("request_id"),
+			Limit:     AuditLimitDefault,
+		}
+		from time.Time
+		to   time.Time
+		temp string
+	)
+
+	switch f.Action {
+	case "", AuditCreate, AuditUpdate, AuditDelete, AuditRestore:
+	default:
+		apierr = api_errors.NewErrIncorrectParam("action")
+		goto skip
+	}
+
+	temp = g.Query("actor_client_id")
+	if temp != "" {
+		f.ActorClientID, apierr = validators.Int64("actor_client_id", temp, false)
+		if apierr != nil {
+			goto skip
+		}
+	}
+
+	temp = g.Query("from")
+	if temp != "" {
+		from, apierr = validators.Date("from", temp, false)
+		if apierr != nil {
+			goto skip
+		}
+		f.From = &from
+	}
+
+	temp = g.Query("to")
+	if temp != "" {
+		to, apierr = validators.Date("to", temp, false)
+		if apierr != nil {
+			goto skip
+		}
+		f.To = &to
+	}
+
+	temp = g.Query("limit")
+	if temp != "" {
+		f.Limit, apierr = validators.Int64("limit", temp, false)
+		if apierr == nil && (f.Limit <= 0 || f.Limit > AuditLimitMax) {
+			apierr = api_errors.NewErrIncorrectParam("limit")
+		}
+	}
+
+skip:
+	if apierr != nil {
+		g.JSON(http.StatusBadRequest, types.APIResponse{Error: apierr})
+		return
+	}
+
+	as, err := s.Audit.List(g, f)
+	if err != nil {
+		repoError(g, err)
+		return
+	}
+
+	if len(as) == 0 {
+		g.JSON(http.StatusNotFound, types.APIResponse{
+			Error: api_errors.NewErrSQLNoRows("No rows"),
+		})
+		return
+	}
+
+	g.JSON(http.StatusOK, as)
+}
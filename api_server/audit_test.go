@@ -0,0 +1,117 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"testing"
+	"time"
+)
+
+// The audit log is only kept by the SQL repositories.
+
+func TestAuditDateRange(t *testing.T) {
+	h := serve(t, NewSQLServer(newSQLiteDB(t)))
+	call(t, h, "POST", "/api/building/new", url.Values{"building_name": {"Main"}}, http.StatusCreated, nil)
+
+	now := time.Now().UTC()
+	day := func(d int) string { return now.AddDate(0, 0, d).Format("2006-01-02 15:04:05") }
+
+	var as []AuditRecord
+	call(t, h, "GET", "/api/audit?"+url.Values{"from": {day(-1)}, "to": {day(1)}}.Encode(), nil, http.StatusOK, &as)
+	if len(as) != 1 || as[0].Entity != "building" {
+		t.Fatalf("records = %+v, want the building create", as)
+	}
+
+	call(t, h, "GET", "/api/audit?"+url.Values{"from": {day(1)}, "to": {day(2)}}.Encode(), nil, http.StatusNotFound, nil)
+	call(t, h, "GET", "/api/audit?"+url.Values{"from": {day(-2)}, "to": {day(-1)}}.Encode(), nil, http.StatusNotFound, nil)
+}
+
+func TestAuditRecord(t *testing.T) {
+	h := serve(t, NewSQLServer(newSQLiteDB(t)))
+	call(t, h, "POST", "/api/building/new", url.Values{"building_name": {"Main"}}, http.StatusCreated, nil)
+
+	w := request(h, testServiceToken, "PATCH", "/api/building/id/1", url.Values{"building_name": {"Other"}})
+	if w.Code != http.StatusOK {
+		t.Fatalf("PATCH building: got %d: %s", w.Code, w.Body)
+	}
+
+	var as []AuditRecord
+	call(t, h, "GET", "/api/audit?entity=building&entity_id=1&action=update", nil, http.StatusOK, &as)
+	if len(as) != 1 {
+		t.Fatalf("records = %+v, want one update", as)
+	}
+
+	var before, after Building
+	if err := json.Unmarshal(as[0].Before, &before); err != nil {
+		t.Fatal(err)
+	}
+	if err := json.Unmarshal(as[0].After, &after); err != nil {
+		t.Fatal(err)
+	}
+	if before.Name != "Main" || after.Name != "Other" {
+		t.Fatalf("images = %s, %s, want Main before and Other after", as[0].Before, as[0].After)
+	}
+	if as[0].ActorRole != RoleService || as[0].RequestID != w.Header().Get("X-Request-ID") {
+		t.Fatalf("record = %+v, want the service and request %s", as[0], w.Header().Get("X-Request-ID"))
+	}
+
+	call(t, h, "GET", "/api/audit?entity=building&action=delete", nil, http.StatusNotFound, nil)
+	call(t, h, "GET", "/api/audit?entity=room", nil, http.StatusNotFound, nil)
+	call(t, h, "GET", "/api/audit?action=undo", nil, http.StatusBadRequest, nil)
+}
+
+func TestAuditActor(t *testing.T) {
+	h := serve(t, NewSQLServer(newSQLiteDB(t)))
+	call(t, h, "POST", "/api/building/new", url.Values{"building_name": {"Main"}}, http.StatusCreated, nil)
+	admin := newClientToken(t, h, "1", true)
+
+	if w := request(h, admin, "PATCH", "/api/building/id/1", url.Values{"building_contact": {"+7 900"}}); w.Code != http.StatusOK {
+		t.Fatalf("admin PATCH building: got %d: %s", w.Code, w.Body)
+	}
+
+	var as []AuditRecord
+	call(t, h, "GET", "/api/audit?actor_role=admin&actor_client_id=1", nil, http.StatusOK, &as)
+	if len(as) != 1 || as[0].Entity != "building" || as[0].ActorClientID == nil || *as[0].ActorClientID != 1 {
+		t.Fatalf("records = %+v, want the building update of client 1", as)
+	}
+
+	call(t, h, "GET", "/api/audit?actor_client_id=2", nil, http.StatusNotFound, nil)
+	call(t, h, "GET", "/api/audit?actor_role=service&entity=building&action=update", nil, http.StatusNotFound, nil)
+}
+
+func TestAuditRollback(t *testing.T) {
+	db := newSQLiteDB(t)
+	h := serve(t, NewSQLServer(db))
+	seedRoom(t, h)
+
+	call(t, h, "POST", "/api/building/new", url.Values{"building_name": {"Euros"}, "building_currency": {"EUR"}}, http.StatusCreated, nil)
+	call(t, h, "POST", "/api/room/id/20", url.Values{
+		"building_id":       {"2"},
+		"client_id":         {"1"},
+		"room_people_count": {"1"},
+		"room_area":         {"30"},
+	}, http.StatusCreated, nil)
+	call(t, h, "POST", "/api/payment/new", url.Values{
+		"client_id":      {"1"},
+		"room_id":        {"10"},
+		"payment_date":   {"2025-02-10 10:00:00"},
+		"payment_amount": {"10"},
+	}, http.StatusCreated, nil)
+
+	// A change that fails leaves no record.
+	call(t, h, "PATCH", "/api/payment/id/1", url.Values{"room_id": {"20"}}, http.StatusConflict, nil)
+	call(t, h, "GET", "/api/audit?entity=payment&action=update", nil, http.StatusNotFound, nil)
+
+	// A record that can't be written rolls the change back.
+	if _, err := db.Exec(`create trigger audit_fail before insert on audit_log begin select raise(abort, 'audit_log is read-only'); end`); err != nil {
+		t.Fatal(err)
+	}
+	call(t, h, "PATCH", "/api/payment/id/1", url.Values{"payment_amount": {"20"}}, http.StatusInternalServerError, nil)
+
+	var p Payment
+	call(t, h, "GET", "/api/payment/id/1", nil, http.StatusOK, &p)
+	if p.Amount.String() != "10.00" {
+		t.Fatalf("amount = %s, want 10.00 unchanged", p.Amount)
+	}
+}
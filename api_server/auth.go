@@ -0,0 +1,187 @@
+package main
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	types "github.com/snakehunterr/hacs_db_types"
+	api_errors "github.com/snakehunterr/hacs_db_types/errors"
+)
+
+// Every /api route requires "Authorization: Bearer <token>". Services such as
+// the telegram bot use one of the tokens listed in DBAPI_SERVICE_TOKENS, while
+// clients use tokens from the api_token table and get the role of their
+// client.is_admin flag at the time of the request.
+
+type Role string
+
+const (
+	RoleService  Role = "service"
+	RoleAdmin    Role = "admin"
+	RoleResident Role = "resident"
+)
+
+// Principal is the caller of a request. ClientID is zero for services.
+type Principal struct {
+	Role     Role
+	ClientID int64
+}
+
+const principalKey = "principal"
+
+var serviceTokens = splitTokens(os.Getenv("DBAPI_SERVICE_TOKENS"))
+
+func splitTokens(s string) (ts []string) {
+	for _, t := range strings.Split(s, ",") {
+		if t = strings.TrimSpace(t); t != "" {
+			ts = append(ts, t)
+		}
+	}
+	return ts
+}
+
+func isServiceToken(token string) bool {
+	for _, t := range serviceTokens {
+		if subtle.ConstantTimeCompare([]byte(t), []byte(token)) == 1 {
+			return true
+		}
+	}
+	return false
+}
+
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+func bearerToken(header string) (string, bool) {
+	scheme, token, ok := strings.Cut(header, " ")
+	if !ok || !strings.EqualFold(scheme, "Bearer") {
+		return "", false
+	}
+
+	token = strings.TrimSpace(token)
+	return token, token != ""
+}
+
+func principalFrom(g *gin.Context) Principal {
+	p, _ := g.MustGet(principalKey).(Principal)
+	return p
+}
+
+// Authenticate resolves the bearer token of a request into its Principal.
+func (s *Server) Authenticate(g *gin.Context) {
+	header := g.GetHeader("Authorization")
+	if header == "" {
+		g.AbortWithStatusJSON(http.StatusUnauthorized, types.APIResponse{
+			Error: api_errors.NewErrEmptyParam("Authorization"),
+		})
+		return
+	}
+
+	token, ok := bearerToken(header)
+	if !ok {
+		g.AbortWithStatusJSON(http.StatusUnauthorized, types.APIResponse{
+			Error: api_errors.NewErrIncorrectParam("Authorization"),
+		})
+		return
+	}
+
+	if isServiceToken(token) {
+		g.Set(principalKey, Principal{Role: RoleService})
+		return
+	}
+
+	p, err := s.Access.Principal(g, hashToken(token))
+	if err != nil {
+		if errors.Is(err, ErrNotFound) {
+			g.AbortWithStatusJSON(http.StatusUnauthorized, types.APIResponse{
+				Error: api_errors.NewErrIncorrectParam("Authorization"),
+			})
+			return
+		}
+
+		logError("Authenticate Principal():", err)
+		g.AbortWithStatusJSON(http.StatusInternalServerError, types.APIResponse{
+			Error: api_errors.NewErrSQLInternalError(err.Error()),
+		})
+		return
+	}
+
+	g.Set(principalKey, p)
+}
+
+// ownerCheck reports whether a resident may access the route's resource.
+type ownerCheck func(s *Server, g *gin.Context, p Principal) (bool, error)
+
+// residentRoutes lists everything a resident can do, keyed by method and
+// route path. Services and admins are not restricted.
+var residentRoutes = map[string]ownerCheck{
+	"GET /api/client/id/:id":          (*Server).ownClient,
+	"GET /api/client/id/:id/balance":  (*Server).ownClient,
+	"GET /api/room/client/id/:id":     (*Server).ownClient,
+	"GET /api/room/id/:id":            (*Server).ownRoom,
+	"GET /api/room/id/:id/balance":    (*Server).ownRoom,
+	"GET /api/room/id/:id/members":    (*Server).ownRoom,
+	"GET /api/room/id/:id/attributes": (*Server).ownRoom,
+	"GET /api/payment/client/id/:id":  (*Server).ownClient,
+	"GET /api/payment/room/id/:id":    (*Server).ownRoom,
+	"GET /api/payment/id/:id":         (*Server).ownPayment,
+}
+
+func (s *Server) ownClient(g *gin.Context, p Principal) (bool, error) {
+	id, err := strconv.ParseInt(g.Param("id"), 10, 64)
+	return err == nil && id == p.ClientID, nil
+}
+
+// ownRoom lets the client a room is registered to and its members of today
+// access the room.
+func (s *Server) ownRoom(g *gin.Context, p Principal) (bool, error) {
+	id, err := strconv.ParseInt(g.Param("id"), 10, 64)
+	if err != nil {
+		return false, nil
+	}
+	return s.Access.OwnsRoom(g, p.ClientID, id, today())
+}
+
+func (s *Server) ownPayment(g *gin.Context, p Principal) (bool, error) {
+	id, err := strconv.ParseInt(g.Param("id"), 10, 64)
+	if err != nil {
+		return false, nil
+	}
+	return s.Access.OwnsPayment(g, p.ClientID, id, today())
+}
+
+// Authorize limits residents to the routes of residentRoutes.
+func (s *Server) Authorize(g *gin.Context) {
+	p := principalFrom(g)
+	if p.Role != RoleResident {
+		return
+	}
+
+	check, ok := residentRoutes[g.Request.Method+" "+g.FullPath()]
+	if !ok {
+		g.AbortWithStatusJSON(http.StatusForbidden, types.APIResponse{Error: NewErrForbidden("forbidden")})
+		return
+	}
+
+	ok, err := check(s, g, p)
+	if err != nil {
+		logError("Authorize ownerCheck():", err)
+		g.AbortWithStatusJSON(http.StatusInternalServerError, types.APIResponse{
+			Error: api_errors.NewErrSQLInternalError(err.Error()),
+		})
+		return
+	}
+
+	if !ok {
+		g.AbortWithStatusJSON(http.StatusForbidden, types.APIResponse{Error: NewErrForbidden("forbidden")})
+	}
+}
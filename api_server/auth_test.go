@@ -0,0 +1,191 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+
+	types "github.com/snakehunterr/hacs_db_types"
+)
+
+// newClientToken creates the client id with a room of the same id in
+// building 1 and returns an API token of the client.
+func newClientToken(t *testing.T, h http.Handler, id string, isAdmin bool) string {
+	t.Helper()
+
+	is_admin := "false"
+	if isAdmin {
+		is_admin = "true"
+	}
+
+	call(t, h, "POST", "/api/client/id/"+id, url.Values{
+		"client_name": {"Client " + id},
+		"is_admin":    {is_admin},
+	}, http.StatusCreated, nil)
+	call(t, h, "POST", "/api/room/id/"+id, url.Values{
+		"building_id":       {"1"},
+		"client_id":         {id},
+		"room_people_count": {"1"},
+		"room_area":         {"30"},
+	}, http.StatusCreated, nil)
+
+	var token APIToken
+	call(t, h, "POST", "/api/token/new", url.Values{"client_id": {id}}, http.StatusCreated, &token)
+	return token.Token
+}
+
+func TestAuthorize(t *testing.T) {
+	testBackends(t, func(t *testing.T, h http.Handler) {
+		call(t, h, "POST", "/api/building/new", url.Values{"building_name": {"Main"}}, http.StatusCreated, nil)
+		resident := newClientToken(t, h, "1", false)
+		other := newClientToken(t, h, "2", false)
+		admin := newClientToken(t, h, "3", true)
+
+		call(t, h, "POST", "/api/payment/new", url.Values{
+			"client_id":      {"2"},
+			"room_id":        {"2"},
+			"payment_date":   {"2025-02-10 10:00:00"},
+			"payment_amount": {"10"},
+		}, http.StatusCreated, nil)
+
+		// Client 2 is a tenant of room 1 too, from 2025 on.
+		call(t, h, "POST", "/api/room/id/1/members", url.Values{
+			"client_id":   {"2"},
+			"member_role": {"tenant"},
+			"member_from": {"2025-01-01"},
+		}, http.StatusCreated, nil)
+
+		tests := []struct {
+			name   string
+			token  string
+			method string
+			target string
+			status int
+			code   int
+		}{
+			{"resident own client", resident, "GET", "/api/client/id/1", http.StatusOK, 0},
+			{"resident own room", resident, "GET", "/api/room/id/1", http.StatusOK, 0},
+			{"resident own room balance", resident, "GET", "/api/room/id/1/balance", http.StatusOK, 0},
+			{"resident other client", resident, "GET", "/api/client/id/2", http.StatusForbidden, int(ErrCodeForbidden)},
+			{"resident other room", resident, "GET", "/api/room/id/2", http.StatusForbidden, int(ErrCodeForbidden)},
+			{"resident missing room", resident, "GET", "/api/room/id/99", http.StatusForbidden, int(ErrCodeForbidden)},
+			{"resident other payment", resident, "GET", "/api/payment/id/1", http.StatusForbidden, int(ErrCodeForbidden)},
+			{"resident admin route", resident, "GET", "/api/client/all", http.StatusForbidden, int(ErrCodeForbidden)},
+			{"resident write", resident, "POST", "/api/token/new", http.StatusForbidden, int(ErrCodeForbidden)},
+			{"member room", other, "GET", "/api/room/id/1", http.StatusOK, 0},
+			{"payer payment", other, "GET", "/api/payment/id/1", http.StatusOK, 0},
+			{"admin any room", admin, "GET", "/api/room/id/2", http.StatusOK, 0},
+			{"admin admin route", admin, "GET", "/api/client/all", http.StatusOK, 0},
+			{"service admin route", testServiceToken, "GET", "/api/client/all", http.StatusOK, 0},
+			{"resident own room attributes", resident, "GET", "/api/room/id/1/attributes", http.StatusOK, 0},
+			{"resident own room members", resident, "GET", "/api/room/id/1/members", http.StatusOK, 0},
+			{"resident other room attributes", resident, "GET", "/api/room/id/2/attributes", http.StatusForbidden, int(ErrCodeForbidden)},
+			{"resident other room members", resident, "GET", "/api/room/id/2/members", http.StatusForbidden, int(ErrCodeForbidden)},
+			{"resident add member", resident, "POST", "/api/room/id/1/members", http.StatusForbidden, int(ErrCodeForbidden)},
+			{"resident patch member", resident, "PATCH", "/api/room/member/id/1", http.StatusForbidden, int(ErrCodeForbidden)},
+			{"resident buildings", resident, "GET", "/api/building/id/1", http.StatusForbidden, int(ErrCodeForbidden)},
+			{"resident building write", resident, "POST", "/api/building/new", http.StatusForbidden, int(ErrCodeForbidden)},
+			{"resident expenses", resident, "GET", "/api/expense/all", http.StatusForbidden, int(ErrCodeForbidden)},
+			{"resident own room allocations", resident, "GET", "/api/expense/allocation/room/id/1", http.StatusForbidden, int(ErrCodeForbidden)},
+			{"resident expense write", resident, "POST", "/api/expense/new", http.StatusForbidden, int(ErrCodeForbidden)},
+			{"resident tariffs", resident, "GET", "/api/tariff/all", http.StatusForbidden, int(ErrCodeForbidden)},
+			{"resident own room charges", resident, "GET", "/api/charge/room/id/1", http.StatusForbidden, int(ErrCodeForbidden)},
+			{"resident charge write", resident, "POST", "/api/charge/generate", http.StatusForbidden, int(ErrCodeForbidden)},
+			{"resident own room meters", resident, "GET", "/api/meter/room/id/1", http.StatusForbidden, int(ErrCodeForbidden)},
+			{"resident meter write", resident, "POST", "/api/meter/new", http.StatusForbidden, int(ErrCodeForbidden)},
+			{"resident rates", resident, "GET", "/api/rate/all", http.StatusForbidden, int(ErrCodeForbidden)},
+			{"resident rate write", resident, "POST", "/api/rate/new", http.StatusForbidden, int(ErrCodeForbidden)},
+			{"resident bot state", resident, "GET", "/api/bot/state/chat-1", http.StatusForbidden, int(ErrCodeForbidden)},
+			{"resident bot state write", resident, "POST", "/api/bot/state/chat-1", http.StatusForbidden, int(ErrCodeForbidden)},
+			{"resident audit", resident, "GET", "/api/audit", http.StatusForbidden, int(ErrCodeForbidden)},
+			{"admin building", admin, "GET", "/api/building/id/1", http.StatusOK, 0},
+			{"admin other room attributes", admin, "GET", "/api/room/id/2/attributes", http.StatusOK, 0},
+			{"admin expenses", admin, "GET", "/api/expense/all", http.StatusOK, 0},
+			{"admin buildings", admin, "GET", "/api/building/all", http.StatusOK, 0},
+			{"service members", testServiceToken, "GET", "/api/room/id/2/members", http.StatusOK, 0},
+			{"service bot state", testServiceToken, "GET", "/api/bot/state/chat-1", http.StatusNotFound, 0},
+			{"missing token", "", "GET", "/api/room/id/1", http.StatusUnauthorized, 1},
+			{"unknown token", "not-a-token", "GET", "/api/room/id/1", http.StatusUnauthorized, 2},
+		}
+
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				w := request(h, tt.token, tt.method, tt.target, nil)
+				if w.Code != tt.status {
+					t.Fatalf("got %d, want %d: %s", w.Code, tt.status, w.Body)
+				}
+				if tt.code == 0 {
+					return
+				}
+
+				var res types.APIResponse
+				if err := json.Unmarshal(w.Body.Bytes(), &res); err != nil {
+					t.Fatal(err)
+				}
+				if res.Error == nil || int(res.Error.Code) != tt.code {
+					t.Fatalf("error = %+v, want code %d", res.Error, tt.code)
+				}
+			})
+		}
+	})
+}
+
+func TestAuthenticateScheme(t *testing.T) {
+	testBackends(t, func(t *testing.T, h http.Handler) {
+		for _, header := range []string{"Basic " + testServiceToken, testServiceToken, "Bearer "} {
+			req := httptest.NewRequest("GET", "/api/building/all", nil)
+			req.Header.Set("Authorization", header)
+
+			w := httptest.NewRecorder()
+			h.ServeHTTP(w, req)
+			if w.Code != http.StatusUnauthorized {
+				t.Errorf("Authorization %q: got %d, want 401", header, w.Code)
+			}
+		}
+	})
+}
+
+// routeParams are the values of the path parameters of TestAuthorizeRoutes.
+var routeParams = strings.NewReplacer(
+	":id", "1",
+	":period", "2025-01",
+	":date", "2025-01-01",
+	":day", "2025-01-01",
+	":currency", "USD",
+	":key", "chat-1",
+	":name", "Client",
+)
+
+// TestAuthorizeRoutes pins residentRoutes down: a resident gets 403 on every
+// other route, while admins and services get past Authorize on every route.
+func TestAuthorizeRoutes(t *testing.T) {
+	testBackends(t, func(t *testing.T, h http.Handler) {
+		call(t, h, "POST", "/api/building/new", url.Values{"building_name": {"Main"}}, http.StatusCreated, nil)
+		resident := newClientToken(t, h, "1", false)
+		admin := newClientToken(t, h, "2", true)
+
+		routes := h.(*gin.Engine).Routes()
+		for _, r := range routes {
+			if _, ok := residentRoutes[r.Method+" "+r.Path]; ok {
+				continue
+			}
+			if w := request(h, resident, r.Method, routeParams.Replace(r.Path), nil); w.Code != http.StatusForbidden {
+				t.Errorf("resident %s %s: got %d, want 403", r.Method, r.Path, w.Code)
+			}
+		}
+
+		for _, token := range []string{admin, testServiceToken} {
+			for _, r := range routes {
+				w := request(h, token, r.Method, routeParams.Replace(r.Path), nil)
+				if w.Code == http.StatusUnauthorized || w.Code == http.StatusForbidden {
+					t.Errorf("%s %s: got %d: %s", r.Method, r.Path, w.Code, w.Body)
+				}
+			}
+		}
+	})
+}
@@ -18,61 +18,93 @@ const docTemplate = `{
     "host": "{{.Host}}",
     "basePath": "{{.BasePath}}",
     "paths": {
-        "/client/admins": {
+        "/audit": {
             "get": {
-                "description": "Get all admin clients",
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Get audit log records, newest first. All filters are optional.",
                 "produces": [
                     "application/json"
                 ],
                 "tags": [
-                    "client"
+                    "audit"
                 ],
-                "summary": "Get all admin clients",
-                "responses": {
-                    "200": {
-                        "description": "ok",
-                        "schema": {
-                            "type": "array",
-                            "items": {
-                                "$ref": "#/definitions/types.Client"
-                            }
-                        }
+                "summary": "Get audit log",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Entity, e.g. payment",
+                        "name": "entity",
+                        "in": "query"
                     },
-                    "404": {
-                        "description": "No rows",
-                        "schema": {
-                            "$ref": "#/definitions/types.APIResponse"
-                        }
+                    {
+                        "type": "string",
+                        "description": "Entity ID",
+                        "name": "entity_id",
+                        "in": "query"
                     },
-                    "500": {
-                        "description": "Internal server error",
-                        "schema": {
-                            "$ref": "#/definitions/types.APIResponse"
-                        }
+                    {
+                        "type": "string",
+                        "description": "Action: create, update, delete or restore",
+                        "name": "action",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Actor role: service, admin or resident",
+                        "name": "actor_role",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Actor client ID",
+                        "name": "actor_client_id",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Request ID",
+                        "name": "request_id",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "From date 'yyyy-mm-dd hh:mm:ss', inclusive",
+                        "name": "from",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "To date 'yyyy-mm-dd hh:mm:ss', exclusive",
+                        "name": "to",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Max records, 100 by default, up to 1000",
+                        "name": "limit",
+                        "in": "query"
                     }
-                }
-            }
-        },
-        "/client/all": {
-            "get": {
-                "description": "Get all clients",
-                "produces": [
-                    "application/json"
-                ],
-                "tags": [
-                    "client"
                 ],
-                "summary": "Get all clients",
                 "responses": {
                     "200": {
                         "description": "ok",
                         "schema": {
                             "type": "array",
                             "items": {
-                                "$ref": "#/definitions/types.Client"
+                                "$ref": "#/definitions/main.AuditRecord"
                             }
                         }
                     },
+                    "400": {
+                        "description": "Incorrect parameter",
+                        "schema": {
+                            "$ref": "#/definitions/types.APIResponse"
+                        }
+                    },
                     "404": {
                         "description": "No rows",
                         "schema": {
@@ -88,21 +120,26 @@ const docTemplate = `{
                 }
             }
         },
-        "/client/id/{id}": {
+        "/bot/state/{key}": {
             "get": {
-                "description": "Get client by telegram ID",
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Get bot state value by key. Expired values are not returned.",
                 "produces": [
                     "application/json"
                 ],
                 "tags": [
-                    "client"
+                    "bot"
                 ],
-                "summary": "Get client by telegram ID",
+                "summary": "Get bot state",
                 "parameters": [
                     {
-                        "type": "integer",
-                        "description": "Telegram ID",
-                        "name": "id",
+                        "type": "string",
+                        "description": "State key",
+                        "name": "key",
                         "in": "path",
                         "required": true
                     }
@@ -111,13 +148,7 @@ const docTemplate = `{
                     "200": {
                         "description": "ok",
                         "schema": {
-                            "$ref": "#/definitions/types.Client"
-                        }
-                    },
-                    "400": {
-                        "description": "Incorrect parameter",
-                        "schema": {
-                            "$ref": "#/definitions/types.APIResponse"
+                            "$ref": "#/definitions/main.BotState"
                         }
                     },
                     "404": {
@@ -135,47 +166,56 @@ const docTemplate = `{
                 }
             },
             "post": {
-                "description": "Create new client",
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Create or replace bot state value. Expired values are cleaned up on every call.",
+                "consumes": [
+                    "application/x-www-form-urlencoded",
+                    "application/json"
+                ],
                 "produces": [
                     "application/json"
                 ],
                 "tags": [
-                    "client"
+                    "bot"
                 ],
-                "summary": "Create new client",
+                "summary": "Set bot state",
                 "parameters": [
                     {
-                        "type": "integer",
-                        "description": "Client telegram ID",
-                        "name": "id",
+                        "type": "string",
+                        "description": "State key",
+                        "name": "key",
                         "in": "path",
                         "required": true
                     },
                     {
                         "type": "string",
-                        "description": "Client name",
-                        "name": "client_name",
+                        "description": "Value",
+                        "name": "state_value",
                         "in": "formData",
                         "required": true
                     },
                     {
-                        "type": "boolean",
-                        "description": "Is admin",
-                        "name": "is_admin",
+                        "type": "integer",
+                        "description": "Time to live in seconds, 0 or empty for no expiry",
+                        "name": "state_ttl",
                         "in": "formData"
                     }
                 ],
                 "responses": {
-                    "201": {
-                        "description": "New client",
+                    "200": {
+                        "description": "Updated",
                         "schema": {
-                            "$ref": "#/definitions/types.Client"
+                            "$ref": "#/definitions/types.APIResponse"
                         }
                     },
                     "400": {
                         "description": "Incorrect parameter",
                         "schema": {
-                            "$ref": "#/definitions/types.APIResponse"
+                            "$ref": "#/definitions/main.ValidationResponse"
                         }
                     },
                     "500": {
@@ -187,19 +227,24 @@ const docTemplate = `{
                 }
             },
             "delete": {
-                "description": "Delete client by telegram ID",
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Delete bot state value by key",
                 "produces": [
                     "application/json"
                 ],
                 "tags": [
-                    "client"
+                    "bot"
                 ],
-                "summary": "Delete client",
+                "summary": "Delete bot state",
                 "parameters": [
                     {
-                        "type": "integer",
-                        "description": "Client telegram ID",
-                        "name": "id",
+                        "type": "string",
+                        "description": "State key",
+                        "name": "key",
                         "in": "path",
                         "required": true
                     }
@@ -211,12 +256,6 @@ const docTemplate = `{
                             "$ref": "#/definitions/types.APIResponse"
                         }
                     },
-                    "400": {
-                        "description": "Incorrect parameter",
-                        "schema": {
-                            "$ref": "#/definitions/types.APIResponse"
-                        }
-                    },
                     "500": {
                         "description": "Internal server error",
                         "schema": {
@@ -224,48 +263,31 @@ const docTemplate = `{
                         }
                     }
                 }
-            },
-            "patch": {
-                "description": "Patch client by client_id",
+            }
+        },
+        "/building/all": {
+            "get": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Get all buildings",
                 "produces": [
                     "application/json"
                 ],
                 "tags": [
-                    "client"
-                ],
-                "summary": "Patch client",
-                "parameters": [
-                    {
-                        "type": "integer",
-                        "description": "Client ID",
-                        "name": "id",
-                        "in": "path",
-                        "required": true
-                    },
-                    {
-                        "type": "string",
-                        "description": "Client name",
-                        "name": "client_name",
-                        "in": "formData"
-                    },
-                    {
-                        "type": "boolean",
-                        "description": "is admin",
-                        "name": "is_admin",
-                        "in": "formData"
-                    }
+                    "building"
                 ],
+                "summary": "Get all buildings",
                 "responses": {
                     "200": {
-                        "description": "Updated",
-                        "schema": {
-                            "$ref": "#/definitions/types.APIResponse"
-                        }
-                    },
-                    "400": {
-                        "description": "Incorrect parameter",
+                        "description": "ok",
                         "schema": {
-                            "$ref": "#/definitions/types.APIResponse"
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/main.Building"
+                            }
                         }
                     },
                     "404": {
@@ -283,21 +305,26 @@ const docTemplate = `{
                 }
             }
         },
-        "/client/name/{name}": {
+        "/building/id/{id}": {
             "get": {
-                "description": "Get clients by client_name",
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Get building by building_id",
                 "produces": [
                     "application/json"
                 ],
                 "tags": [
-                    "client"
+                    "building"
                 ],
-                "summary": "Get clients by client_name",
+                "summary": "Get building by building_id",
                 "parameters": [
                     {
-                        "type": "string",
-                        "description": "Client name",
-                        "name": "name",
+                        "type": "integer",
+                        "description": "Building ID",
+                        "name": "id",
                         "in": "path",
                         "required": true
                     }
@@ -306,9 +333,12 @@ const docTemplate = `{
                     "200": {
                         "description": "ok",
                         "schema": {
-                            "type": "array",
-                            "items": {
-                                "$ref": "#/definitions/types.Client"
+                            "$ref": "#/definitions/main.Building"
+                        },
+                        "headers": {
+                            "ETag": {
+                                "type": "string",
+                                "description": "Version of the row, for If-Match"
                             }
                         }
                     },
@@ -331,133 +361,150 @@ const docTemplate = `{
                         }
                     }
                 }
-            }
-        },
-        "/expense/all": {
-            "get": {
-                "description": "Get all expenses",
-                "produces": [
-                    "application/json"
-                ],
-                "tags": [
-                    "expense"
-                ],
-                "summary": "Get all expenses",
-                "responses": {
-                    "200": {
-                        "description": "ok",
-                        "schema": {
-                            "type": "array",
-                            "items": {
-                                "$ref": "#/definitions/types.Expense"
-                            }
-                        }
-                    },
-                    "404": {
-                        "description": "No rows",
-                        "schema": {
-                            "$ref": "#/definitions/types.APIResponse"
-                        }
-                    },
-                    "500": {
-                        "description": "Internal server error",
-                        "schema": {
-                            "$ref": "#/definitions/types.APIResponse"
-                        }
+            },
+            "delete": {
+                "security": [
+                    {
+                        "BearerAuth": []
                     }
-                }
-            }
-        },
-        "/expense/date/range": {
-            "post": {
-                "description": "Get expenses by date range",
+                ],
+                "description": "Delete building by building_id. A building with rooms, expenses or tariffs, deleted ones included, can't be deleted.",
                 "produces": [
                     "application/json"
                 ],
                 "tags": [
-                    "expense"
+                    "building"
                 ],
-                "summary": "Get expenses by date range",
+                "summary": "Delete building",
                 "parameters": [
                     {
-                        "type": "string",
-                        "description": "Expense date start",
-                        "name": "date_start",
-                        "in": "formData",
+                        "type": "integer",
+                        "description": "Building ID",
+                        "name": "id",
+                        "in": "path",
                         "required": true
                     },
                     {
                         "type": "string",
-                        "description": "Expense date end",
-                        "name": "date_end",
-                        "in": "formData",
-                        "required": true
+                        "description": "ETag of the row; the delete fails if the row has changed",
+                        "name": "If-Match",
+                        "in": "header"
                     }
                 ],
                 "responses": {
                     "200": {
-                        "description": "ok",
+                        "description": "Deleted",
                         "schema": {
-                            "type": "array",
-                            "items": {
-                                "$ref": "#/definitions/types.Expense"
-                            }
+                            "$ref": "#/definitions/types.APIResponse"
                         }
                     },
                     "400": {
                         "description": "Incorrect parameter",
                         "schema": {
-                            "type": "objcet"
+                            "$ref": "#/definitions/types.APIResponse"
                         }
                     },
                     "404": {
                         "description": "No rows",
                         "schema": {
-                            "type": "objcet"
+                            "$ref": "#/definitions/types.APIResponse"
+                        }
+                    },
+                    "409": {
+                        "description": "Building has rooms, expenses or tariffs",
+                        "schema": {
+                            "$ref": "#/definitions/types.APIResponse"
+                        }
+                    },
+                    "412": {
+                        "description": "Row has changed",
+                        "schema": {
+                            "$ref": "#/definitions/types.APIResponse"
                         }
                     },
                     "500": {
                         "description": "Internal server error",
                         "schema": {
-                            "type": "objcet"
+                            "$ref": "#/definitions/types.APIResponse"
                         }
                     }
                 }
-            }
-        },
-        "/expense/date/{date}": {
-            "get": {
-                "description": "Get expenses by expense_date",
-                "produces": [
+            },
+            "patch": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Patch building by building_id. The currency of a building can't be changed, as its amounts are kept in it.",
+                "consumes": [
+                    "application/x-www-form-urlencoded",
+                    "application/json",
+                    "application/merge-patch+json"
+                ],
+                "produces": [
                     "application/json"
                 ],
                 "tags": [
-                    "expense"
+                    "building"
                 ],
-                "summary": "Get expenses by expense_date",
+                "summary": "Patch building",
                 "parameters": [
                     {
-                        "type": "string",
-                        "description": "Expense date",
-                        "name": "date",
+                        "type": "integer",
+                        "description": "Building ID",
+                        "name": "id",
                         "in": "path",
                         "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "ETag of the row; the patch fails if the row has changed",
+                        "name": "If-Match",
+                        "in": "header"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Building name",
+                        "name": "building_name",
+                        "in": "formData"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Postal address",
+                        "name": "building_address",
+                        "in": "formData"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Management contact, e.g. phone or e-mail",
+                        "name": "building_contact",
+                        "in": "formData"
+                    },
+                    {
+                        "type": "string",
+                        "description": "ISO 4217 code of the currency of the building; only the current one is accepted",
+                        "name": "building_currency",
+                        "in": "formData"
                     }
                 ],
                 "responses": {
                     "200": {
-                        "description": "ok",
+                        "description": "Updated",
                         "schema": {
-                            "type": "array",
-                            "items": {
-                                "$ref": "#/definitions/types.Expense"
+                            "$ref": "#/definitions/main.Building"
+                        },
+                        "headers": {
+                            "ETag": {
+                                "type": "string",
+                                "description": "Version of the row, for If-Match"
                             }
                         }
                     },
                     "400": {
                         "description": "Incorrect parameter",
                         "schema": {
-                            "$ref": "#/definitions/types.APIResponse"
+                            "$ref": "#/definitions/main.ValidationResponse"
                         }
                     },
                     "404": {
@@ -466,49 +513,14 @@ const docTemplate = `{
                             "$ref": "#/definitions/types.APIResponse"
                         }
                     },
-                    "500": {
-                        "description": "Internal server error",
-                        "schema": {
-                            "$ref": "#/definitions/types.APIResponse"
-                        }
-                    }
-                }
-            }
-        },
-        "/expense/id/{id}": {
-            "get": {
-                "description": "Get expense by expense_id",
-                "produces": [
-                    "application/json"
-                ],
-                "tags": [
-                    "expense"
-                ],
-                "summary": "Get expense by expense_id",
-                "parameters": [
-                    {
-                        "type": "integer",
-                        "description": "Expense ID",
-                        "name": "id",
-                        "in": "path",
-                        "required": true
-                    }
-                ],
-                "responses": {
-                    "200": {
-                        "description": "ok",
-                        "schema": {
-                            "$ref": "#/definitions/types.Expense"
-                        }
-                    },
-                    "400": {
-                        "description": "Incorrect parameter",
+                    "409": {
+                        "description": "Currency differs from the currency of the building",
                         "schema": {
                             "$ref": "#/definitions/types.APIResponse"
                         }
                     },
-                    "404": {
-                        "description": "No rows",
+                    "412": {
+                        "description": "Row has changed",
                         "schema": {
                             "$ref": "#/definitions/types.APIResponse"
                         }
@@ -520,36 +532,75 @@ const docTemplate = `{
                         }
                     }
                 }
-            },
-            "delete": {
-                "description": "Delete expense by expense_id",
+            }
+        },
+        "/building/new": {
+            "post": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Create new building",
+                "consumes": [
+                    "application/x-www-form-urlencoded",
+                    "application/json"
+                ],
                 "produces": [
                     "application/json"
                 ],
                 "tags": [
-                    "expense"
+                    "building"
                 ],
-                "summary": "Delete expense by expense_id",
+                "summary": "Create new building",
                 "parameters": [
                     {
-                        "type": "integer",
-                        "description": "Expense ID",
-                        "name": "id",
-                        "in": "path",
+                        "type": "string",
+                        "description": "Building name",
+                        "name": "building_name",
+                        "in": "formData",
                         "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Postal address",
+                        "name": "building_address",
+                        "in": "formData"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Management contact, e.g. phone or e-mail",
+                        "name": "building_contact",
+                        "in": "formData"
+                    },
+                    {
+                        "type": "string",
+                        "description": "ISO 4217 code of the currency of the ledger, the base currency by default",
+                        "name": "building_currency",
+                        "in": "formData"
                     }
                 ],
                 "responses": {
-                    "200": {
-                        "description": "Deleted",
+                    "201": {
+                        "description": "New building",
                         "schema": {
-                            "$ref": "#/definitions/types.APIResponse"
+                            "$ref": "#/definitions/main.Building"
+                        },
+                        "headers": {
+                            "ETag": {
+                                "type": "string",
+                                "description": "ETag of the new building"
+                            },
+                            "Location": {
+                                "type": "string",
+                                "description": "URL of the new building"
+                            }
                         }
                     },
                     "400": {
                         "description": "Incorrect parameter",
                         "schema": {
-                            "$ref": "#/definitions/types.APIResponse"
+                            "$ref": "#/definitions/main.ValidationResponse"
                         }
                     },
                     "500": {
@@ -559,42 +610,39 @@ const docTemplate = `{
                         }
                     }
                 }
-            },
-            "patch": {
-                "description": "Patch expense by expense_id",
+            }
+        },
+        "/charge/all": {
+            "get": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Get all charges, optionally only those of the rooms of a building",
                 "produces": [
                     "application/json"
                 ],
                 "tags": [
-                    "expense"
+                    "charge"
                 ],
-                "summary": "Patch expense",
+                "summary": "Get all charges",
                 "parameters": [
                     {
                         "type": "integer",
-                        "description": "Expense ID",
-                        "name": "id",
-                        "in": "path",
-                        "required": true
-                    },
-                    {
-                        "type": "string",
-                        "description": "Date 'yyyy-mm-dd hh:mm:ss'",
-                        "name": "expense_date",
-                        "in": "formData"
-                    },
-                    {
-                        "type": "number",
-                        "description": "Amount",
-                        "name": "expense_amount",
-                        "in": "formData"
+                        "description": "Building ID",
+                        "name": "building_id",
+                        "in": "query"
                     }
                 ],
                 "responses": {
                     "200": {
-                        "description": "Updated",
+                        "description": "ok",
                         "schema": {
-                            "$ref": "#/definitions/types.APIResponse"
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/main.Charge"
+                            }
                         }
                     },
                     "400": {
@@ -618,40 +666,67 @@ const docTemplate = `{
                 }
             }
         },
-        "/expense/new": {
+        "/charge/generate": {
             "post": {
-                "description": "Create new expense",
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Create a charge for every room, or every room of a building, for the given period. Rooms already charged for the period are skipped, so repeating the call is safe.\nEach room is charged at the tariff in effect for its building, unless tariff_id is given. A tariff of a building only charges the rooms of that building.\nThe people count and area of a room are those in effect on the first day of the period; rooms without any by then are skipped.",
+                "consumes": [
+                    "application/x-www-form-urlencoded",
+                    "application/json"
+                ],
                 "produces": [
                     "application/json"
                 ],
                 "tags": [
-                    "expense"
+                    "charge"
                 ],
-                "summary": "Create new expense",
+                "summary": "Generate monthly charges",
                 "parameters": [
                     {
                         "type": "string",
-                        "description": "Expense date",
-                        "name": "expense_date",
+                        "description": "Period 'yyyy-mm'",
+                        "name": "charge_period",
+                        "in": "formData",
+                        "required": true
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Tariff ID, defaults to the tariff in effect for the building of each room",
+                        "name": "tariff_id",
                         "in": "formData"
                     },
                     {
-                        "type": "number",
-                        "description": "Expense amount",
-                        "name": "expense_amount",
-                        "in": "formData",
-                        "required": true
+                        "type": "integer",
+                        "description": "Building ID, all buildings by default",
+                        "name": "building_id",
+                        "in": "formData"
                     }
                 ],
                 "responses": {
                     "201": {
-                        "description": "New expense",
+                        "description": "ok",
                         "schema": {
-                            "$ref": "#/definitions/types.Expense"
+                            "$ref": "#/definitions/main.ChargeGenerateResult"
+                        },
+                        "headers": {
+                            "Location": {
+                                "type": "string",
+                                "description": "URL of the charges of the period"
+                            }
                         }
                     },
                     "400": {
                         "description": "Incorrect parameter",
+                        "schema": {
+                            "$ref": "#/definitions/main.ValidationResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "No tariff",
                         "schema": {
                             "$ref": "#/definitions/types.APIResponse"
                         }
@@ -665,24 +740,41 @@ const docTemplate = `{
                 }
             }
         },
-        "/payment/all": {
+        "/charge/id/{id}": {
             "get": {
-                "description": "Get all payments",
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Get charge by charge_id",
                 "produces": [
                     "application/json"
                 ],
                 "tags": [
-                    "payment"
+                    "charge"
+                ],
+                "summary": "Get charge by charge_id",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Charge ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
                 ],
-                "summary": "Get all payments",
                 "responses": {
                     "200": {
                         "description": "ok",
                         "schema": {
-                            "type": "array",
-                            "items": {
-                                "$ref": "#/definitions/types.Payment"
-                            }
+                            "$ref": "#/definitions/main.Charge"
+                        }
+                    },
+                    "400": {
+                        "description": "Incorrect parameter",
+                        "schema": {
+                            "$ref": "#/definitions/types.APIResponse"
                         }
                     },
                     "404": {
@@ -698,22 +790,25 @@ const docTemplate = `{
                         }
                     }
                 }
-            }
-        },
-        "/payment/client/id/{id}": {
-            "get": {
-                "description": "Get all payments by client_id",
+            },
+            "delete": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Delete charge by charge_id",
                 "produces": [
                     "application/json"
                 ],
                 "tags": [
-                    "payment"
+                    "charge"
                 ],
-                "summary": "Get all payments by client_id",
+                "summary": "Delete charge",
                 "parameters": [
                     {
                         "type": "integer",
-                        "description": "Client ID",
+                        "description": "Charge ID",
                         "name": "id",
                         "in": "path",
                         "required": true
@@ -721,12 +816,9 @@ const docTemplate = `{
                 ],
                 "responses": {
                     "200": {
-                        "description": "ok",
+                        "description": "Deleted",
                         "schema": {
-                            "type": "array",
-                            "items": {
-                                "$ref": "#/definitions/types.Payment"
-                            }
+                            "$ref": "#/definitions/types.APIResponse"
                         }
                     },
                     "400": {
@@ -750,30 +842,34 @@ const docTemplate = `{
                 }
             }
         },
-        "/payment/date/range": {
-            "post": {
-                "description": "Get payment by date range",
+        "/charge/period/{period}": {
+            "get": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Get all charges for a billing period, optionally only those of the rooms of a building",
                 "produces": [
                     "application/json"
                 ],
                 "tags": [
-                    "payment"
+                    "charge"
                 ],
-                "summary": "Get payment by date range",
+                "summary": "Get all charges by period",
                 "parameters": [
                     {
                         "type": "string",
-                        "description": "Date 'yyyy-mm-dd hh:mm:ss'",
-                        "name": "date_start",
-                        "in": "formData",
+                        "description": "Period 'yyyy-mm'",
+                        "name": "period",
+                        "in": "path",
                         "required": true
                     },
                     {
-                        "type": "string",
-                        "description": "Date 'yyyy-mm-dd hh:mm:ss'",
-                        "name": "date_end",
-                        "in": "formData",
-                        "required": true
+                        "type": "integer",
+                        "description": "Building ID",
+                        "name": "building_id",
+                        "in": "query"
                     }
                 ],
                 "responses": {
@@ -782,14 +878,14 @@ const docTemplate = `{
                         "schema": {
                             "type": "array",
                             "items": {
-                                "$ref": "#/definitions/types.Payment"
+                                "$ref": "#/definitions/main.Charge"
                             }
                         }
                     },
                     "400": {
                         "description": "Incorrect parameter",
                         "schema": {
-                            "$ref": "#/definitions/types.APIResponse"
+                            "$ref": "#/definitions/main.ValidationResponse"
                         }
                     },
                     "404": {
@@ -807,21 +903,26 @@ const docTemplate = `{
                 }
             }
         },
-        "/payment/date/{date}": {
+        "/charge/room/id/{id}": {
             "get": {
-                "description": "Get payment by date",
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Get all charges by room_id",
                 "produces": [
                     "application/json"
                 ],
                 "tags": [
-                    "payment"
+                    "charge"
                 ],
-                "summary": "Get payment by date",
+                "summary": "Get all charges by room_id",
                 "parameters": [
                     {
-                        "type": "string",
-                        "description": "Date 'yyyy-mm-dd hh:mm:ss'",
-                        "name": "date",
+                        "type": "integer",
+                        "description": "Room ID",
+                        "name": "id",
                         "in": "path",
                         "required": true
                     }
@@ -832,7 +933,7 @@ const docTemplate = `{
                         "schema": {
                             "type": "array",
                             "items": {
-                                "$ref": "#/definitions/types.Payment"
+                                "$ref": "#/definitions/main.Charge"
                             }
                         }
                     },
@@ -857,36 +958,37 @@ const docTemplate = `{
                 }
             }
         },
-        "/payment/id/{id}": {
+        "/client/admins": {
             "get": {
-                "description": "Get payment by payment_id",
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Get all admin clients",
                 "produces": [
                     "application/json"
                 ],
                 "tags": [
-                    "payment"
+                    "client"
                 ],
-                "summary": "Get payment by payment_id",
+                "summary": "Get all admin clients",
                 "parameters": [
                     {
-                        "type": "integer",
-                        "description": "Payment ID",
-                        "name": "id",
-                        "in": "path",
-                        "required": true
+                        "type": "boolean",
+                        "description": "Include soft deleted rows",
+                        "name": "include_deleted",
+                        "in": "query"
                     }
                 ],
                 "responses": {
                     "200": {
                         "description": "ok",
                         "schema": {
-                            "$ref": "#/definitions/types.Payment"
-                        }
-                    },
-                    "400": {
-                        "description": "Incorrect parameter",
-                        "schema": {
-                            "$ref": "#/definitions/types.APIResponse"
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/main.Client"
+                            }
                         }
                     },
                     "404": {
@@ -902,30 +1004,91 @@ const docTemplate = `{
                         }
                     }
                 }
-            },
-            "delete": {
-                "description": "Delete payment by payment_id",
+            }
+        },
+        "/client/all": {
+            "get": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Get a page of clients, optionally filtered by name, admin flag and building",
                 "produces": [
                     "application/json"
                 ],
                 "tags": [
-                    "payment"
+                    "client"
                 ],
-                "summary": "Delete payment",
+                "summary": "Get all clients",
                 "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Part of the client name",
+                        "name": "name",
+                        "in": "query"
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "Only admins, or only residents",
+                        "name": "is_admin",
+                        "in": "query"
+                    },
                     {
                         "type": "integer",
-                        "description": "Payment ID",
-                        "name": "id",
-                        "in": "path",
-                        "required": true
+                        "description": "Only clients with a room in the building",
+                        "name": "building_id",
+                        "in": "query"
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "Include soft deleted rows",
+                        "name": "include_deleted",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Sort field: client_id, client_name, last_edited; client_id by default",
+                        "name": "sort",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Sort order: asc (default) or desc",
+                        "name": "order",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Page size, 50 by default, up to 500",
+                        "name": "limit",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "X-Next-Cursor of the previous page",
+                        "name": "cursor",
+                        "in": "query"
                     }
                 ],
                 "responses": {
                     "200": {
-                        "description": "Deleted",
+                        "description": "ok",
                         "schema": {
-                            "$ref": "#/definitions/types.APIResponse"
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/main.Client"
+                            }
+                        },
+                        "headers": {
+                            "X-Next-Cursor": {
+                                "type": "string",
+                                "description": "Cursor of the next page, absent on the last page"
+                            },
+                            "X-Total-Count": {
+                                "type": "integer",
+                                "description": "Number of matching rows over all pages"
+                            }
                         }
                     },
                     "400": {
@@ -941,54 +1104,49 @@ const docTemplate = `{
                         }
                     }
                 }
-            },
-            "patch": {
-                "description": "Patch payment by payment_id",
+            }
+        },
+        "/client/id/{id}": {
+            "get": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Get client by telegram ID",
                 "produces": [
                     "application/json"
                 ],
                 "tags": [
-                    "payment"
+                    "client"
                 ],
-                "summary": "Patch payment",
+                "summary": "Get client by telegram ID",
                 "parameters": [
                     {
                         "type": "integer",
-                        "description": "Payment ID",
+                        "description": "Telegram ID",
                         "name": "id",
                         "in": "path",
                         "required": true
                     },
                     {
-                        "type": "integer",
-                        "description": "Client ID",
-                        "name": "client_id",
-                        "in": "formData"
-                    },
-                    {
-                        "type": "integer",
-                        "description": "Room ID",
-                        "name": "room_id",
-                        "in": "formData"
-                    },
-                    {
-                        "type": "string",
-                        "description": "Date 'yyyy-mm-dd hh:mm:ss'",
-                        "name": "payment_date",
-                        "in": "formData"
-                    },
-                    {
-                        "type": "number",
-                        "description": "Payment amount",
-                        "name": "payment_amount",
-                        "in": "formData"
+                        "type": "boolean",
+                        "description": "Include soft deleted rows",
+                        "name": "include_deleted",
+                        "in": "query"
                     }
                 ],
                 "responses": {
                     "200": {
-                        "description": "Updated",
+                        "description": "ok",
                         "schema": {
-                            "$ref": "#/definitions/types.APIResponse"
+                            "$ref": "#/definitions/main.Client"
+                        },
+                        "headers": {
+                            "ETag": {
+                                "type": "string",
+                                "description": "Version of the row, for If-Match"
+                            }
                         }
                     },
                     "400": {
@@ -1010,56 +1168,73 @@ const docTemplate = `{
                         }
                     }
                 }
-            }
-        },
-        "/payment/new": {
+            },
             "post": {
-                "description": "Create new payment",
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Create new client",
+                "consumes": [
+                    "application/x-www-form-urlencoded",
+                    "application/json"
+                ],
                 "produces": [
                     "application/json"
                 ],
                 "tags": [
-                    "payment"
+                    "client"
                 ],
-                "summary": "Create new payment",
+                "summary": "Create new client",
                 "parameters": [
                     {
                         "type": "integer",
-                        "description": "Client ID",
-                        "name": "client_id",
-                        "in": "formData",
+                        "description": "Client telegram ID",
+                        "name": "id",
+                        "in": "path",
                         "required": true
                     },
                     {
-                        "type": "integer",
-                        "description": "Room ID",
-                        "name": "room_id",
+                        "type": "string",
+                        "description": "Client name",
+                        "name": "client_name",
                         "in": "formData",
                         "required": true
                     },
                     {
-                        "type": "string",
-                        "description": "Date 'yyyy-mm-dd hh:mm:ss'",
-                        "name": "payment_date",
-                        "in": "formData"
-                    },
-                    {
-                        "type": "number",
-                        "description": "Amount",
-                        "name": "payment_amount",
+                        "type": "boolean",
+                        "description": "Is admin",
+                        "name": "is_admin",
                         "in": "formData",
                         "required": true
                     }
                 ],
                 "responses": {
                     "201": {
-                        "description": "New payment",
+                        "description": "New client",
                         "schema": {
-                            "$ref": "#/definitions/types.Payment"
+                            "$ref": "#/definitions/main.Client"
+                        },
+                        "headers": {
+                            "ETag": {
+                                "type": "string",
+                                "description": "ETag of the new client"
+                            },
+                            "Location": {
+                                "type": "string",
+                                "description": "URL of the new client"
+                            }
                         }
                     },
                     "400": {
                         "description": "Incorrect parameter",
+                        "schema": {
+                            "$ref": "#/definitions/main.ValidationResponse"
+                        }
+                    },
+                    "409": {
+                        "description": "Client already exists",
                         "schema": {
                             "$ref": "#/definitions/types.APIResponse"
                         }
@@ -1071,35 +1246,41 @@ const docTemplate = `{
                         }
                     }
                 }
-            }
-        },
-        "/payment/room/id/{id}": {
-            "get": {
-                "description": "Get all payments by room_id",
+            },
+            "delete": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Soft delete client by telegram ID together with its rooms. Use restore to undo.",
                 "produces": [
                     "application/json"
                 ],
                 "tags": [
-                    "payment"
+                    "client"
                 ],
-                "summary": "Get all payments by room_id",
+                "summary": "Delete client",
                 "parameters": [
                     {
                         "type": "integer",
-                        "description": "Room ID",
+                        "description": "Client telegram ID",
                         "name": "id",
                         "in": "path",
                         "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "ETag of the row; the delete fails if the row has changed",
+                        "name": "If-Match",
+                        "in": "header"
                     }
                 ],
                 "responses": {
                     "200": {
-                        "description": "ok",
+                        "description": "Deleted",
                         "schema": {
-                            "type": "array",
-                            "items": {
-                                "$ref": "#/definitions/types.Payment"
-                            }
+                            "$ref": "#/definitions/types.APIResponse"
                         }
                     },
                     "400": {
@@ -1109,42 +1290,13 @@ const docTemplate = `{
                         }
                     },
                     "404": {
-                        "description": "No rows",
-                        "schema": {
-                            "$ref": "#/definitions/types.APIResponse"
-                        }
-                    },
-                    "500": {
-                        "description": "Internal server error",
+                        "description": "No client",
                         "schema": {
                             "$ref": "#/definitions/types.APIResponse"
                         }
-                    }
-                }
-            }
-        },
-        "/room/all": {
-            "get": {
-                "description": "Get all rooms from MySQL",
-                "produces": [
-                    "application/json"
-                ],
-                "tags": [
-                    "room"
-                ],
-                "summary": "Get all rooms",
-                "responses": {
-                    "200": {
-                        "description": "ok",
-                        "schema": {
-                            "type": "array",
-                            "items": {
-                                "$ref": "#/definitions/types.Room"
-                            }
-                        }
                     },
-                    "404": {
-                        "description": "No rows",
+                    "412": {
+                        "description": "Row has changed",
                         "schema": {
                             "$ref": "#/definitions/types.APIResponse"
                         }
@@ -1156,18 +1308,26 @@ const docTemplate = `{
                         }
                     }
                 }
-            }
-        },
-        "/room/client/id/{id}": {
-            "get": {
-                "description": "Get rooms by client_id",
+            },
+            "patch": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Patch client by client_id",
+                "consumes": [
+                    "application/x-www-form-urlencoded",
+                    "application/json",
+                    "application/merge-patch+json"
+                ],
                 "produces": [
                     "application/json"
                 ],
                 "tags": [
-                    "room"
+                    "client"
                 ],
-                "summary": "Get rooms by client_id",
+                "summary": "Patch client",
                 "parameters": [
                     {
                         "type": "integer",
@@ -1175,22 +1335,43 @@ const docTemplate = `{
                         "name": "id",
                         "in": "path",
                         "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "ETag of the row; the patch fails if the row has changed",
+                        "name": "If-Match",
+                        "in": "header"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Client name",
+                        "name": "client_name",
+                        "in": "formData"
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "is admin",
+                        "name": "is_admin",
+                        "in": "formData"
                     }
                 ],
                 "responses": {
                     "200": {
-                        "description": "ok",
+                        "description": "Updated",
                         "schema": {
-                            "type": "array",
-                            "items": {
-                                "$ref": "#/definitions/types.Room"
+                            "$ref": "#/definitions/main.Client"
+                        },
+                        "headers": {
+                            "ETag": {
+                                "type": "string",
+                                "description": "Version of the row, for If-Match"
                             }
                         }
                     },
                     "400": {
                         "description": "Incorrect parameter",
                         "schema": {
-                            "$ref": "#/definitions/types.APIResponse"
+                            "$ref": "#/definitions/main.ValidationResponse"
                         }
                     },
                     "404": {
@@ -1199,6 +1380,12 @@ const docTemplate = `{
                             "$ref": "#/definitions/types.APIResponse"
                         }
                     },
+                    "412": {
+                        "description": "Row has changed",
+                        "schema": {
+                            "$ref": "#/definitions/types.APIResponse"
+                        }
+                    },
                     "500": {
                         "description": "Internal server error",
                         "schema": {
@@ -1208,30 +1395,41 @@ const docTemplate = `{
                 }
             }
         },
-        "/room/id/{id}": {
+        "/client/id/{id}/balance": {
             "get": {
-                "description": "Get room by room_id",
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Get monthly opening balance, charges, shared expenses, payments and closing balance over the rooms a client is responsible for paying for today, or over those in one building. A positive balance is a debt.\nThe payers of a room are responsible for paying for it, or, if it has none, its owners and co-owners. Each of them owes the whole balance of the room.\nRooms in buildings with different currencies can't be summed: such a client needs building_id.",
                 "produces": [
                     "application/json"
                 ],
                 "tags": [
-                    "room"
+                    "client"
                 ],
-                "summary": "Get room by room_id",
+                "summary": "Get client balance",
                 "parameters": [
                     {
                         "type": "integer",
-                        "description": "Room ID",
+                        "description": "Client telegram ID",
                         "name": "id",
                         "in": "path",
                         "required": true
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Building ID",
+                        "name": "building_id",
+                        "in": "query"
                     }
                 ],
                 "responses": {
                     "200": {
                         "description": "ok",
                         "schema": {
-                            "$ref": "#/definitions/types.Room"
+                            "$ref": "#/definitions/main.Balance"
                         }
                     },
                     "400": {
@@ -1246,58 +1444,111 @@ const docTemplate = `{
                             "$ref": "#/definitions/types.APIResponse"
                         }
                     },
-                    "500": {
-                        "description": "Internal server error",
+                    "409": {
+                        "description": "Rooms in buildings with different currencies",
                         "schema": {
                             "$ref": "#/definitions/types.APIResponse"
                         }
-                    }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "$ref": "#/definitions/types.APIResponse"
+                        }
+                    }
                 }
-            },
+            }
+        },
+        "/client/id/{id}/restore": {
             "post": {
-                "description": "Create new room",
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Restore soft deleted client together with the rooms deleted with it",
                 "produces": [
                     "application/json"
                 ],
                 "tags": [
-                    "room"
+                    "client"
                 ],
-                "summary": "Create new room",
+                "summary": "Restore client",
                 "parameters": [
                     {
                         "type": "integer",
-                        "description": "Room ID",
+                        "description": "Client telegram ID",
                         "name": "id",
                         "in": "path",
                         "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Restored",
+                        "schema": {
+                            "$ref": "#/definitions/types.APIResponse"
+                        }
                     },
-                    {
-                        "type": "integer",
-                        "description": "Client ID",
-                        "name": "client_id",
-                        "in": "formData",
-                        "required": true
+                    "400": {
+                        "description": "Incorrect parameter",
+                        "schema": {
+                            "$ref": "#/definitions/types.APIResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "No deleted client",
+                        "schema": {
+                            "$ref": "#/definitions/types.APIResponse"
+                        }
                     },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "$ref": "#/definitions/types.APIResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/client/name/{name}": {
+            "get": {
+                "security": [
                     {
-                        "type": "integer",
-                        "description": "People living in room",
-                        "name": "room_people_count",
-                        "in": "formData",
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Get clients by client_name",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "client"
+                ],
+                "summary": "Get clients by client_name",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Client name",
+                        "name": "name",
+                        "in": "path",
                         "required": true
                     },
                     {
-                        "type": "number",
-                        "description": "Room area",
-                        "name": "room_area",
-                        "in": "formData",
-                        "required": true
+                        "type": "boolean",
+                        "description": "Include soft deleted rows",
+                        "name": "include_deleted",
+                        "in": "query"
                     }
                 ],
                 "responses": {
-                    "201": {
-                        "description": "Created",
+                    "200": {
+                        "description": "ok",
                         "schema": {
-                            "$ref": "#/definitions/types.APIResponse"
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/main.Client"
+                            }
                         }
                     },
                     "400": {
@@ -1306,6 +1557,12 @@ const docTemplate = `{
                             "$ref": "#/definitions/types.APIResponse"
                         }
                     },
+                    "404": {
+                        "description": "No rows",
+                        "schema": {
+                            "$ref": "#/definitions/types.APIResponse"
+                        }
+                    },
                     "500": {
                         "description": "Internal server error",
                         "schema": {
@@ -1313,30 +1570,116 @@ const docTemplate = `{
                         }
                     }
                 }
-            },
-            "delete": {
-                "description": "Delete room by room_id",
+            }
+        },
+        "/expense/all": {
+            "get": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Get a page of expenses, optionally filtered by building, category, vendor and amount",
                 "produces": [
                     "application/json"
                 ],
                 "tags": [
-                    "room"
+                    "expense"
                 ],
-                "summary": "Delete room by room_id",
+                "summary": "Get all expenses",
                 "parameters": [
                     {
                         "type": "integer",
-                        "description": "Room ID",
-                        "name": "id",
-                        "in": "path",
-                        "required": true
+                        "description": "Building ID",
+                        "name": "building_id",
+                        "in": "query"
+                    },
+                    {
+                        "enum": [
+                            "cleaning",
+                            "repairs",
+                            "common_electricity",
+                            "common_water",
+                            "heating",
+                            "elevator",
+                            "waste",
+                            "security",
+                            "insurance",
+                            "management",
+                            "other"
+                        ],
+                        "type": "string",
+                        "description": "Expense category",
+                        "name": "category",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Expense vendor",
+                        "name": "vendor",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Minimum expense amount, e.g. '120.50'",
+                        "name": "min_amount",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Maximum expense amount, e.g. '120.50'",
+                        "name": "max_amount",
+                        "in": "query"
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "Include soft deleted rows",
+                        "name": "include_deleted",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Sort field: expense_id, building_id, expense_date, expense_amount, expense_category, expense_vendor, last_edited; expense_id by default",
+                        "name": "sort",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Sort order: asc (default) or desc",
+                        "name": "order",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Page size, 50 by default, up to 500",
+                        "name": "limit",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "X-Next-Cursor of the previous page",
+                        "name": "cursor",
+                        "in": "query"
                     }
                 ],
                 "responses": {
                     "200": {
-                        "description": "Deleted",
+                        "description": "ok",
                         "schema": {
-                            "$ref": "#/definitions/types.APIResponse"
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/main.Expense"
+                            }
+                        },
+                        "headers": {
+                            "X-Next-Cursor": {
+                                "type": "string",
+                                "description": "Cursor of the next page, absent on the last page"
+                            },
+                            "X-Total-Count": {
+                                "type": "integer",
+                                "description": "Number of matching rows over all pages"
+                            }
                         }
                     },
                     "400": {
@@ -1352,16 +1695,23 @@ const docTemplate = `{
                         }
                     }
                 }
-            },
-            "patch": {
-                "description": "Patch room by room_id",
+            }
+        },
+        "/expense/allocation/room/id/{id}": {
+            "get": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Get all shared expense allocations of a room",
                 "produces": [
                     "application/json"
                 ],
                 "tags": [
-                    "room"
+                    "expense"
                 ],
-                "summary": "Patch room",
+                "summary": "Get expense allocations by room_id",
                 "parameters": [
                     {
                         "type": "integer",
@@ -1369,31 +1719,76 @@ const docTemplate = `{
                         "name": "id",
                         "in": "path",
                         "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "ok",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/main.ExpenseAllocation"
+                            }
+                        }
                     },
-                    {
-                        "type": "integer",
-                        "description": "Client ID",
-                        "name": "client_id",
-                        "in": "formData"
+                    "400": {
+                        "description": "Incorrect parameter",
+                        "schema": {
+                            "$ref": "#/definitions/types.APIResponse"
+                        }
                     },
-                    {
-                        "type": "number",
-                        "description": "Room area",
-                        "name": "room_area",
-                        "in": "formData"
+                    "404": {
+                        "description": "No rows",
+                        "schema": {
+                            "$ref": "#/definitions/types.APIResponse"
+                        }
                     },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "$ref": "#/definitions/types.APIResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/expense/category/totals": {
+            "get": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Get number and sum of expenses per building and category, optionally for one building and one vendor",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "expense"
+                ],
+                "summary": "Get expense totals per category",
+                "parameters": [
                     {
                         "type": "integer",
-                        "description": "People living in room",
-                        "name": "room_people_count",
-                        "in": "formData"
+                        "description": "Building ID",
+                        "name": "building_id",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Expense vendor",
+                        "name": "vendor",
+                        "in": "query"
                     }
                 ],
                 "responses": {
                     "200": {
-                        "description": "Updated",
+                        "description": "ok",
                         "schema": {
-                            "$ref": "#/definitions/types.APIResponse"
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/main.ExpenseCategoryTotal"
+                            }
                         }
                     },
                     "400": {
@@ -1403,7 +1798,7 @@ const docTemplate = `{
                         }
                     },
                     "404": {
-                        "description": "Record not founded in DB",
+                        "description": "No rows",
                         "schema": {
                             "$ref": "#/definitions/types.APIResponse"
                         }
@@ -1416,114 +1811,4581 @@ const docTemplate = `{
                     }
                 }
             }
-        }
-    },
-    "definitions": {
-        "errors.APIError": {
-            "type": "object",
-            "properties": {
-                "code": {
-                    "$ref": "#/definitions/errors.APIErrorCode"
-                },
-                "error": {
-                    "type": "string"
-                }
-            }
-        },
-        "errors.APIErrorCode": {
-            "type": "integer",
-            "enum": [
-                1,
-                2,
-                3,
-                4
-            ],
-            "x-enum-varnames": [
-                "ErrCodeMissingParam",
-                "ErrCodeIncorrectParam",
-                "ErrCodeSQLNoRows",
-                "ErrCodeSQLInternalError"
-            ]
-        },
-        "types.APIResponse": {
-            "type": "object",
-            "properties": {
-                "error": {
-                    "$ref": "#/definitions/errors.APIError"
-                },
-                "message": {
-                    "type": "string"
-                }
-            }
         },
-        "types.Client": {
-            "type": "object",
-            "properties": {
+        "/expense/date/range": {
+            "get": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Get expenses made from date_start up to, but not including, date_end",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "expense"
+                ],
+                "summary": "Get expenses by date range",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Expense date start",
+                        "name": "date_start",
+                        "in": "query",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Expense date end, excluded",
+                        "name": "date_end",
+                        "in": "query",
+                        "required": true
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "Include soft deleted rows",
+                        "name": "include_deleted",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "ok",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/main.Expense"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Incorrect parameter",
+                        "schema": {
+                            "$ref": "#/definitions/types.APIResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "No rows",
+                        "schema": {
+                            "$ref": "#/definitions/types.APIResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "$ref": "#/definitions/types.APIResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/expense/date/{date}": {
+            "get": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Get expenses by expense_date",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "expense"
+                ],
+                "summary": "Get expenses by expense_date",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Expense date",
+                        "name": "date",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "Include soft deleted rows",
+                        "name": "include_deleted",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "ok",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/main.Expense"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Incorrect parameter",
+                        "schema": {
+                            "$ref": "#/definitions/types.APIResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "No rows",
+                        "schema": {
+                            "$ref": "#/definitions/types.APIResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "$ref": "#/definitions/types.APIResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/expense/id/{id}": {
+            "get": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Get expense by expense_id",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "expense"
+                ],
+                "summary": "Get expense by expense_id",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Expense ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "Include soft deleted rows",
+                        "name": "include_deleted",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "ok",
+                        "schema": {
+                            "$ref": "#/definitions/main.Expense"
+                        },
+                        "headers": {
+                            "ETag": {
+                                "type": "string",
+                                "description": "Version of the row, for If-Match"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Incorrect parameter",
+                        "schema": {
+                            "$ref": "#/definitions/types.APIResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "No rows",
+                        "schema": {
+                            "$ref": "#/definitions/types.APIResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "$ref": "#/definitions/types.APIResponse"
+                        }
+                    }
+                }
+            },
+            "delete": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Soft delete expense by expense_id. Use restore to undo.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "expense"
+                ],
+                "summary": "Delete expense by expense_id",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Expense ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "ETag of the row; the delete fails if the row has changed",
+                        "name": "If-Match",
+                        "in": "header"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Deleted",
+                        "schema": {
+                            "$ref": "#/definitions/types.APIResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Incorrect parameter",
+                        "schema": {
+                            "$ref": "#/definitions/types.APIResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "No expense",
+                        "schema": {
+                            "$ref": "#/definitions/types.APIResponse"
+                        }
+                    },
+                    "412": {
+                        "description": "Row has changed",
+                        "schema": {
+                            "$ref": "#/definitions/types.APIResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "$ref": "#/definitions/types.APIResponse"
+                        }
+                    }
+                }
+            },
+            "patch": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Patch expense by expense_id. An expense can't be moved to another building.\nThe amount and date of a shared expense can't be changed: stop sharing it first, then share it again.",
+                "consumes": [
+                    "application/x-www-form-urlencoded",
+                    "application/json",
+                    "application/merge-patch+json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "expense"
+                ],
+                "summary": "Patch expense",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Expense ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "ETag of the row; the patch fails if the row has changed",
+                        "name": "If-Match",
+                        "in": "header"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Date 'yyyy-mm-dd hh:mm:ss'",
+                        "name": "expense_date",
+                        "in": "formData"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Amount, e.g. '120.50'",
+                        "name": "expense_amount",
+                        "in": "formData"
+                    },
+                    {
+                        "enum": [
+                            "cleaning",
+                            "repairs",
+                            "common_electricity",
+                            "common_water",
+                            "heating",
+                            "elevator",
+                            "waste",
+                            "security",
+                            "insurance",
+                            "management",
+                            "other"
+                        ],
+                        "type": "string",
+                        "description": "Expense category",
+                        "name": "expense_category",
+                        "in": "formData"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Vendor, empty value clears it",
+                        "name": "expense_vendor",
+                        "in": "formData"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Description, empty value clears it",
+                        "name": "expense_description",
+                        "in": "formData"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Invoice or receipt reference, empty value clears it",
+                        "name": "expense_document_ref",
+                        "in": "formData"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Updated",
+                        "schema": {
+                            "$ref": "#/definitions/main.Expense"
+                        },
+                        "headers": {
+                            "ETag": {
+                                "type": "string",
+                                "description": "Version of the row, for If-Match"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Incorrect parameter",
+                        "schema": {
+                            "$ref": "#/definitions/main.ValidationResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "No rows",
+                        "schema": {
+                            "$ref": "#/definitions/types.APIResponse"
+                        }
+                    },
+                    "409": {
+                        "description": "Amount or date of a shared expense",
+                        "schema": {
+                            "$ref": "#/definitions/types.APIResponse"
+                        }
+                    },
+                    "412": {
+                        "description": "Row has changed",
+                        "schema": {
+                            "$ref": "#/definitions/types.APIResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "$ref": "#/definitions/types.APIResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/expense/id/{id}/restore": {
+            "post": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Restore soft deleted expense by expense_id",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "expense"
+                ],
+                "summary": "Restore expense",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Expense ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Restored",
+                        "schema": {
+                            "$ref": "#/definitions/types.APIResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Incorrect parameter",
+                        "schema": {
+                            "$ref": "#/definitions/types.APIResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "No deleted expense",
+                        "schema": {
+                            "$ref": "#/definitions/types.APIResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "$ref": "#/definitions/types.APIResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/expense/id/{id}/share": {
+            "get": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Get distribution rule, rounding remainder and per-room allocations of a shared expense",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "expense"
+                ],
+                "summary": "Get expense share",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Expense ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "ok",
+                        "schema": {
+                            "$ref": "#/definitions/main.ExpenseShare"
+                        }
+                    },
+                    "400": {
+                        "description": "Incorrect parameter",
+                        "schema": {
+                            "$ref": "#/definitions/types.APIResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "No rows",
+                        "schema": {
+                            "$ref": "#/definitions/types.APIResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "$ref": "#/definitions/types.APIResponse"
+                        }
+                    }
+                }
+            },
+            "post": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Mark expense as shared and write per-room allocations between the rooms of the building of the expense, by their area or people count in effect on the expense date. Sharing an already shared expense replaces its allocations.\nEvery room gets its share rounded down to a whole kopeck; the kopecks left, share_remainder, go one each to the rooms with the largest fractions.",
+                "consumes": [
+                    "application/x-www-form-urlencoded",
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "expense"
+                ],
+                "summary": "Share expense between rooms",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Expense ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "enum": [
+                            "area",
+                            "people",
+                            "equal"
+                        ],
+                        "type": "string",
+                        "description": "Distribution rule",
+                        "name": "share_rule",
+                        "in": "formData",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "201": {
+                        "description": "New share",
+                        "schema": {
+                            "$ref": "#/definitions/main.ExpenseShare"
+                        },
+                        "headers": {
+                            "Location": {
+                                "type": "string",
+                                "description": "URL of the share"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Incorrect parameter",
+                        "schema": {
+                            "$ref": "#/definitions/main.ValidationResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "No rows",
+                        "schema": {
+                            "$ref": "#/definitions/types.APIResponse"
+                        }
+                    },
+                    "412": {
+                        "description": "Expense changed while sharing it",
+                        "schema": {
+                            "$ref": "#/definitions/types.APIResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "$ref": "#/definitions/types.APIResponse"
+                        }
+                    }
+                }
+            },
+            "delete": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Remove share and all per-room allocations of an expense",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "expense"
+                ],
+                "summary": "Stop sharing expense",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Expense ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Deleted",
+                        "schema": {
+                            "$ref": "#/definitions/types.APIResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Incorrect parameter",
+                        "schema": {
+                            "$ref": "#/definitions/types.APIResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Expense is not shared",
+                        "schema": {
+                            "$ref": "#/definitions/types.APIResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "$ref": "#/definitions/types.APIResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/expense/new": {
+            "post": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Create new expense of a building, in the currency of the building",
+                "consumes": [
+                    "application/x-www-form-urlencoded",
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "expense"
+                ],
+                "summary": "Create new expense",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Building ID",
+                        "name": "building_id",
+                        "in": "formData",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Expense date",
+                        "name": "expense_date",
+                        "in": "formData"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Expense amount, e.g. '120.50'",
+                        "name": "expense_amount",
+                        "in": "formData",
+                        "required": true
+                    },
+                    {
+                        "enum": [
+                            "cleaning",
+                            "repairs",
+                            "common_electricity",
+                            "common_water",
+                            "heating",
+                            "elevator",
+                            "waste",
+                            "security",
+                            "insurance",
+                            "management",
+                            "other"
+                        ],
+                        "type": "string",
+                        "description": "Expense category, 'other' by default",
+                        "name": "expense_category",
+                        "in": "formData"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Vendor",
+                        "name": "expense_vendor",
+                        "in": "formData"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Description",
+                        "name": "expense_description",
+                        "in": "formData"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Invoice or receipt reference",
+                        "name": "expense_document_ref",
+                        "in": "formData"
+                    }
+                ],
+                "responses": {
+                    "201": {
+                        "description": "New expense",
+                        "schema": {
+                            "$ref": "#/definitions/main.Expense"
+                        },
+                        "headers": {
+                            "ETag": {
+                                "type": "string",
+                                "description": "ETag of the new expense"
+                            },
+                            "Location": {
+                                "type": "string",
+                                "description": "URL of the new expense"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Incorrect parameter",
+                        "schema": {
+                            "$ref": "#/definitions/main.ValidationResponse"
+                        }
+                    },
+                    "422": {
+                        "description": "No such building",
+                        "schema": {
+                            "$ref": "#/definitions/types.APIResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "$ref": "#/definitions/types.APIResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/meter/all": {
+            "get": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Get all meters, optionally only those of the rooms of a building",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "meter"
+                ],
+                "summary": "Get all meters",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Building ID",
+                        "name": "building_id",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "ok",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/main.Meter"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Incorrect parameter",
+                        "schema": {
+                            "$ref": "#/definitions/types.APIResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "No rows",
+                        "schema": {
+                            "$ref": "#/definitions/types.APIResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "$ref": "#/definitions/types.APIResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/meter/id/{id}": {
+            "get": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Get meter by meter_id",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "meter"
+                ],
+                "summary": "Get meter by meter_id",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Meter ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "ok",
+                        "schema": {
+                            "$ref": "#/definitions/main.Meter"
+                        }
+                    },
+                    "400": {
+                        "description": "Incorrect parameter",
+                        "schema": {
+                            "$ref": "#/definitions/types.APIResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "No rows",
+                        "schema": {
+                            "$ref": "#/definitions/types.APIResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "$ref": "#/definitions/types.APIResponse"
+                        }
+                    }
+                }
+            },
+            "delete": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Delete meter and all its readings by meter_id",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "meter"
+                ],
+                "summary": "Delete meter",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Meter ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Deleted",
+                        "schema": {
+                            "$ref": "#/definitions/types.APIResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Incorrect parameter",
+                        "schema": {
+                            "$ref": "#/definitions/types.APIResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "No rows",
+                        "schema": {
+                            "$ref": "#/definitions/types.APIResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "$ref": "#/definitions/types.APIResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/meter/id/{id}/consumption/{period}": {
+            "get": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Get consumption of a meter in a billing period",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "meter"
+                ],
+                "summary": "Get meter consumption for a period",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Meter ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Period 'yyyy-mm'",
+                        "name": "period",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "ok",
+                        "schema": {
+                            "$ref": "#/definitions/main.MeterConsumption"
+                        }
+                    },
+                    "400": {
+                        "description": "Incorrect parameter",
+                        "schema": {
+                            "$ref": "#/definitions/types.APIResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "No rows",
+                        "schema": {
+                            "$ref": "#/definitions/types.APIResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "$ref": "#/definitions/types.APIResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/meter/id/{id}/reading": {
+            "post": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Submit a meter reading. Readings must be newer than and not less than the last one; jumps far above the meter's average consumption are flagged as abnormal.\nA reading with the value of the last one on the same day is not stored again: the last reading is returned instead.",
+                "consumes": [
+                    "application/x-www-form-urlencoded",
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "meter"
+                ],
+                "summary": "Submit meter reading",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Meter ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Meter value with at most 3 decimals, e.g. '10.125'",
+                        "name": "reading_value",
+                        "in": "formData",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Date 'yyyy-mm-dd hh:mm:ss', now by default",
+                        "name": "reading_date",
+                        "in": "formData"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Last reading, repeated",
+                        "schema": {
+                            "$ref": "#/definitions/main.MeterReading"
+                        }
+                    },
+                    "201": {
+                        "description": "New reading",
+                        "schema": {
+                            "$ref": "#/definitions/main.MeterReading"
+                        },
+                        "headers": {
+                            "Location": {
+                                "type": "string",
+                                "description": "URL of the new reading"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Incorrect parameter",
+                        "schema": {
+                            "$ref": "#/definitions/main.ValidationResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "No rows",
+                        "schema": {
+                            "$ref": "#/definitions/types.APIResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "$ref": "#/definitions/types.APIResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/meter/id/{id}/readings": {
+            "get": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Get all readings of a meter sorted by date",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "meter"
+                ],
+                "summary": "Get meter readings",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Meter ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "ok",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/main.MeterReading"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Incorrect parameter",
+                        "schema": {
+                            "$ref": "#/definitions/types.APIResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "No rows",
+                        "schema": {
+                            "$ref": "#/definitions/types.APIResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "$ref": "#/definitions/types.APIResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/meter/new": {
+            "post": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Create new meter in a room",
+                "consumes": [
+                    "application/x-www-form-urlencoded",
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "meter"
+                ],
+                "summary": "Create new meter",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Room ID",
+                        "name": "room_id",
+                        "in": "formData",
+                        "required": true
+                    },
+                    {
+                        "enum": [
+                            "cold_water",
+                            "hot_water",
+                            "electricity",
+                            "gas"
+                        ],
+                        "type": "string",
+                        "description": "Meter kind",
+                        "name": "meter_kind",
+                        "in": "formData",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Serial number",
+                        "name": "meter_serial",
+                        "in": "formData",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "201": {
+                        "description": "New meter",
+                        "schema": {
+                            "$ref": "#/definitions/main.Meter"
+                        },
+                        "headers": {
+                            "Location": {
+                                "type": "string",
+                                "description": "URL of the new meter"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Incorrect parameter",
+                        "schema": {
+                            "$ref": "#/definitions/main.ValidationResponse"
+                        }
+                    },
+                    "409": {
+                        "description": "Meter with the same kind and serial exists",
+                        "schema": {
+                            "$ref": "#/definitions/types.APIResponse"
+                        }
+                    },
+                    "422": {
+                        "description": "No such room",
+                        "schema": {
+                            "$ref": "#/definitions/types.APIResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "$ref": "#/definitions/types.APIResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/meter/reading/id/{id}": {
+            "get": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Get meter reading by reading_id",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "meter"
+                ],
+                "summary": "Get meter reading by reading_id",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Reading ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "ok",
+                        "schema": {
+                            "$ref": "#/definitions/main.MeterReading"
+                        }
+                    },
+                    "400": {
+                        "description": "Incorrect parameter",
+                        "schema": {
+                            "$ref": "#/definitions/types.APIResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "No rows",
+                        "schema": {
+                            "$ref": "#/definitions/types.APIResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "$ref": "#/definitions/types.APIResponse"
+                        }
+                    }
+                }
+            },
+            "delete": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Delete meter reading by reading_id. Only the last reading of a meter can be deleted.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "meter"
+                ],
+                "summary": "Delete meter reading",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Reading ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Deleted",
+                        "schema": {
+                            "$ref": "#/definitions/types.APIResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Incorrect parameter",
+                        "schema": {
+                            "$ref": "#/definitions/types.APIResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "No rows",
+                        "schema": {
+                            "$ref": "#/definitions/types.APIResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "$ref": "#/definitions/types.APIResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/meter/room/id/{id}": {
+            "get": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Get meters by room_id",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "meter"
+                ],
+                "summary": "Get meters by room_id",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Room ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "ok",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/main.Meter"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Incorrect parameter",
+                        "schema": {
+                            "$ref": "#/definitions/types.APIResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "No rows",
+                        "schema": {
+                            "$ref": "#/definitions/types.APIResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "$ref": "#/definitions/types.APIResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/meter/room/id/{id}/consumption/{period}": {
+            "get": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Get consumption of every meter of a room in a billing period. Meters without readings in the period are omitted.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "meter"
+                ],
+                "summary": "Get room consumption for a period",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Room ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Period 'yyyy-mm'",
+                        "name": "period",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "ok",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/main.MeterConsumption"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Incorrect parameter",
+                        "schema": {
+                            "$ref": "#/definitions/types.APIResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "No rows",
+                        "schema": {
+                            "$ref": "#/definitions/types.APIResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "$ref": "#/definitions/types.APIResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/payment/all": {
+            "get": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Get a page of payments, optionally filtered by building, client, room and amount",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "payment"
+                ],
+                "summary": "Get all payments",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Building ID",
+                        "name": "building_id",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Client telegram ID",
+                        "name": "client_id",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Room ID",
+                        "name": "room_id",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Minimum payment amount, e.g. '120.50'",
+                        "name": "min_amount",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Maximum payment amount, e.g. '120.50'",
+                        "name": "max_amount",
+                        "in": "query"
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "Include soft deleted rows",
+                        "name": "include_deleted",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Sort field: payment_id, client_id, room_id, payment_date, payment_amount, last_edited; payment_id by default",
+                        "name": "sort",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Sort order: asc (default) or desc",
+                        "name": "order",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Page size, 50 by default, up to 500",
+                        "name": "limit",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "X-Next-Cursor of the previous page",
+                        "name": "cursor",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "ok",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/main.Payment"
+                            }
+                        },
+                        "headers": {
+                            "X-Next-Cursor": {
+                                "type": "string",
+                                "description": "Cursor of the next page, absent on the last page"
+                            },
+                            "X-Total-Count": {
+                                "type": "integer",
+                                "description": "Number of matching rows over all pages"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Incorrect parameter",
+                        "schema": {
+                            "$ref": "#/definitions/types.APIResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "$ref": "#/definitions/types.APIResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/payment/client/id/{id}": {
+            "get": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Get all payments by client_id",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "payment"
+                ],
+                "summary": "Get all payments by client_id",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Client ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "Include soft deleted rows",
+                        "name": "include_deleted",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "ok",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/main.Payment"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Incorrect parameter",
+                        "schema": {
+                            "$ref": "#/definitions/types.APIResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "No rows",
+                        "schema": {
+                            "$ref": "#/definitions/types.APIResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "$ref": "#/definitions/types.APIResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/payment/date/range": {
+            "get": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Get payments made from date_start up to, but not including, date_end",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "payment"
+                ],
+                "summary": "Get payments by date range",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Date 'yyyy-mm-dd hh:mm:ss'",
+                        "name": "date_start",
+                        "in": "query",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Date 'yyyy-mm-dd hh:mm:ss'",
+                        "name": "date_end",
+                        "in": "query",
+                        "required": true
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "Include soft deleted rows",
+                        "name": "include_deleted",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "ok",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/main.Payment"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Incorrect parameter",
+                        "schema": {
+                            "$ref": "#/definitions/types.APIResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "No rows",
+                        "schema": {
+                            "$ref": "#/definitions/types.APIResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "$ref": "#/definitions/types.APIResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/payment/date/{date}": {
+            "get": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Get payment by date",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "payment"
+                ],
+                "summary": "Get payment by date",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Date 'yyyy-mm-dd hh:mm:ss'",
+                        "name": "date",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "Include soft deleted rows",
+                        "name": "include_deleted",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "ok",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/main.Payment"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Incorrect parameter",
+                        "schema": {
+                            "$ref": "#/definitions/types.APIResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "No rows",
+                        "schema": {
+                            "$ref": "#/definitions/types.APIResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "$ref": "#/definitions/types.APIResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/payment/id/{id}": {
+            "get": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Get payment by payment_id",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "payment"
+                ],
+                "summary": "Get payment by payment_id",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Payment ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "Include soft deleted rows",
+                        "name": "include_deleted",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "ok",
+                        "schema": {
+                            "$ref": "#/definitions/main.Payment"
+                        },
+                        "headers": {
+                            "ETag": {
+                                "type": "string",
+                                "description": "Version of the row, for If-Match"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Incorrect parameter",
+                        "schema": {
+                            "$ref": "#/definitions/types.APIResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "No rows",
+                        "schema": {
+                            "$ref": "#/definitions/types.APIResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "$ref": "#/definitions/types.APIResponse"
+                        }
+                    }
+                }
+            },
+            "delete": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Soft delete payment by payment_id. Use restore to undo.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "payment"
+                ],
+                "summary": "Delete payment",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Payment ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "ETag of the row; the delete fails if the row has changed",
+                        "name": "If-Match",
+                        "in": "header"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Deleted",
+                        "schema": {
+                            "$ref": "#/definitions/types.APIResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Incorrect parameter",
+                        "schema": {
+                            "$ref": "#/definitions/types.APIResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "No payment",
+                        "schema": {
+                            "$ref": "#/definitions/types.APIResponse"
+                        }
+                    },
+                    "412": {
+                        "description": "Row has changed",
+                        "schema": {
+                            "$ref": "#/definitions/types.APIResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "$ref": "#/definitions/types.APIResponse"
+                        }
+                    }
+                }
+            },
+            "patch": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Patch payment by payment_id. The amount of a payment in a foreign currency is in that currency and converted at the rate the payment was converted at.\nA payment can't be moved to a room of a building with another currency.",
+                "consumes": [
+                    "application/x-www-form-urlencoded",
+                    "application/json",
+                    "application/merge-patch+json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "payment"
+                ],
+                "summary": "Patch payment",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Payment ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "ETag of the row; the patch fails if the row has changed",
+                        "name": "If-Match",
+                        "in": "header"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Client ID",
+                        "name": "client_id",
+                        "in": "formData"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Room ID",
+                        "name": "room_id",
+                        "in": "formData"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Date 'yyyy-mm-dd hh:mm:ss'",
+                        "name": "payment_date",
+                        "in": "formData"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Amount in payment_currency, e.g. '120.50'",
+                        "name": "payment_amount",
+                        "in": "formData"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Updated",
+                        "schema": {
+                            "$ref": "#/definitions/main.Payment"
+                        },
+                        "headers": {
+                            "ETag": {
+                                "type": "string",
+                                "description": "Version of the row, for If-Match"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Incorrect parameter",
+                        "schema": {
+                            "$ref": "#/definitions/main.ValidationResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "No rows",
+                        "schema": {
+                            "$ref": "#/definitions/types.APIResponse"
+                        }
+                    },
+                    "409": {
+                        "description": "Room in a building with another currency",
+                        "schema": {
+                            "$ref": "#/definitions/types.APIResponse"
+                        }
+                    },
+                    "412": {
+                        "description": "Row has changed",
+                        "schema": {
+                            "$ref": "#/definitions/types.APIResponse"
+                        }
+                    },
+                    "422": {
+                        "description": "No such client or room",
+                        "schema": {
+                            "$ref": "#/definitions/types.APIResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "$ref": "#/definitions/types.APIResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/payment/id/{id}/restore": {
+            "post": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Restore soft deleted payment by payment_id",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "payment"
+                ],
+                "summary": "Restore payment",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Payment ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Restored",
+                        "schema": {
+                            "$ref": "#/definitions/types.APIResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Incorrect parameter",
+                        "schema": {
+                            "$ref": "#/definitions/types.APIResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "No deleted payment",
+                        "schema": {
+                            "$ref": "#/definitions/types.APIResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "$ref": "#/definitions/types.APIResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/payment/new": {
+            "post": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Create new payment. A payment with an external ID is created only once: repeating the request returns the original payment, and a different payment under the same ID is rejected.\nA payment in a currency other than the currency of the building of the room is converted at the exchange rates in effect on its date.",
+                "consumes": [
+                    "application/x-www-form-urlencoded",
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "payment"
+                ],
+                "summary": "Create new payment",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "External ID, same as payment_external_id",
+                        "name": "Idempotency-Key",
+                        "in": "header"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Client ID",
+                        "name": "client_id",
+                        "in": "formData",
+                        "required": true
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Room ID",
+                        "name": "room_id",
+                        "in": "formData",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Date 'yyyy-mm-dd hh:mm:ss'",
+                        "name": "payment_date",
+                        "in": "formData"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Amount in payment_currency, e.g. '120.50'",
+                        "name": "payment_amount",
+                        "in": "formData",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Payment provider charge ID",
+                        "name": "payment_provider_charge_id",
+                        "in": "formData"
+                    },
+                    {
+                        "type": "string",
+                        "description": "External transaction ID",
+                        "name": "payment_external_id",
+                        "in": "formData"
+                    },
+                    {
+                        "type": "string",
+                        "description": "ISO 4217 code of the currency received, the currency of the building by default",
+                        "name": "payment_currency",
+                        "in": "formData"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Existing payment with the same external ID",
+                        "schema": {
+                            "$ref": "#/definitions/main.Payment"
+                        }
+                    },
+                    "201": {
+                        "description": "New payment",
+                        "schema": {
+                            "$ref": "#/definitions/main.Payment"
+                        },
+                        "headers": {
+                            "ETag": {
+                                "type": "string",
+                                "description": "ETag of the new payment"
+                            },
+                            "Location": {
+                                "type": "string",
+                                "description": "URL of the new payment"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Incorrect parameter",
+                        "schema": {
+                            "$ref": "#/definitions/main.ValidationResponse"
+                        }
+                    },
+                    "409": {
+                        "description": "External ID used by another payment",
+                        "schema": {
+                            "$ref": "#/definitions/types.APIResponse"
+                        }
+                    },
+                    "422": {
+                        "description": "No such client or room, or no exchange rate of the currency",
+                        "schema": {
+                            "$ref": "#/definitions/types.APIResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "$ref": "#/definitions/types.APIResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/payment/room/id/{id}": {
+            "get": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Get all payments by room_id",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "payment"
+                ],
+                "summary": "Get all payments by room_id",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Room ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "Include soft deleted rows",
+                        "name": "include_deleted",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "ok",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/main.Payment"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Incorrect parameter",
+                        "schema": {
+                            "$ref": "#/definitions/types.APIResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "No rows",
+                        "schema": {
+                            "$ref": "#/definitions/types.APIResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "$ref": "#/definitions/types.APIResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/rate/all": {
+            "get": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Get all exchange rates, optionally of one currency",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "rate"
+                ],
+                "summary": "Get all exchange rates",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "ISO 4217 currency code",
+                        "name": "currency",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "ok",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/main.Rate"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Incorrect parameter",
+                        "schema": {
+                            "$ref": "#/definitions/types.APIResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "No rows",
+                        "schema": {
+                            "$ref": "#/definitions/types.APIResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "$ref": "#/definitions/types.APIResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/rate/currency/{currency}/day/{day}": {
+            "get": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Get the latest rate of currency whose date is not after day",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "rate"
+                ],
+                "summary": "Get exchange rate in effect on a day",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "ISO 4217 currency code",
+                        "name": "currency",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Day 'yyyy-mm-dd'",
+                        "name": "day",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "ok",
+                        "schema": {
+                            "$ref": "#/definitions/main.Rate"
+                        }
+                    },
+                    "400": {
+                        "description": "Incorrect parameter",
+                        "schema": {
+                            "$ref": "#/definitions/main.ValidationResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "No rows",
+                        "schema": {
+                            "$ref": "#/definitions/types.APIResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "$ref": "#/definitions/types.APIResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/rate/id/{id}": {
+            "get": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Get exchange rate by rate_id",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "rate"
+                ],
+                "summary": "Get exchange rate by rate_id",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Rate ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "ok",
+                        "schema": {
+                            "$ref": "#/definitions/main.Rate"
+                        }
+                    },
+                    "400": {
+                        "description": "Incorrect parameter",
+                        "schema": {
+                            "$ref": "#/definitions/types.APIResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "No rows",
+                        "schema": {
+                            "$ref": "#/definitions/types.APIResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "$ref": "#/definitions/types.APIResponse"
+                        }
+                    }
+                }
+            },
+            "delete": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Delete exchange rate by rate_id. Payments already converted at it keep the rate.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "rate"
+                ],
+                "summary": "Delete exchange rate",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Rate ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Deleted",
+                        "schema": {
+                            "$ref": "#/definitions/types.APIResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Incorrect parameter",
+                        "schema": {
+                            "$ref": "#/definitions/types.APIResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "No rows",
+                        "schema": {
+                            "$ref": "#/definitions/types.APIResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "$ref": "#/definitions/types.APIResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/rate/new": {
+            "post": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Create new exchange rate of a currency in the base currency. Payments already converted keep the rate they were converted at.",
+                "consumes": [
+                    "application/x-www-form-urlencoded",
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "rate"
+                ],
+                "summary": "Create new exchange rate",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "ISO 4217 currency code, other than the base currency",
+                        "name": "rate_currency",
+                        "in": "formData",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Day 'yyyy-mm-dd' from which the rate applies",
+                        "name": "rate_date",
+                        "in": "formData",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Price of one unit of the currency in the base currency, e.g. '0.012345'",
+                        "name": "rate_value",
+                        "in": "formData",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "201": {
+                        "description": "New rate",
+                        "schema": {
+                            "$ref": "#/definitions/main.Rate"
+                        },
+                        "headers": {
+                            "Location": {
+                                "type": "string",
+                                "description": "URL of the new rate"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Incorrect parameter",
+                        "schema": {
+                            "$ref": "#/definitions/main.ValidationResponse"
+                        }
+                    },
+                    "409": {
+                        "description": "Currency already has a rate on that day",
+                        "schema": {
+                            "$ref": "#/definitions/types.APIResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "$ref": "#/definitions/types.APIResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/room/all": {
+            "get": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Get a page of rooms, optionally filtered by building, client and area",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "room"
+                ],
+                "summary": "Get all rooms",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Building ID",
+                        "name": "building_id",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Client telegram ID",
+                        "name": "client_id",
+                        "in": "query"
+                    },
+                    {
+                        "type": "number",
+                        "description": "Minimum room area",
+                        "name": "min_area",
+                        "in": "query"
+                    },
+                    {
+                        "type": "number",
+                        "description": "Maximum room area",
+                        "name": "max_area",
+                        "in": "query"
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "Include soft deleted rows",
+                        "name": "include_deleted",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Sort field: room_id, building_id, room_number, client_id, room_area, room_people_count, last_edited; room_id by default",
+                        "name": "sort",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Sort order: asc (default) or desc",
+                        "name": "order",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Page size, 50 by default, up to 500",
+                        "name": "limit",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "X-Next-Cursor of the previous page",
+                        "name": "cursor",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "ok",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/main.Room"
+                            }
+                        },
+                        "headers": {
+                            "X-Next-Cursor": {
+                                "type": "string",
+                                "description": "Cursor of the next page, absent on the last page"
+                            },
+                            "X-Total-Count": {
+                                "type": "integer",
+                                "description": "Number of matching rows over all pages"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Incorrect parameter",
+                        "schema": {
+                            "$ref": "#/definitions/types.APIResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "$ref": "#/definitions/types.APIResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/room/client/id/{id}": {
+            "get": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Get the rooms a client is a member of today, with the roles of the client in each and whether it is responsible for paying for it",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "room"
+                ],
+                "summary": "Get rooms by client_id",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Client ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "Include soft deleted rows",
+                        "name": "include_deleted",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "ok",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/main.ClientRoom"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Incorrect parameter",
+                        "schema": {
+                            "$ref": "#/definitions/types.APIResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "No rows",
+                        "schema": {
+                            "$ref": "#/definitions/types.APIResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "$ref": "#/definitions/types.APIResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/room/id/{id}": {
+            "get": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Get room by room_id",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "room"
+                ],
+                "summary": "Get room by room_id",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Room ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "Include soft deleted rows",
+                        "name": "include_deleted",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "ok",
+                        "schema": {
+                            "$ref": "#/definitions/main.Room"
+                        },
+                        "headers": {
+                            "ETag": {
+                                "type": "string",
+                                "description": "Version of the row, for If-Match"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Incorrect parameter",
+                        "schema": {
+                            "$ref": "#/definitions/types.APIResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "No rows",
+                        "schema": {
+                            "$ref": "#/definitions/types.APIResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "$ref": "#/definitions/types.APIResponse"
+                        }
+                    }
+                }
+            },
+            "post": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Create new room. The client of the room becomes its first owner.",
+                "consumes": [
+                    "application/x-www-form-urlencoded",
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "room"
+                ],
+                "summary": "Create new room",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Room ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Building ID",
+                        "name": "building_id",
+                        "in": "formData",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Room number within the building, the room ID by default",
+                        "name": "room_number",
+                        "in": "formData"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "ID of the client the room is registered to",
+                        "name": "client_id",
+                        "in": "formData",
+                        "required": true
+                    },
+                    {
+                        "type": "integer",
+                        "description": "People living in room",
+                        "name": "room_people_count",
+                        "in": "formData",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Room area in m², at most 2 decimals",
+                        "name": "room_area",
+                        "in": "formData",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Day 'yyyy-mm-dd' from which the people count and area are in effect, not after today; the first day of the current month by default",
+                        "name": "room_attributes_from",
+                        "in": "formData"
+                    }
+                ],
+                "responses": {
+                    "201": {
+                        "description": "New room",
+                        "schema": {
+                            "$ref": "#/definitions/main.Room"
+                        },
+                        "headers": {
+                            "ETag": {
+                                "type": "string",
+                                "description": "ETag of the new room"
+                            },
+                            "Location": {
+                                "type": "string",
+                                "description": "URL of the new room"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Incorrect parameter",
+                        "schema": {
+                            "$ref": "#/definitions/main.ValidationResponse"
+                        }
+                    },
+                    "409": {
+                        "description": "Room or room number already exists",
+                        "schema": {
+                            "$ref": "#/definitions/types.APIResponse"
+                        }
+                    },
+                    "422": {
+                        "description": "No such building or client",
+                        "schema": {
+                            "$ref": "#/definitions/types.APIResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "$ref": "#/definitions/types.APIResponse"
+                        }
+                    }
+                }
+            },
+            "delete": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Soft delete room by room_id. Use restore to undo.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "room"
+                ],
+                "summary": "Delete room by room_id",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Room ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "ETag of the row; the delete fails if the row has changed",
+                        "name": "If-Match",
+                        "in": "header"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Deleted",
+                        "schema": {
+                            "$ref": "#/definitions/types.APIResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Incorrect parameter",
+                        "schema": {
+                            "$ref": "#/definitions/types.APIResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "No room",
+                        "schema": {
+                            "$ref": "#/definitions/types.APIResponse"
+                        }
+                    },
+                    "412": {
+                        "description": "Row has changed",
+                        "schema": {
+                            "$ref": "#/definitions/types.APIResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "$ref": "#/definitions/types.APIResponse"
+                        }
+                    }
+                }
+            },
+            "patch": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Patch room by room_id. A room can't be moved to another building. Changing client_id leaves the members of the room as they are.\nA new room_area or room_people_count is recorded as a version of the attributes of the room in effect from room_attributes_from, replacing a version of that day. Fields left out keep their values of that day. A version before the latest one only corrects the history: the room keeps the values of its latest version.",
+                "consumes": [
+                    "application/x-www-form-urlencoded",
+                    "application/json",
+                    "application/merge-patch+json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "room"
+                ],
+                "summary": "Patch room",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Room ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "ETag of the row; the patch fails if the row has changed",
+                        "name": "If-Match",
+                        "in": "header"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Room number within the building",
+                        "name": "room_number",
+                        "in": "formData"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "ID of the client the room is registered to",
+                        "name": "client_id",
+                        "in": "formData"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Room area in m², at most 2 decimals",
+                        "name": "room_area",
+                        "in": "formData"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "People living in room",
+                        "name": "room_people_count",
+                        "in": "formData"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Day 'yyyy-mm-dd' from which room_area and room_people_count are in effect, not after today; the first day of the current month by default",
+                        "name": "room_attributes_from",
+                        "in": "formData"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Updated",
+                        "schema": {
+                            "$ref": "#/definitions/main.Room"
+                        },
+                        "headers": {
+                            "ETag": {
+                                "type": "string",
+                                "description": "Version of the row, for If-Match"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Incorrect parameter",
+                        "schema": {
+                            "$ref": "#/definitions/main.ValidationResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Record not founded in DB",
+                        "schema": {
+                            "$ref": "#/definitions/types.APIResponse"
+                        }
+                    },
+                    "409": {
+                        "description": "Room number already exists",
+                        "schema": {
+                            "$ref": "#/definitions/types.APIResponse"
+                        }
+                    },
+                    "412": {
+                        "description": "Row has changed",
+                        "schema": {
+                            "$ref": "#/definitions/types.APIResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "$ref": "#/definitions/types.APIResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/room/id/{id}/attributes": {
+            "get": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Get the versions of the people count and area of a room, oldest first. Each is in effect from room_attributes_from until the next one.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "room"
+                ],
+                "summary": "Get room attribute history",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Room ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "ok",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/main.RoomAttributes"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Incorrect parameter",
+                        "schema": {
+                            "$ref": "#/definitions/types.APIResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "No rows",
+                        "schema": {
+                            "$ref": "#/definitions/types.APIResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "$ref": "#/definitions/types.APIResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/room/id/{id}/balance": {
+            "get": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Get monthly opening balance, charges, shared expenses, payments and closing balance of a room. A positive balance is a debt.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "room"
+                ],
+                "summary": "Get room balance",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Room ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "ok",
+                        "schema": {
+                            "$ref": "#/definitions/main.Balance"
+                        }
+                    },
+                    "400": {
+                        "description": "Incorrect parameter",
+                        "schema": {
+                            "$ref": "#/definitions/types.APIResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "No rows",
+                        "schema": {
+                            "$ref": "#/definitions/types.APIResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "$ref": "#/definitions/types.APIResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/room/id/{id}/members": {
+            "get": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Get the owners, co-owners, tenants and payers of a room, past and future ones included unless day is given",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "room"
+                ],
+                "summary": "Get room members",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Room ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Day 'yyyy-mm-dd': only the members on that day",
+                        "name": "day",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "ok",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/main.RoomMember"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Incorrect parameter",
+                        "schema": {
+                            "$ref": "#/definitions/types.APIResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "No rows",
+                        "schema": {
+                            "$ref": "#/definitions/types.APIResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "$ref": "#/definitions/types.APIResponse"
+                        }
+                    }
+                }
+            },
+            "post": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Add an owner, co-owner, tenant or payer to a room. A client can't hold the same role in a room twice on a day.",
+                "consumes": [
+                    "application/x-www-form-urlencoded",
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "room"
+                ],
+                "summary": "Add room member",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Room ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Client ID",
+                        "name": "client_id",
+                        "in": "formData",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Role: owner, co-owner, tenant or payer",
+                        "name": "member_role",
+                        "in": "formData",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Share of the ownership over 0 up to 1 with at most 4 decimals, of owners and co-owners only. The shares of a room can't add up to more than 1 on a day.",
+                        "name": "member_share",
+                        "in": "formData"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Day 'yyyy-mm-dd' from which the membership holds",
+                        "name": "member_from",
+                        "in": "formData"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Day 'yyyy-mm-dd' from which the membership no longer holds",
+                        "name": "member_until",
+                        "in": "formData"
+                    }
+                ],
+                "responses": {
+                    "201": {
+                        "description": "New member",
+                        "schema": {
+                            "$ref": "#/definitions/main.RoomMember"
+                        },
+                        "headers": {
+                            "ETag": {
+                                "type": "string",
+                                "description": "ETag of the new member"
+                            },
+                            "Location": {
+                                "type": "string",
+                                "description": "URL of the new member"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Incorrect parameter",
+                        "schema": {
+                            "$ref": "#/definitions/main.ValidationResponse"
+                        }
+                    },
+                    "409": {
+                        "description": "Client already has the role in the room",
+                        "schema": {
+                            "$ref": "#/definitions/types.APIResponse"
+                        }
+                    },
+                    "422": {
+                        "description": "No such room or client",
+                        "schema": {
+                            "$ref": "#/definitions/types.APIResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "$ref": "#/definitions/types.APIResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/room/id/{id}/restore": {
+            "post": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Restore soft deleted room by room_id. Rooms of a deleted client are restored with the client.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "room"
+                ],
+                "summary": "Restore room",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Room ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Restored",
+                        "schema": {
+                            "$ref": "#/definitions/types.APIResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Incorrect parameter",
+                        "schema": {
+                            "$ref": "#/definitions/types.APIResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "No deleted room",
+                        "schema": {
+                            "$ref": "#/definitions/types.APIResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "$ref": "#/definitions/types.APIResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/room/member/id/{id}": {
+            "get": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Get room member by member_id",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "room"
+                ],
+                "summary": "Get room member by member_id",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Member ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "ok",
+                        "schema": {
+                            "$ref": "#/definitions/main.RoomMember"
+                        },
+                        "headers": {
+                            "ETag": {
+                                "type": "string",
+                                "description": "Version of the row, for If-Match"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Incorrect parameter",
+                        "schema": {
+                            "$ref": "#/definitions/types.APIResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "No rows",
+                        "schema": {
+                            "$ref": "#/definitions/types.APIResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "$ref": "#/definitions/types.APIResponse"
+                        }
+                    }
+                }
+            },
+            "delete": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Delete a room member by member_id. To keep the history of a room, set member_until instead.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "room"
+                ],
+                "summary": "Delete room member",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Member ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "ETag of the row; the delete fails if the row has changed",
+                        "name": "If-Match",
+                        "in": "header"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Deleted",
+                        "schema": {
+                            "$ref": "#/definitions/types.APIResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Incorrect parameter",
+                        "schema": {
+                            "$ref": "#/definitions/types.APIResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "No rows",
+                        "schema": {
+                            "$ref": "#/definitions/types.APIResponse"
+                        }
+                    },
+                    "412": {
+                        "description": "Row has changed",
+                        "schema": {
+                            "$ref": "#/definitions/types.APIResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "$ref": "#/definitions/types.APIResponse"
+                        }
+                    }
+                }
+            },
+            "patch": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Patch the role, share and dates of a room member by member_id. A null of a merge patch clears the share or a date; a member_share of 0 also clears the share.",
+                "consumes": [
+                    "application/x-www-form-urlencoded",
+                    "application/json",
+                    "application/merge-patch+json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "room"
+                ],
+                "summary": "Patch room member",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Member ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "ETag of the row; the patch fails if the row has changed",
+                        "name": "If-Match",
+                        "in": "header"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Role: owner, co-owner, tenant or payer",
+                        "name": "member_role",
+                        "in": "formData"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Share of the ownership over 0 up to 1 with at most 4 decimals, of owners and co-owners only. The shares of a room can't add up to more than 1 on a day.",
+                        "name": "member_share",
+                        "in": "formData"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Day 'yyyy-mm-dd' from which the membership holds",
+                        "name": "member_from",
+                        "in": "formData"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Day 'yyyy-mm-dd' from which the membership no longer holds",
+                        "name": "member_until",
+                        "in": "formData"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Updated",
+                        "schema": {
+                            "$ref": "#/definitions/main.RoomMember"
+                        },
+                        "headers": {
+                            "ETag": {
+                                "type": "string",
+                                "description": "Version of the row, for If-Match"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Incorrect parameter",
+                        "schema": {
+                            "$ref": "#/definitions/main.ValidationResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "No rows",
+                        "schema": {
+                            "$ref": "#/definitions/types.APIResponse"
+                        }
+                    },
+                    "409": {
+                        "description": "Client already has the role in the room",
+                        "schema": {
+                            "$ref": "#/definitions/types.APIResponse"
+                        }
+                    },
+                    "412": {
+                        "description": "Row has changed",
+                        "schema": {
+                            "$ref": "#/definitions/types.APIResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "$ref": "#/definitions/types.APIResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/tariff/all": {
+            "get": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Get all tariff versions, optionally only those that apply to a building: its own and those of every building",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "tariff"
+                ],
+                "summary": "Get all tariffs",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Building ID",
+                        "name": "building_id",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "ok",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/main.Tariff"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Incorrect parameter",
+                        "schema": {
+                            "$ref": "#/definitions/types.APIResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "No rows",
+                        "schema": {
+                            "$ref": "#/definitions/types.APIResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "$ref": "#/definitions/types.APIResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/tariff/id/{id}": {
+            "get": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Get tariff by tariff_id",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "tariff"
+                ],
+                "summary": "Get tariff by tariff_id",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Tariff ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "ok",
+                        "schema": {
+                            "$ref": "#/definitions/main.Tariff"
+                        }
+                    },
+                    "400": {
+                        "description": "Incorrect parameter",
+                        "schema": {
+                            "$ref": "#/definitions/types.APIResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "No rows",
+                        "schema": {
+                            "$ref": "#/definitions/types.APIResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "$ref": "#/definitions/types.APIResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/tariff/new": {
+            "post": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Create new tariff version. Existing tariffs are never changed, so charges keep the rates they were computed with.",
+                "consumes": [
+                    "application/x-www-form-urlencoded",
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "tariff"
+                ],
+                "summary": "Create new tariff version",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Building ID, none for a tariff of every building",
+                        "name": "building_id",
+                        "in": "formData"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Price per m² of room_area, at most 4 decimals",
+                        "name": "tariff_area_rate",
+                        "in": "formData",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Price per person of room_people_count, at most 4 decimals",
+                        "name": "tariff_people_rate",
+                        "in": "formData",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Fixed fee per room, e.g. '120.50'",
+                        "name": "tariff_fixed_fee",
+                        "in": "formData",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Period 'yyyy-mm' from which the tariff applies",
+                        "name": "tariff_effective_from",
+                        "in": "formData",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "201": {
+                        "description": "New tariff",
+                        "schema": {
+                            "$ref": "#/definitions/main.Tariff"
+                        },
+                        "headers": {
+                            "Location": {
+                                "type": "string",
+                                "description": "URL of the new tariff"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Incorrect parameter",
+                        "schema": {
+                            "$ref": "#/definitions/main.ValidationResponse"
+                        }
+                    },
+                    "422": {
+                        "description": "No such building",
+                        "schema": {
+                            "$ref": "#/definitions/types.APIResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "$ref": "#/definitions/types.APIResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/tariff/period/{period}": {
+            "get": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Get the latest tariff whose effective date is not after the first day of period. With building_id, the latest tariff of the building wins over those of every building.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "tariff"
+                ],
+                "summary": "Get tariff in effect for a period",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Period 'yyyy-mm'",
+                        "name": "period",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Building ID",
+                        "name": "building_id",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "ok",
+                        "schema": {
+                            "$ref": "#/definitions/main.Tariff"
+                        }
+                    },
+                    "400": {
+                        "description": "Incorrect parameter",
+                        "schema": {
+                            "$ref": "#/definitions/main.ValidationResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "No rows",
+                        "schema": {
+                            "$ref": "#/definitions/types.APIResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "$ref": "#/definitions/types.APIResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/token/client/id/{id}": {
+            "get": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Get API tokens of a client. Token values are not returned.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "token"
+                ],
+                "summary": "Get API tokens by client_id",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Client ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "ok",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/main.APIToken"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Incorrect parameter",
+                        "schema": {
+                            "$ref": "#/definitions/types.APIResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "No rows",
+                        "schema": {
+                            "$ref": "#/definitions/types.APIResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "$ref": "#/definitions/types.APIResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/token/id/{id}": {
+            "get": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Get API token by token_id. The token value is not returned.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "token"
+                ],
+                "summary": "Get API token by token_id",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Token ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "ok",
+                        "schema": {
+                            "$ref": "#/definitions/main.APIToken"
+                        }
+                    },
+                    "400": {
+                        "description": "Incorrect parameter",
+                        "schema": {
+                            "$ref": "#/definitions/types.APIResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "No rows",
+                        "schema": {
+                            "$ref": "#/definitions/types.APIResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "$ref": "#/definitions/types.APIResponse"
+                        }
+                    }
+                }
+            },
+            "delete": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Delete API token by token_id",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "token"
+                ],
+                "summary": "Delete API token",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Token ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Deleted",
+                        "schema": {
+                            "$ref": "#/definitions/types.APIResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Incorrect parameter",
+                        "schema": {
+                            "$ref": "#/definitions/types.APIResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "No rows",
+                        "schema": {
+                            "$ref": "#/definitions/types.APIResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "$ref": "#/definitions/types.APIResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/token/new": {
+            "post": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Create API token for a client. The token is only returned by this call.",
+                "consumes": [
+                    "application/x-www-form-urlencoded",
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "token"
+                ],
+                "summary": "Create API token",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Client ID",
+                        "name": "client_id",
+                        "in": "formData",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Token name",
+                        "name": "token_name",
+                        "in": "formData"
+                    }
+                ],
+                "responses": {
+                    "201": {
+                        "description": "New token",
+                        "schema": {
+                            "$ref": "#/definitions/main.APIToken"
+                        },
+                        "headers": {
+                            "Location": {
+                                "type": "string",
+                                "description": "URL of the new token"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Incorrect parameter",
+                        "schema": {
+                            "$ref": "#/definitions/main.ValidationResponse"
+                        }
+                    },
+                    "422": {
+                        "description": "No such client",
+                        "schema": {
+                            "$ref": "#/definitions/types.APIResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "$ref": "#/definitions/types.APIResponse"
+                        }
+                    }
+                }
+            }
+        }
+    },
+    "definitions": {
+        "types.APIResponse": {
+            "type": "object",
+            "properties": {
+                "error": {
+                    "$ref": "#/definitions/errors.APIError"
+                },
+                "message": {
+                    "type": "string"
+                }
+            }
+        },
+        "errors.APIError": {
+            "type": "object",
+            "properties": {
+                "code": {
+                    "$ref": "#/definitions/errors.APIErrorCode"
+                },
+                "error": {
+                    "type": "string"
+                }
+            }
+        },
+        "errors.APIErrorCode": {
+            "type": "integer",
+            "enum": [
+                1,
+                2,
+                3,
+                4
+            ],
+            "x-enum-varnames": [
+                "ErrCodeMissingParam",
+                "ErrCodeIncorrectParam",
+                "ErrCodeSQLNoRows",
+                "ErrCodeSQLInternalError"
+            ]
+        },
+        "main.APIToken": {
+            "type": "object",
+            "properties": {
+                "client_id": {
+                    "type": "integer"
+                },
+                "last_edited": {
+                    "type": "string"
+                },
+                "token": {
+                    "type": "string"
+                },
+                "token_id": {
+                    "type": "integer"
+                },
+                "token_name": {
+                    "type": "string"
+                }
+            }
+        },
+        "main.AuditRecord": {
+            "type": "object",
+            "properties": {
+                "actor_client_id": {
+                    "type": "integer"
+                },
+                "actor_role": {
+                    "$ref": "#/definitions/main.Role"
+                },
+                "audit_action": {
+                    "type": "string"
+                },
+                "audit_after": {
+                    "type": "object"
+                },
+                "audit_before": {
+                    "type": "object"
+                },
+                "audit_date": {
+                    "type": "string"
+                },
+                "audit_entity": {
+                    "type": "string"
+                },
+                "audit_entity_id": {
+                    "type": "string"
+                },
+                "audit_id": {
+                    "type": "integer"
+                },
+                "request_id": {
+                    "type": "string"
+                }
+            }
+        },
+        "main.Balance": {
+            "type": "object",
+            "properties": {
+                "building_id": {
+                    "type": "integer"
+                },
+                "client_id": {
+                    "type": "integer"
+                },
+                "closing_balance": {
+                    "type": "string"
+                },
+                "currency": {
+                    "type": "string"
+                },
+                "months": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/main.BalanceMonth"
+                    }
+                },
+                "room_id": {
+                    "type": "integer"
+                }
+            }
+        },
+        "main.BalanceMonth": {
+            "type": "object",
+            "properties": {
+                "charges": {
+                    "type": "string"
+                },
+                "closing_balance": {
+                    "type": "string"
+                },
+                "expenses": {
+                    "type": "string"
+                },
+                "opening_balance": {
+                    "type": "string"
+                },
+                "payments": {
+                    "type": "string"
+                },
+                "period": {
+                    "type": "string"
+                }
+            }
+        },
+        "main.BotState": {
+            "type": "object",
+            "properties": {
+                "last_edited": {
+                    "type": "string"
+                },
+                "state_expires": {
+                    "type": "string"
+                },
+                "state_key": {
+                    "type": "string"
+                },
+                "state_value": {
+                    "type": "string"
+                }
+            }
+        },
+        "main.Building": {
+            "type": "object",
+            "properties": {
+                "building_address": {
+                    "type": "string"
+                },
+                "building_contact": {
+                    "type": "string"
+                },
+                "building_currency": {
+                    "type": "string"
+                },
+                "building_id": {
+                    "type": "integer"
+                },
+                "building_name": {
+                    "type": "string"
+                },
+                "last_edited": {
+                    "type": "string"
+                }
+            }
+        },
+        "main.Charge": {
+            "type": "object",
+            "properties": {
+                "charge_amount": {
+                    "type": "string"
+                },
+                "charge_area_amount": {
+                    "type": "string"
+                },
+                "charge_fixed_amount": {
+                    "type": "string"
+                },
+                "charge_id": {
+                    "type": "integer"
+                },
+                "charge_people_amount": {
+                    "type": "string"
+                },
+                "charge_period": {
+                    "type": "string"
+                },
+                "last_edited": {
+                    "type": "string"
+                },
+                "room_id": {
+                    "type": "integer"
+                },
+                "tariff_id": {
+                    "type": "integer"
+                }
+            }
+        },
+        "main.ChargeGenerateResult": {
+            "type": "object",
+            "properties": {
+                "building_id": {
+                    "type": "integer"
+                },
+                "charge_period": {
+                    "type": "string"
+                },
+                "created": {
+                    "type": "integer"
+                },
+                "tariff_id": {
+                    "type": "integer"
+                }
+            }
+        },
+        "main.Client": {
+            "type": "object",
+            "properties": {
+                "client_id": {
+                    "type": "integer"
+                },
+                "client_name": {
+                    "type": "string"
+                },
+                "deleted_at": {
+                    "type": "string"
+                },
+                "is_admin": {
+                    "type": "boolean"
+                },
+                "last_edited": {
+                    "type": "string"
+                }
+            }
+        },
+        "main.ClientRoom": {
+            "type": "object",
+            "properties": {
+                "building_id": {
+                    "type": "integer"
+                },
                 "client_id": {
                     "type": "integer"
                 },
-                "client_name": {
+                "deleted_at": {
                     "type": "string"
                 },
-                "is_admin": {
+                "last_edited": {
+                    "type": "string"
+                },
+                "member_pays": {
                     "type": "boolean"
                 },
-                "last_edited": {
+                "member_roles": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "member_share": {
+                    "type": "string"
+                },
+                "room_area": {
+                    "type": "string"
+                },
+                "room_attributes_from": {
+                    "type": "string"
+                },
+                "room_id": {
+                    "type": "integer"
+                },
+                "room_number": {
                     "type": "string"
+                },
+                "room_people_count": {
+                    "type": "integer"
                 }
             }
         },
-        "types.Expense": {
+        "main.Expense": {
             "type": "object",
             "properties": {
+                "building_id": {
+                    "type": "integer"
+                },
+                "deleted_at": {
+                    "type": "string"
+                },
                 "expense_amount": {
-                    "type": "number"
+                    "type": "string"
+                },
+                "expense_category": {
+                    "type": "string"
                 },
                 "expense_date": {
                     "type": "string"
                 },
+                "expense_description": {
+                    "type": "string"
+                },
+                "expense_document_ref": {
+                    "type": "string"
+                },
+                "expense_id": {
+                    "type": "integer"
+                },
+                "expense_vendor": {
+                    "type": "string"
+                },
+                "last_edited": {
+                    "type": "string"
+                }
+            }
+        },
+        "main.ExpenseAllocation": {
+            "type": "object",
+            "properties": {
+                "allocation_amount": {
+                    "type": "string"
+                },
+                "allocation_id": {
+                    "type": "integer"
+                },
+                "expense_id": {
+                    "type": "integer"
+                },
+                "last_edited": {
+                    "type": "string"
+                },
+                "room_id": {
+                    "type": "integer"
+                }
+            }
+        },
+        "main.ExpenseCategoryTotal": {
+            "type": "object",
+            "properties": {
+                "building_id": {
+                    "type": "integer"
+                },
+                "count": {
+                    "type": "integer"
+                },
+                "currency": {
+                    "type": "string"
+                },
+                "expense_amount": {
+                    "type": "string"
+                },
+                "expense_category": {
+                    "type": "string"
+                }
+            }
+        },
+        "main.ExpenseShare": {
+            "type": "object",
+            "properties": {
+                "allocations": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/main.ExpenseAllocation"
+                    }
+                },
                 "expense_id": {
                     "type": "integer"
                 },
                 "last_edited": {
                     "type": "string"
+                },
+                "share_remainder": {
+                    "type": "string"
+                },
+                "share_rule": {
+                    "type": "string"
+                }
+            }
+        },
+        "main.Meter": {
+            "type": "object",
+            "properties": {
+                "last_edited": {
+                    "type": "string"
+                },
+                "meter_id": {
+                    "type": "integer"
+                },
+                "meter_kind": {
+                    "type": "string"
+                },
+                "meter_serial": {
+                    "type": "string"
+                },
+                "room_id": {
+                    "type": "integer"
+                }
+            }
+        },
+        "main.MeterConsumption": {
+            "type": "object",
+            "properties": {
+                "consumption": {
+                    "type": "string"
+                },
+                "end_value": {
+                    "type": "string"
+                },
+                "meter_id": {
+                    "type": "integer"
+                },
+                "meter_kind": {
+                    "type": "string"
+                },
+                "period": {
+                    "type": "string"
+                },
+                "start_value": {
+                    "type": "string"
+                }
+            }
+        },
+        "main.MeterReading": {
+            "type": "object",
+            "properties": {
+                "last_edited": {
+                    "type": "string"
+                },
+                "meter_id": {
+                    "type": "integer"
+                },
+                "reading_abnormal": {
+                    "type": "boolean"
+                },
+                "reading_consumption": {
+                    "type": "string"
+                },
+                "reading_date": {
+                    "type": "string"
+                },
+                "reading_id": {
+                    "type": "integer"
+                },
+                "reading_value": {
+                    "type": "string"
                 }
             }
         },
-        "types.Payment": {
+        "main.Payment": {
             "type": "object",
             "properties": {
                 "client_id": {
                     "type": "integer"
                 },
+                "deleted_at": {
+                    "type": "string"
+                },
                 "last_edited": {
                     "type": "string"
                 },
+                "ledger_currency": {
+                    "description": "Amount is in LedgerCurrency, the currency of the building of the room.\nCurrency is the currency the payment was received in; a payment in\nanother currency than the ledger keeps the amount received and the rate\nit was converted at.",
+                    "type": "string"
+                },
                 "payment_amount": {
-                    "type": "number"
+                    "type": "string"
+                },
+                "payment_currency": {
+                    "type": "string"
                 },
                 "payment_date": {
                     "type": "string"
                 },
+                "payment_exchange_rate": {
+                    "type": "string"
+                },
+                "payment_external_id": {
+                    "type": "string"
+                },
                 "payment_id": {
                     "type": "integer"
                 },
+                "payment_original_amount": {
+                    "type": "string"
+                },
+                "payment_provider_charge_id": {
+                    "type": "string"
+                },
                 "room_id": {
                     "type": "integer"
                 }
             }
         },
-        "types.Room": {
+        "main.Rate": {
+            "type": "object",
+            "properties": {
+                "last_edited": {
+                    "type": "string"
+                },
+                "rate_currency": {
+                    "type": "string"
+                },
+                "rate_date": {
+                    "type": "string"
+                },
+                "rate_id": {
+                    "type": "integer"
+                },
+                "rate_value": {
+                    "type": "string"
+                }
+            }
+        },
+        "main.Role": {
+            "type": "string",
+            "enum": [
+                "service",
+                "admin",
+                "resident"
+            ],
+            "x-enum-varnames": [
+                "RoleService",
+                "RoleAdmin",
+                "RoleResident"
+            ]
+        },
+        "main.Room": {
             "type": "object",
             "properties": {
+                "building_id": {
+                    "type": "integer"
+                },
                 "client_id": {
                     "type": "integer"
                 },
+                "deleted_at": {
+                    "type": "string"
+                },
+                "last_edited": {
+                    "type": "string"
+                },
+                "room_area": {
+                    "type": "string"
+                },
+                "room_attributes_from": {
+                    "type": "string"
+                },
+                "room_id": {
+                    "type": "integer"
+                },
+                "room_number": {
+                    "type": "string"
+                },
+                "room_people_count": {
+                    "type": "integer"
+                }
+            }
+        },
+        "main.RoomAttributes": {
+            "type": "object",
+            "properties": {
+                "attribute_id": {
+                    "type": "integer"
+                },
                 "last_edited": {
                     "type": "string"
                 },
                 "room_area": {
-                    "type": "number"
+                    "type": "string"
+                },
+                "room_attributes_from": {
+                    "type": "string"
                 },
                 "room_id": {
                     "type": "integer"
@@ -1532,6 +6394,83 @@ const docTemplate = `{
                     "type": "integer"
                 }
             }
+        },
+        "main.RoomMember": {
+            "type": "object",
+            "properties": {
+                "client_id": {
+                    "type": "integer"
+                },
+                "last_edited": {
+                    "type": "string"
+                },
+                "member_from": {
+                    "type": "string"
+                },
+                "member_id": {
+                    "type": "integer"
+                },
+                "member_role": {
+                    "type": "string"
+                },
+                "member_share": {
+                    "type": "string"
+                },
+                "member_until": {
+                    "type": "string"
+                },
+                "room_id": {
+                    "type": "integer"
+                }
+            }
+        },
+        "main.Tariff": {
+            "type": "object",
+            "properties": {
+                "building_id": {
+                    "type": "integer"
+                },
+                "last_edited": {
+                    "type": "string"
+                },
+                "tariff_area_rate": {
+                    "type": "string"
+                },
+                "tariff_effective_from": {
+                    "type": "string"
+                },
+                "tariff_fixed_fee": {
+                    "type": "string"
+                },
+                "tariff_id": {
+                    "type": "integer"
+                },
+                "tariff_people_rate": {
+                    "type": "string"
+                }
+            }
+        },
+        "main.ValidationResponse": {
+            "type": "object",
+            "properties": {
+                "error": {
+                    "$ref": "#/definitions/errors.APIError"
+                },
+                "errors": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/errors.APIError"
+                    }
+                }
+            }
+        }
+    },
+    "securityDefinitions": {
+        "BearerAuth": {
+            "description": "\"Bearer \u003ctoken\u003e\": a service token from DBAPI_SERVICE_TOKENS or a client token from /token/new",
+            "type": "apiKey",
+            "name": "Authorization",
+            "in": "header"
         }
     }
 }`
@@ -0,0 +1,39 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"strings"
+)
+
+// GET by id routes of clients, rooms, payments and expenses send the ETag of
+// the row. PATCH and DELETE take it back in If-Match and fail with 412 when
+// the row has changed since, so concurrent edits can't silently overwrite
+// each other.
+//
+// The ETag is a hash of the row as the API returns it. last_edited is part of
+// it, and so are the other columns, so two edits made within the same second
+// of last_edited still get different ETags.
+
+func etag(v any) string {
+	bs, _ := json.Marshal(v)
+	sum := sha256.Sum256(bs)
+	return `"` + hex.EncodeToString(sum[:16]) + `"`
+}
+
+// etagMatch reports whether the If-Match header ifMatch accepts the row v. An
+// empty header accepts every row.
+func etagMatch(ifMatch string, v any) bool {
+	if ifMatch == "" {
+		return true
+	}
+
+	tag := etag(v)
+	for _, t := range strings.Split(ifMatch, ",") {
+		if t = strings.TrimSpace(t); t == "*" || t == tag {
+			return true
+		}
+	}
+	return false
+}
@@ -6,11 +6,15 @@ import (
 	"fmt"
 	"log"
 	"net/http"
+	"net/url"
 	"os"
 	"time"
 
 	"github.com/charmbracelet/lipgloss"
+	"github.com/gin-gonic/gin"
+	"github.com/go-sql-driver/mysql"
 	api_errors "github.com/snakehunterr/hacs_db_types/errors"
+	validators "github.com/snakehunterr/hacs_db_types/validators"
 )
 
 var (
@@ -27,57 +31,165 @@ func logError(v ...any) {
 	logger.Println(fmt.Sprintf(" %s %s: ", time.Now().Format("2006/01/02 15:04:05"), logErrorMessage) + fmt.Sprint(v...))
 }
 
-func queryRow[T any](
-	dst T,
-	fn func(T, *sql.Row) error,
-	query string,
-	a ...any,
-) (code int, e *api_errors.APIError) {
-	row := db.QueryRow(query, a...)
+const (
+	PERIOD_FORMAT = "2006-01"
+	DAY_FORMAT    = "2006-01-02"
+)
 
-	if err := fn(dst, row); err != nil {
-		if errors.Is(err, sql.ErrNoRows) {
-			return http.StatusNotFound, api_errors.NewErrSQLNoRows("No rows")
-		}
+// validatePeriod parses a billing period in 'yyyy-mm' format and returns the
+// first day of that month.
+func validatePeriod(name, value string, emptyCheck bool) (t time.Time, err *api_errors.APIError) {
+	if emptyCheck && len(value) == 0 {
+		return t, api_errors.NewErrEmptyParam(name)
+	}
+	v, e := time.Parse(PERIOD_FORMAT, value)
+	if e != nil {
+		return t, api_errors.NewErrIncorrectParam(name)
+	}
+	return v, nil
+}
 
-		logError("queryRow scanRowFunc() err:", err)
-		return http.StatusInternalServerError, api_errors.NewErrSQLInternalError(err.Error())
+// validateDay parses a day in 'yyyy-mm-dd' format.
+func validateDay(name, value string, emptyCheck bool) (t time.Time, err *api_errors.APIError) {
+	if emptyCheck && len(value) == 0 {
+		return t, api_errors.NewErrEmptyParam(name)
+	}
+	v, e := time.Parse(DAY_FORMAT, value)
+	if e != nil {
+		return t, api_errors.NewErrIncorrectParam(name)
 	}
+	return v, nil
+}
 
-	return http.StatusOK, nil
+// dayRange returns the half-open range [start, end) of the day of date.
+func dayRange(date time.Time) (start, end time.Time) {
+	start = time.Date(date.Year(), date.Month(), date.Day(), 0, 0, 0, 0, date.Location())
+	return start, start.AddDate(0, 0, 1)
 }
 
-func queryRows[T any](
-	dst T,
-	fn func(T, *sql.Rows) error,
-	query string,
-	a ...any,
-) (code int, e *api_errors.APIError) {
-	var (
-		rows *sql.Rows
-		err  error
-	)
+// today returns the start of the current UTC day.
+func today() time.Time {
+	start, _ := dayRange(time.Now().UTC())
+	return start
+}
 
-	if a == nil {
-		rows, err = db.Query(query)
-	} else {
-		rows, err = db.Query(query, a...)
+// thisMonth returns the first day of the current UTC month.
+func thisMonth() time.Time {
+	t := today()
+	return t.AddDate(0, 0, 1-t.Day())
+}
+
+// validateDateRange reads the date_start and date_end query parameters of a
+// half-open range [date_start, date_end).
+func validateDateRange(g *gin.Context) (start, end time.Time, err *api_errors.APIError) {
+	start, err = validators.Date("date_start", g.Query("date_start"), true)
+	if err != nil {
+		return
 	}
 
+	end, err = validators.Date("date_end", g.Query("date_end"), true)
 	if err != nil {
-		if errors.Is(err, sql.ErrNoRows) {
-			return http.StatusNotFound, api_errors.NewErrSQLNoRows("No rows")
-		}
+		return
+	}
 
-		logError("queryRows db.Query() err:", err)
-		return http.StatusInternalServerError, api_errors.NewErrSQLInternalError(err.Error())
+	if !end.After(start) {
+		err = api_errors.NewErrIncorrectParam("date_end: must be after date_start")
 	}
-	defer rows.Close()
+	return
+}
+
+// MySQL server error numbers handled by the API.
+const (
+	mysqlErrDupEntry        = 1062
+	mysqlErrRowIsReferenced = 1451
+	mysqlErrNoReferencedRow = 1452
+)
+
+func isMySQLError(err error, number uint16) bool {
+	var e *mysql.MySQLError
+	return errors.As(err, &e) && e.Number == number
+}
+
+// isDuplicateKey reports a unique or primary key violation of either backend.
+func isDuplicateKey(err error) bool {
+	return isMySQLError(err, mysqlErrDupEntry) ||
+		isSQLiteError(err, sqliteErrConstraintUnique, sqliteErrConstraintPrimaryKey)
+}
+
+// isNoReferencedRow reports an insert or update whose foreign key has no row
+// to reference. SQLite reports every foreign key violation the same way, so
+// it is only told apart from isRowIsReferenced by the statement that failed.
+func isNoReferencedRow(err error) bool {
+	return isMySQLError(err, mysqlErrNoReferencedRow) ||
+		isSQLiteError(err, sqliteErrConstraintForeignKey)
+}
 
-	if err := fn(dst, rows); err != nil {
-		logError("queryRows scanRowsFunc() err:", err)
-		return http.StatusInternalServerError, api_errors.NewErrSQLInternalError(err.Error())
+// isRowIsReferenced reports a change of a row that other rows still
+// reference.
+func isRowIsReferenced(err error) bool {
+	return isMySQLError(err, mysqlErrRowIsReferenced)
+}
+
+// API error codes added to those of hacs_db_types.
+const (
+	ErrCodeConflict api_errors.APIErrorCode = api_errors.ErrCodeSQLInternalError + 1 + iota
+	ErrCodeInvalidReference
+	ErrCodeForbidden
+	ErrCodePreconditionFailed
+)
+
+// NewErrConflict is the error of a change that conflicts with other rows: a
+// key that is already taken or a row that is still referenced.
+func NewErrConflict(err string) *api_errors.APIError {
+	return &api_errors.APIError{Code: ErrCodeConflict, Err: err}
+}
+
+// NewErrInvalidReference is the error of a change that references a row
+// that does not exist.
+func NewErrInvalidReference(err string) *api_errors.APIError {
+	return &api_errors.APIError{Code: ErrCodeInvalidReference, Err: err}
+}
+
+// NewErrForbidden is the error of a caller whose role doesn't allow the
+// route, or who doesn't own the row it asks for.
+func NewErrForbidden(err string) *api_errors.APIError {
+	return &api_errors.APIError{Code: ErrCodeForbidden, Err: err}
+}
+
+// NewErrPreconditionFailed is the error of a change whose If-Match doesn't
+// match the row anymore.
+func NewErrPreconditionFailed(err string) *api_errors.APIError {
+	return &api_errors.APIError{Code: ErrCodePreconditionFailed, Err: err}
+}
+
+// created answers a create route with 201 and the new entity v. Location
+// points at v: loc is resolved against the path of the request like a
+// relative link, e.g. "id/7" from /api/payment/new is /api/payment/id/7.
+func created(g *gin.Context, loc string, v any) {
+	g.Header("Location", g.Request.URL.ResolveReference(&url.URL{Path: loc}).Path)
+	g.JSON(http.StatusCreated, v)
+}
+
+// includeDeleted reads the include_deleted query flag of routes that list or
+// get soft deleted entities.
+func includeDeleted(g *gin.Context) (bool, *api_errors.APIError) {
+	temp := g.Query("include_deleted")
+	if temp == "" {
+		return false, nil
 	}
+	return validators.Bool("include_deleted", temp, false)
+}
 
-	return http.StatusOK, nil
+func nullTime(t sql.NullTime) *time.Time {
+	if !t.Valid {
+		return nil
+	}
+	return &t.Time
+}
+
+func nullValue[T any](v sql.Null[T]) *T {
+	if !v.Valid {
+		return nil
+	}
+	return &v.V
 }
@@ -0,0 +1,321 @@
+package main
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	api_errors "github.com/snakehunterr/hacs_db_types/errors"
+	validators "github.com/snakehunterr/hacs_db_types/validators"
+)
+
+// The /all routes return one page of rows sorted by a whitelisted field. The
+// body is still a JSON array, empty when nothing matches; the X-Total-Count
+// header holds the number of matching rows over all pages and X-Next-Cursor
+// the cursor of the next page, if there is one. A cursor holds the sort
+// value and id of the last row of a page, so rows created or deleted in the
+// meantime don't shift the following pages.
+
+const (
+	ListLimitDefault = 50
+	ListLimitMax     = 500
+)
+
+type sortKind int
+
+const (
+	sortInt sortKind = iota
+	sortArea
+	sortMoney
+	sortString
+	sortTime
+)
+
+// sortField is a field a list can be sorted by: its column and its value in
+// a row, of the Go type of kind.
+type sortField[T any] struct {
+	column string
+	kind   sortKind
+	value  func(T) any
+}
+
+// listFields describes how a list of T is sorted. Rows with equal sort
+// values are ordered by id, which also is the default sort.
+type listFields[T any] struct {
+	id    string
+	idOf  func(T) int64
+	sorts map[string]sortField[T]
+}
+
+// PageQuery selects a page of a list. After is the cursor of the previous
+// page, nil for the first one.
+type PageQuery struct {
+	Limit int
+	Sort  string
+	Desc  bool
+	After *Cursor
+}
+
+// Cursor points right after the last row of a page.
+type Cursor struct {
+	Sort  string `json:"s"`
+	Desc  bool   `json:"d,omitempty"`
+	Value string `json:"v"`
+	ID    int64  `json:"id"`
+}
+
+func (c Cursor) String() string {
+	bs, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(bs)
+}
+
+func parseCursor(s string) (*Cursor, bool) {
+	bs, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, false
+	}
+
+	var c Cursor
+	if err := json.Unmarshal(bs, &c); err != nil {
+		return nil, false
+	}
+	return &c, true
+}
+
+// Page is one page of a list. Next is nil on the last page.
+type Page[T any] struct {
+	Items []T
+	Total int64
+	Next  *Cursor
+}
+
+// pageQuery reads the limit, cursor, sort and order query parameters.
+func (f listFields[T]) pageQuery(g *gin.Context) (q PageQuery, apierr *api_errors.APIError) {
+	q.Limit = ListLimitDefault
+	if temp := g.Query("limit"); temp != "" {
+		var limit int64
+		limit, apierr = validators.Int64("limit", temp, false)
+		if apierr == nil && (limit <= 0 || limit > ListLimitMax) {
+			apierr = api_errors.NewErrIncorrectParam("limit")
+		}
+		if apierr != nil {
+			return q, apierr
+		}
+		q.Limit = int(limit)
+	}
+
+	q.Sort = g.Query("sort")
+	if _, ok := f.sorts[q.Sort]; q.Sort != "" && !ok {
+		return q, api_errors.NewErrIncorrectParam("sort")
+	}
+
+	switch order := g.Query("order"); order {
+	case "", "asc":
+	case "desc":
+		q.Desc = true
+	default:
+		return q, api_errors.NewErrIncorrectParam("order")
+	}
+
+	if temp := g.Query("cursor"); temp != "" {
+		c, ok := parseCursor(temp)
+		if !ok {
+			return q, api_errors.NewErrIncorrectParam("cursor")
+		}
+		if _, ok := f.sorts[c.Sort]; !ok {
+			return q, api_errors.NewErrIncorrectParam("cursor")
+		}
+		if (q.Sort != "" && q.Sort != c.Sort) || (g.Query("order") != "" && q.Desc != c.Desc) {
+			return q, api_errors.NewErrIncorrectParam("cursor: sorted differently")
+		}
+
+		q.Sort, q.Desc, q.After = c.Sort, c.Desc, c
+		if _, err := f.afterValue(q); err != nil {
+			return q, api_errors.NewErrIncorrectParam("cursor")
+		}
+	}
+
+	if q.Sort == "" {
+		q.Sort = f.id
+	}
+	return q, nil
+}
+
+// afterValue returns the sort value of the cursor of q as the Go type of its
+// kind.
+func (f listFields[T]) afterValue(q PageQuery) (any, error) {
+	v := q.After.Value
+	switch f.sorts[q.Sort].kind {
+	case sortInt:
+		return strconv.ParseInt(v, 10, 64)
+	case sortArea:
+		a, ok := parseArea(v)
+		if !ok {
+			return nil, errors.New("invalid area")
+		}
+		return a, nil
+	case sortMoney:
+		m, ok := parseMoney(v)
+		if !ok {
+			return nil, errors.New("invalid amount")
+		}
+		return m, nil
+	case sortTime:
+		return time.Parse(time.RFC3339Nano, v)
+	default:
+		return v, nil
+	}
+}
+
+func (f listFields[T]) cursor(q PageQuery, v T) *Cursor {
+	c := &Cursor{Sort: q.Sort, Desc: q.Desc, ID: f.idOf(v)}
+
+	switch value := f.sorts[q.Sort].value(v).(type) {
+	case int64:
+		c.Value = strconv.FormatInt(value, 10)
+	case Money:
+		c.Value = value.String()
+	case Area:
+		c.Value = value.String()
+	case time.Time:
+		c.Value = value.UTC().Format(time.RFC3339Nano)
+	case string:
+		c.Value = value
+	}
+	return c
+}
+
+// page cuts rows, fetched with a limit of q.Limit+1, to a page.
+func (f listFields[T]) page(q PageQuery, rows []T, total int64) Page[T] {
+	p := Page[T]{Items: rows, Total: total}
+	if len(rows) > q.Limit {
+		p.Items = rows[:q.Limit]
+		p.Next = f.cursor(q, p.Items[q.Limit-1])
+	}
+	return p
+}
+
+// after reports whether v comes after the cursor of q.
+func (f listFields[T]) after(q PageQuery, v T, after any) bool {
+	c := compareSortValues(f.sorts[q.Sort].value(v), after)
+	if c == 0 {
+		c = compareOrdered(f.idOf(v), q.After.ID)
+	}
+	if q.Desc {
+		return c < 0
+	}
+	return c > 0
+}
+
+// compare orders rows by the sort of q and then by id.
+func (f listFields[T]) compare(q PageQuery, a, b T) int {
+	field := f.sorts[q.Sort]
+	c := compareSortValues(field.value(a), field.value(b))
+	if c == 0 {
+		c = compareOrdered(f.idOf(a), f.idOf(b))
+	}
+	if q.Desc {
+		return -c
+	}
+	return c
+}
+
+// compareSortValues compares two values of the same sortKind.
+func compareSortValues(a, b any) int {
+	switch a := a.(type) {
+	case int64:
+		return compareOrdered(a, b.(int64))
+	case Money:
+		return compareOrdered(a, b.(Money))
+	case Area:
+		return compareOrdered(a, b.(Area))
+	case time.Time:
+		return a.Compare(b.(time.Time))
+	case string:
+		return strings.Compare(a, b.(string))
+	}
+	return 0
+}
+
+func compareOrdered[V ~int64 | ~float64](a, b V) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
+}
+
+// listJSON answers a list route with a page.
+func listJSON[T any](g *gin.Context, p Page[T]) {
+	g.Header("X-Total-Count", strconv.FormatInt(p.Total, 10))
+	if p.Next != nil {
+		g.Header("X-Next-Cursor", p.Next.String())
+	}
+
+	if p.Items == nil {
+		p.Items = []T{}
+	}
+	g.JSON(http.StatusOK, p.Items)
+}
+
+// optionalArea reads an optional area query parameter, nil when it is not
+// set.
+func optionalArea(g *gin.Context, name string) (*Area, *api_errors.APIError) {
+	temp := g.Query(name)
+	if temp == "" {
+		return nil, nil
+	}
+
+	v, apierr := validateArea(name, temp, false)
+	if apierr != nil {
+		return nil, apierr
+	}
+	return &v, nil
+}
+
+// optionalMoney reads an optional amount query parameter, nil when it is not
+// set.
+func optionalMoney(g *gin.Context, name string) (*Money, *api_errors.APIError) {
+	temp := g.Query(name)
+	if temp == "" {
+		return nil, nil
+	}
+
+	v, apierr := validateMoney(name, temp, false)
+	if apierr != nil {
+		return nil, apierr
+	}
+	return &v, nil
+}
+
+// optionalInt64 reads an optional integer query parameter, 0 when it is not
+// set.
+func optionalInt64(g *gin.Context, name string) (int64, *api_errors.APIError) {
+	temp := g.Query(name)
+	if temp == "" {
+		return 0, nil
+	}
+	return validators.Int64(name, temp, false)
+}
+
+// optionalBool reads an optional bool query parameter, nil when it is not
+// set.
+func optionalBool(g *gin.Context, name string) (*bool, *api_errors.APIError) {
+	temp := g.Query(name)
+	if temp == "" {
+		return nil, nil
+	}
+
+	v, apierr := validators.Bool(name, temp, false)
+	if apierr != nil {
+		return nil, apierr
+	}
+	return &v, nil
+}
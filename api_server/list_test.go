@@ -0,0 +1,99 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"slices"
+	"testing"
+)
+
+func TestListCursor(t *testing.T) {
+	testBackends(t, func(t *testing.T, h http.Handler) {
+		seedRoom(t, h)
+
+		// Payments 1 to 5, with ties on both the amount and the date.
+		for _, p := range []struct{ date, amount string }{
+			{"2025-02-01 10:00:00", "10.00"},
+			{"2025-02-02 10:00:00", "20.00"},
+			{"2025-02-01 10:00:00", "10.00"},
+			{"2025-02-02 10:00:00", "20.00"},
+			{"2025-02-01 10:00:00", "10.00"},
+		} {
+			call(t, h, "POST", "/api/payment/new", url.Values{
+				"client_id":      {"1"},
+				"room_id":        {"10"},
+				"payment_date":   {p.date},
+				"payment_amount": {p.amount},
+			}, http.StatusCreated, nil)
+		}
+
+		// walk returns the IDs of the payments of all pages of query, two
+		// per page.
+		walk := func(query string) []int64 {
+			t.Helper()
+
+			var ids []int64
+			target := "/api/payment/all?limit=2&" + query
+			for pages := 0; ; pages++ {
+				if pages > 5 {
+					t.Fatalf("%s: no last page", query)
+				}
+
+				w := request(h, testServiceToken, "GET", target, nil)
+				if w.Code != http.StatusOK {
+					t.Fatalf("GET %s: got %d: %s", target, w.Code, w.Body)
+				}
+				if got := w.Header().Get("X-Total-Count"); got != "5" {
+					t.Fatalf("GET %s: X-Total-Count = %s, want 5", target, got)
+				}
+
+				var ps []Payment
+				decode(t, w, &ps)
+				for _, p := range ps {
+					ids = append(ids, p.ID)
+				}
+
+				next := w.Header().Get("X-Next-Cursor")
+				if next == "" {
+					return ids
+				}
+				target = "/api/payment/all?limit=2&cursor=" + next
+			}
+		}
+
+		tests := []struct {
+			query string
+			want  []int64
+		}{
+			{"", []int64{1, 2, 3, 4, 5}},
+			{"sort=payment_amount", []int64{1, 3, 5, 2, 4}},
+			{"sort=payment_amount&order=desc", []int64{4, 2, 5, 3, 1}},
+			{"sort=payment_date", []int64{1, 3, 5, 2, 4}},
+			{"sort=payment_date&order=desc", []int64{4, 2, 5, 3, 1}},
+		}
+		for _, tt := range tests {
+			if got := walk(tt.query); !slices.Equal(got, tt.want) {
+				t.Errorf("%q: got %v, want %v", tt.query, got, tt.want)
+			}
+		}
+
+		amount := Cursor{Sort: "payment_amount", Value: "10.00", ID: 3}
+		for _, query := range []string{
+			"cursor=not-a-cursor!",
+			"cursor=" + Cursor{Sort: "payment_note", Value: "x", ID: 1}.String(),
+			"cursor=" + Cursor{Sort: "payment_amount", Value: "ten", ID: 1}.String(),
+			"cursor=" + amount.String() + "&sort=payment_date",
+			"cursor=" + amount.String() + "&order=desc",
+		} {
+			call(t, h, "GET", "/api/payment/all?"+query, nil, http.StatusBadRequest, nil)
+		}
+
+		// A cursor continues its sort without the sort parameters.
+		var ps []Payment
+		call(t, h, "GET", fmt.Sprintf("/api/payment/all?cursor=%s", amount), nil, http.StatusOK, &ps)
+		if len(ps) != 3 || ps[0].ID != 5 || ps[1].ID != 2 || ps[2].ID != 4 {
+			t.Fatalf("after %+v: got %+v, want payments 5, 2 and 4", amount, ps)
+		}
+	})
+}
@@ -2,6 +2,7 @@ package main
 
 import (
 	"database/sql"
+	"flag"
 	"fmt"
 	"net/http"
 	"os"
@@ -19,15 +20,37 @@ import (
 // @BasePath /api
 // @produce json
 
-var (
-	db  *sql.DB
-	e   *gin.Engine      = gin.Default()
-	api *gin.RouterGroup = e.Group("/api")
-)
+// @securityDefinitions.apikey BearerAuth
+// @in header
+// @name Authorization
+// @description "Bearer <token>": a service token from DBAPI_SERVICE_TOKENS or a client token from /token/new
 
 func main() {
-	db = openDB()
-	api = e.Group("/api")
+	if len(os.Args) > 1 && os.Args[1] == "migrate" {
+		os.Exit(runMigrate(os.Args[2:]))
+	}
+
+	auto_migrate := flag.Bool(
+		"auto-migrate",
+		os.Getenv("DBAPI_AUTO_MIGRATE") == "true",
+		"apply pending schema migrations on startup (env DBAPI_AUTO_MIGRATE=true)",
+	)
+	flag.Parse()
+
+	if _, apierr := validateCurrency("DBAPI_CURRENCY", baseCurrency(), false); apierr != nil {
+		panic(apierr)
+	}
+
+	db := openDB()
+
+	if *auto_migrate {
+		if err := autoMigrate(db); err != nil {
+			panic(err)
+		}
+	}
+
+	e := gin.Default()
+	NewSQLServer(db).Routes(e.Group("/api"))
 
 	docs.SwaggerInfo.Host = fmt.Sprintf("localhost:%s", os.Getenv("DBAPI_SERVER_PORT"))
 
@@ -44,7 +67,30 @@ func main() {
 	}
 }
 
+// openDB connects to the database chosen by DBAPI_DB_DRIVER: mysql, the
+// default, or sqlite for a single file at DBAPI_SQLITE_PATH.
 func openDB() *sql.DB {
+	switch driver := os.Getenv("DBAPI_DB_DRIVER"); driver {
+	case "", "mysql":
+		return openMySQL()
+
+	case "sqlite":
+		path := sqlitePath()
+
+		db, err := openSQLite(path)
+		if err != nil {
+			panic(err)
+		}
+
+		logInfo("Opened SQLite database:", path)
+		return db
+
+	default:
+		panic(fmt.Sprintf("unknown DBAPI_DB_DRIVER: %q", driver))
+	}
+}
+
+func openMySQL() *sql.DB {
 	for {
 		dsn := fmt.Sprintf(
 			"%s:%s@tcp(%s:%s)/%s?parseTime=true",
@@ -0,0 +1,400 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"embed"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	validators "github.com/snakehunterr/hacs_db_types/validators"
+)
+
+// The schema is defined by numbered migrations in migrations/: every version
+// has a NNNN_name.up.sql file and a NNNN_name.down.sql file that undoes it.
+// Applied versions are recorded in schema_migrations. MySQL commits DDL
+// implicitly, so a migration that fails halfway has to be fixed by hand.
+
+//go:embed migrations/*.sql
+var migrationsFS embed.FS
+
+var migrationFileRe = regexp.MustCompile(`^(\d+)_(\w+)\.(up|down)\.sql$`)
+
+const migrationLock = "schema_migrations"
+
+const migrationTableQuery = `create table if not exists schema_migrations (
+    version bigint not null,
+    name varchar(255) not null,
+    applied_at timestamp not null default current_timestamp,
+    primary key (version)
+)`
+
+type Migration struct {
+	Version   int64
+	Name      string
+	Up        string
+	Down      string
+	AppliedAt *time.Time
+}
+
+func loadMigrations() ([]Migration, error) {
+	files, err := fs.Glob(migrationsFS, "migrations/*.sql")
+	if err != nil {
+		return nil, err
+	}
+
+	byVersion := map[int64]*Migration{}
+	for _, f := range files {
+		match := migrationFileRe.FindStringSubmatch(strings.TrimPrefix(f, "migrations/"))
+		if match == nil {
+			return nil, fmt.Errorf("bad migration file name: %s", f)
+		}
+
+		version, err := strconv.ParseInt(match[1], 10, 64)
+		if err != nil {
+			return nil, err
+		}
+
+		bs, err := migrationsFS.ReadFile(f)
+		if err != nil {
+			return nil, err
+		}
+
+		m, ok := byVersion[version]
+		if !ok {
+			m = &Migration{Version: version, Name: match[2]}
+			byVersion[version] = m
+		}
+		if m.Name != match[2] {
+			return nil, fmt.Errorf("migration %d has two names: %s, %s", version, m.Name, match[2])
+		}
+
+		if match[3] == "up" {
+			m.Up = string(bs)
+		} else {
+			m.Down = string(bs)
+		}
+	}
+
+	var ms []Migration
+	for _, m := range byVersion {
+		if m.Up == "" || m.Down == "" {
+			return nil, fmt.Errorf("migration %d_%s needs both up and down files", m.Version, m.Name)
+		}
+		ms = append(ms, *m)
+	}
+
+	sort.Slice(ms, func(i, j int) bool { return ms[i].Version < ms[j].Version })
+	return ms, nil
+}
+
+// splitStatements splits a migration into statements ending with ";" at the
+// end of a line. Comment lines are dropped.
+func splitStatements(script string) (stmts []string) {
+	var b strings.Builder
+
+	for _, line := range strings.Split(script, "\n") {
+		trimmed := strings.TrimSpace(line)
+		if trimmed == "" || strings.HasPrefix(trimmed, "--") {
+			continue
+		}
+
+		b.WriteString(line)
+		b.WriteString("\n")
+
+		if strings.HasSuffix(trimmed, ";") {
+			stmts = append(stmts, strings.TrimSuffix(strings.TrimSpace(b.String()), ";"))
+			b.Reset()
+		}
+	}
+
+	if s := strings.TrimSpace(b.String()); s != "" {
+		stmts = append(stmts, s)
+	}
+	return stmts
+}
+
+// Migrator applies migrations over one connection that holds the migration
+// lock, so several api_server instances can start at the same time.
+type Migrator struct {
+	conn       *sql.Conn
+	migrations []Migration
+}
+
+func NewMigrator(ctx context.Context, db *sql.DB) (*Migrator, error) {
+	ms, err := loadMigrations()
+	if err != nil {
+		return nil, err
+	}
+
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var locked sql.NullBool
+	if err := conn.QueryRowContext(ctx, "select get_lock(?, 60)", migrationLock).Scan(&locked); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	if !locked.Bool {
+		conn.Close()
+		return nil, errors.New("timeout waiting for the migration lock")
+	}
+
+	if _, err := conn.ExecContext(ctx, migrationTableQuery); err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	mg := &Migrator{conn: conn, migrations: ms}
+	if err := mg.loadApplied(ctx); err != nil {
+		mg.Close()
+		return nil, err
+	}
+	return mg, nil
+}
+
+func (mg *Migrator) Close() error {
+	if _, err := mg.conn.ExecContext(context.Background(), "select release_lock(?)", migrationLock); err != nil {
+		logError("Migrator release_lock():", err)
+	}
+	return mg.conn.Close()
+}
+
+func (mg *Migrator) loadApplied(ctx context.Context) error {
+	rows, err := mg.conn.QueryContext(ctx, "select version, applied_at from schema_migrations")
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	applied := map[int64]time.Time{}
+	for rows.Next() {
+		var (
+			version int64
+			at      time.Time
+		)
+		if err := rows.Scan(&version, &at); err != nil {
+			return err
+		}
+		applied[version] = at
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	for i := range mg.migrations {
+		m := &mg.migrations[i]
+		m.AppliedAt = nil
+		if at, ok := applied[m.Version]; ok {
+			m.AppliedAt = &at
+			delete(applied, m.Version)
+		}
+	}
+
+	for version := range applied {
+		return fmt.Errorf("schema version %d is applied but unknown to this build", version)
+	}
+	return nil
+}
+
+// Version returns the latest applied migration, 0 for an empty schema.
+func (mg *Migrator) Version() int64 {
+	var v int64
+	for _, m := range mg.migrations {
+		if m.AppliedAt != nil {
+			v = m.Version
+		}
+	}
+	return v
+}
+
+// Latest returns the version of the newest migration.
+func (mg *Migrator) Latest() int64 {
+	if len(mg.migrations) == 0 {
+		return 0
+	}
+	return mg.migrations[len(mg.migrations)-1].Version
+}
+
+func (mg *Migrator) exec(ctx context.Context, m Migration, script, record string, args ...any) error {
+	for _, stmt := range splitStatements(script) {
+		if _, err := mg.conn.ExecContext(ctx, stmt); err != nil {
+			return fmt.Errorf("migration %d_%s: %w", m.Version, m.Name, err)
+		}
+	}
+
+	if _, err := mg.conn.ExecContext(ctx, record, args...); err != nil {
+		return fmt.Errorf("migration %d_%s: %w", m.Version, m.Name, err)
+	}
+	return nil
+}
+
+// To applies or reverts migrations until version is the latest applied one.
+func (mg *Migrator) To(ctx context.Context, version int64) error {
+	if version < 0 || version > mg.Latest() {
+		return fmt.Errorf("unknown schema version: %d", version)
+	}
+
+	for _, m := range mg.migrations {
+		if m.Version > version || m.AppliedAt != nil {
+			continue
+		}
+
+		err := mg.exec(
+			ctx, m, m.Up,
+			"insert into schema_migrations (version, name) values (?, ?)",
+			m.Version, m.Name,
+		)
+		if err != nil {
+			return err
+		}
+		logInfo(fmt.Sprintf("Applied migration %d_%s", m.Version, m.Name))
+	}
+
+	for i := len(mg.migrations) - 1; i >= 0; i-- {
+		m := mg.migrations[i]
+		if m.Version <= version || m.AppliedAt == nil {
+			continue
+		}
+
+		err := mg.exec(
+			ctx, m, m.Down,
+			"delete from schema_migrations where version = ?",
+			m.Version,
+		)
+		if err != nil {
+			return err
+		}
+		logInfo(fmt.Sprintf("Reverted migration %d_%s", m.Version, m.Name))
+	}
+
+	return mg.loadApplied(ctx)
+}
+
+// Up applies all pending migrations.
+func (mg *Migrator) Up(ctx context.Context) error {
+	return mg.To(ctx, mg.Latest())
+}
+
+// Down reverts the last n applied migrations.
+func (mg *Migrator) Down(ctx context.Context, n int) error {
+	var applied []int64
+	for _, m := range mg.migrations {
+		if m.AppliedAt != nil {
+			applied = append(applied, m.Version)
+		}
+	}
+
+	if n > len(applied) {
+		return fmt.Errorf("only %d migrations are applied", len(applied))
+	}
+
+	var target int64
+	if i := len(applied) - n - 1; i >= 0 {
+		target = applied[i]
+	}
+	return mg.To(ctx, target)
+}
+
+func (mg *Migrator) Status(w io.Writer) error {
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+
+	fmt.Fprintln(tw, "VERSION\tNAME\tAPPLIED AT")
+	for _, m := range mg.migrations {
+		at := "pending"
+		if m.AppliedAt != nil {
+			at = m.AppliedAt.Format(validators.DATE_FORMAT)
+		}
+		fmt.Fprintf(tw, "%d\t%s\t%s\n", m.Version, m.Name, at)
+	}
+
+	return tw.Flush()
+}
+
+// autoMigrate brings the schema to the latest version on startup.
+func autoMigrate(db *sql.DB) error {
+	ctx := context.Background()
+
+	mg, err := NewMigrator(ctx, db)
+	if err != nil {
+		return err
+	}
+	defer mg.Close()
+
+	return mg.Up(ctx)
+}
+
+const migrateUsage = `usage: api_server migrate <command>
+
+commands:
+  status          list migrations and whether they are applied
+  up              apply all pending migrations
+  down [n]        revert the last n migrations, 1 by default
+  to <version>    apply or revert migrations up to version, 0 for none
+`
+
+// runMigrate implements the migrate subcommand.
+func runMigrate(args []string) int {
+	if len(args) == 0 {
+		fmt.Fprint(os.Stderr, migrateUsage)
+		return 2
+	}
+
+	ctx := context.Background()
+
+	mg, err := NewMigrator(ctx, openDB())
+	if err != nil {
+		logError("NewMigrator():", err)
+		return 1
+	}
+	defer mg.Close()
+
+	switch cmd := args[0]; {
+	case cmd == "status" && len(args) == 1:
+		err = mg.Status(os.Stdout)
+
+	case cmd == "up" && len(args) == 1:
+		err = mg.Up(ctx)
+
+	case cmd == "down" && len(args) <= 2:
+		n := 1
+		if len(args) == 2 {
+			if n, err = strconv.Atoi(args[1]); err != nil || n < 1 {
+				fmt.Fprint(os.Stderr, migrateUsage)
+				return 2
+			}
+		}
+		err = mg.Down(ctx, n)
+
+	case cmd == "to" && len(args) == 2:
+		var version int64
+		if version, err = strconv.ParseInt(args[1], 10, 64); err != nil {
+			fmt.Fprint(os.Stderr, migrateUsage)
+			return 2
+		}
+		err = mg.To(ctx, version)
+
+	default:
+		fmt.Fprint(os.Stderr, migrateUsage)
+		return 2
+	}
+
+	if err != nil {
+		logError("migrate ", args[0], ": ", err)
+		return 1
+	}
+
+	logInfo("Schema version: ", mg.Version())
+	return 0
+}
@@ -0,0 +1,196 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// newMigrator returns a migrator of a new, empty SQLite database.
+func newMigrator(t *testing.T) (*sql.DB, *Migrator) {
+	t.Helper()
+
+	db, err := openSQLite(filepath.Join(t.TempDir(), "hacs.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	mg, err := NewMigrator(context.Background(), db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { mg.Close() })
+	return db, mg
+}
+
+// migrateTo applies or reverts migrations up to version.
+func migrateTo(t *testing.T, mg *Migrator, version int64) {
+	t.Helper()
+
+	if err := mg.To(context.Background(), version); err != nil {
+		t.Fatal(err)
+	}
+	if mg.Version() != version {
+		t.Fatalf("schema version %d, want %d", mg.Version(), version)
+	}
+}
+
+// dumpRows returns a line of the values of each row of query, after prefix.
+func dumpRows(t *testing.T, db *sql.DB, prefix, query string, args ...any) string {
+	t.Helper()
+
+	rows, err := db.Query(query, args...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rows.Close()
+
+	var b strings.Builder
+
+	cols, _ := rows.Columns()
+	for rows.Next() {
+		vs := make([]sql.NullString, len(cols))
+		ps := make([]any, len(cols))
+		for i := range vs {
+			ps[i] = &vs[i]
+		}
+		if err := rows.Scan(ps...); err != nil {
+			t.Fatal(err)
+		}
+
+		fmt.Fprint(&b, prefix)
+		for _, v := range vs {
+			if v.Valid {
+				fmt.Fprintf(&b, " %q", v.String)
+			} else {
+				fmt.Fprint(&b, " null")
+			}
+		}
+		fmt.Fprintln(&b)
+	}
+	if err := rows.Err(); err != nil {
+		t.Fatal(err)
+	}
+	return b.String()
+}
+
+// sqliteSchema describes the tables, with their columns and foreign keys,
+// and the indexes of db, apart from schema_migrations.
+func sqliteSchema(t *testing.T, db *sql.DB) string {
+	t.Helper()
+
+	var tables []string
+	rows, err := db.Query(`select name from sqlite_master where type = 'table' and name not like 'sqlite_%' and name != 'schema_migrations' order by name`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			t.Fatal(err)
+		}
+		tables = append(tables, name)
+	}
+	rows.Close()
+
+	var b strings.Builder
+	for _, table := range tables {
+		b.WriteString(dumpRows(t, db, "column "+table, `select name, type, "notnull", dflt_value, pk from pragma_table_info(?) order by cid`, table))
+		b.WriteString(dumpRows(t, db, "foreign key "+table, `select "table", "from", "to", on_delete from pragma_foreign_key_list(?) order by "table", "from"`, table))
+	}
+	b.WriteString(dumpRows(t, db, "index", `select tbl_name, name, sql from sqlite_master where type = 'index' and sql is not null order by name`))
+	return b.String()
+}
+
+// TestMigrationsDown checks that every down migration restores the schema
+// its up migration started from.
+func TestMigrationsDown(t *testing.T) {
+	db, mg := newMigrator(t)
+
+	schemas := []string{sqliteSchema(t, db)}
+	for v := int64(1); v <= mg.Latest(); v++ {
+		migrateTo(t, mg, v)
+		schemas = append(schemas, sqliteSchema(t, db))
+	}
+
+	for v := mg.Latest() - 1; v >= 0; v-- {
+		migrateTo(t, mg, v)
+		if got := sqliteSchema(t, db); got != schemas[v] {
+			t.Fatalf("schema after reverting migration %d:\n%s\nwant:\n%s", v+1, got, schemas[v])
+		}
+	}
+
+	// The migrations apply again after being reverted.
+	migrateTo(t, mg, mg.Latest())
+	if got := sqliteSchema(t, db); got != schemas[mg.Latest()] {
+		t.Fatalf("schema after migrating up again:\n%s\nwant:\n%s", got, schemas[mg.Latest()])
+	}
+}
+
+// TestMigrationsData checks that the migrations that rewrite rows, 0004,
+// 0015 and 0018, keep the rows of a database created before them, and that
+// reverting them gives the rows back as they were.
+func TestMigrationsData(t *testing.T) {
+	db, mg := newMigrator(t)
+	migrateTo(t, mg, 3)
+
+	for _, stmt := range []string{
+		`insert into client (client_id, client_name, is_admin) values (1, 'Resident', false)`,
+		`insert into room (room_id, client_id, room_people_count, room_area) values (10, 1, 2, 50.5)`,
+		`insert into payment (client_id, room_id, payment_date, payment_amount) values (1, 10, '2025-02-10 10:00:00', 12.5)`,
+		`insert into expense (expense_date, expense_amount) values ('2025-02-05 00:00:00', 30)`,
+		`insert into tariff (tariff_area_rate, tariff_people_rate, tariff_fixed_fee, tariff_effective_from) values (1.5, 100, 10, '2025-01-01')`,
+	} {
+		if _, err := db.Exec(stmt); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// dump returns the rows that existed before the migrations.
+	dump := func() string {
+		var b strings.Builder
+		for _, table := range []string{"client", "room", "payment", "expense", "tariff"} {
+			b.WriteString(dumpRows(t, db, table, "select * from "+table+" order by 1"))
+		}
+		return b.String()
+	}
+	before := dump()
+
+	migrateTo(t, mg, mg.Latest())
+
+	h := serve(t, NewSQLServer(db))
+
+	var b Building
+	call(t, h, "GET", "/api/building/id/1", nil, http.StatusOK, &b)
+	if b.Name != "Building 1" || b.Currency != baseCurrency() {
+		t.Fatalf("building = %+v, want Building 1 in the base currency", b)
+	}
+
+	var r Room
+	call(t, h, "GET", "/api/room/id/10", nil, http.StatusOK, &r)
+	if r.BuildingID != 1 || r.Number != "10" || r.Area.String() != "50.50" {
+		t.Fatalf("room = %+v, want number 10 of building 1", r)
+	}
+
+	var e Expense
+	call(t, h, "GET", "/api/expense/id/1", nil, http.StatusOK, &e)
+	if e.BuildingID != 1 || e.Category != "other" || e.Description != "" {
+		t.Fatalf("expense = %+v, want an expense of building 1 in category other", e)
+	}
+
+	var p Payment
+	call(t, h, "GET", "/api/payment/id/1", nil, http.StatusOK, &p)
+	if p.Amount.String() != "12.50" || p.Currency != baseCurrency() || p.LedgerCurrency != baseCurrency() {
+		t.Fatalf("payment = %+v, want 12.50 in the base currency", p)
+	}
+
+	migrateTo(t, mg, 3)
+	if got := dump(); got != before {
+		t.Fatalf("rows after reverting the migrations:\n%s\nwant:\n%s", got, before)
+	}
+}
@@ -0,0 +1,4 @@
+drop table if exists expense;
+drop table if exists payment;
+drop table if exists room;
+drop table if exists client;
@@ -0,0 +1,2 @@
+drop table if exists charge;
+drop table if exists tariff;
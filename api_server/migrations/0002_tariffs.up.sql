@@ -0,0 +1,26 @@
+-- Tariffs and the monthly charges generated from them per room.
+create table if not exists tariff (
+    tariff_id int not null auto_increment,
+    tariff_area_rate float not null,
+    tariff_people_rate float not null,
+    tariff_fixed_fee float not null,
+    tariff_effective_from date not null,
+    last_edited timestamp not null default current_timestamp,
+    primary key (tariff_id)
+);
+
+create table if not exists charge (
+    charge_id int not null auto_increment,
+    room_id int not null,
+    tariff_id int not null,
+    charge_period date not null,
+    charge_area_amount float not null,
+    charge_people_amount float not null,
+    charge_fixed_amount float not null,
+    charge_amount float not null,
+    last_edited timestamp not null default current_timestamp,
+    primary key (charge_id),
+    unique key (room_id, charge_period),
+    foreign key (room_id) references room(room_id) on delete cascade,
+    foreign key (tariff_id) references tariff(tariff_id)
+);
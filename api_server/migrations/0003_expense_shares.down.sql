@@ -0,0 +1,2 @@
+drop table if exists expense_allocation;
+drop table if exists expense_share;
@@ -0,0 +1,22 @@
+-- The rule an expense is shared across rooms by, and the amount of every
+-- room.
+create table if not exists expense_share (
+    expense_id int not null,
+    share_rule enum('area', 'people', 'equal') not null,
+    share_remainder float not null,
+    last_edited timestamp not null default current_timestamp,
+    primary key (expense_id),
+    foreign key (expense_id) references expense(expense_id) on delete cascade
+);
+
+create table if not exists expense_allocation (
+    allocation_id int not null auto_increment,
+    expense_id int not null,
+    room_id int not null,
+    allocation_amount float not null,
+    last_edited timestamp not null default current_timestamp,
+    primary key (allocation_id),
+    unique key (expense_id, room_id),
+    foreign key (expense_id) references expense(expense_id) on delete cascade,
+    foreign key (room_id) references room(room_id) on delete cascade
+);
@@ -0,0 +1,6 @@
+drop index expense_vendor_idx on expense;
+drop index expense_category_idx on expense;
+alter table expense drop column expense_document_ref;
+alter table expense drop column expense_description;
+alter table expense drop column expense_vendor;
+alter table expense drop column expense_category;
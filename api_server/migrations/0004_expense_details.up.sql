@@ -0,0 +1,11 @@
+-- The category, vendor, description and document reference of an expense.
+-- MySQL can't give text a default, so the description is filled in before
+-- it becomes not null; SQLite skips modify and keeps it nullable.
+alter table expense add column expense_category varchar(50) not null default 'other';
+alter table expense add column expense_vendor varchar(100) not null default '';
+alter table expense add column expense_description text null;
+alter table expense add column expense_document_ref varchar(255) not null default '';
+update expense set expense_description = '';
+alter table expense modify expense_description text not null;
+create index expense_category_idx on expense (expense_category);
+create index expense_vendor_idx on expense (expense_vendor);
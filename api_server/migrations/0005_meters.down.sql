@@ -0,0 +1,2 @@
+drop table if exists meter_reading;
+drop table if exists meter;
@@ -0,0 +1,24 @@
+-- Utility meters of rooms and their readings.
+create table if not exists meter (
+    meter_id int not null auto_increment,
+    room_id int not null,
+    meter_kind enum('cold_water', 'hot_water', 'electricity', 'gas') not null,
+    meter_serial varchar(50) not null,
+    last_edited timestamp not null default current_timestamp,
+    primary key (meter_id),
+    unique key (meter_kind, meter_serial),
+    foreign key (room_id) references room(room_id) on delete cascade
+);
+
+create table if not exists meter_reading (
+    reading_id int not null auto_increment,
+    meter_id int not null,
+    reading_date timestamp not null default current_timestamp,
+    reading_value double not null,
+    reading_consumption double not null,
+    reading_abnormal boolean not null,
+    last_edited timestamp not null default current_timestamp,
+    primary key (reading_id),
+    key (meter_id, reading_date),
+    foreign key (meter_id) references meter(meter_id) on delete cascade
+);
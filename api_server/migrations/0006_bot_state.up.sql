@@ -0,0 +1,9 @@
+-- Conversation state of the Telegram bot.
+create table if not exists bot_state (
+    state_key varchar(100) not null,
+    state_value text not null,
+    state_expires timestamp null,
+    last_edited timestamp not null default current_timestamp,
+    primary key (state_key),
+    key (state_expires)
+);
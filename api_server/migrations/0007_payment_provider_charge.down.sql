@@ -0,0 +1 @@
+alter table payment drop column payment_provider_charge_id;
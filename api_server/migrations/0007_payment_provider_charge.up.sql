@@ -0,0 +1,2 @@
+-- The charge ID Telegram Payments gives to a payment made in the bot.
+alter table payment add column payment_provider_charge_id varchar(255) not null default '';
@@ -0,0 +1,2 @@
+drop index payment_external_id_idx on payment;
+alter table payment drop column payment_external_id;
@@ -0,0 +1,4 @@
+-- The transaction ID of the payment in an outside system, which makes
+-- retried creates idempotent.
+alter table payment add column payment_external_id varchar(255) null;
+create unique index payment_external_id_idx on payment (payment_external_id);
@@ -0,0 +1,11 @@
+-- API tokens of clients. Only the SHA-256 hash of a token is stored.
+create table if not exists api_token (
+    token_id int not null auto_increment,
+    client_id bigint not null,
+    token_name varchar(100) not null default '',
+    token_hash char(64) not null,
+    last_edited timestamp not null default current_timestamp,
+    primary key (token_id),
+    unique key (token_hash),
+    foreign key (client_id) references client(client_id) on delete cascade
+);
@@ -0,0 +1,18 @@
+-- Every mutation with the row before and after it.
+create table if not exists audit_log (
+    audit_id bigint not null auto_increment,
+    audit_date timestamp not null default current_timestamp,
+    actor_role varchar(20) not null,
+    actor_client_id bigint,
+    audit_action enum('create', 'update', 'delete', 'restore') not null,
+    audit_entity varchar(50) not null,
+    audit_entity_id varchar(100) not null,
+    audit_before json,
+    audit_after json,
+    request_id varchar(64) not null,
+    primary key (audit_id),
+    key (audit_entity, audit_entity_id),
+    key (actor_client_id),
+    key (request_id),
+    key (audit_date)
+);
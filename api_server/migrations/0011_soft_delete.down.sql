@@ -0,0 +1,4 @@
+alter table expense drop column deleted_at;
+alter table payment drop column deleted_at;
+alter table room drop column deleted_at;
+alter table client drop column deleted_at;
@@ -0,0 +1,6 @@
+-- Deleted clients, rooms, payments and expenses keep their rows with the
+-- time they were deleted, so they can be restored.
+alter table client add column deleted_at timestamp null;
+alter table room add column deleted_at timestamp null;
+alter table payment add column deleted_at timestamp null;
+alter table expense add column deleted_at timestamp null;
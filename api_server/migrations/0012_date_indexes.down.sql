@@ -0,0 +1,2 @@
+drop index expense_date_idx on expense;
+drop index payment_date_idx on payment;
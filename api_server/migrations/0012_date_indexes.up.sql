@@ -0,0 +1,4 @@
+-- MySQL indexes the client_id and room_id foreign keys by itself; SQLite
+-- gets the same indexes from the create table statements.
+create index payment_date_idx on payment (payment_date);
+create index expense_date_idx on expense (expense_date);
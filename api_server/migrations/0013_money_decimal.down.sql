@@ -0,0 +1,14 @@
+alter table expense_allocation modify allocation_amount float not null;
+alter table expense_share modify share_remainder float not null;
+alter table charge
+    modify charge_area_amount float not null,
+    modify charge_people_amount float not null,
+    modify charge_fixed_amount float not null,
+    modify charge_amount float not null;
+alter table room modify room_area float not null;
+alter table tariff
+    modify tariff_area_rate float not null,
+    modify tariff_people_rate float not null,
+    modify tariff_fixed_fee float not null;
+alter table expense modify expense_amount float not null;
+alter table payment modify payment_amount float not null;
@@ -0,0 +1,17 @@
+-- Amounts of money, tariff prices and room areas become exact decimals.
+-- SQLite has no decimal type: it skips modify, and Money, UnitPrice and Area
+-- round the floats it returns to their last decimal.
+alter table payment modify payment_amount decimal(14,2) not null;
+alter table expense modify expense_amount decimal(14,2) not null;
+alter table tariff
+    modify tariff_area_rate decimal(14,4) not null,
+    modify tariff_people_rate decimal(14,4) not null,
+    modify tariff_fixed_fee decimal(14,2) not null;
+alter table room modify room_area decimal(10,2) not null;
+alter table charge
+    modify charge_area_amount decimal(14,2) not null,
+    modify charge_people_amount decimal(14,2) not null,
+    modify charge_fixed_amount decimal(14,2) not null,
+    modify charge_amount decimal(14,2) not null;
+alter table expense_share modify share_remainder decimal(14,2) not null;
+alter table expense_allocation modify allocation_amount decimal(14,2) not null;
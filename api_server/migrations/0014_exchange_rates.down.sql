@@ -0,0 +1,5 @@
+alter table payment drop column payment_exchange_rate;
+alter table payment drop column payment_original_amount;
+alter table payment drop column payment_currency;
+
+drop table if exists exchange_rate;
@@ -0,0 +1,16 @@
+-- Exchange rates into the ledger currency, and the currency of a payment
+-- with the received amount and the rate it was converted at. Payments in the
+-- ledger currency leave them null.
+create table if not exists exchange_rate (
+    rate_id int not null auto_increment,
+    rate_currency char(3) not null,
+    rate_date date not null,
+    rate_value decimal(18,6) not null,
+    last_edited timestamp not null default current_timestamp,
+    primary key (rate_id),
+    unique key (rate_currency, rate_date)
+);
+
+alter table payment add column payment_currency char(3) null;
+alter table payment add column payment_original_amount decimal(14,2) null;
+alter table payment add column payment_exchange_rate decimal(18,6) null;
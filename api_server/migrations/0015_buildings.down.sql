@@ -0,0 +1,12 @@
+alter table tariff drop foreign key tariff_building;
+alter table tariff drop column building_id;
+
+alter table expense drop foreign key expense_building;
+alter table expense drop column building_id;
+
+alter table room drop foreign key room_building;
+drop index room_building_number_idx on room;
+alter table room drop column room_number;
+alter table room drop column building_id;
+
+drop table if exists building;
@@ -0,0 +1,35 @@
+-- Rooms, expenses and tariffs belong to a building. A room keeps its global
+-- room_id and gets a number unique within its building. Tariffs without a
+-- building apply to every building that has none of its own. Rows that exist
+-- already are moved into one building, created only if there are any.
+create table if not exists building (
+    building_id int not null auto_increment,
+    building_name varchar(100) not null,
+    building_address varchar(255) not null default '',
+    building_contact varchar(255) not null default '',
+    building_currency char(3) null,
+    last_edited timestamp not null default current_timestamp,
+    primary key (building_id)
+);
+
+insert into building (building_name)
+select 'Building 1' from (select room_id as id from room union all select expense_id from expense) as used limit 1;
+
+alter table room
+    add column building_id int null,
+    add constraint room_building foreign key (building_id) references building(building_id);
+alter table room add column room_number varchar(20) null;
+update room set building_id = (select min(building_id) from building), room_number = room_id;
+alter table room modify building_id int not null;
+alter table room modify room_number varchar(20) not null;
+create unique index room_building_number_idx on room (building_id, room_number);
+
+alter table expense
+    add column building_id int null,
+    add constraint expense_building foreign key (building_id) references building(building_id);
+update expense set building_id = (select min(building_id) from building);
+alter table expense modify building_id int not null;
+
+alter table tariff
+    add column building_id int null,
+    add constraint tariff_building foreign key (building_id) references building(building_id);
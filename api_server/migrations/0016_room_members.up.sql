@@ -0,0 +1,21 @@
+-- A room has any number of members: owners and co-owners with their shares
+-- of the ownership, tenants and payers. A membership holds from member_from
+-- up to, but not including, member_until; null dates leave it open. The
+-- client_id of a room stays the client the room is registered to, and is
+-- its first owner.
+create table if not exists room_member (
+    member_id int not null auto_increment,
+    room_id int not null,
+    client_id bigint not null,
+    member_role varchar(20) not null,
+    member_share decimal(5,4) null,
+    member_from date null,
+    member_until date null,
+    last_edited timestamp not null default current_timestamp,
+    primary key (member_id),
+    foreign key (room_id) references room(room_id) on delete cascade,
+    foreign key (client_id) references client(client_id) on delete cascade
+);
+
+insert into room_member (room_id, client_id, member_role)
+select room_id, client_id, 'owner' from room;
@@ -0,0 +1,3 @@
+alter table room drop column room_attributes_from;
+
+drop table if exists room_attribute;
@@ -0,0 +1,23 @@
+-- The people count and area of a room are versioned: a version holds from
+-- attribute_from until the next version of the room. The room keeps the
+-- values of its latest version and the day it took effect. Rooms that exist
+-- already get one version in effect since 1970-01-01, as their history is not
+-- known.
+create table if not exists room_attribute (
+    attribute_id int not null auto_increment,
+    room_id int not null,
+    attribute_from date not null,
+    room_people_count int not null,
+    room_area decimal(10,2) not null,
+    last_edited timestamp not null default current_timestamp,
+    primary key (attribute_id),
+    unique key (room_id, attribute_from),
+    foreign key (room_id) references room(room_id) on delete cascade
+);
+
+alter table room add column room_attributes_from date null;
+update room set room_attributes_from = '1970-01-01';
+alter table room modify room_attributes_from date not null;
+
+insert into room_attribute (room_id, attribute_from, room_people_count, room_area)
+select room_id, room_attributes_from, room_people_count, room_area from room;
@@ -0,0 +1,9 @@
+-- The currency payment_amount is kept in, the currency of the building of
+-- the room when the payment was made. Null is the base currency, as for
+-- building_currency.
+alter table payment add column payment_ledger_currency char(3) null;
+
+update payment set payment_ledger_currency = (
+    select b.building_currency from room r join building b on b.building_id = r.building_id
+    where r.room_id = payment.room_id
+);
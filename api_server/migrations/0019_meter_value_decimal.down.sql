@@ -0,0 +1,3 @@
+alter table meter_reading
+    modify reading_value double not null,
+    modify reading_consumption double not null;
@@ -0,0 +1,6 @@
+-- Meter values and consumptions become exact decimals in thousandths of the
+-- unit of their meter. SQLite has no decimal type: it skips modify, and
+-- MeterValue rounds the floats it returns to their last decimal.
+alter table meter_reading
+    modify reading_value decimal(14,3) not null,
+    modify reading_consumption decimal(14,3) not null;
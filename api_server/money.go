@@ -0,0 +1,494 @@
+package main
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"math"
+	"math/big"
+	"os"
+	"strconv"
+	"strings"
+
+	api_errors "github.com/snakehunterr/hacs_db_types/errors"
+)
+
+// Amounts of money are kept exact all the way: decimal(14,2) columns in
+// MySQL, integer minor units in Go and decimal strings with two fraction
+// digits in JSON, e.g. "120.50". So are the tariff prices and room areas
+// charges are computed from, and meter values, so a consumption is the exact
+// difference of two readings. Requests may also send an amount as a JSON
+// number, which is read from its text, never through a float.
+//
+// SQLite has no decimal type and returns amounts and sums as floats. Money
+// rounds them to the nearest minor unit, which is exact for any sum of
+// amounts that fits in a float64 mantissa.
+//
+// Every building keeps its ledger, its balances, charges and expenses, in its
+// own currency, building_currency. Exchange rates are quoted in the base
+// currency, DBAPI_CURRENCY, which is also the currency of a building created
+// without one. A payment received in another currency than the ledger of its
+// room is converted through the base currency at the rates in effect on its
+// date, and keeps the received amount and the rate it was converted at.
+
+// baseCurrency is the ISO 4217 code of the currency exchange rates are quoted
+// in.
+func baseCurrency() string {
+	if c := os.Getenv("DBAPI_CURRENCY"); c != "" {
+		return strings.ToUpper(c)
+	}
+	return "RUB"
+}
+
+// currencies are the ISO 4217 codes of the currencies in circulation. Funds,
+// precious metals and the codes reserved for testing and for no currency,
+// such as XXX, are left out.
+var currencies = func() map[string]bool {
+	m := map[string]bool{}
+	for _, c := range strings.Fields(`
+	AED AFN ALL AMD ANG AOA ARS AUD AWG AZN BAM BBD BDT BGN BHD BIF
+	BMD BND BOB BRL BSD BTN BWP BYN BZD CAD CDF CHF CLP CNY COP CRC
+	CUC CUP CVE CZK DJF DKK DOP DZD EGP ERN ETB EUR FJD FKP GBP GEL
+	GHS GIP GMD GNF GTQ GYD HKD HNL HTG HUF IDR ILS INR IQD IRR ISK
+	JMD JOD JPY KES KGS KHR KMF KPW KRW KWD KYD KZT LAK LBP LKR LRD
+	LSL LYD MAD MDL MGA MKD MMK MNT MOP MRU MUR MVR MWK MXN MYR MZN
+	NAD NGN NIO NOK NPR NZD OMR PAB PEN PGK PHP PKR PLN PYG QAR RON
+	RSD RUB RWF SAR SBD SCR SDG SEK SGD SHP SLE SLL SOS SRD SSP STN
+	SVC SYP SZL THB TJS TMT TND TOP TRY TTD TWD TZS UAH UGX USD UYU
+	UZS VED VES VND VUV WST XAF XCD XCG XOF XPF YER ZAR ZMW ZWG
+`) {
+		m[c] = true
+	}
+	return m
+}()
+
+// validateCurrency parses an ISO 4217 currency code.
+func validateCurrency(name, value string, emptyCheck bool) (string, *api_errors.APIError) {
+	if emptyCheck && len(value) == 0 {
+		return "", api_errors.NewErrEmptyParam(name)
+	}
+
+	value = strings.ToUpper(value)
+	if !currencies[value] {
+		return "", api_errors.NewErrIncorrectParam(name + ": not an ISO 4217 currency code")
+	}
+	return value, nil
+}
+
+// Money is an amount in minor units of its currency, e.g. kopecks.
+type Money int64
+
+// moneyMax is the largest amount a decimal(14,2) column holds.
+const moneyMax Money = 1e14 - 1
+
+func (m Money) String() string {
+	return formatDecimal(int64(m), 2)
+}
+
+// parseMoney parses an amount with at most two fraction digits.
+func parseMoney(s string) (Money, bool) {
+	v, ok := parseDecimal(s, 2, int64(moneyMax))
+	return Money(v), ok
+}
+
+// validateMoney is the validator of amount parameters. An amount can't be
+// negative.
+func validateMoney(name, value string, emptyCheck bool) (Money, *api_errors.APIError) {
+	if emptyCheck && len(value) == 0 {
+		return 0, api_errors.NewErrEmptyParam(name)
+	}
+
+	m, ok := parseMoney(value)
+	if !ok || m < 0 {
+		return 0, api_errors.NewErrIncorrectParam(name + ": not a non-negative amount with at most 2 decimals")
+	}
+	return m, nil
+}
+
+func (m Money) MarshalJSON() ([]byte, error) {
+	return json.Marshal(m.String())
+}
+
+// UnmarshalJSON reads an amount sent as a string or a number.
+func (m *Money) UnmarshalJSON(b []byte) error {
+	v, err := unmarshalDecimal(b, 2, int64(moneyMax))
+	*m = Money(v)
+	return err
+}
+
+// Scan reads a decimal column, or a sum of them, of either backend.
+func (m *Money) Scan(src any) error {
+	v, err := scanDecimal(src, 2, int64(moneyMax))
+	*m = Money(v)
+	return err
+}
+
+// Value writes m as a decimal, so it is stored and compared exactly.
+func (m Money) Value() (driver.Value, error) {
+	return m.String(), nil
+}
+
+// ExchangeRate is the price of one unit of a currency in another, in
+// millionths.
+type ExchangeRate int64
+
+// exchangeRateMax is the largest rate a decimal(18,6) column holds.
+const exchangeRateMax ExchangeRate = 1e18 - 1
+
+func (r ExchangeRate) String() string {
+	return formatDecimal(int64(r), 6)
+}
+
+// validateExchangeRate is the validator of exchange rate parameters. A rate
+// must be positive.
+func validateExchangeRate(name, value string, emptyCheck bool) (ExchangeRate, *api_errors.APIError) {
+	if emptyCheck && len(value) == 0 {
+		return 0, api_errors.NewErrEmptyParam(name)
+	}
+
+	v, ok := parseDecimal(value, 6, int64(exchangeRateMax))
+	if !ok || v <= 0 {
+		return 0, api_errors.NewErrIncorrectParam(name + ": not a positive rate with at most 6 decimals")
+	}
+	return ExchangeRate(v), nil
+}
+
+// exchangeRateOne is the rate of a currency in itself.
+const exchangeRateOne ExchangeRate = 1e6
+
+// convert returns m, an amount in the currency r is the price of, in the
+// currency r is quoted in, rounded half away from zero to a whole minor unit.
+func (r ExchangeRate) convert(m Money) Money {
+	return Money(mulDiv(int64(m), int64(r), int64(exchangeRateOne)))
+}
+
+// cross returns the rate of r's currency in o's currency, both quoted in the
+// same currency, rounded half away from zero to a millionth.
+func (r ExchangeRate) cross(o ExchangeRate) ExchangeRate {
+	return ExchangeRate(mulDiv(int64(r), int64(exchangeRateOne), int64(o)))
+}
+
+// mulDiv returns a*b/c rounded half away from zero, for a positive c.
+func mulDiv(a, b, c int64) int64 {
+	var (
+		v    = new(big.Int).Mul(big.NewInt(a), big.NewInt(b))
+		sign = int64(v.Sign())
+		d    = big.NewInt(c)
+		rem  = new(big.Int)
+	)
+
+	v.QuoRem(v, d, rem)
+	if rem.Abs(rem).Mul(rem, big.NewInt(2)).Cmp(d) >= 0 {
+		v.Add(v, big.NewInt(sign))
+	}
+	return v.Int64()
+}
+
+func (r ExchangeRate) MarshalJSON() ([]byte, error) {
+	return json.Marshal(r.String())
+}
+
+// UnmarshalJSON reads a rate sent as a string or a number.
+func (r *ExchangeRate) UnmarshalJSON(b []byte) error {
+	v, err := unmarshalDecimal(b, 6, int64(exchangeRateMax))
+	*r = ExchangeRate(v)
+	return err
+}
+
+func (r *ExchangeRate) Scan(src any) error {
+	v, err := scanDecimal(src, 6, int64(exchangeRateMax))
+	*r = ExchangeRate(v)
+	return err
+}
+
+func (r ExchangeRate) Value() (driver.Value, error) {
+	return r.String(), nil
+}
+
+// UnitPrice is a price of one m² or one person in ten-thousandths of a major
+// unit of its currency, kept in decimal(14,4) columns.
+type UnitPrice int64
+
+// unitPriceMax is the largest price a decimal(14,4) column holds.
+const unitPriceMax UnitPrice = 1e14 - 1
+
+func (p UnitPrice) String() string {
+	return formatDecimal(int64(p), 4)
+}
+
+// validateUnitPrice is the validator of tariff rate parameters. A price can't
+// be negative.
+func validateUnitPrice(name, value string, emptyCheck bool) (UnitPrice, *api_errors.APIError) {
+	if emptyCheck && len(value) == 0 {
+		return 0, api_errors.NewErrEmptyParam(name)
+	}
+
+	v, ok := parseDecimal(value, 4, int64(unitPriceMax))
+	if !ok || v < 0 {
+		return 0, api_errors.NewErrIncorrectParam(name + ": not a non-negative price with at most 4 decimals")
+	}
+	return UnitPrice(v), nil
+}
+
+func (p UnitPrice) MarshalJSON() ([]byte, error) {
+	return json.Marshal(p.String())
+}
+
+// UnmarshalJSON reads a price sent as a string or a number.
+func (p *UnitPrice) UnmarshalJSON(b []byte) error {
+	v, err := unmarshalDecimal(b, 4, int64(unitPriceMax))
+	*p = UnitPrice(v)
+	return err
+}
+
+func (p *UnitPrice) Scan(src any) error {
+	v, err := scanDecimal(src, 4, int64(unitPriceMax))
+	*p = UnitPrice(v)
+	return err
+}
+
+func (p UnitPrice) Value() (driver.Value, error) {
+	return p.String(), nil
+}
+
+// Area is an area of a room in hundredths of m², kept in decimal(10,2)
+// columns.
+type Area int64
+
+// areaMax is the largest area a decimal(10,2) column holds.
+const areaMax Area = 1e10 - 1
+
+func (a Area) String() string {
+	return formatDecimal(int64(a), 2)
+}
+
+// parseArea parses an area with at most two fraction digits.
+func parseArea(s string) (Area, bool) {
+	v, ok := parseDecimal(s, 2, int64(areaMax))
+	return Area(v), ok
+}
+
+// validateArea is the validator of area parameters. An area can't be
+// negative.
+func validateArea(name, value string, emptyCheck bool) (Area, *api_errors.APIError) {
+	if emptyCheck && len(value) == 0 {
+		return 0, api_errors.NewErrEmptyParam(name)
+	}
+
+	a, ok := parseArea(value)
+	if !ok || a < 0 {
+		return 0, api_errors.NewErrIncorrectParam(name + ": not a non-negative area with at most 2 decimals")
+	}
+	return a, nil
+}
+
+func (a Area) MarshalJSON() ([]byte, error) {
+	return json.Marshal(a.String())
+}
+
+// UnmarshalJSON reads an area sent as a string or a number.
+func (a *Area) UnmarshalJSON(b []byte) error {
+	v, err := unmarshalDecimal(b, 2, int64(areaMax))
+	*a = Area(v)
+	return err
+}
+
+func (a *Area) Scan(src any) error {
+	v, err := scanDecimal(src, 2, int64(areaMax))
+	*a = Area(v)
+	return err
+}
+
+func (a Area) Value() (driver.Value, error) {
+	return a.String(), nil
+}
+
+// MeterValue is a meter value or consumption in thousandths of the unit of
+// its meter, e.g. liters of a water meter counting m³, kept in
+// decimal(14,3) columns.
+type MeterValue int64
+
+// meterValueMax is the largest value a decimal(14,3) column holds.
+const meterValueMax MeterValue = 1e14 - 1
+
+func (v MeterValue) String() string {
+	return formatDecimal(int64(v), 3)
+}
+
+// validateMeterValue is the validator of meter value parameters. A value
+// can't be negative.
+func validateMeterValue(name, value string, emptyCheck bool) (MeterValue, *api_errors.APIError) {
+	if emptyCheck && len(value) == 0 {
+		return 0, api_errors.NewErrEmptyParam(name)
+	}
+
+	v, ok := parseDecimal(value, 3, int64(meterValueMax))
+	if !ok || v < 0 {
+		return 0, api_errors.NewErrIncorrectParam(name + ": not a non-negative value with at most 3 decimals")
+	}
+	return MeterValue(v), nil
+}
+
+func (v MeterValue) MarshalJSON() ([]byte, error) {
+	return json.Marshal(v.String())
+}
+
+// UnmarshalJSON reads a value sent as a string or a number.
+func (v *MeterValue) UnmarshalJSON(b []byte) error {
+	d, err := unmarshalDecimal(b, 3, int64(meterValueMax))
+	*v = MeterValue(d)
+	return err
+}
+
+func (v *MeterValue) Scan(src any) error {
+	d, err := scanDecimal(src, 3, int64(meterValueMax))
+	*v = MeterValue(d)
+	return err
+}
+
+func (v MeterValue) Value() (driver.Value, error) {
+	return v.String(), nil
+}
+
+// MemberShare is the share of the ownership of a room held by an owner or a
+// co-owner in ten-thousandths, kept in decimal(5,4) columns.
+type MemberShare int64
+
+const (
+	// memberShareMax is the largest value a decimal(5,4) column holds.
+	memberShareMax MemberShare = 1e5 - 1
+	// memberShareWhole is the whole of a room.
+	memberShareWhole MemberShare = 1e4
+)
+
+func (m MemberShare) String() string {
+	return formatDecimal(int64(m), 4)
+}
+
+// validateMemberShare is the validator of share parameters. A share can't
+// be negative.
+func validateMemberShare(name, value string, emptyCheck bool) (MemberShare, *api_errors.APIError) {
+	if emptyCheck && len(value) == 0 {
+		return 0, api_errors.NewErrEmptyParam(name)
+	}
+
+	v, ok := parseDecimal(value, 4, int64(memberShareMax))
+	if !ok || v < 0 {
+		return 0, api_errors.NewErrIncorrectParam(name + ": not a non-negative share with at most 4 decimals")
+	}
+	return MemberShare(v), nil
+}
+
+func (m MemberShare) MarshalJSON() ([]byte, error) {
+	return json.Marshal(m.String())
+}
+
+// UnmarshalJSON reads a share sent as a string or a number.
+func (m *MemberShare) UnmarshalJSON(b []byte) error {
+	v, err := unmarshalDecimal(b, 4, int64(memberShareMax))
+	*m = MemberShare(v)
+	return err
+}
+
+func (m *MemberShare) Scan(src any) error {
+	v, err := scanDecimal(src, 4, int64(memberShareMax))
+	*m = MemberShare(v)
+	return err
+}
+
+func (m MemberShare) Value() (driver.Value, error) {
+	return m.String(), nil
+}
+
+// Money, ExchangeRate, UnitPrice, Area, MeterValue and MemberShare are
+// fixed-point decimals: integers counting units of 10^-digits.
+
+func formatDecimal(v int64, digits int) string {
+	sign := ""
+	if v < 0 {
+		sign, v = "-", -v
+	}
+
+	scale := int64(math.Pow10(digits))
+	return fmt.Sprintf("%s%d.%0*d", sign, v/scale, digits, v%scale)
+}
+
+// parseDecimal parses a decimal with at most digits fraction digits and an
+// absolute value of at most max.
+func parseDecimal(s string, digits int, max int64) (int64, bool) {
+	neg := strings.HasPrefix(s, "-")
+	s = strings.TrimPrefix(strings.TrimPrefix(s, "-"), "+")
+
+	whole, frac, _ := strings.Cut(s, ".")
+	if whole == "" || len(frac) > digits || strings.Trim(whole+frac, "0123456789") != "" {
+		return 0, false
+	}
+
+	scale := int64(math.Pow10(digits))
+	units, err := strconv.ParseInt(whole, 10, 64)
+	if err != nil || units > max/scale {
+		return 0, false
+	}
+
+	part, _ := strconv.ParseInt(frac+strings.Repeat("0", digits-len(frac)), 10, 64)
+	v := units*scale + part
+	if v > max {
+		return 0, false
+	}
+
+	if neg {
+		v = -v
+	}
+	return v, true
+}
+
+func unmarshalDecimal(b []byte, digits int, max int64) (int64, error) {
+	s := string(b)
+	if len(b) > 0 && b[0] == '"' {
+		if err := json.Unmarshal(b, &s); err != nil {
+			return 0, err
+		}
+	}
+
+	v, ok := parseDecimal(s, digits, max)
+	if !ok {
+		return 0, fmt.Errorf("invalid decimal: %s", b)
+	}
+	return v, nil
+}
+
+// scanDecimal reads a decimal as either backend returns it. MySQL returns
+// text, and sums of it may have more fraction digits, all zeros; SQLite
+// returns integers and floats.
+func scanDecimal(src any, digits int, max int64) (int64, error) {
+	scale := math.Pow10(digits)
+
+	switch v := src.(type) {
+	case nil:
+		return 0, nil
+	case int64:
+		return v * int64(scale), nil
+	case float64:
+		return int64(math.Round(v * scale)), nil
+	case []byte:
+		src = string(v)
+	}
+
+	s, ok := src.(string)
+	if !ok {
+		return 0, fmt.Errorf("unsupported decimal value: %T", src)
+	}
+
+	if whole, frac, ok := strings.Cut(s, "."); ok && len(frac) > digits {
+		if strings.Trim(frac[digits:], "0") != "" {
+			return 0, fmt.Errorf("decimal has more than %d fraction digits: %s", digits, s)
+		}
+		s = whole + "." + frac[:digits]
+	}
+
+	v, ok := parseDecimal(s, digits, max)
+	if !ok {
+		return 0, fmt.Errorf("invalid decimal: %s", s)
+	}
+	return v, nil
+}
@@ -0,0 +1,402 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	types "github.com/snakehunterr/hacs_db_types"
+	api_errors "github.com/snakehunterr/hacs_db_types/errors"
+)
+
+// Every entity of the API is stored through the repositories below. Every method takes the context of the
+// request: for a *gin.Context the SQL repositories also take the actor and
+// request id of the audit log from it. Methods with an includeDeleted flag
+// skip soft deleted rows unless it is set.
+//
+// Create stores a row and fills it in as stored, id and last_edited
+// included. Delete fails with ErrNotFound for a row that does not exist or is
+// already deleted.
+//
+// Update reads the active row, applies patch to it and writes it back in one
+// transaction, and returns the row as written. Update and Delete take the
+// If-Match header of the request and fail with ErrPreconditionFailed when it
+// does not match the ETag of the row; an empty ifMatch matches every row.
+
+var (
+	// ErrNotFound is returned for a row that does not exist, is soft deleted
+	// or, for Restore, is not deleted.
+	ErrNotFound = errors.New("not found")
+
+	// ErrDuplicate is returned by Create for a key that is already taken.
+	ErrDuplicate = errors.New("duplicate")
+
+	// ErrInvalidReference is returned by Create and Update for a row that
+	// references a building, client, room or meter that does not exist.
+	ErrInvalidReference = errors.New("invalid reference")
+
+	// ErrInUse is returned for a change of a row that other rows still
+	// reference.
+	ErrInUse = errors.New("in use")
+
+	// ErrPreconditionFailed is returned by Update and Delete for a row that
+	// has changed since the ETag of If-Match was read.
+	ErrPreconditionFailed = errors.New("precondition failed")
+
+	// ErrCurrencyMismatch is returned by Update for a payment moved to a room
+	// of a building that keeps its ledger in another currency.
+	ErrCurrencyMismatch = errors.New("currency mismatch")
+)
+
+type BuildingRepo interface {
+	All(ctx context.Context) ([]Building, error)
+	ByID(ctx context.Context, id int64) (Building, error)
+	Create(ctx context.Context, b *Building) error
+	Update(ctx context.Context, id int64, ifMatch string, patch func(*Building)) (Building, error)
+	// Delete deletes the building for good. It fails with ErrInUse while
+	// rooms, expenses or tariffs, deleted or not, belong to it.
+	Delete(ctx context.Context, id int64, ifMatch string) error
+}
+
+// ClientFilter selects clients by a part of their name, by IsAdmin and by
+// a building they have a room in; zero fields match everything.
+type ClientFilter struct {
+	Name           string
+	IsAdmin        *bool
+	BuildingID     int64
+	IncludeDeleted bool
+}
+
+type ClientRepo interface {
+	List(ctx context.Context, f ClientFilter, q PageQuery) (Page[Client], error)
+	Admins(ctx context.Context, includeDeleted bool) ([]Client, error)
+	ByName(ctx context.Context, name string, includeDeleted bool) ([]Client, error)
+	ByID(ctx context.Context, id int64, includeDeleted bool) (Client, error)
+	Create(ctx context.Context, c *Client) error
+	Update(ctx context.Context, id int64, ifMatch string, patch func(*Client)) (Client, error)
+	// Delete soft deletes the client together with its rooms.
+	Delete(ctx context.Context, id int64, ifMatch string) error
+	// Restore restores the client and the rooms deleted with it.
+	Restore(ctx context.Context, id int64) error
+}
+
+// RoomFilter selects rooms by building, client and area; zero fields match
+// everything.
+type RoomFilter struct {
+	BuildingID     int64
+	ClientID       int64
+	MinArea        *Area
+	MaxArea        *Area
+	IncludeDeleted bool
+}
+
+type RoomRepo interface {
+	List(ctx context.Context, f RoomFilter, q PageQuery) (Page[Room], error)
+	ByBuildingID(ctx context.Context, buildingID int64, includeDeleted bool) ([]Room, error)
+	ByID(ctx context.Context, id int64, includeDeleted bool) (Room, error)
+	// ByClientID returns the rooms registered to the client, not those it is
+	// only a member of.
+	ByClientID(ctx context.Context, clientID int64, includeDeleted bool) ([]Room, error)
+	// Attributes returns the versions of the people count and area of the
+	// room, oldest first.
+	Attributes(ctx context.Context, roomID int64) ([]RoomAttributes, error)
+	// Create fails with ErrDuplicate for a taken room_id or for a Number
+	// taken in the building. It makes ClientID the first owner of the room,
+	// and its PeopleCount and Area the first version of its attributes.
+	Create(ctx context.Context, r *Room) error
+	// Update stores the PeopleCount and Area of the patched room as the
+	// version of its attributes from AttributesFrom, replacing a version of
+	// the same day. The room returned has the values of its latest version.
+	Update(ctx context.Context, id int64, ifMatch string, patch func(*Room)) (Room, error)
+	Delete(ctx context.Context, id int64, ifMatch string) error
+	// Restore fails with ErrNotFound while the client of the room is deleted.
+	Restore(ctx context.Context, id int64) error
+}
+
+type MemberRepo interface {
+	ByID(ctx context.Context, id int64) (RoomMember, error)
+	ByRoomID(ctx context.Context, roomID int64) ([]RoomMember, error)
+	// ByClientID returns the memberships of the client in every room,
+	// deleted rooms included.
+	ByClientID(ctx context.Context, clientID int64) ([]RoomMember, error)
+	// Create stores m. While the members of its room are locked it calls
+	// check with m and the members of the room, and fails with the error of
+	// check as is.
+	Create(ctx context.Context, m *RoomMember, check func(RoomMember, []RoomMember) error) error
+	// Update changes the role, share and dates of a membership; its room
+	// and client stay. Like Create it calls check with the patched
+	// membership, which is among the members of the room unpatched.
+	Update(ctx context.Context, id int64, ifMatch string, patch func(*RoomMember), check func(RoomMember, []RoomMember) error) (RoomMember, error)
+	// Delete deletes the membership for good.
+	Delete(ctx context.Context, id int64, ifMatch string) error
+}
+
+// PaymentFilter selects payments by building, client, room and amount; zero
+// fields match everything.
+type PaymentFilter struct {
+	BuildingID     int64
+	ClientID       int64
+	RoomID         int64
+	MinAmount      *Money
+	MaxAmount      *Money
+	IncludeDeleted bool
+}
+
+type PaymentRepo interface {
+	List(ctx context.Context, f PaymentFilter, q PageQuery) (Page[Payment], error)
+	ByID(ctx context.Context, id int64, includeDeleted bool) (Payment, error)
+	ByClientID(ctx context.Context, clientID int64, includeDeleted bool) ([]Payment, error)
+	ByRoomID(ctx context.Context, roomID int64, includeDeleted bool) ([]Payment, error)
+	// ByDateRange returns the payments made from start up to, but not
+	// including, end.
+	ByDateRange(ctx context.Context, start, end time.Time, includeDeleted bool) ([]Payment, error)
+	// ByExternalID also returns soft deleted payments.
+	ByExternalID(ctx context.Context, externalID string) (Payment, error)
+	// Create fails with ErrDuplicate for a taken ExternalID.
+	Create(ctx context.Context, p *Payment) error
+	// Update fails with ErrCurrencyMismatch for a room of a building that
+	// keeps its ledger in another currency than the payment.
+	Update(ctx context.Context, id int64, ifMatch string, patch func(*Payment)) (Payment, error)
+	Delete(ctx context.Context, id int64, ifMatch string) error
+	Restore(ctx context.Context, id int64) error
+}
+
+// ExpenseFilter selects expenses by building, category, vendor and amount;
+// zero fields match everything.
+type ExpenseFilter struct {
+	BuildingID     int64
+	Category       string
+	Vendor         string
+	MinAmount      *Money
+	MaxAmount      *Money
+	IncludeDeleted bool
+}
+
+type ExpenseRepo interface {
+	List(ctx context.Context, f ExpenseFilter, q PageQuery) (Page[Expense], error)
+	ByID(ctx context.Context, id int64, includeDeleted bool) (Expense, error)
+	// ByDateRange returns the expenses made from start up to, but not
+	// including, end.
+	ByDateRange(ctx context.Context, start, end time.Time, includeDeleted bool) ([]Expense, error)
+	// CategoryTotals sums the active expenses of vendor, or of all vendors
+	// for an empty vendor, per building and category. A zero buildingID
+	// sums the expenses of every building.
+	CategoryTotals(ctx context.Context, buildingID int64, vendor string) ([]ExpenseCategoryTotal, error)
+	Create(ctx context.Context, e *Expense) error
+	// Update fails with ErrInUse for a change of the amount or date of a
+	// shared expense, which would leave its allocations stale.
+	Update(ctx context.Context, id int64, ifMatch string, patch func(*Expense)) (Expense, error)
+	Delete(ctx context.Context, id int64, ifMatch string) error
+	Restore(ctx context.Context, id int64) error
+}
+
+// TariffRepo stores tariff versions; a tariff is never changed or deleted.
+type TariffRepo interface {
+	// All returns the tariffs that apply to the building buildingID, its own
+	// and those of every building, or all tariffs for a zero buildingID.
+	All(ctx context.Context, buildingID int64) ([]Tariff, error)
+	ByID(ctx context.Context, id int64) (Tariff, error)
+	// Effective returns the latest tariff effective from period or earlier.
+	// A tariff of the building buildingID wins over those of every building.
+	Effective(ctx context.Context, period time.Time, buildingID int64) (Tariff, error)
+	Create(ctx context.Context, t *Tariff) error
+}
+
+// ChargeRepo stores the charges of rooms. Methods with a buildingID only
+// return the charges of the rooms of that building, unless it is zero.
+type ChargeRepo interface {
+	All(ctx context.Context, buildingID int64) ([]Charge, error)
+	ByID(ctx context.Context, id int64) (Charge, error)
+	ByRoomID(ctx context.Context, roomID int64) ([]Charge, error)
+	ByPeriod(ctx context.Context, period time.Time, buildingID int64) ([]Charge, error)
+	// Generate creates the missing charges of period for the active rooms of
+	// the building buildingID, at the tariff tariffID or, when it is zero, at
+	// the tariff in effect for the building of each room. It returns the
+	// number of charges created.
+	Generate(ctx context.Context, period time.Time, tariffID, buildingID int64) (int64, error)
+	Delete(ctx context.Context, id int64) error
+}
+
+// MeterRepo stores meters and their readings. Readings are returned oldest
+// first.
+type MeterRepo interface {
+	// All returns the meters of the rooms of the building buildingID, or of
+	// every room for a zero buildingID.
+	All(ctx context.Context, buildingID int64) ([]Meter, error)
+	ByID(ctx context.Context, id int64) (Meter, error)
+	ByRoomID(ctx context.Context, roomID int64) ([]Meter, error)
+	// Create fails with ErrDuplicate for a kind and serial that are taken.
+	Create(ctx context.Context, m *Meter) error
+	// Delete deletes the meter together with its readings.
+	Delete(ctx context.Context, id int64) error
+	Readings(ctx context.Context, meterID int64) ([]MeterReading, error)
+	Reading(ctx context.Context, id int64) (MeterReading, error)
+	// AddReading stores r for its meter. While the meter is locked it calls
+	// check with r and the readings of the meter, and fails with the error of
+	// check as is.
+	AddReading(ctx context.Context, r *MeterReading, check func(*MeterReading, []MeterReading) error) error
+	DeleteReading(ctx context.Context, id int64) error
+}
+
+type RateRepo interface {
+	// All returns the rates of currency, or of every currency for an empty
+	// one, by currency and date.
+	All(ctx context.Context, currency string) ([]Rate, error)
+	ByID(ctx context.Context, id int64) (Rate, error)
+	// Effective returns the latest rate of currency from day or earlier.
+	Effective(ctx context.Context, currency string, day time.Time) (Rate, error)
+	// Create fails with ErrDuplicate for a currency that has a rate on the
+	// day.
+	Create(ctx context.Context, r *Rate) error
+	Delete(ctx context.Context, id int64) error
+}
+
+// TokenRepo stores API tokens by their hash; their Token is never returned.
+type TokenRepo interface {
+	ByID(ctx context.Context, id int64) (APIToken, error)
+	ByClientID(ctx context.Context, clientID int64) ([]APIToken, error)
+	// Create stores the hash of t.Token and keeps t.Token in t.
+	Create(ctx context.Context, t *APIToken) error
+	Delete(ctx context.Context, id int64) error
+}
+
+type BotStateRepo interface {
+	// Get fails with ErrNotFound for an expired value.
+	Get(ctx context.Context, key string) (BotState, error)
+	// Set creates or replaces the value of key, which expires after ttl
+	// seconds unless ttl is zero. Expired values are removed.
+	Set(ctx context.Context, key, value string, ttl int64) error
+	Delete(ctx context.Context, key string) error
+}
+
+// AuditFilter selects audit log records; zero fields match everything.
+type AuditFilter struct {
+	Entity        string
+	EntityID      string
+	Action        string
+	Role          string
+	ActorClientID int64
+	RequestID     string
+	From          *time.Time
+	To            *time.Time
+	Limit         int64
+}
+
+type AuditRepo interface {
+	// List returns up to f.Limit records, newest first.
+	List(ctx context.Context, f AuditFilter) ([]AuditRecord, error)
+}
+
+type ShareRepo interface {
+	// ByExpenseID returns the share of the expense with its allocations.
+	ByExpenseID(ctx context.Context, expenseID int64) (ExpenseShare, error)
+	// AllocationsByRoomID returns the allocations of the room of expenses
+	// that are not deleted.
+	AllocationsByRoomID(ctx context.Context, roomID int64) ([]ExpenseAllocation, error)
+	// Set shares the expense s.ExpenseID by s.Rule, replacing its
+	// allocations with s.Allocations. It fails with ErrPreconditionFailed
+	// unless the expense still matches ifMatch, the ETag of the version the
+	// allocations were computed from.
+	Set(ctx context.Context, s *ExpenseShare, ifMatch string) error
+	// Delete removes the share of the expense with its allocations.
+	Delete(ctx context.Context, expenseID int64) error
+}
+
+type BalanceRepo interface {
+	// RoomMonths returns the charges, shared expenses and payments of the
+	// room summed per month, oldest first. Deleted payments and expenses are
+	// left out.
+	RoomMonths(ctx context.Context, roomID int64) ([]balanceRow, error)
+}
+
+// AccessRepo answers the questions of Authenticate and Authorize. Day is
+// the day memberships are checked for.
+type AccessRepo interface {
+	// Principal returns the caller with the API token of hash tokenHash. It
+	// fails with ErrNotFound for an unknown token or a deleted client.
+	Principal(ctx context.Context, tokenHash string) (Principal, error)
+	// OwnsRoom reports whether the active room roomID is registered to the
+	// client clientID or has it as a member.
+	OwnsRoom(ctx context.Context, clientID, roomID int64, day time.Time) (bool, error)
+	// OwnsPayment reports whether the active payment paymentID was made by
+	// the client clientID, or is of a room registered to it or having it as
+	// a member.
+	OwnsPayment(ctx context.Context, clientID, paymentID int64, day time.Time) (bool, error)
+}
+
+// Server serves every /api route from its repositories.
+type Server struct {
+	Buildings BuildingRepo
+	Clients   ClientRepo
+	Rooms     RoomRepo
+	Members   MemberRepo
+	Payments  PaymentRepo
+	Expenses  ExpenseRepo
+	Tariffs   TariffRepo
+	Charges   ChargeRepo
+	Meters    MeterRepo
+	Rates     RateRepo
+	Tokens    TokenRepo
+	BotStates BotStateRepo
+	Audit     AuditRepo
+	Shares    ShareRepo
+	Balances  BalanceRepo
+	Access    AccessRepo
+}
+
+// Routes registers the routes of s in api, behind RequestID, Authenticate
+// and Authorize.
+func (s *Server) Routes(api *gin.RouterGroup) {
+	api.Use(RequestID, s.Authenticate, s.Authorize)
+
+	s.buildingRoutes(api.Group("/building"))
+	s.clientRoutes(api.Group("/client"))
+	s.roomRoutes(api.Group("/room"))
+	s.paymentRoutes(api.Group("/payment"))
+	s.expenseRoutes(api.Group("/expense"))
+	s.tariffRoutes(api.Group("/tariff"))
+	s.chargeRoutes(api.Group("/charge"))
+	s.meterRoutes(api.Group("/meter"))
+	s.rateRoutes(api.Group("/rate"))
+	s.tokenRoutes(api.Group("/token"))
+	s.botRoutes(api.Group("/bot"))
+	api.GET("/audit", s.RouteAuditGet)
+}
+
+// repoError answers a failed repository call: 404 for ErrNotFound, 409 for
+// ErrDuplicate and ErrInUse, 412 for ErrPreconditionFailed, 422 for
+// ErrInvalidReference and 500 for anything else.
+func repoError(g *gin.Context, err error) {
+	switch {
+	case errors.Is(err, ErrNotFound):
+		g.JSON(http.StatusNotFound, types.APIResponse{
+			Error: api_errors.NewErrSQLNoRows("No rows"),
+		})
+		return
+
+	case errors.Is(err, ErrDuplicate), errors.Is(err, ErrInUse), errors.Is(err, ErrCurrencyMismatch):
+		g.JSON(http.StatusConflict, types.APIResponse{
+			Error: NewErrConflict(err.Error()),
+		})
+		return
+
+	case errors.Is(err, ErrInvalidReference):
+		g.JSON(http.StatusUnprocessableEntity, types.APIResponse{
+			Error: NewErrInvalidReference(err.Error()),
+		})
+		return
+
+	case errors.Is(err, ErrPreconditionFailed):
+		g.JSON(http.StatusPreconditionFailed, types.APIResponse{
+			Error: NewErrPreconditionFailed("If-Match: the row has changed"),
+		})
+		return
+	}
+
+	logError(g.Request.Method, " ", g.FullPath(), ": ", err)
+	g.JSON(http.StatusInternalServerError, types.APIResponse{
+		Error: api_errors.NewErrSQLInternalError(err.Error()),
+	})
+}
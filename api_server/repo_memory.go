@@ -0,0 +1,1634 @@
+package main
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// memoryStore keeps every entity in maps for the in-memory repositories. It
+// checks the references and unique keys a MySQL table would check, and
+// writes no audit log.
+type memoryStore struct {
+	mu               sync.Mutex
+	buildings        map[int64]Building
+	clients          map[int64]Client
+	rooms            map[int64]Room
+	attributes       map[int64]RoomAttributes
+	members          map[int64]RoomMember
+	payments         map[int64]Payment
+	expenses         map[int64]Expense
+	tariffs          map[int64]Tariff
+	charges          map[int64]Charge
+	meters           map[int64]Meter
+	readings         map[int64]MeterReading
+	rates            map[int64]Rate
+	tokens           map[int64]APIToken
+	tokenHashes      map[int64]string
+	botStates        map[string]BotState
+	shares           map[int64]ExpenseShare
+	allocations      map[int64]ExpenseAllocation
+	lastBuildingID   int64
+	lastAttributeID  int64
+	lastMemberID     int64
+	lastPaymentID    int64
+	lastExpenseID    int64
+	lastTariffID     int64
+	lastChargeID     int64
+	lastMeterID      int64
+	lastReadingID    int64
+	lastRateID       int64
+	lastTokenID      int64
+	lastAllocationID int64
+}
+
+// NewMemoryServer returns a Server whose repositories live in memory, e.g.
+// for handler tests.
+func NewMemoryServer() *Server {
+	s := &memoryStore{
+		buildings:   map[int64]Building{},
+		clients:     map[int64]Client{},
+		rooms:       map[int64]Room{},
+		attributes:  map[int64]RoomAttributes{},
+		members:     map[int64]RoomMember{},
+		payments:    map[int64]Payment{},
+		expenses:    map[int64]Expense{},
+		tariffs:     map[int64]Tariff{},
+		charges:     map[int64]Charge{},
+		meters:      map[int64]Meter{},
+		readings:    map[int64]MeterReading{},
+		rates:       map[int64]Rate{},
+		tokens:      map[int64]APIToken{},
+		tokenHashes: map[int64]string{},
+		botStates:   map[string]BotState{},
+		shares:      map[int64]ExpenseShare{},
+		allocations: map[int64]ExpenseAllocation{},
+	}
+
+	return &Server{
+		Buildings: memoryBuildingRepo{s},
+		Clients:   memoryClientRepo{s},
+		Rooms:     memoryRoomRepo{s},
+		Members:   memoryMemberRepo{s},
+		Payments:  memoryPaymentRepo{s},
+		Expenses:  memoryExpenseRepo{s},
+		Tariffs:   memoryTariffRepo{s},
+		Charges:   memoryChargeRepo{s},
+		Meters:    memoryMeterRepo{s},
+		Rates:     memoryRateRepo{s},
+		Tokens:    memoryTokenRepo{s},
+		BotStates: memoryBotStateRepo{s},
+		Audit:     memoryAuditRepo{},
+		Shares:    memoryShareRepo{s},
+		Balances:  memoryBalanceRepo{s},
+		Access:    memoryAccessRepo{s},
+	}
+}
+
+// memoryList returns the values of m accepted by keep, ordered by key.
+func memoryList[T any](m map[int64]T, keep func(T) bool) []T {
+	ids := make([]int64, 0, len(m))
+	for id := range m {
+		ids = append(ids, id)
+	}
+	slices.Sort(ids)
+
+	var vs []T
+	for _, id := range ids {
+		if v := m[id]; keep(v) {
+			vs = append(vs, v)
+		}
+	}
+	return vs
+}
+
+// memoryPage returns the page q of rows.
+func memoryPage[T any](f listFields[T], q PageQuery, rows []T) Page[T] {
+	slices.SortStableFunc(rows, func(a, b T) int { return f.compare(q, a, b) })
+
+	total := int64(len(rows))
+	if q.After != nil {
+		after, _ := f.afterValue(q)
+
+		i := 0
+		for i < len(rows) && !f.after(q, rows[i], after) {
+			i++
+		}
+		rows = rows[i:]
+	}
+
+	if len(rows) > q.Limit+1 {
+		rows = rows[:q.Limit+1]
+	}
+	return f.page(q, rows, total)
+}
+
+func memoryGet[T any](m map[int64]T, id int64, keep func(T) bool) (T, error) {
+	v, ok := m[id]
+	if !ok || !keep(v) {
+		var zero T
+		return zero, ErrNotFound
+	}
+	return v, nil
+}
+
+// memoryPatch returns the active row id of m, checked against ifMatch and
+// patched. The caller stores it.
+func memoryPatch[T any](m map[int64]T, id int64, ifMatch string, active func(T) bool, patch func(*T)) (T, error) {
+	v, err := memoryGet(m, id, active)
+	if err != nil {
+		return v, err
+	}
+	if !etagMatch(ifMatch, v) {
+		var zero T
+		return zero, ErrPreconditionFailed
+	}
+
+	patch(&v)
+	return v, nil
+}
+
+func visible(deleted_at *time.Time, includeDeleted bool) bool {
+	return includeDeleted || deleted_at == nil
+}
+
+func inBounds[V ~int64 | ~float64](v V, min, max *V) bool {
+	return (min == nil || v >= *min) && (max == nil || v <= *max)
+}
+
+func inRange(t, start, end time.Time) bool {
+	return !t.Before(start) && t.Before(end)
+}
+
+// monthOf returns the first day of the month of t.
+func monthOf(t time.Time) time.Time {
+	start, _ := dayRange(t)
+	return start.AddDate(0, 0, 1-start.Day())
+}
+
+func (s *memoryStore) activeClient(id int64) bool {
+	c, ok := s.clients[id]
+	return ok && c.DeletedAt == nil
+}
+
+// inBuilding reports whether the room roomID belongs to the building
+// buildingID, or buildingID is zero.
+func (s *memoryStore) inBuilding(roomID, buildingID int64) bool {
+	return buildingID == 0 || s.rooms[roomID].BuildingID == buildingID
+}
+
+func (s *memoryStore) checkBuilding(id int64) error {
+	if _, ok := s.buildings[id]; !ok {
+		return fmt.Errorf("%w: no building with building_id %d", ErrInvalidReference, id)
+	}
+	return nil
+}
+
+// checkRoomNumber fails for a room whose number is taken by another room of
+// its building, deleted ones included.
+func (s *memoryStore) checkRoomNumber(room Room) error {
+	for id, other := range s.rooms {
+		if id != room.ID && other.BuildingID == room.BuildingID && other.Number == room.Number {
+			return fmt.Errorf("%w: room_number %q in building_id %d", ErrDuplicate, room.Number, room.BuildingID)
+		}
+	}
+	return nil
+}
+
+// setAttributes stores the people count and area of room as the version of
+// its attributes from room.AttributesFrom, and returns room with the values
+// of its latest version.
+func (s *memoryStore) setAttributes(room Room) Room {
+	now := time.Now()
+
+	var (
+		latest RoomAttributes
+		stored bool
+	)
+	for id, a := range s.attributes {
+		if a.RoomID != room.ID {
+			continue
+		}
+
+		if a.From.Equal(room.AttributesFrom) {
+			a.PeopleCount, a.Area, a.LastEdited = room.PeopleCount, room.Area, now
+			s.attributes[id] = a
+			stored = true
+		}
+		if a.From.After(latest.From) {
+			latest = a
+		}
+	}
+
+	if !stored {
+		s.lastAttributeID++
+		a := RoomAttributes{
+			ID:          s.lastAttributeID,
+			RoomID:      room.ID,
+			From:        room.AttributesFrom,
+			PeopleCount: room.PeopleCount,
+			Area:        room.Area,
+			LastEdited:  now,
+		}
+		s.attributes[a.ID] = a
+
+		if a.From.After(latest.From) {
+			latest = a
+		}
+	}
+
+	room.PeopleCount, room.Area, room.AttributesFrom = latest.PeopleCount, latest.Area, latest.From
+	return room
+}
+
+// Building
+
+type memoryBuildingRepo struct {
+	*memoryStore
+}
+
+func (r memoryBuildingRepo) All(ctx context.Context) ([]Building, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return memoryList(r.buildings, func(Building) bool { return true }), nil
+}
+
+func (r memoryBuildingRepo) ByID(ctx context.Context, id int64) (Building, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return memoryGet(r.buildings, id, func(Building) bool { return true })
+}
+
+func (r memoryBuildingRepo) Create(ctx context.Context, b *Building) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.lastBuildingID++
+	b.ID = r.lastBuildingID
+	b.LastEdited = time.Now()
+	r.buildings[b.ID] = *b
+	return nil
+}
+
+func (r memoryBuildingRepo) Update(ctx context.Context, id int64, ifMatch string, patch func(*Building)) (Building, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	b, err := memoryPatch(r.buildings, id, ifMatch, func(Building) bool { return true }, patch)
+	if err != nil {
+		return Building{}, err
+	}
+
+	b.ID = id
+	b.Currency = r.buildings[id].Currency
+	b.LastEdited = time.Now()
+	r.buildings[id] = b
+	return b, nil
+}
+
+func (r memoryBuildingRepo) Delete(ctx context.Context, id int64, ifMatch string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	b, ok := r.buildings[id]
+	if !ok {
+		return ErrNotFound
+	}
+	if !etagMatch(ifMatch, b) {
+		return ErrPreconditionFailed
+	}
+
+	for _, room := range r.rooms {
+		if room.BuildingID == id {
+			return fmt.Errorf("%w: building_id %d has rooms", ErrInUse, id)
+		}
+	}
+	for _, e := range r.expenses {
+		if e.BuildingID == id {
+			return fmt.Errorf("%w: building_id %d has expenses", ErrInUse, id)
+		}
+	}
+
+	delete(r.buildings, id)
+	return nil
+}
+
+// Client
+
+type memoryClientRepo struct {
+	*memoryStore
+}
+
+func (r memoryClientRepo) List(ctx context.Context, f ClientFilter, q PageQuery) (Page[Client], error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return memoryPage(clientListFields, q, memoryList(r.clients, func(c Client) bool {
+		return strings.Contains(c.Name, f.Name) &&
+			(f.IsAdmin == nil || c.IsAdmin == *f.IsAdmin) &&
+			(f.BuildingID == 0 || r.hasRoomIn(c.ID, f.BuildingID)) &&
+			visible(c.DeletedAt, f.IncludeDeleted)
+	})), nil
+}
+
+// hasRoomIn reports whether the client clientID has an active room in the
+// building buildingID.
+func (r memoryClientRepo) hasRoomIn(clientID, buildingID int64) bool {
+	for _, room := range r.rooms {
+		if room.ClientID == clientID && room.BuildingID == buildingID && room.DeletedAt == nil {
+			return true
+		}
+	}
+	return false
+}
+
+func (r memoryClientRepo) Admins(ctx context.Context, includeDeleted bool) ([]Client, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return memoryList(r.clients, func(c Client) bool {
+		return c.IsAdmin && visible(c.DeletedAt, includeDeleted)
+	}), nil
+}
+
+func (r memoryClientRepo) ByName(ctx context.Context, name string, includeDeleted bool) ([]Client, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	name = strings.ToLower(name)
+	return memoryList(r.clients, func(c Client) bool {
+		return strings.Contains(strings.ToLower(c.Name), name) && visible(c.DeletedAt, includeDeleted)
+	}), nil
+}
+
+func (r memoryClientRepo) ByID(ctx context.Context, id int64, includeDeleted bool) (Client, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return memoryGet(r.clients, id, func(c Client) bool {
+		return visible(c.DeletedAt, includeDeleted)
+	})
+}
+
+func (r memoryClientRepo) Create(ctx context.Context, c *Client) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.clients[c.ID]; ok {
+		return fmt.Errorf("%w: client_id %d", ErrDuplicate, c.ID)
+	}
+
+	c.DeletedAt = nil
+	c.LastEdited = time.Now()
+	r.clients[c.ID] = *c
+	return nil
+}
+
+func (r memoryClientRepo) Update(ctx context.Context, id int64, ifMatch string, patch func(*Client)) (Client, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	c, err := memoryPatch(r.clients, id, ifMatch, Client.active, patch)
+	if err != nil {
+		return Client{}, err
+	}
+
+	c.ID = id
+	c.LastEdited = time.Now()
+	r.clients[id] = c
+	return c, nil
+}
+
+func (r memoryClientRepo) Delete(ctx context.Context, id int64, ifMatch string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	c, ok := r.clients[id]
+	if !ok || c.DeletedAt != nil {
+		return ErrNotFound
+	}
+	if !etagMatch(ifMatch, c) {
+		return ErrPreconditionFailed
+	}
+
+	now := time.Now()
+	deleted_at := now.UTC().Truncate(time.Second)
+
+	for room_id, room := range r.rooms {
+		if room.ClientID == id && room.DeletedAt == nil {
+			room.DeletedAt = &deleted_at
+			room.LastEdited = now
+			r.rooms[room_id] = room
+		}
+	}
+
+	c.DeletedAt = &deleted_at
+	c.LastEdited = now
+	r.clients[id] = c
+	return nil
+}
+
+func (r memoryClientRepo) Restore(ctx context.Context, id int64) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	c, ok := r.clients[id]
+	if !ok || c.DeletedAt == nil {
+		return ErrNotFound
+	}
+
+	now := time.Now()
+
+	for room_id, room := range r.rooms {
+		if room.ClientID == id && room.DeletedAt != nil && room.DeletedAt.Equal(*c.DeletedAt) {
+			room.DeletedAt = nil
+			room.LastEdited = now
+			r.rooms[room_id] = room
+		}
+	}
+
+	c.DeletedAt = nil
+	c.LastEdited = now
+	r.clients[id] = c
+	return nil
+}
+
+// Room
+
+type memoryRoomRepo struct {
+	*memoryStore
+}
+
+func (r memoryRoomRepo) List(ctx context.Context, f RoomFilter, q PageQuery) (Page[Room], error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return memoryPage(roomListFields, q, memoryList(r.rooms, func(room Room) bool {
+		return (f.BuildingID == 0 || room.BuildingID == f.BuildingID) &&
+			(f.ClientID == 0 || room.ClientID == f.ClientID) &&
+			inBounds(room.Area, f.MinArea, f.MaxArea) &&
+			visible(room.DeletedAt, f.IncludeDeleted)
+	})), nil
+}
+
+func (r memoryRoomRepo) ByBuildingID(ctx context.Context, buildingID int64, includeDeleted bool) ([]Room, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return memoryList(r.rooms, func(room Room) bool {
+		return room.BuildingID == buildingID && visible(room.DeletedAt, includeDeleted)
+	}), nil
+}
+
+func (r memoryRoomRepo) ByID(ctx context.Context, id int64, includeDeleted bool) (Room, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return memoryGet(r.rooms, id, func(room Room) bool {
+		return visible(room.DeletedAt, includeDeleted)
+	})
+}
+
+func (r memoryRoomRepo) ByClientID(ctx context.Context, clientID int64, includeDeleted bool) ([]Room, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return memoryList(r.rooms, func(room Room) bool {
+		return room.ClientID == clientID && visible(room.DeletedAt, includeDeleted)
+	}), nil
+}
+
+func (r memoryRoomRepo) Attributes(ctx context.Context, roomID int64) ([]RoomAttributes, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	as := memoryList(r.attributes, func(a RoomAttributes) bool { return a.RoomID == roomID })
+	slices.SortFunc(as, func(a, b RoomAttributes) int { return a.From.Compare(b.From) })
+	return as, nil
+}
+
+func (r memoryRoomRepo) Create(ctx context.Context, room *Room) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.rooms[room.ID]; ok {
+		return fmt.Errorf("%w: room_id %d", ErrDuplicate, room.ID)
+	}
+	if _, ok := r.clients[room.ClientID]; !ok {
+		return fmt.Errorf("%w: no client with client_id %d", ErrInvalidReference, room.ClientID)
+	}
+	if err := r.checkBuilding(room.BuildingID); err != nil {
+		return err
+	}
+	if err := r.checkRoomNumber(*room); err != nil {
+		return err
+	}
+
+	room.DeletedAt = nil
+	room.LastEdited = time.Now()
+	*room = r.setAttributes(*room)
+	r.rooms[room.ID] = *room
+
+	r.lastMemberID++
+	r.members[r.lastMemberID] = RoomMember{
+		ID:         r.lastMemberID,
+		RoomID:     room.ID,
+		ClientID:   room.ClientID,
+		Role:       MemberOwner,
+		LastEdited: room.LastEdited,
+	}
+	return nil
+}
+
+func (r memoryRoomRepo) Update(ctx context.Context, id int64, ifMatch string, patch func(*Room)) (Room, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	room, err := memoryPatch(r.rooms, id, ifMatch, Room.active, patch)
+	if err != nil {
+		return Room{}, err
+	}
+	if _, ok := r.clients[room.ClientID]; !ok {
+		return Room{}, fmt.Errorf("%w: no client with client_id %d", ErrInvalidReference, room.ClientID)
+	}
+
+	room.ID = id
+	room.BuildingID = r.rooms[id].BuildingID
+	if err := r.checkRoomNumber(room); err != nil {
+		return Room{}, err
+	}
+
+	room = r.setAttributes(room)
+	room.LastEdited = time.Now()
+	r.rooms[id] = room
+	return room, nil
+}
+
+func (r memoryRoomRepo) Delete(ctx context.Context, id int64, ifMatch string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	room, ok := r.rooms[id]
+	if !ok || room.DeletedAt != nil {
+		return ErrNotFound
+	}
+	if !etagMatch(ifMatch, room) {
+		return ErrPreconditionFailed
+	}
+
+	now := time.Now()
+	room.DeletedAt = &now
+	room.LastEdited = now
+	r.rooms[id] = room
+	return nil
+}
+
+func (r memoryRoomRepo) Restore(ctx context.Context, id int64) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	room, ok := r.rooms[id]
+	if !ok || room.DeletedAt == nil || !r.activeClient(room.ClientID) {
+		return ErrNotFound
+	}
+
+	room.DeletedAt = nil
+	room.LastEdited = time.Now()
+	r.rooms[id] = room
+	return nil
+}
+
+// Room member
+
+type memoryMemberRepo struct {
+	*memoryStore
+}
+
+func (r memoryMemberRepo) ByID(ctx context.Context, id int64) (RoomMember, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return memoryGet(r.members, id, func(RoomMember) bool { return true })
+}
+
+func (r memoryMemberRepo) ByRoomID(ctx context.Context, roomID int64) ([]RoomMember, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return r.roomMembers(roomID), nil
+}
+
+// roomMembers returns the members of the room roomID; r.mu must be held.
+func (r memoryMemberRepo) roomMembers(roomID int64) []RoomMember {
+	return memoryList(r.members, func(m RoomMember) bool { return m.RoomID == roomID })
+}
+
+func (r memoryMemberRepo) ByClientID(ctx context.Context, clientID int64) ([]RoomMember, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	ms := memoryList(r.members, func(m RoomMember) bool { return m.ClientID == clientID })
+	slices.SortStableFunc(ms, func(a, b RoomMember) int { return cmp.Compare(a.RoomID, b.RoomID) })
+	return ms, nil
+}
+
+func (r memoryMemberRepo) Create(ctx context.Context, m *RoomMember, check func(RoomMember, []RoomMember) error) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.rooms[m.RoomID]; !ok {
+		return fmt.Errorf("%w: no room with room_id %d", ErrInvalidReference, m.RoomID)
+	}
+	if _, ok := r.clients[m.ClientID]; !ok {
+		return fmt.Errorf("%w: no client with client_id %d", ErrInvalidReference, m.ClientID)
+	}
+
+	if err := check(*m, r.roomMembers(m.RoomID)); err != nil {
+		return err
+	}
+
+	r.lastMemberID++
+	m.ID = r.lastMemberID
+	m.LastEdited = time.Now()
+	r.members[m.ID] = *m
+	return nil
+}
+
+func (r memoryMemberRepo) Update(ctx context.Context, id int64, ifMatch string, patch func(*RoomMember), check func(RoomMember, []RoomMember) error) (RoomMember, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	m, err := memoryPatch(r.members, id, ifMatch, func(RoomMember) bool { return true }, patch)
+	if err != nil {
+		return RoomMember{}, err
+	}
+
+	old := r.members[id]
+	m.ID, m.RoomID, m.ClientID = id, old.RoomID, old.ClientID
+	if err := check(m, r.roomMembers(m.RoomID)); err != nil {
+		return RoomMember{}, err
+	}
+
+	m.LastEdited = time.Now()
+	r.members[id] = m
+	return m, nil
+}
+
+func (r memoryMemberRepo) Delete(ctx context.Context, id int64, ifMatch string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	m, ok := r.members[id]
+	if !ok {
+		return ErrNotFound
+	}
+	if !etagMatch(ifMatch, m) {
+		return ErrPreconditionFailed
+	}
+
+	delete(r.members, id)
+	return nil
+}
+
+// Payment
+
+type memoryPaymentRepo struct {
+	*memoryStore
+}
+
+func (r memoryPaymentRepo) list(includeDeleted bool, keep func(Payment) bool) []Payment {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return memoryList(r.payments, func(p Payment) bool {
+		return keep(p) && visible(p.DeletedAt, includeDeleted)
+	})
+}
+
+func (r memoryPaymentRepo) List(ctx context.Context, f PaymentFilter, q PageQuery) (Page[Payment], error) {
+	return memoryPage(paymentListFields, q, r.list(f.IncludeDeleted, func(p Payment) bool {
+		return r.inBuilding(p.RoomID, f.BuildingID) &&
+			(f.ClientID == 0 || p.ClientID == f.ClientID) &&
+			(f.RoomID == 0 || p.RoomID == f.RoomID) &&
+			inBounds(p.Amount, f.MinAmount, f.MaxAmount)
+	})), nil
+}
+
+func (r memoryPaymentRepo) ByID(ctx context.Context, id int64, includeDeleted bool) (Payment, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return memoryGet(r.payments, id, func(p Payment) bool {
+		return visible(p.DeletedAt, includeDeleted)
+	})
+}
+
+func (r memoryPaymentRepo) ByClientID(ctx context.Context, clientID int64, includeDeleted bool) ([]Payment, error) {
+	return r.list(includeDeleted, func(p Payment) bool { return p.ClientID == clientID }), nil
+}
+
+func (r memoryPaymentRepo) ByRoomID(ctx context.Context, roomID int64, includeDeleted bool) ([]Payment, error) {
+	return r.list(includeDeleted, func(p Payment) bool { return p.RoomID == roomID }), nil
+}
+
+func (r memoryPaymentRepo) ByDateRange(ctx context.Context, start, end time.Time, includeDeleted bool) ([]Payment, error) {
+	return r.list(includeDeleted, func(p Payment) bool { return inRange(p.Date, start, end) }), nil
+}
+
+func (r memoryPaymentRepo) ByExternalID(ctx context.Context, externalID string) (Payment, error) {
+	ps := r.list(true, func(p Payment) bool { return p.ExternalID != "" && p.ExternalID == externalID })
+	if len(ps) == 0 {
+		return Payment{}, ErrNotFound
+	}
+	return ps[0], nil
+}
+
+func (r memoryPaymentRepo) Create(ctx context.Context, p *Payment) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if p.ExternalID != "" {
+		for _, old := range r.payments {
+			if old.ExternalID == p.ExternalID {
+				return fmt.Errorf("%w: payment_external_id %q", ErrDuplicate, p.ExternalID)
+			}
+		}
+	}
+	if _, ok := r.clients[p.ClientID]; !ok {
+		return fmt.Errorf("%w: no client with client_id %d", ErrInvalidReference, p.ClientID)
+	}
+	if _, ok := r.rooms[p.RoomID]; !ok {
+		return fmt.Errorf("%w: no room with room_id %d", ErrInvalidReference, p.RoomID)
+	}
+
+	r.lastPaymentID++
+	p.ID = r.lastPaymentID
+	p.DeletedAt = nil
+	p.LastEdited = time.Now()
+	r.payments[p.ID] = *p
+	return nil
+}
+
+func (r memoryPaymentRepo) Update(ctx context.Context, id int64, ifMatch string, patch func(*Payment)) (Payment, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	p, err := memoryPatch(r.payments, id, ifMatch, Payment.active, patch)
+	if err != nil {
+		return Payment{}, err
+	}
+	if _, ok := r.clients[p.ClientID]; !ok {
+		return Payment{}, fmt.Errorf("%w: no client with client_id %d", ErrInvalidReference, p.ClientID)
+	}
+	room, ok := r.rooms[p.RoomID]
+	if !ok {
+		return Payment{}, fmt.Errorf("%w: no room with room_id %d", ErrInvalidReference, p.RoomID)
+	}
+	if c := r.buildings[room.BuildingID].Currency; p.RoomID != r.payments[id].RoomID && c != p.LedgerCurrency {
+		return Payment{}, fmt.Errorf("%w: room_id %d is in a building that keeps its ledger in %s, not %s", ErrCurrencyMismatch, p.RoomID, c, p.LedgerCurrency)
+	}
+
+	p.ID = id
+	p.LastEdited = time.Now()
+	r.payments[id] = p
+	return p, nil
+}
+
+func (r memoryPaymentRepo) Delete(ctx context.Context, id int64, ifMatch string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	p, ok := r.payments[id]
+	if !ok || p.DeletedAt != nil {
+		return ErrNotFound
+	}
+	if !etagMatch(ifMatch, p) {
+		return ErrPreconditionFailed
+	}
+
+	now := time.Now()
+	p.DeletedAt = &now
+	p.LastEdited = now
+	r.payments[id] = p
+	return nil
+}
+
+func (r memoryPaymentRepo) Restore(ctx context.Context, id int64) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	p, ok := r.payments[id]
+	if !ok || p.DeletedAt == nil {
+		return ErrNotFound
+	}
+
+	p.DeletedAt = nil
+	p.LastEdited = time.Now()
+	r.payments[id] = p
+	return nil
+}
+
+// Expense
+
+type memoryExpenseRepo struct {
+	*memoryStore
+}
+
+func (r memoryExpenseRepo) List(ctx context.Context, f ExpenseFilter, q PageQuery) (Page[Expense], error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return memoryPage(expenseListFields, q, memoryList(r.expenses, func(e Expense) bool {
+		return (f.BuildingID == 0 || e.BuildingID == f.BuildingID) &&
+			(f.Category == "" || e.Category == f.Category) &&
+			(f.Vendor == "" || e.Vendor == f.Vendor) &&
+			inBounds(e.Amount, f.MinAmount, f.MaxAmount) &&
+			visible(e.DeletedAt, f.IncludeDeleted)
+	})), nil
+}
+
+func (r memoryExpenseRepo) ByID(ctx context.Context, id int64, includeDeleted bool) (Expense, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return memoryGet(r.expenses, id, func(e Expense) bool {
+		return visible(e.DeletedAt, includeDeleted)
+	})
+}
+
+func (r memoryExpenseRepo) ByDateRange(ctx context.Context, start, end time.Time, includeDeleted bool) ([]Expense, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return memoryList(r.expenses, func(e Expense) bool {
+		return inRange(e.Date, start, end) && visible(e.DeletedAt, includeDeleted)
+	}), nil
+}
+
+func (r memoryExpenseRepo) CategoryTotals(ctx context.Context, buildingID int64, vendor string) ([]ExpenseCategoryTotal, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	type key struct {
+		buildingID int64
+		category   string
+	}
+
+	totals := map[key]*ExpenseCategoryTotal{}
+	for _, e := range r.expenses {
+		if e.DeletedAt != nil || (vendor != "" && e.Vendor != vendor) ||
+			(buildingID != 0 && e.BuildingID != buildingID) {
+			continue
+		}
+
+		k := key{e.BuildingID, e.Category}
+		t, ok := totals[k]
+		if !ok {
+			t = &ExpenseCategoryTotal{
+				BuildingID: e.BuildingID,
+				Category:   e.Category,
+				Currency:   r.buildings[e.BuildingID].Currency,
+			}
+			totals[k] = t
+		}
+		t.Count++
+		t.Amount += e.Amount
+	}
+
+	var ts []ExpenseCategoryTotal
+	for _, t := range totals {
+		ts = append(ts, *t)
+	}
+	slices.SortFunc(ts, func(a, b ExpenseCategoryTotal) int {
+		return cmp.Or(cmp.Compare(a.BuildingID, b.BuildingID), strings.Compare(a.Category, b.Category))
+	})
+	return ts, nil
+}
+
+func (r memoryExpenseRepo) Create(ctx context.Context, e *Expense) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if err := r.checkBuilding(e.BuildingID); err != nil {
+		return err
+	}
+
+	r.lastExpenseID++
+	e.ID = r.lastExpenseID
+	e.DeletedAt = nil
+	e.LastEdited = time.Now()
+	r.expenses[e.ID] = *e
+	return nil
+}
+
+func (r memoryExpenseRepo) Update(ctx context.Context, id int64, ifMatch string, patch func(*Expense)) (Expense, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	e, err := memoryPatch(r.expenses, id, ifMatch, Expense.active, patch)
+	if err != nil {
+		return Expense{}, err
+	}
+
+	before := r.expenses[id]
+	if _, shared := r.shares[id]; shared && (e.Amount != before.Amount || !e.Date.Equal(before.Date)) {
+		return Expense{}, fmt.Errorf("%w: expense_id %d is shared, stop sharing it to change its amount or date", ErrInUse, id)
+	}
+
+	e.ID = id
+	e.BuildingID = r.expenses[id].BuildingID
+	e.LastEdited = time.Now()
+	r.expenses[id] = e
+	return e, nil
+}
+
+func (r memoryExpenseRepo) Delete(ctx context.Context, id int64, ifMatch string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	e, ok := r.expenses[id]
+	if !ok || e.DeletedAt != nil {
+		return ErrNotFound
+	}
+	if !etagMatch(ifMatch, e) {
+		return ErrPreconditionFailed
+	}
+
+	now := time.Now()
+	e.DeletedAt = &now
+	e.LastEdited = now
+	r.expenses[id] = e
+	return nil
+}
+
+func (r memoryExpenseRepo) Restore(ctx context.Context, id int64) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	e, ok := r.expenses[id]
+	if !ok || e.DeletedAt == nil {
+		return ErrNotFound
+	}
+
+	e.DeletedAt = nil
+	e.LastEdited = time.Now()
+	r.expenses[id] = e
+	return nil
+}
+
+// Tariff
+
+type memoryTariffRepo struct {
+	*memoryStore
+}
+
+func (r memoryTariffRepo) All(ctx context.Context, buildingID int64) ([]Tariff, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return memoryList(r.tariffs, func(t Tariff) bool {
+		return buildingID == 0 || t.BuildingID == nil || *t.BuildingID == buildingID
+	}), nil
+}
+
+func (r memoryTariffRepo) ByID(ctx context.Context, id int64) (Tariff, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return memoryGet(r.tariffs, id, func(Tariff) bool { return true })
+}
+
+func (r memoryTariffRepo) Effective(ctx context.Context, period time.Time, buildingID int64) (Tariff, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	t, ok := r.effectiveTariff(period, buildingID)
+	if !ok {
+		return Tariff{}, ErrNotFound
+	}
+	return t, nil
+}
+
+// effectiveTariff picks the tariff in effect for the building buildingID in
+// period the way tariff_get_effective.sql does: a tariff of the building
+// first, then the latest effective_from, then the largest id.
+func (s *memoryStore) effectiveTariff(period time.Time, buildingID int64) (t Tariff, ok bool) {
+	newer := func(a, b Tariff) bool {
+		if (a.BuildingID == nil) != (b.BuildingID == nil) {
+			return a.BuildingID != nil
+		}
+		return cmp.Or(a.EffectiveFrom.Compare(b.EffectiveFrom), cmp.Compare(a.ID, b.ID)) > 0
+	}
+
+	for _, v := range s.tariffs {
+		if v.EffectiveFrom.After(period) || (v.BuildingID != nil && *v.BuildingID != buildingID) {
+			continue
+		}
+		if !ok || newer(v, t) {
+			t, ok = v, true
+		}
+	}
+	return t, ok
+}
+
+func (r memoryTariffRepo) Create(ctx context.Context, t *Tariff) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if t.BuildingID != nil {
+		if err := r.checkBuilding(*t.BuildingID); err != nil {
+			return err
+		}
+	}
+
+	r.lastTariffID++
+	t.ID = r.lastTariffID
+	t.LastEdited = time.Now()
+	r.tariffs[t.ID] = *t
+	return nil
+}
+
+// Charge
+
+type memoryChargeRepo struct {
+	*memoryStore
+}
+
+func (r memoryChargeRepo) All(ctx context.Context, buildingID int64) ([]Charge, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return memoryList(r.charges, func(c Charge) bool { return r.inBuilding(c.RoomID, buildingID) }), nil
+}
+
+func (r memoryChargeRepo) ByID(ctx context.Context, id int64) (Charge, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return memoryGet(r.charges, id, func(Charge) bool { return true })
+}
+
+func (r memoryChargeRepo) ByRoomID(ctx context.Context, roomID int64) ([]Charge, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return memoryList(r.charges, func(c Charge) bool { return c.RoomID == roomID }), nil
+}
+
+func (r memoryChargeRepo) ByPeriod(ctx context.Context, period time.Time, buildingID int64) ([]Charge, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return memoryList(r.charges, func(c Charge) bool {
+		return c.Period.Equal(period) && r.inBuilding(c.RoomID, buildingID)
+	}), nil
+}
+
+// Generate charges each room by the rules of charge_generate.sql.
+func (r memoryChargeRepo) Generate(ctx context.Context, period time.Time, tariffID, buildingID int64) (int64, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	charged := map[int64]bool{}
+	for _, c := range r.charges {
+		if c.Period.Equal(period) {
+			charged[c.RoomID] = true
+		}
+	}
+
+	var created int64
+	for _, room := range memoryList(r.rooms, func(room Room) bool {
+		return room.DeletedAt == nil && r.inBuilding(room.ID, buildingID) && !charged[room.ID]
+	}) {
+		as := memoryList(r.attributes, func(a RoomAttributes) bool { return a.RoomID == room.ID })
+		slices.SortFunc(as, func(a, b RoomAttributes) int { return a.From.Compare(b.From) })
+
+		a, ok := attributesOn(as, period)
+		if !ok {
+			continue
+		}
+
+		var t Tariff
+		if tariffID != 0 {
+			t, ok = r.tariffs[tariffID]
+		} else {
+			t, ok = r.effectiveTariff(period, room.BuildingID)
+		}
+		if !ok {
+			continue
+		}
+
+		c := Charge{
+			RoomID:       room.ID,
+			TariffID:     t.ID,
+			Period:       period,
+			AreaAmount:   Money(mulDiv(int64(a.Area), int64(t.AreaRate), 1e4)),
+			PeopleAmount: Money(mulDiv(int64(a.PeopleCount), int64(t.PeopleRate), 100)),
+			FixedAmount:  t.FixedFee,
+			LastEdited:   time.Now(),
+		}
+		c.Amount = c.AreaAmount + c.PeopleAmount + c.FixedAmount
+
+		r.lastChargeID++
+		c.ID = r.lastChargeID
+		r.charges[c.ID] = c
+		created++
+	}
+
+	return created, nil
+}
+
+func (r memoryChargeRepo) Delete(ctx context.Context, id int64) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.charges[id]; !ok {
+		return ErrNotFound
+	}
+
+	delete(r.charges, id)
+	return nil
+}
+
+// Meter
+
+type memoryMeterRepo struct {
+	*memoryStore
+}
+
+func (r memoryMeterRepo) All(ctx context.Context, buildingID int64) ([]Meter, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return memoryList(r.meters, func(m Meter) bool { return r.inBuilding(m.RoomID, buildingID) }), nil
+}
+
+func (r memoryMeterRepo) ByID(ctx context.Context, id int64) (Meter, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return memoryGet(r.meters, id, func(Meter) bool { return true })
+}
+
+func (r memoryMeterRepo) ByRoomID(ctx context.Context, roomID int64) ([]Meter, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return memoryList(r.meters, func(m Meter) bool { return m.RoomID == roomID }), nil
+}
+
+func (r memoryMeterRepo) Create(ctx context.Context, m *Meter) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.rooms[m.RoomID]; !ok {
+		return fmt.Errorf("%w: no room with room_id %d", ErrInvalidReference, m.RoomID)
+	}
+	for _, other := range r.meters {
+		if other.Kind == m.Kind && other.Serial == m.Serial {
+			return fmt.Errorf("%w: %s meter_serial %q", ErrDuplicate, m.Kind, m.Serial)
+		}
+	}
+
+	r.lastMeterID++
+	m.ID = r.lastMeterID
+	m.LastEdited = time.Now()
+	r.meters[m.ID] = *m
+	return nil
+}
+
+func (r memoryMeterRepo) Delete(ctx context.Context, id int64) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.meters[id]; !ok {
+		return ErrNotFound
+	}
+
+	for reading_id, reading := range r.readings {
+		if reading.MeterID == id {
+			delete(r.readings, reading_id)
+		}
+	}
+
+	delete(r.meters, id)
+	return nil
+}
+
+func (r memoryMeterRepo) Readings(ctx context.Context, meterID int64) ([]MeterReading, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return r.history(meterID), nil
+}
+
+// history returns the readings of the meter meterID by date.
+func (r memoryMeterRepo) history(meterID int64) []MeterReading {
+	rs := memoryList(r.readings, func(reading MeterReading) bool { return reading.MeterID == meterID })
+	slices.SortStableFunc(rs, func(a, b MeterReading) int { return a.Date.Compare(b.Date) })
+	return rs
+}
+
+func (r memoryMeterRepo) Reading(ctx context.Context, id int64) (MeterReading, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return memoryGet(r.readings, id, func(MeterReading) bool { return true })
+}
+
+func (r memoryMeterRepo) AddReading(ctx context.Context, reading *MeterReading, check func(*MeterReading, []MeterReading) error) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.meters[reading.MeterID]; !ok {
+		return ErrNotFound
+	}
+	if err := check(reading, r.history(reading.MeterID)); err != nil {
+		return err
+	}
+
+	r.lastReadingID++
+	reading.ID = r.lastReadingID
+	reading.LastEdited = time.Now()
+	r.readings[reading.ID] = *reading
+	return nil
+}
+
+func (r memoryMeterRepo) DeleteReading(ctx context.Context, id int64) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.readings[id]; !ok {
+		return ErrNotFound
+	}
+
+	delete(r.readings, id)
+	return nil
+}
+
+// Exchange rate
+
+type memoryRateRepo struct {
+	*memoryStore
+}
+
+func (r memoryRateRepo) All(ctx context.Context, currency string) ([]Rate, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	rs := memoryList(r.rates, func(rate Rate) bool { return currency == "" || rate.Currency == currency })
+	slices.SortStableFunc(rs, func(a, b Rate) int {
+		return cmp.Or(strings.Compare(a.Currency, b.Currency), a.Date.Compare(b.Date))
+	})
+	return rs, nil
+}
+
+func (r memoryRateRepo) ByID(ctx context.Context, id int64) (Rate, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return memoryGet(r.rates, id, func(Rate) bool { return true })
+}
+
+func (r memoryRateRepo) Effective(ctx context.Context, currency string, day time.Time) (rate Rate, err error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	err = ErrNotFound
+	for _, v := range r.rates {
+		if v.Currency == currency && !v.Date.After(day) && (err != nil || v.Date.After(rate.Date)) {
+			rate, err = v, nil
+		}
+	}
+	return rate, err
+}
+
+func (r memoryRateRepo) Create(ctx context.Context, rate *Rate) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, other := range r.rates {
+		if other.Currency == rate.Currency && other.Date.Equal(rate.Date) {
+			return fmt.Errorf("%w: %s rate_date %s", ErrDuplicate, rate.Currency, rate.Date.Format(DAY_FORMAT))
+		}
+	}
+
+	r.lastRateID++
+	rate.ID = r.lastRateID
+	rate.LastEdited = time.Now()
+	r.rates[rate.ID] = *rate
+	return nil
+}
+
+func (r memoryRateRepo) Delete(ctx context.Context, id int64) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.rates[id]; !ok {
+		return ErrNotFound
+	}
+
+	delete(r.rates, id)
+	return nil
+}
+
+// API token
+
+type memoryTokenRepo struct {
+	*memoryStore
+}
+
+func (r memoryTokenRepo) ByID(ctx context.Context, id int64) (APIToken, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return memoryGet(r.tokens, id, func(APIToken) bool { return true })
+}
+
+func (r memoryTokenRepo) ByClientID(ctx context.Context, clientID int64) ([]APIToken, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return memoryList(r.tokens, func(t APIToken) bool { return t.ClientID == clientID }), nil
+}
+
+func (r memoryTokenRepo) Create(ctx context.Context, t *APIToken) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.clients[t.ClientID]; !ok {
+		return fmt.Errorf("%w: no client with client_id %d", ErrInvalidReference, t.ClientID)
+	}
+
+	hash := hashToken(t.Token)
+	for _, other := range r.tokenHashes {
+		if other == hash {
+			return fmt.Errorf("%w: token", ErrDuplicate)
+		}
+	}
+
+	r.lastTokenID++
+	t.ID = r.lastTokenID
+	t.LastEdited = time.Now()
+
+	stored := *t
+	stored.Token = ""
+	r.tokens[t.ID] = stored
+	r.tokenHashes[t.ID] = hash
+	return nil
+}
+
+func (r memoryTokenRepo) Delete(ctx context.Context, id int64) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.tokens[id]; !ok {
+		return ErrNotFound
+	}
+
+	delete(r.tokens, id)
+	delete(r.tokenHashes, id)
+	return nil
+}
+
+// Bot state
+
+type memoryBotStateRepo struct {
+	*memoryStore
+}
+
+func (r memoryBotStateRepo) Get(ctx context.Context, key string) (BotState, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	state, ok := r.botStates[key]
+	if !ok || (state.Expires != nil && !state.Expires.After(time.Now())) {
+		return BotState{}, ErrNotFound
+	}
+	return state, nil
+}
+
+func (r memoryBotStateRepo) Set(ctx context.Context, key, value string, ttl int64) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	now := time.Now()
+	for k, state := range r.botStates {
+		if state.Expires != nil && !state.Expires.After(now) {
+			delete(r.botStates, k)
+		}
+	}
+
+	state := BotState{Key: key, Value: value, LastEdited: now}
+	if ttl > 0 {
+		expires := now.Add(time.Duration(ttl) * time.Second)
+		state.Expires = &expires
+	}
+
+	r.botStates[key] = state
+	return nil
+}
+
+func (r memoryBotStateRepo) Delete(ctx context.Context, key string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	delete(r.botStates, key)
+	return nil
+}
+
+// Audit log
+
+// memoryAuditRepo lists the audit log the in-memory repositories don't
+// write, so it is always empty.
+type memoryAuditRepo struct{}
+
+func (memoryAuditRepo) List(ctx context.Context, f AuditFilter) ([]AuditRecord, error) {
+	return nil, nil
+}
+
+// Expense share
+
+type memoryShareRepo struct {
+	*memoryStore
+}
+
+func (r memoryShareRepo) ByExpenseID(ctx context.Context, expenseID int64) (ExpenseShare, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	s, ok := r.shares[expenseID]
+	if !ok {
+		return ExpenseShare{}, ErrNotFound
+	}
+
+	s.Allocations = append([]ExpenseAllocation{}, memoryList(r.allocations, func(a ExpenseAllocation) bool {
+		return a.ExpenseID == expenseID
+	})...)
+	return s, nil
+}
+
+func (r memoryShareRepo) AllocationsByRoomID(ctx context.Context, roomID int64) ([]ExpenseAllocation, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return memoryList(r.allocations, func(a ExpenseAllocation) bool {
+		return a.RoomID == roomID && r.expenses[a.ExpenseID].DeletedAt == nil
+	}), nil
+}
+
+func (r memoryShareRepo) Set(ctx context.Context, s *ExpenseShare, ifMatch string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	e, ok := r.expenses[s.ExpenseID]
+	if !ok || !e.active() {
+		return ErrNotFound
+	}
+	if !etagMatch(ifMatch, e) {
+		return ErrPreconditionFailed
+	}
+	for _, a := range s.Allocations {
+		if _, ok := r.rooms[a.RoomID]; !ok {
+			return fmt.Errorf("%w: no room with room_id %d", ErrInvalidReference, a.RoomID)
+		}
+	}
+
+	r.deleteAllocations(s.ExpenseID)
+
+	now := time.Now()
+	for i := range s.Allocations {
+		r.lastAllocationID++
+		s.Allocations[i].ID = r.lastAllocationID
+		s.Allocations[i].ExpenseID = s.ExpenseID
+		s.Allocations[i].LastEdited = now
+		r.allocations[r.lastAllocationID] = s.Allocations[i]
+	}
+
+	stored := *s
+	stored.Allocations = nil
+	stored.LastEdited = now
+	r.shares[s.ExpenseID] = stored
+	s.LastEdited = now
+	return nil
+}
+
+func (r memoryShareRepo) deleteAllocations(expenseID int64) {
+	for id, a := range r.allocations {
+		if a.ExpenseID == expenseID {
+			delete(r.allocations, id)
+		}
+	}
+}
+
+func (r memoryShareRepo) Delete(ctx context.Context, expenseID int64) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.shares[expenseID]; !ok {
+		return ErrNotFound
+	}
+
+	r.deleteAllocations(expenseID)
+	delete(r.shares, expenseID)
+	return nil
+}
+
+// Balance
+
+type memoryBalanceRepo struct {
+	*memoryStore
+}
+
+func (r memoryBalanceRepo) RoomMonths(ctx context.Context, roomID int64) ([]balanceRow, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var bs []balanceRow
+	for _, c := range r.charges {
+		if c.RoomID == roomID {
+			bs = append(bs, balanceRow{Period: c.Period, Charges: c.Amount})
+		}
+	}
+	for _, p := range r.payments {
+		if p.RoomID == roomID && p.DeletedAt == nil {
+			bs = append(bs, balanceRow{Period: monthOf(p.Date), Payments: p.Amount})
+		}
+	}
+	for _, a := range r.allocations {
+		if e := r.expenses[a.ExpenseID]; a.RoomID == roomID && e.DeletedAt == nil {
+			bs = append(bs, balanceRow{Period: monthOf(e.Date), Expenses: a.Amount})
+		}
+	}
+
+	return sumBalanceRows(bs), nil
+}
+
+// Access
+
+type memoryAccessRepo struct {
+	*memoryStore
+}
+
+func (r memoryAccessRepo) Principal(ctx context.Context, tokenHash string) (Principal, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for id, hash := range r.tokenHashes {
+		if hash != tokenHash {
+			continue
+		}
+
+		c, ok := r.clients[r.tokens[id].ClientID]
+		if !ok || c.DeletedAt != nil {
+			break
+		}
+
+		p := Principal{Role: RoleResident, ClientID: c.ID}
+		if c.IsAdmin {
+			p.Role = RoleAdmin
+		}
+		return p, nil
+	}
+
+	return Principal{}, ErrNotFound
+}
+
+func (r memoryAccessRepo) OwnsRoom(ctx context.Context, clientID, roomID int64, day time.Time) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	room, ok := r.rooms[roomID]
+	return ok && room.DeletedAt == nil && r.owns(clientID, room, day), nil
+}
+
+func (r memoryAccessRepo) OwnsPayment(ctx context.Context, clientID, paymentID int64, day time.Time) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	p, ok := r.payments[paymentID]
+	if !ok || p.DeletedAt != nil {
+		return false, nil
+	}
+	return p.ClientID == clientID || r.owns(clientID, r.rooms[p.RoomID], day), nil
+}
+
+// owns reports whether room is registered to the client clientID or has it
+// as a member on day.
+func (r memoryAccessRepo) owns(clientID int64, room Room, day time.Time) bool {
+	if room.ClientID == clientID {
+		return true
+	}
+	for _, m := range r.members {
+		if m.RoomID == room.ID && m.ClientID == clientID && m.activeAt(day) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,2060 @@
+package main
+
+import (
+	"cmp"
+	"context"
+	"database/sql"
+	_ "embed"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// NewSQLServer returns a Server backed by db, a MySQL or SQLite database.
+func NewSQLServer(db *sql.DB) *Server {
+	return &Server{
+		Buildings: sqlBuildingRepo{db},
+		Clients:   sqlClientRepo{db},
+		Rooms:     sqlRoomRepo{db},
+		Members:   sqlMemberRepo{db},
+		Payments:  sqlPaymentRepo{db},
+		Expenses:  sqlExpenseRepo{db},
+		Tariffs:   sqlTariffRepo{db},
+		Charges:   sqlChargeRepo{db},
+		Meters:    sqlMeterRepo{db},
+		Rates:     sqlRateRepo{db},
+		Tokens:    sqlTokenRepo{db},
+		BotStates: sqlBotStateRepo{db},
+		Audit:     sqlAuditRepo{db},
+		Shares:    sqlShareRepo{db},
+		Balances:  sqlBalanceRepo{db},
+		Access:    sqlAccessRepo{db},
+	}
+}
+
+func sqlGet[T any](ctx context.Context, db *sql.DB, scan func(*T, *sql.Row) error, query string, a ...any) (v T, err error) {
+	if err = scan(&v, db.QueryRowContext(ctx, query, a...)); errors.Is(err, sql.ErrNoRows) {
+		err = ErrNotFound
+	}
+	return v, err
+}
+
+func sqlList[T any](ctx context.Context, db *sql.DB, scan func(*[]T, *sql.Rows) error, query string, a ...any) ([]T, error) {
+	rows, err := db.QueryContext(ctx, query, a...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var vs []T
+	if err := scan(&vs, rows); err != nil {
+		return nil, err
+	}
+	return vs, rows.Err()
+}
+
+// sqlPage returns the page q of the rows selected by query. The page is
+// sorted and cut by wrapping query, so query must not sort or limit.
+func sqlPage[T any](ctx context.Context, db *sql.DB, scan func(*[]T, *sql.Rows) error, f listFields[T], q PageQuery, query string, a ...any) (Page[T], error) {
+	var total int64
+	err := db.QueryRowContext(ctx, "select count(*) from (\n"+query+"\n) as page", a...).Scan(&total)
+	if err != nil {
+		return Page[T]{}, err
+	}
+
+	var (
+		column = f.sorts[q.Sort].column
+		order  = "asc"
+		cmp    = ">"
+		where  string
+		args   = append([]any{}, a...)
+	)
+	if q.Desc {
+		order, cmp = "desc", "<"
+	}
+
+	if q.After != nil {
+		after, err := f.afterValue(q)
+		if err != nil {
+			return Page[T]{}, err
+		}
+
+		where = fmt.Sprintf("where (%[1]s %[3]s ? or (%[1]s = ? and %[2]s %[3]s ?))", column, f.id, cmp)
+		args = append(args, after, after, q.After.ID)
+	}
+
+	rows, err := sqlList(
+		ctx, db, scan,
+		fmt.Sprintf(
+			"select * from (\n%s\n) as page %s order by %s %s, %s %s limit ?",
+			query, where, column, order, f.id, order,
+		),
+		append(args, q.Limit+1)...,
+	)
+	if err != nil {
+		return Page[T]{}, err
+	}
+	return f.page(q, rows, total), nil
+}
+
+// sqlWriteError reports the key violations of an insert or update as
+// ErrDuplicate, ErrInvalidReference and ErrInUse.
+func sqlWriteError(err error) error {
+	switch {
+	case isDuplicateKey(err):
+		return fmt.Errorf("%w: %v", ErrDuplicate, err)
+	case isRowIsReferenced(err):
+		return fmt.Errorf("%w: %v", ErrInUse, err)
+	case isNoReferencedRow(err):
+		return fmt.Errorf("%w: %v", ErrInvalidReference, err)
+	}
+	return err
+}
+
+// sqlAffectedError reports a statement that changed nothing as ErrNotFound.
+func sqlAffectedError(res sql.Result, err error) error {
+	if err != nil {
+		return err
+	}
+	if n, err := res.RowsAffected(); err != nil || n == 0 {
+		return errors.Join(ErrNotFound, err)
+	}
+	return nil
+}
+
+// sqlUpdate is the Update of the entity e: in one transaction it locks the
+// row id, checks that it is active and matches ifMatch, applies patch and
+// writes the result with write. It returns the row as written.
+func sqlUpdate[T any](
+	ctx context.Context,
+	db *sql.DB,
+	e auditEntity[T],
+	id int64,
+	ifMatch string,
+	active func(T) bool,
+	patch func(*T),
+	write func(*sql.Tx, T) error,
+) (v T, err error) {
+	_, err = auditTx(ctx, db, e, AuditUpdate, id, func(tx *sql.Tx, before *T) (bool, error) {
+		if before == nil || !active(*before) {
+			return false, ErrNotFound
+		}
+		if !etagMatch(ifMatch, *before) {
+			return false, ErrPreconditionFailed
+		}
+
+		v = *before
+		patch(&v)
+		if err := write(tx, v); err != nil {
+			return false, sqlWriteError(err)
+		}
+
+		after, err := e.get(tx, id, false)
+		if err != nil {
+			return false, err
+		}
+		v = *after
+		return true, nil
+	})
+	return v, err
+}
+
+// sqlDelete is the Delete of the entity e with a single statement: query
+// soft deletes the active row id if it matches ifMatch.
+func sqlDelete[T any](ctx context.Context, db *sql.DB, e auditEntity[T], id int64, ifMatch string, active func(T) bool, query string) error {
+	_, err := auditTx(ctx, db, e, AuditDelete, id, func(tx *sql.Tx, before *T) (bool, error) {
+		if before == nil || !active(*before) {
+			return false, ErrNotFound
+		}
+		if !etagMatch(ifMatch, *before) {
+			return false, ErrPreconditionFailed
+		}
+
+		_, err := tx.Exec(query, id)
+		return err == nil, err
+	})
+	return err
+}
+
+// Building
+
+func buildingScanRow(b *Building, row *sql.Row) error {
+	var currency sql.NullString
+
+	if err := row.Scan(&b.ID, &b.Name, &b.Address, &b.Contact, &currency, &b.LastEdited); err != nil {
+		return err
+	}
+
+	b.Currency = cmp.Or(currency.String, baseCurrency())
+	return nil
+}
+
+func buildingScanRows(bs *[]Building, rows *sql.Rows) error {
+	if bs == nil {
+		return errors.New("*[]Building is nil")
+	}
+
+	_bs := *bs
+	for rows.Next() {
+		var (
+			b        Building
+			currency sql.NullString
+		)
+
+		if err := rows.Scan(&b.ID, &b.Name, &b.Address, &b.Contact, &currency, &b.LastEdited); err != nil {
+			return err
+		}
+
+		b.Currency = cmp.Or(currency.String, baseCurrency())
+
+		_bs = append(_bs, b)
+	}
+
+	*bs = _bs
+	return nil
+}
+
+var buildingAudit = auditEntity[Building]{name: "building", scan: buildingScanRow, query: SQLBuildingGetByIDQuery}
+
+//go:embed sql/building/building_get_all.sql
+var SQLBuildingGetAllQuery string
+
+//go:embed sql/building/building_get_by_id.sql
+var SQLBuildingGetByIDQuery string
+
+//go:embed sql/building/building_is_used.sql
+var SQLBuildingIsUsedQuery string
+
+//go:embed sql/building/building_insert.sql
+var SQLBuildingPostCreateQuery string
+
+//go:embed sql/building/building_patch.sql
+var SQLBuildingPatchQuery string
+
+//go:embed sql/building/building_delete.sql
+var SQLBuildingDeleteQuery string
+
+type sqlBuildingRepo struct {
+	db *sql.DB
+}
+
+func (r sqlBuildingRepo) All(ctx context.Context) ([]Building, error) {
+	return sqlList(ctx, r.db, buildingScanRows, SQLBuildingGetAllQuery)
+}
+
+func (r sqlBuildingRepo) ByID(ctx context.Context, id int64) (Building, error) {
+	return sqlGet(ctx, r.db, buildingScanRow, SQLBuildingGetByIDQuery, id)
+}
+
+func (r sqlBuildingRepo) Create(ctx context.Context, b *Building) error {
+	res, err := auditExec(
+		ctx, r.db, buildingAudit, AuditCreate, nil,
+		SQLBuildingPostCreateQuery,
+		b.Name, b.Address, b.Contact, b.Currency,
+	)
+	if err != nil {
+		return sqlWriteError(err)
+	}
+
+	id, err := res.LastInsertId()
+	if err != nil {
+		return err
+	}
+
+	*b, err = r.ByID(ctx, id)
+	return err
+}
+
+func (r sqlBuildingRepo) Update(ctx context.Context, id int64, ifMatch string, patch func(*Building)) (Building, error) {
+	active := func(Building) bool { return true }
+
+	return sqlUpdate(ctx, r.db, buildingAudit, id, ifMatch, active, patch, func(tx *sql.Tx, b Building) error {
+		_, err := tx.Exec(SQLBuildingPatchQuery, b.Name, b.Address, b.Contact, id)
+		return err
+	})
+}
+
+func (r sqlBuildingRepo) Delete(ctx context.Context, id int64, ifMatch string) error {
+	_, err := auditTx(ctx, r.db, buildingAudit, AuditDelete, id, func(tx *sql.Tx, before *Building) (bool, error) {
+		if before == nil {
+			return false, ErrNotFound
+		}
+		if !etagMatch(ifMatch, *before) {
+			return false, ErrPreconditionFailed
+		}
+
+		var used bool
+		if err := tx.QueryRow(SQLBuildingIsUsedQuery, id, id, id).Scan(&used); err != nil {
+			return false, err
+		}
+		if used {
+			return false, fmt.Errorf("%w: building_id %d has rooms, expenses or tariffs", ErrInUse, id)
+		}
+
+		_, err := tx.Exec(SQLBuildingDeleteQuery, id)
+		return err == nil, err
+	})
+	return err
+}
+
+// Client
+
+func clientScanRow(c *Client, row *sql.Row) error {
+	var deleted_at sql.NullTime
+
+	if err := row.Scan(&c.ID, &c.Name, &c.IsAdmin, &c.LastEdited, &deleted_at); err != nil {
+		return err
+	}
+
+	c.DeletedAt = nullTime(deleted_at)
+	return nil
+}
+
+func clientScanRows(cs *[]Client, rows *sql.Rows) error {
+	if cs == nil {
+		return errors.New("*[]Client is nil")
+	}
+	_cs := *cs
+	for rows.Next() {
+		var (
+			c          Client
+			deleted_at sql.NullTime
+		)
+
+		if err := rows.Scan(&c.ID, &c.Name, &c.IsAdmin, &c.LastEdited, &deleted_at); err != nil {
+			return err
+		}
+
+		c.DeletedAt = nullTime(deleted_at)
+
+		_cs = append(_cs, c)
+	}
+	*cs = _cs
+	return nil
+}
+
+var clientAudit = auditEntity[Client]{name: "client", scan: clientScanRow, query: SQLClientGetByIDQuery, softDelete: true}
+
+//go:embed sql/client/client_get_filtered.sql
+var SQLClientGetFilteredQuery string
+
+//go:embed sql/client/client_get_admins.sql
+var SQLClientGetAdminsQuery string
+
+//go:embed sql/client/client_get_by_id.sql
+var SQLClientGetByIDQuery string
+
+//go:embed sql/client/client_get_by_name.sql
+var SQLClientGetByNameQuery string
+
+//go:embed sql/client/client_insert.sql
+var SQLClientPostCreateQuery string
+
+//go:embed sql/client/client_patch.sql
+var SQLClientPatchQuery string
+
+//go:embed sql/client/client_delete.sql
+var SQLClientDeleteQuery string
+
+//go:embed sql/client/client_restore.sql
+var SQLClientRestoreQuery string
+
+type sqlClientRepo struct {
+	db *sql.DB
+}
+
+func (r sqlClientRepo) List(ctx context.Context, f ClientFilter, q PageQuery) (Page[Client], error) {
+	return sqlPage(
+		ctx, r.db, clientScanRows, clientListFields, q,
+		SQLClientGetFilteredQuery,
+		f.Name, f.Name, f.IsAdmin, f.IsAdmin, f.BuildingID, f.BuildingID, f.IncludeDeleted,
+	)
+}
+
+func (r sqlClientRepo) Admins(ctx context.Context, includeDeleted bool) ([]Client, error) {
+	return sqlList(ctx, r.db, clientScanRows, SQLClientGetAdminsQuery, includeDeleted)
+}
+
+func (r sqlClientRepo) ByName(ctx context.Context, name string, includeDeleted bool) ([]Client, error) {
+	return sqlList(ctx, r.db, clientScanRows, SQLClientGetByNameQuery, name, includeDeleted)
+}
+
+func (r sqlClientRepo) ByID(ctx context.Context, id int64, includeDeleted bool) (Client, error) {
+	return sqlGet(ctx, r.db, clientScanRow, SQLClientGetByIDQuery, id, includeDeleted)
+}
+
+func (r sqlClientRepo) Create(ctx context.Context, c *Client) error {
+	_, err := auditExec(ctx, r.db, clientAudit, AuditCreate, c.ID, SQLClientPostCreateQuery, c.ID, c.Name, c.IsAdmin)
+	if err != nil {
+		return sqlWriteError(err)
+	}
+
+	*c, err = r.ByID(ctx, c.ID, true)
+	return err
+}
+
+func (r sqlClientRepo) Update(ctx context.Context, id int64, ifMatch string, patch func(*Client)) (Client, error) {
+	return sqlUpdate(ctx, r.db, clientAudit, id, ifMatch, Client.active, patch, func(tx *sql.Tx, c Client) error {
+		_, err := tx.Exec(SQLClientPatchQuery, c.Name, c.IsAdmin, id)
+		return err
+	})
+}
+
+func (r sqlClientRepo) Delete(ctx context.Context, id int64, ifMatch string) error {
+	deleted_at := time.Now().UTC().Truncate(time.Second)
+
+	_, err := auditTx(ctx, r.db, clientAudit, AuditDelete, id, func(tx *sql.Tx, before *Client) (bool, error) {
+		if before == nil || before.DeletedAt != nil {
+			return false, ErrNotFound
+		}
+		if !etagMatch(ifMatch, *before) {
+			return false, ErrPreconditionFailed
+		}
+
+		// Rooms get the same deleted_at as the client, so restoring the
+		// client brings back exactly the rooms deleted with it.
+		if _, err := tx.Exec(SQLRoomDeleteByClientIDQuery, deleted_at, id); err != nil {
+			return false, err
+		}
+
+		_, err := tx.Exec(SQLClientDeleteQuery, deleted_at, id)
+		return err == nil, err
+	})
+	return err
+}
+
+func (r sqlClientRepo) Restore(ctx context.Context, id int64) error {
+	restored, err := auditTx(ctx, r.db, clientAudit, AuditRestore, id, func(tx *sql.Tx, before *Client) (bool, error) {
+		if before == nil || before.DeletedAt == nil {
+			return false, nil
+		}
+
+		if _, err := tx.Exec(SQLRoomRestoreByClientIDQuery, id, *before.DeletedAt); err != nil {
+			return false, err
+		}
+
+		_, err := tx.Exec(SQLClientRestoreQuery, id)
+		return err == nil, err
+	})
+	if err == nil && !restored {
+		err = ErrNotFound
+	}
+	return err
+}
+
+// Room
+
+func roomScanRow(r *Room, row *sql.Row) error {
+	var deleted_at sql.NullTime
+
+	if err := row.Scan(
+		&r.ID, &r.ClientID, &r.PeopleCount, &r.Area, &r.LastEdited, &deleted_at,
+		&r.BuildingID, &r.Number, &r.AttributesFrom,
+	); err != nil {
+		return err
+	}
+
+	r.DeletedAt = nullTime(deleted_at)
+	return nil
+}
+
+func roomScanRows(rs *[]Room, rows *sql.Rows) error {
+	if rs == nil {
+		return errors.New("*[]Room is nil")
+	}
+
+	_rs := *rs
+	for rows.Next() {
+		var (
+			r          Room
+			deleted_at sql.NullTime
+		)
+
+		if err := rows.Scan(
+			&r.ID, &r.ClientID, &r.PeopleCount, &r.Area, &r.LastEdited, &deleted_at,
+			&r.BuildingID, &r.Number, &r.AttributesFrom,
+		); err != nil {
+			return err
+		}
+
+		r.DeletedAt = nullTime(deleted_at)
+
+		_rs = append(_rs, r)
+	}
+
+	*rs = _rs
+	return nil
+}
+
+func roomAttributesScanRows(as *[]RoomAttributes, rows *sql.Rows) error {
+	if as == nil {
+		return errors.New("*[]RoomAttributes is nil")
+	}
+
+	_as := *as
+	for rows.Next() {
+		var a RoomAttributes
+
+		if err := rows.Scan(&a.ID, &a.RoomID, &a.From, &a.PeopleCount, &a.Area, &a.LastEdited); err != nil {
+			return err
+		}
+
+		_as = append(_as, a)
+	}
+
+	*as = _as
+	return nil
+}
+
+var roomAudit = auditEntity[Room]{name: "room", scan: roomScanRow, query: SQLRoomGetByIDQuery, softDelete: true}
+
+//go:embed sql/room/room_get_filtered.sql
+var SQLRoomGetFilteredQuery string
+
+//go:embed sql/room/room_get_by_building_id.sql
+var SQLRoomGetByBuildingIDQuery string
+
+//go:embed sql/room/room_get_by_id.sql
+var SQLRoomGetByIDQuery string
+
+//go:embed sql/room/room_currency_for_update.sql
+var SQLRoomCurrencyForUpdateQuery string
+
+//go:embed sql/room/room_get_by_client_id.sql
+var SQLRoomGetByClientIDQuery string
+
+//go:embed sql/room/room_insert.sql
+var SQLRoomPostCreateQuery string
+
+//go:embed sql/room/room_patch.sql
+var SQLRoomPatchQuery string
+
+//go:embed sql/room/room_delete.sql
+var SQLRoomDeleteQuery string
+
+//go:embed sql/room/room_delete_by_client_id.sql
+var SQLRoomDeleteByClientIDQuery string
+
+//go:embed sql/room/room_restore.sql
+var SQLRoomRestoreQuery string
+
+//go:embed sql/room/room_restore_by_client_id.sql
+var SQLRoomRestoreByClientIDQuery string
+
+//go:embed sql/room/room_attribute_get_by_room_id.sql
+var SQLRoomAttributeGetByRoomIDQuery string
+
+//go:embed sql/room/room_attribute_upsert.sql
+var SQLRoomAttributeUpsertQuery string
+
+type sqlRoomRepo struct {
+	db *sql.DB
+}
+
+func (r sqlRoomRepo) List(ctx context.Context, f RoomFilter, q PageQuery) (Page[Room], error) {
+	return sqlPage(
+		ctx, r.db, roomScanRows, roomListFields, q,
+		SQLRoomGetFilteredQuery,
+		f.BuildingID, f.BuildingID, f.ClientID, f.ClientID,
+		f.MinArea, f.MinArea, f.MaxArea, f.MaxArea, f.IncludeDeleted,
+	)
+}
+
+func (r sqlRoomRepo) ByBuildingID(ctx context.Context, buildingID int64, includeDeleted bool) ([]Room, error) {
+	return sqlList(ctx, r.db, roomScanRows, SQLRoomGetByBuildingIDQuery, buildingID, includeDeleted)
+}
+
+func (r sqlRoomRepo) ByID(ctx context.Context, id int64, includeDeleted bool) (Room, error) {
+	return sqlGet(ctx, r.db, roomScanRow, SQLRoomGetByIDQuery, id, includeDeleted)
+}
+
+func (r sqlRoomRepo) ByClientID(ctx context.Context, clientID int64, includeDeleted bool) ([]Room, error) {
+	return sqlList(ctx, r.db, roomScanRows, SQLRoomGetByClientIDQuery, clientID, includeDeleted)
+}
+
+func (r sqlRoomRepo) Attributes(ctx context.Context, roomID int64) ([]RoomAttributes, error) {
+	return sqlList(ctx, r.db, roomAttributesScanRows, SQLRoomAttributeGetByRoomIDQuery, roomID)
+}
+
+func (r sqlRoomRepo) Create(ctx context.Context, room *Room) error {
+	_, err := auditTx(ctx, r.db, roomAudit, AuditCreate, room.ID, func(tx *sql.Tx, _ *Room) (bool, error) {
+		_, err := tx.Exec(
+			SQLRoomPostCreateQuery,
+			room.ID, room.ClientID, room.PeopleCount, room.Area, room.BuildingID, room.Number,
+			room.AttributesFrom,
+		)
+		if err != nil {
+			return false, err
+		}
+
+		_, err = tx.Exec(SQLRoomAttributeUpsertQuery, room.ID, room.AttributesFrom, room.PeopleCount, room.Area)
+		if err != nil {
+			return false, err
+		}
+
+		_, err = tx.Exec(SQLMemberPostCreateQuery, room.ID, room.ClientID, MemberOwner, nil, nil, nil)
+		return err == nil, err
+	})
+	if err != nil {
+		return sqlWriteError(err)
+	}
+
+	*room, err = r.ByID(ctx, room.ID, true)
+	return err
+}
+
+func (r sqlRoomRepo) Update(ctx context.Context, id int64, ifMatch string, patch func(*Room)) (Room, error) {
+	return sqlUpdate(ctx, r.db, roomAudit, id, ifMatch, Room.active, patch, func(tx *sql.Tx, room Room) error {
+		_, err := tx.Exec(SQLRoomAttributeUpsertQuery, id, room.AttributesFrom, room.PeopleCount, room.Area)
+		if err != nil {
+			return err
+		}
+
+		_, err = tx.Exec(SQLRoomPatchQuery, room.ClientID, room.Number, id)
+		return err
+	})
+}
+
+func (r sqlRoomRepo) Delete(ctx context.Context, id int64, ifMatch string) error {
+	return sqlDelete(ctx, r.db, roomAudit, id, ifMatch, Room.active, SQLRoomDeleteQuery)
+}
+
+func (r sqlRoomRepo) Restore(ctx context.Context, id int64) error {
+	return sqlAffectedError(auditExec(ctx, r.db, roomAudit, AuditRestore, id, SQLRoomRestoreQuery, id))
+}
+
+// Room member
+
+func memberScanRow(m *RoomMember, row *sql.Row) error {
+	var (
+		share      sql.Null[MemberShare]
+		from_date  sql.NullTime
+		until_date sql.NullTime
+	)
+
+	if err := row.Scan(
+		&m.ID, &m.RoomID, &m.ClientID, &m.Role,
+		&share, &from_date, &until_date, &m.LastEdited,
+	); err != nil {
+		return err
+	}
+
+	m.Share = nullValue(share)
+	m.From = nullTime(from_date)
+	m.Until = nullTime(until_date)
+	return nil
+}
+
+func memberScanRows(ms *[]RoomMember, rows *sql.Rows) error {
+	if ms == nil {
+		return errors.New("*[]RoomMember is nil")
+	}
+
+	_ms := *ms
+	for rows.Next() {
+		var (
+			m          RoomMember
+			share      sql.Null[MemberShare]
+			from_date  sql.NullTime
+			until_date sql.NullTime
+		)
+
+		if err := rows.Scan(
+			&m.ID, &m.RoomID, &m.ClientID, &m.Role,
+			&share, &from_date, &until_date, &m.LastEdited,
+		); err != nil {
+			return err
+		}
+
+		m.Share = nullValue(share)
+		m.From = nullTime(from_date)
+		m.Until = nullTime(until_date)
+
+		_ms = append(_ms, m)
+	}
+
+	*ms = _ms
+	return nil
+}
+
+var memberAudit = auditEntity[RoomMember]{name: "room_member", scan: memberScanRow, query: SQLMemberGetByIDQuery}
+
+//go:embed sql/member/member_get_by_id.sql
+var SQLMemberGetByIDQuery string
+
+//go:embed sql/member/member_get_by_room_id.sql
+var SQLMemberGetByRoomIDQuery string
+
+//go:embed sql/member/member_get_by_room_id_for_update.sql
+var SQLMemberGetByRoomIDForUpdateQuery string
+
+//go:embed sql/member/member_get_by_client_id.sql
+var SQLMemberGetByClientIDQuery string
+
+//go:embed sql/member/member_insert.sql
+var SQLMemberPostCreateQuery string
+
+//go:embed sql/member/member_patch.sql
+var SQLMemberPatchQuery string
+
+//go:embed sql/member/member_delete.sql
+var SQLMemberDeleteQuery string
+
+type sqlMemberRepo struct {
+	db *sql.DB
+}
+
+func (r sqlMemberRepo) ByID(ctx context.Context, id int64) (RoomMember, error) {
+	return sqlGet(ctx, r.db, memberScanRow, SQLMemberGetByIDQuery, id)
+}
+
+func (r sqlMemberRepo) ByRoomID(ctx context.Context, roomID int64) ([]RoomMember, error) {
+	return sqlList(ctx, r.db, memberScanRows, SQLMemberGetByRoomIDQuery, roomID)
+}
+
+func (r sqlMemberRepo) ByClientID(ctx context.Context, clientID int64) ([]RoomMember, error) {
+	return sqlList(ctx, r.db, memberScanRows, SQLMemberGetByClientIDQuery, clientID)
+}
+
+// roomMembers locks the members of the room roomID in tx and returns them.
+func (r sqlMemberRepo) roomMembers(tx *sql.Tx, roomID int64) ([]RoomMember, error) {
+	rows, err := tx.Query(SQLMemberGetByRoomIDForUpdateQuery, roomID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var ms []RoomMember
+	if err := memberScanRows(&ms, rows); err != nil {
+		return nil, err
+	}
+	return ms, rows.Err()
+}
+
+func (r sqlMemberRepo) Create(ctx context.Context, m *RoomMember, check func(RoomMember, []RoomMember) error) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	ms, err := r.roomMembers(tx, m.RoomID)
+	if err != nil {
+		return err
+	}
+
+	if err := check(*m, ms); err != nil {
+		return err
+	}
+
+	res, err := tx.Exec(
+		SQLMemberPostCreateQuery,
+		m.RoomID, m.ClientID, m.Role, m.Share, m.From, m.Until,
+	)
+	if err != nil {
+		return sqlWriteError(err)
+	}
+
+	id, err := res.LastInsertId()
+	if err != nil {
+		return err
+	}
+
+	after, err := memberAudit.get(tx, id, false)
+	if err != nil {
+		return err
+	}
+
+	if err := writeAudit(ctx, tx, AuditCreate, memberAudit.name, id, nil, after); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+
+	*m = *after
+	return nil
+}
+
+func (r sqlMemberRepo) Update(ctx context.Context, id int64, ifMatch string, patch func(*RoomMember), check func(RoomMember, []RoomMember) error) (RoomMember, error) {
+	active := func(RoomMember) bool { return true }
+
+	return sqlUpdate(ctx, r.db, memberAudit, id, ifMatch, active, patch, func(tx *sql.Tx, m RoomMember) error {
+		ms, err := r.roomMembers(tx, m.RoomID)
+		if err != nil {
+			return err
+		}
+
+		if err := check(m, ms); err != nil {
+			return err
+		}
+
+		_, err = tx.Exec(SQLMemberPatchQuery, m.Role, m.Share, m.From, m.Until, id)
+		return err
+	})
+}
+
+func (r sqlMemberRepo) Delete(ctx context.Context, id int64, ifMatch string) error {
+	active := func(RoomMember) bool { return true }
+
+	return sqlDelete(ctx, r.db, memberAudit, id, ifMatch, active, SQLMemberDeleteQuery)
+}
+
+// Payment
+
+func paymentScanRow(p *Payment, row *sql.Row) error {
+	var (
+		external_id     sql.NullString
+		deleted_at      sql.NullTime
+		currency        sql.NullString
+		original_amount sql.Null[Money]
+		exchange_rate   sql.Null[ExchangeRate]
+		ledger_currency sql.NullString
+	)
+
+	if err := row.Scan(
+		&p.ID, &p.ClientID, &p.RoomID, &p.Date, &p.Amount, &p.LastEdited,
+		&p.ProviderChargeID, &external_id, &deleted_at,
+		&currency, &original_amount, &exchange_rate, &ledger_currency,
+	); err != nil {
+		return err
+	}
+
+	p.ExternalID = external_id.String
+	p.DeletedAt = nullTime(deleted_at)
+	p.Currency = cmp.Or(currency.String, baseCurrency())
+	p.OriginalAmount = nullValue(original_amount)
+	p.ExchangeRate = nullValue(exchange_rate)
+	p.LedgerCurrency = cmp.Or(ledger_currency.String, baseCurrency())
+	return nil
+}
+
+func paymentScanRows(ps *[]Payment, rows *sql.Rows) error {
+	if ps == nil {
+		return errors.New("*[]Payment is nil")
+	}
+
+	_ps := *ps
+	for rows.Next() {
+		var (
+			p               Payment
+			external_id     sql.NullString
+			deleted_at      sql.NullTime
+			currency        sql.NullString
+			original_amount sql.Null[Money]
+			exchange_rate   sql.Null[ExchangeRate]
+			ledger_currency sql.NullString
+		)
+
+		if err := rows.Scan(
+			&p.ID, &p.ClientID, &p.RoomID, &p.Date, &p.Amount, &p.LastEdited,
+			&p.ProviderChargeID, &external_id, &deleted_at,
+			&currency, &original_amount, &exchange_rate, &ledger_currency,
+		); err != nil {
+			return err
+		}
+
+		p.ExternalID = external_id.String
+		p.DeletedAt = nullTime(deleted_at)
+		p.Currency = cmp.Or(currency.String, baseCurrency())
+		p.OriginalAmount = nullValue(original_amount)
+		p.ExchangeRate = nullValue(exchange_rate)
+		p.LedgerCurrency = cmp.Or(ledger_currency.String, baseCurrency())
+
+		_ps = append(_ps, p)
+	}
+
+	*ps = _ps
+	return nil
+}
+
+var paymentAudit = auditEntity[Payment]{name: "payment", scan: paymentScanRow, query: SQLPaymentGetByPaymentIDQuery, softDelete: true}
+
+//go:embed sql/payment/payment_get_filtered.sql
+var SQLPaymentGetFilteredQuery string
+
+//go:embed sql/payment/payment_get_by_id.sql
+var SQLPaymentGetByPaymentIDQuery string
+
+//go:embed sql/payment/payment_get_by_client_id.sql
+var SQLPaymentGetByClientIDQuery string
+
+//go:embed sql/payment/payment_get_by_room_id.sql
+var SQLPaymentGetByRoomIDQuery string
+
+//go:embed sql/payment/payment_get_by_date_range.sql
+var SQLPaymentGetByDateRangeQuery string
+
+//go:embed sql/payment/payment_get_by_external_id.sql
+var SQLPaymentGetByExternalIDQuery string
+
+//go:embed sql/payment/payment_insert.sql
+var SQLPaymentPostCreateQuery string
+
+//go:embed sql/payment/payment_patch.sql
+var SQLPaymentPatchQuery string
+
+//go:embed sql/payment/payment_delete.sql
+var SQLPaymentDeleteQuery string
+
+//go:embed sql/payment/payment_restore.sql
+var SQLPaymentRestoreQuery string
+
+type sqlPaymentRepo struct {
+	db *sql.DB
+}
+
+func (r sqlPaymentRepo) List(ctx context.Context, f PaymentFilter, q PageQuery) (Page[Payment], error) {
+	return sqlPage(
+		ctx, r.db, paymentScanRows, paymentListFields, q,
+		SQLPaymentGetFilteredQuery,
+		f.BuildingID, f.BuildingID, f.ClientID, f.ClientID, f.RoomID, f.RoomID,
+		f.MinAmount, f.MinAmount, f.MaxAmount, f.MaxAmount, f.IncludeDeleted,
+	)
+}
+
+func (r sqlPaymentRepo) ByID(ctx context.Context, id int64, includeDeleted bool) (Payment, error) {
+	return sqlGet(ctx, r.db, paymentScanRow, SQLPaymentGetByPaymentIDQuery, id, includeDeleted)
+}
+
+func (r sqlPaymentRepo) ByClientID(ctx context.Context, clientID int64, includeDeleted bool) ([]Payment, error) {
+	return sqlList(ctx, r.db, paymentScanRows, SQLPaymentGetByClientIDQuery, clientID, includeDeleted)
+}
+
+func (r sqlPaymentRepo) ByRoomID(ctx context.Context, roomID int64, includeDeleted bool) ([]Payment, error) {
+	return sqlList(ctx, r.db, paymentScanRows, SQLPaymentGetByRoomIDQuery, roomID, includeDeleted)
+}
+
+func (r sqlPaymentRepo) ByDateRange(ctx context.Context, start, end time.Time, includeDeleted bool) ([]Payment, error) {
+	return sqlList(ctx, r.db, paymentScanRows, SQLPaymentGetByDateRangeQuery, start, end, includeDeleted)
+}
+
+func (r sqlPaymentRepo) ByExternalID(ctx context.Context, externalID string) (Payment, error) {
+	return sqlGet(ctx, r.db, paymentScanRow, SQLPaymentGetByExternalIDQuery, externalID)
+}
+
+func (r sqlPaymentRepo) Create(ctx context.Context, p *Payment) error {
+	var external_id any
+	if p.ExternalID != "" {
+		external_id = p.ExternalID
+	}
+
+	res, err := auditExec(
+		ctx, r.db, paymentAudit, AuditCreate, nil,
+		SQLPaymentPostCreateQuery,
+		p.ClientID, p.RoomID, p.Date, p.Amount,
+		p.ProviderChargeID, external_id,
+		p.Currency, p.OriginalAmount, p.ExchangeRate, p.LedgerCurrency,
+	)
+	if err != nil {
+		return sqlWriteError(err)
+	}
+
+	id, err := res.LastInsertId()
+	if err != nil {
+		return err
+	}
+
+	*p, err = r.ByID(ctx, id, true)
+	return err
+}
+
+func (r sqlPaymentRepo) Update(ctx context.Context, id int64, ifMatch string, patch func(*Payment)) (Payment, error) {
+	var before Payment
+	keep := func(p *Payment) {
+		before = *p
+		patch(p)
+	}
+
+	return sqlUpdate(ctx, r.db, paymentAudit, id, ifMatch, Payment.active, keep, func(tx *sql.Tx, p Payment) error {
+		if p.RoomID != before.RoomID {
+			var currency sql.NullString
+			err := tx.QueryRow(SQLRoomCurrencyForUpdateQuery, p.RoomID).Scan(&currency)
+			if errors.Is(err, sql.ErrNoRows) {
+				return fmt.Errorf("%w: no room with room_id %d", ErrInvalidReference, p.RoomID)
+			}
+			if err != nil {
+				return err
+			}
+			if c := cmp.Or(currency.String, baseCurrency()); c != p.LedgerCurrency {
+				return fmt.Errorf("%w: room_id %d is in a building that keeps its ledger in %s, not %s", ErrCurrencyMismatch, p.RoomID, c, p.LedgerCurrency)
+			}
+		}
+
+		_, err := tx.Exec(SQLPaymentPatchQuery, p.ClientID, p.RoomID, p.Date, p.Amount, p.OriginalAmount, id)
+		return err
+	})
+}
+
+func (r sqlPaymentRepo) Delete(ctx context.Context, id int64, ifMatch string) error {
+	return sqlDelete(ctx, r.db, paymentAudit, id, ifMatch, Payment.active, SQLPaymentDeleteQuery)
+}
+
+func (r sqlPaymentRepo) Restore(ctx context.Context, id int64) error {
+	return sqlAffectedError(auditExec(ctx, r.db, paymentAudit, AuditRestore, id, SQLPaymentRestoreQuery, id))
+}
+
+// Expense
+
+func expenseScanRow(e *Expense, row *sql.Row) error {
+	var deleted_at sql.NullTime
+
+	if err := row.Scan(
+		&e.ID, &e.Date, &e.Amount, &e.LastEdited,
+		&e.Category, &e.Vendor, &e.Description, &e.DocumentRef,
+		&deleted_at, &e.BuildingID,
+	); err != nil {
+		return err
+	}
+
+	e.DeletedAt = nullTime(deleted_at)
+	return nil
+}
+
+func expenseScanRows(es *[]Expense, rows *sql.Rows) error {
+	if es == nil {
+		return errors.New("*[]Expense is nil")
+	}
+
+	_es := *es
+	for rows.Next() {
+		var (
+			e          Expense
+			deleted_at sql.NullTime
+		)
+
+		if err := rows.Scan(
+			&e.ID, &e.Date, &e.Amount, &e.LastEdited,
+			&e.Category, &e.Vendor, &e.Description, &e.DocumentRef,
+			&deleted_at, &e.BuildingID,
+		); err != nil {
+			return err
+		}
+
+		e.DeletedAt = nullTime(deleted_at)
+
+		_es = append(_es, e)
+	}
+
+	*es = _es
+	return nil
+}
+
+func expenseCategoryTotalScanRows(ts *[]ExpenseCategoryTotal, rows *sql.Rows) error {
+	if ts == nil {
+		return errors.New("*[]ExpenseCategoryTotal is nil")
+	}
+
+	_ts := *ts
+	for rows.Next() {
+		var (
+			t        ExpenseCategoryTotal
+			currency sql.NullString
+		)
+
+		if err := rows.Scan(&t.BuildingID, &currency, &t.Category, &t.Count, &t.Amount); err != nil {
+			return err
+		}
+
+		t.Currency = cmp.Or(currency.String, baseCurrency())
+
+		_ts = append(_ts, t)
+	}
+
+	*ts = _ts
+	return nil
+}
+
+var expenseAudit = auditEntity[Expense]{name: "expense", scan: expenseScanRow, query: SQLExpenseGetByIDQuery, softDelete: true}
+
+//go:embed sql/expense/expense_get_filtered.sql
+var SQLExpenseGetFilteredQuery string
+
+//go:embed sql/expense/expense_get_by_expense_id.sql
+var SQLExpenseGetByIDQuery string
+
+//go:embed sql/expense/expense_get_by_date_range.sql
+var SQLExpenseGetByDateRangeQuery string
+
+//go:embed sql/expense/expense_get_category_totals.sql
+var SQLExpenseGetCategoryTotalsQuery string
+
+//go:embed sql/expense/expense_insert.sql
+var SQLExpensePostCreateQuery string
+
+//go:embed sql/expense/expense_patch.sql
+var SQLExpensePatchQuery string
+
+//go:embed sql/expense/expense_delete.sql
+var SQLExpenseDeleteQuery string
+
+//go:embed sql/expense/expense_restore.sql
+var SQLExpenseRestoreQuery string
+
+type sqlExpenseRepo struct {
+	db *sql.DB
+}
+
+func (r sqlExpenseRepo) List(ctx context.Context, f ExpenseFilter, q PageQuery) (Page[Expense], error) {
+	return sqlPage(
+		ctx, r.db, expenseScanRows, expenseListFields, q,
+		SQLExpenseGetFilteredQuery,
+		f.BuildingID, f.BuildingID, f.Category, f.Category, f.Vendor, f.Vendor,
+		f.MinAmount, f.MinAmount, f.MaxAmount, f.MaxAmount, f.IncludeDeleted,
+	)
+}
+
+func (r sqlExpenseRepo) ByID(ctx context.Context, id int64, includeDeleted bool) (Expense, error) {
+	return sqlGet(ctx, r.db, expenseScanRow, SQLExpenseGetByIDQuery, id, includeDeleted)
+}
+
+func (r sqlExpenseRepo) ByDateRange(ctx context.Context, start, end time.Time, includeDeleted bool) ([]Expense, error) {
+	return sqlList(ctx, r.db, expenseScanRows, SQLExpenseGetByDateRangeQuery, start, end, includeDeleted)
+}
+
+func (r sqlExpenseRepo) CategoryTotals(ctx context.Context, buildingID int64, vendor string) ([]ExpenseCategoryTotal, error) {
+	return sqlList(
+		ctx, r.db, expenseCategoryTotalScanRows,
+		SQLExpenseGetCategoryTotalsQuery,
+		buildingID, buildingID, vendor, vendor,
+	)
+}
+
+func (r sqlExpenseRepo) Create(ctx context.Context, e *Expense) error {
+	res, err := auditExec(
+		ctx, r.db, expenseAudit, AuditCreate, nil,
+		SQLExpensePostCreateQuery,
+		e.Date, e.Amount,
+		e.Category, e.Vendor, e.Description, e.DocumentRef, e.BuildingID,
+	)
+	if err != nil {
+		return sqlWriteError(err)
+	}
+
+	id, err := res.LastInsertId()
+	if err != nil {
+		return err
+	}
+
+	*e, err = r.ByID(ctx, id, true)
+	return err
+}
+
+func (r sqlExpenseRepo) Update(ctx context.Context, id int64, ifMatch string, patch func(*Expense)) (Expense, error) {
+	var before Expense
+	keep := func(e *Expense) {
+		before = *e
+		patch(e)
+	}
+
+	return sqlUpdate(ctx, r.db, expenseAudit, id, ifMatch, Expense.active, keep, func(tx *sql.Tx, e Expense) error {
+		if e.Amount != before.Amount || !e.Date.Equal(before.Date) {
+			share, err := shareAudit.get(tx, id, false)
+			if err != nil {
+				return err
+			}
+			if share != nil {
+				return fmt.Errorf("%w: expense_id %d is shared, stop sharing it to change its amount or date", ErrInUse, id)
+			}
+		}
+
+		_, err := tx.Exec(
+			SQLExpensePatchQuery,
+			e.Date, e.Amount,
+			e.Category, e.Vendor, e.Description, e.DocumentRef,
+			id,
+		)
+		return err
+	})
+}
+
+func (r sqlExpenseRepo) Delete(ctx context.Context, id int64, ifMatch string) error {
+	return sqlDelete(ctx, r.db, expenseAudit, id, ifMatch, Expense.active, SQLExpenseDeleteQuery)
+}
+
+func (r sqlExpenseRepo) Restore(ctx context.Context, id int64) error {
+	return sqlAffectedError(auditExec(ctx, r.db, expenseAudit, AuditRestore, id, SQLExpenseRestoreQuery, id))
+}
+
+// Tariff
+
+func tariffScanRow(t *Tariff, row *sql.Row) error {
+	var building_id sql.Null[int64]
+
+	if err := row.Scan(&t.ID, &t.AreaRate, &t.PeopleRate, &t.FixedFee, &t.EffectiveFrom, &t.LastEdited, &building_id); err != nil {
+		return err
+	}
+
+	t.BuildingID = nullValue(building_id)
+	return nil
+}
+
+func tariffScanRows(ts *[]Tariff, rows *sql.Rows) error {
+	if ts == nil {
+		return errors.New("*[]Tariff is nil")
+	}
+
+	_ts := *ts
+	for rows.Next() {
+		var (
+			t           Tariff
+			building_id sql.Null[int64]
+		)
+
+		if err := rows.Scan(&t.ID, &t.AreaRate, &t.PeopleRate, &t.FixedFee, &t.EffectiveFrom, &t.LastEdited, &building_id); err != nil {
+			return err
+		}
+
+		t.BuildingID = nullValue(building_id)
+
+		_ts = append(_ts, t)
+	}
+
+	*ts = _ts
+	return nil
+}
+
+var tariffAudit = auditEntity[Tariff]{name: "tariff", scan: tariffScanRow, query: SQLTariffGetByIDQuery}
+
+//go:embed sql/tariff/tariff_get_all.sql
+var SQLTariffGetAllQuery string
+
+//go:embed sql/tariff/tariff_get_by_id.sql
+var SQLTariffGetByIDQuery string
+
+//go:embed sql/tariff/tariff_get_effective.sql
+var SQLTariffGetEffectiveQuery string
+
+//go:embed sql/tariff/tariff_insert.sql
+var SQLTariffPostCreateQuery string
+
+type sqlTariffRepo struct {
+	db *sql.DB
+}
+
+func (r sqlTariffRepo) All(ctx context.Context, buildingID int64) ([]Tariff, error) {
+	return sqlList(ctx, r.db, tariffScanRows, SQLTariffGetAllQuery, buildingID, buildingID)
+}
+
+func (r sqlTariffRepo) ByID(ctx context.Context, id int64) (Tariff, error) {
+	return sqlGet(ctx, r.db, tariffScanRow, SQLTariffGetByIDQuery, id)
+}
+
+func (r sqlTariffRepo) Effective(ctx context.Context, period time.Time, buildingID int64) (Tariff, error) {
+	return sqlGet(ctx, r.db, tariffScanRow, SQLTariffGetEffectiveQuery, period, buildingID)
+}
+
+func (r sqlTariffRepo) Create(ctx context.Context, t *Tariff) error {
+	res, err := auditExec(
+		ctx, r.db, tariffAudit, AuditCreate, nil,
+		SQLTariffPostCreateQuery,
+		t.AreaRate, t.PeopleRate, t.FixedFee, t.EffectiveFrom, t.BuildingID,
+	)
+	if err != nil {
+		return sqlWriteError(err)
+	}
+
+	id, err := res.LastInsertId()
+	if err != nil {
+		return err
+	}
+
+	*t, err = r.ByID(ctx, id)
+	return err
+}
+
+// Charge
+
+func chargeScanRow(c *Charge, row *sql.Row) error {
+	return row.Scan(
+		&c.ID, &c.RoomID, &c.TariffID, &c.Period,
+		&c.AreaAmount, &c.PeopleAmount, &c.FixedAmount, &c.Amount,
+		&c.LastEdited,
+	)
+}
+
+func chargeScanRows(cs *[]Charge, rows *sql.Rows) error {
+	if cs == nil {
+		return errors.New("*[]Charge is nil")
+	}
+
+	_cs := *cs
+	for rows.Next() {
+		var c Charge
+
+		if err := rows.Scan(
+			&c.ID, &c.RoomID, &c.TariffID, &c.Period,
+			&c.AreaAmount, &c.PeopleAmount, &c.FixedAmount, &c.Amount,
+			&c.LastEdited,
+		); err != nil {
+			return err
+		}
+
+		_cs = append(_cs, c)
+	}
+
+	*cs = _cs
+	return nil
+}
+
+var chargeAudit = auditEntity[Charge]{name: "charge", scan: chargeScanRow, query: SQLChargeGetByIDQuery}
+
+//go:embed sql/charge/charge_get_all.sql
+var SQLChargeGetAllQuery string
+
+//go:embed sql/charge/charge_get_by_id.sql
+var SQLChargeGetByIDQuery string
+
+//go:embed sql/charge/charge_get_by_room_id.sql
+var SQLChargeGetByRoomIDQuery string
+
+//go:embed sql/charge/charge_get_by_period.sql
+var SQLChargeGetByPeriodQuery string
+
+//go:embed sql/charge/charge_generate.sql
+var SQLChargeGenerateQuery string
+
+//go:embed sql/charge/charge_delete.sql
+var SQLChargeDeleteQuery string
+
+type sqlChargeRepo struct {
+	db *sql.DB
+}
+
+func (r sqlChargeRepo) All(ctx context.Context, buildingID int64) ([]Charge, error) {
+	return sqlList(ctx, r.db, chargeScanRows, SQLChargeGetAllQuery, buildingID, buildingID)
+}
+
+func (r sqlChargeRepo) ByID(ctx context.Context, id int64) (Charge, error) {
+	return sqlGet(ctx, r.db, chargeScanRow, SQLChargeGetByIDQuery, id)
+}
+
+func (r sqlChargeRepo) ByRoomID(ctx context.Context, roomID int64) ([]Charge, error) {
+	return sqlList(ctx, r.db, chargeScanRows, SQLChargeGetByRoomIDQuery, roomID)
+}
+
+func (r sqlChargeRepo) ByPeriod(ctx context.Context, period time.Time, buildingID int64) ([]Charge, error) {
+	return sqlList(ctx, r.db, chargeScanRows, SQLChargeGetByPeriodQuery, period, buildingID, buildingID)
+}
+
+// Generate records the whole batch as one audit_log entry keyed by the
+// period.
+func (r sqlChargeRepo) Generate(ctx context.Context, period time.Time, tariffID, buildingID int64) (int64, error) {
+	var tariff sql.Null[int64]
+	if tariffID != 0 {
+		tariff = sql.Null[int64]{V: tariffID, Valid: true}
+	}
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	res, err := tx.Exec(SQLChargeGenerateQuery, period, period, tariff, period, buildingID, buildingID)
+	if err != nil {
+		return 0, err
+	}
+
+	created, err := res.RowsAffected()
+	if err != nil {
+		return 0, err
+	}
+
+	if created > 0 {
+		batch := ChargeGenerateResult{
+			Period:     period.Format(PERIOD_FORMAT),
+			TariffID:   tariffID,
+			BuildingID: buildingID,
+			Created:    created,
+		}
+		if err := writeAudit(ctx, tx, AuditCreate, chargeAudit.name, batch.Period, nil, batch); err != nil {
+			return 0, err
+		}
+	}
+
+	return created, tx.Commit()
+}
+
+func (r sqlChargeRepo) Delete(ctx context.Context, id int64) error {
+	return sqlAffectedError(auditExec(ctx, r.db, chargeAudit, AuditDelete, id, SQLChargeDeleteQuery, id))
+}
+
+// Meter
+
+func meterScanRow(m *Meter, row *sql.Row) error {
+	return row.Scan(&m.ID, &m.RoomID, &m.Kind, &m.Serial, &m.LastEdited)
+}
+
+func meterScanRows(ms *[]Meter, rows *sql.Rows) error {
+	if ms == nil {
+		return errors.New("*[]Meter is nil")
+	}
+
+	_ms := *ms
+	for rows.Next() {
+		var m Meter
+
+		if err := rows.Scan(&m.ID, &m.RoomID, &m.Kind, &m.Serial, &m.LastEdited); err != nil {
+			return err
+		}
+
+		_ms = append(_ms, m)
+	}
+
+	*ms = _ms
+	return nil
+}
+
+func meterReadingScanRow(r *MeterReading, row *sql.Row) error {
+	return row.Scan(&r.ID, &r.MeterID, &r.Date, &r.Value, &r.Consumption, &r.Abnormal, &r.LastEdited)
+}
+
+func meterReadingScanRows(rs *[]MeterReading, rows *sql.Rows) error {
+	if rs == nil {
+		return errors.New("*[]MeterReading is nil")
+	}
+
+	_rs := *rs
+	for rows.Next() {
+		var r MeterReading
+
+		if err := rows.Scan(&r.ID, &r.MeterID, &r.Date, &r.Value, &r.Consumption, &r.Abnormal, &r.LastEdited); err != nil {
+			return err
+		}
+
+		_rs = append(_rs, r)
+	}
+
+	*rs = _rs
+	return nil
+}
+
+var (
+	meterAudit        = auditEntity[Meter]{name: "meter", scan: meterScanRow, query: SQLMeterGetByIDQuery}
+	meterReadingAudit = auditEntity[MeterReading]{name: "meter_reading", scan: meterReadingScanRow, query: SQLMeterReadingGetByIDQuery}
+)
+
+//go:embed sql/meter/meter_get_all.sql
+var SQLMeterGetAllQuery string
+
+//go:embed sql/meter/meter_get_by_id.sql
+var SQLMeterGetByIDQuery string
+
+//go:embed sql/meter/meter_get_by_id_for_update.sql
+var SQLMeterGetByIDForUpdateQuery string
+
+//go:embed sql/meter/meter_get_by_room_id.sql
+var SQLMeterGetByRoomIDQuery string
+
+//go:embed sql/meter/meter_insert.sql
+var SQLMeterPostCreateQuery string
+
+//go:embed sql/meter/meter_delete.sql
+var SQLMeterDeleteQuery string
+
+//go:embed sql/meter/reading_get_by_id.sql
+var SQLMeterReadingGetByIDQuery string
+
+//go:embed sql/meter/reading_get_by_meter_id.sql
+var SQLMeterReadingGetByMeterIDQuery string
+
+//go:embed sql/meter/reading_insert.sql
+var SQLMeterReadingPostCreateQuery string
+
+//go:embed sql/meter/reading_delete.sql
+var SQLMeterReadingDeleteQuery string
+
+type sqlMeterRepo struct {
+	db *sql.DB
+}
+
+func (r sqlMeterRepo) All(ctx context.Context, buildingID int64) ([]Meter, error) {
+	return sqlList(ctx, r.db, meterScanRows, SQLMeterGetAllQuery, buildingID, buildingID)
+}
+
+func (r sqlMeterRepo) ByID(ctx context.Context, id int64) (Meter, error) {
+	return sqlGet(ctx, r.db, meterScanRow, SQLMeterGetByIDQuery, id)
+}
+
+func (r sqlMeterRepo) ByRoomID(ctx context.Context, roomID int64) ([]Meter, error) {
+	return sqlList(ctx, r.db, meterScanRows, SQLMeterGetByRoomIDQuery, roomID)
+}
+
+func (r sqlMeterRepo) Create(ctx context.Context, m *Meter) error {
+	res, err := auditExec(ctx, r.db, meterAudit, AuditCreate, nil, SQLMeterPostCreateQuery, m.RoomID, m.Kind, m.Serial)
+	if err != nil {
+		return sqlWriteError(err)
+	}
+
+	id, err := res.LastInsertId()
+	if err != nil {
+		return err
+	}
+
+	*m, err = r.ByID(ctx, id)
+	return err
+}
+
+func (r sqlMeterRepo) Delete(ctx context.Context, id int64) error {
+	return sqlAffectedError(auditExec(ctx, r.db, meterAudit, AuditDelete, id, SQLMeterDeleteQuery, id))
+}
+
+func (r sqlMeterRepo) Readings(ctx context.Context, meterID int64) ([]MeterReading, error) {
+	return sqlList(ctx, r.db, meterReadingScanRows, SQLMeterReadingGetByMeterIDQuery, meterID)
+}
+
+func (r sqlMeterRepo) Reading(ctx context.Context, id int64) (MeterReading, error) {
+	return sqlGet(ctx, r.db, meterReadingScanRow, SQLMeterReadingGetByIDQuery, id)
+}
+
+func (r sqlMeterRepo) AddReading(ctx context.Context, reading *MeterReading, check func(*MeterReading, []MeterReading) error) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var m Meter
+	if err := meterScanRow(&m, tx.QueryRow(SQLMeterGetByIDForUpdateQuery, reading.MeterID)); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrNotFound
+		}
+		return err
+	}
+
+	rows, err := tx.Query(SQLMeterReadingGetByMeterIDQuery, reading.MeterID)
+	if err != nil {
+		return err
+	}
+
+	var history []MeterReading
+	err = meterReadingScanRows(&history, rows)
+	rows.Close()
+	if err != nil {
+		return err
+	}
+
+	if err := check(reading, history); err != nil {
+		return err
+	}
+
+	res, err := tx.Exec(
+		SQLMeterReadingPostCreateQuery,
+		reading.MeterID, reading.Date, reading.Value, reading.Consumption, reading.Abnormal,
+	)
+	if err != nil {
+		return err
+	}
+
+	id, err := res.LastInsertId()
+	if err != nil {
+		return err
+	}
+
+	after, err := meterReadingAudit.get(tx, id, false)
+	if err != nil {
+		return err
+	}
+
+	if err := writeAudit(ctx, tx, AuditCreate, meterReadingAudit.name, id, nil, after); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+
+	*reading = *after
+	return nil
+}
+
+func (r sqlMeterRepo) DeleteReading(ctx context.Context, id int64) error {
+	return sqlAffectedError(auditExec(ctx, r.db, meterReadingAudit, AuditDelete, id, SQLMeterReadingDeleteQuery, id))
+}
+
+// Exchange rate
+
+func rateScanRow(r *Rate, row *sql.Row) error {
+	return row.Scan(&r.ID, &r.Currency, &r.Date, &r.Value, &r.LastEdited)
+}
+
+func rateScanRows(rs *[]Rate, rows *sql.Rows) error {
+	if rs == nil {
+		return errors.New("*[]Rate is nil")
+	}
+
+	_rs := *rs
+	for rows.Next() {
+		var r Rate
+
+		if err := rows.Scan(&r.ID, &r.Currency, &r.Date, &r.Value, &r.LastEdited); err != nil {
+			return err
+		}
+
+		_rs = append(_rs, r)
+	}
+
+	*rs = _rs
+	return nil
+}
+
+var rateAudit = auditEntity[Rate]{name: "exchange_rate", scan: rateScanRow, query: SQLRateGetByIDQuery}
+
+//go:embed sql/rate/rate_get_all.sql
+var SQLRateGetAllQuery string
+
+//go:embed sql/rate/rate_get_by_id.sql
+var SQLRateGetByIDQuery string
+
+//go:embed sql/rate/rate_get_effective.sql
+var SQLRateGetEffectiveQuery string
+
+//go:embed sql/rate/rate_insert.sql
+var SQLRatePostCreateQuery string
+
+//go:embed sql/rate/rate_delete.sql
+var SQLRateDeleteQuery string
+
+type sqlRateRepo struct {
+	db *sql.DB
+}
+
+func (r sqlRateRepo) All(ctx context.Context, currency string) ([]Rate, error) {
+	return sqlList(ctx, r.db, rateScanRows, SQLRateGetAllQuery, currency, currency)
+}
+
+func (r sqlRateRepo) ByID(ctx context.Context, id int64) (Rate, error) {
+	return sqlGet(ctx, r.db, rateScanRow, SQLRateGetByIDQuery, id)
+}
+
+func (r sqlRateRepo) Effective(ctx context.Context, currency string, day time.Time) (Rate, error) {
+	return sqlGet(ctx, r.db, rateScanRow, SQLRateGetEffectiveQuery, currency, day)
+}
+
+func (r sqlRateRepo) Create(ctx context.Context, rate *Rate) error {
+	res, err := auditExec(
+		ctx, r.db, rateAudit, AuditCreate, nil,
+		SQLRatePostCreateQuery,
+		rate.Currency, rate.Date, rate.Value,
+	)
+	if err != nil {
+		return sqlWriteError(err)
+	}
+
+	id, err := res.LastInsertId()
+	if err != nil {
+		return err
+	}
+
+	*rate, err = r.ByID(ctx, id)
+	return err
+}
+
+func (r sqlRateRepo) Delete(ctx context.Context, id int64) error {
+	return sqlAffectedError(auditExec(ctx, r.db, rateAudit, AuditDelete, id, SQLRateDeleteQuery, id))
+}
+
+// API token
+
+func tokenScanRow(t *APIToken, row *sql.Row) error {
+	return row.Scan(&t.ID, &t.ClientID, &t.Name, &t.LastEdited)
+}
+
+func tokenScanRows(ts *[]APIToken, rows *sql.Rows) error {
+	if ts == nil {
+		return errors.New("*[]APIToken is nil")
+	}
+
+	_ts := *ts
+	for rows.Next() {
+		var t APIToken
+
+		if err := rows.Scan(&t.ID, &t.ClientID, &t.Name, &t.LastEdited); err != nil {
+			return err
+		}
+
+		_ts = append(_ts, t)
+	}
+
+	*ts = _ts
+	return nil
+}
+
+var tokenAudit = auditEntity[APIToken]{name: "api_token", scan: tokenScanRow, query: SQLTokenGetByIDQuery}
+
+//go:embed sql/token/token_get_by_id.sql
+var SQLTokenGetByIDQuery string
+
+//go:embed sql/token/token_get_by_client_id.sql
+var SQLTokenGetByClientIDQuery string
+
+//go:embed sql/token/token_insert.sql
+var SQLTokenPostCreateQuery string
+
+//go:embed sql/token/token_delete.sql
+var SQLTokenDeleteQuery string
+
+type sqlTokenRepo struct {
+	db *sql.DB
+}
+
+func (r sqlTokenRepo) ByID(ctx context.Context, id int64) (APIToken, error) {
+	return sqlGet(ctx, r.db, tokenScanRow, SQLTokenGetByIDQuery, id)
+}
+
+func (r sqlTokenRepo) ByClientID(ctx context.Context, clientID int64) ([]APIToken, error) {
+	return sqlList(ctx, r.db, tokenScanRows, SQLTokenGetByClientIDQuery, clientID)
+}
+
+func (r sqlTokenRepo) Create(ctx context.Context, t *APIToken) error {
+	res, err := auditExec(
+		ctx, r.db, tokenAudit, AuditCreate, nil,
+		SQLTokenPostCreateQuery,
+		t.ClientID, t.Name, hashToken(t.Token),
+	)
+	if err != nil {
+		return sqlWriteError(err)
+	}
+
+	id, err := res.LastInsertId()
+	if err != nil {
+		return err
+	}
+
+	token := t.Token
+	*t, err = r.ByID(ctx, id)
+	t.Token = token
+	return err
+}
+
+func (r sqlTokenRepo) Delete(ctx context.Context, id int64) error {
+	return sqlAffectedError(auditExec(ctx, r.db, tokenAudit, AuditDelete, id, SQLTokenDeleteQuery, id))
+}
+
+// Bot state
+
+func botStateScanRow(s *BotState, row *sql.Row) error {
+	var expires sql.NullTime
+
+	if err := row.Scan(&s.Key, &s.Value, &expires, &s.LastEdited); err != nil {
+		return err
+	}
+
+	s.Expires = nullTime(expires)
+	return nil
+}
+
+//go:embed sql/bot/bot_state_get_by_key.sql
+var SQLBotStateGetByKeyQuery string
+
+//go:embed sql/bot/bot_state_upsert.sql
+var SQLBotStatePostCreateQuery string
+
+//go:embed sql/bot/bot_state_delete_expired.sql
+var SQLBotStateDeleteExpiredQuery string
+
+//go:embed sql/bot/bot_state_delete.sql
+var SQLBotStateDeleteQuery string
+
+type sqlBotStateRepo struct {
+	db *sql.DB
+}
+
+func (r sqlBotStateRepo) Get(ctx context.Context, key string) (BotState, error) {
+	return sqlGet(ctx, r.db, botStateScanRow, SQLBotStateGetByKeyQuery, key)
+}
+
+func (r sqlBotStateRepo) Set(ctx context.Context, key, value string, ttl int64) error {
+	if _, err := r.db.ExecContext(ctx, SQLBotStateDeleteExpiredQuery); err != nil {
+		logError("sqlBotStateRepo.Set():", err)
+	}
+
+	_, err := r.db.ExecContext(ctx, SQLBotStatePostCreateQuery, key, value, ttl, ttl)
+	return err
+}
+
+func (r sqlBotStateRepo) Delete(ctx context.Context, key string) error {
+	_, err := r.db.ExecContext(ctx, SQLBotStateDeleteQuery, key)
+	return err
+}
+
+// Audit log
+
+func auditScanRows(as *[]AuditRecord, rows *sql.Rows) error {
+	if as == nil {
+		return errors.New("*[]AuditRecord is nil")
+	}
+
+	_as := *as
+	for rows.Next() {
+		var (
+			a         AuditRecord
+			client_id sql.Null[int64]
+			before    []byte
+			after     []byte
+		)
+
+		if err := rows.Scan(
+			&a.ID, &a.Date, &a.ActorRole, &client_id, &a.Action,
+			&a.Entity, &a.EntityID, &before, &after, &a.RequestID,
+		); err != nil {
+			return err
+		}
+
+		a.ActorClientID = nullValue(client_id)
+		if before != nil {
+			a.Before = before
+		}
+		if after != nil {
+			a.After = after
+		}
+
+		_as = append(_as, a)
+	}
+
+	*as = _as
+	return nil
+}
+
+//go:embed sql/audit/audit_get_filtered.sql
+var SQLAuditGetFilteredQuery string
+
+type sqlAuditRepo struct {
+	db *sql.DB
+}
+
+func (r sqlAuditRepo) List(ctx context.Context, f AuditFilter) ([]AuditRecord, error) {
+	return sqlList(
+		ctx, r.db, auditScanRows, SQLAuditGetFilteredQuery,
+		f.Entity, f.Entity,
+		f.EntityID, f.EntityID,
+		f.Action, f.Action,
+		f.Role, f.Role,
+		f.ActorClientID, f.ActorClientID,
+		f.RequestID, f.RequestID,
+		f.From, f.From,
+		f.To, f.To,
+		f.Limit,
+	)
+}
+
+// Expense share
+
+func shareScanRow(s *ExpenseShare, row *sql.Row) error {
+	return row.Scan(&s.ExpenseID, &s.Rule, &s.Remainder, &s.LastEdited)
+}
+
+func allocationScanRows(as *[]ExpenseAllocation, rows *sql.Rows) error {
+	if as == nil {
+		return errors.New("*[]ExpenseAllocation is nil")
+	}
+
+	_as := *as
+	for rows.Next() {
+		var a ExpenseAllocation
+
+		if err := rows.Scan(&a.ID, &a.ExpenseID, &a.RoomID, &a.Amount, &a.LastEdited); err != nil {
+			return err
+		}
+
+		_as = append(_as, a)
+	}
+
+	*as = _as
+	return nil
+}
+
+var shareAudit = auditEntity[ExpenseShare]{name: "expense_share", scan: shareScanRow, query: SQLShareGetByExpenseIDQuery}
+
+//go:embed sql/allocation/share_get_by_expense_id.sql
+var SQLShareGetByExpenseIDQuery string
+
+//go:embed sql/allocation/share_upsert.sql
+var SQLSharePostCreateQuery string
+
+//go:embed sql/allocation/share_delete.sql
+var SQLShareDeleteQuery string
+
+//go:embed sql/allocation/allocation_get_by_expense_id.sql
+var SQLAllocationGetByExpenseIDQuery string
+
+//go:embed sql/allocation/allocation_get_by_room_id.sql
+var SQLAllocationGetByRoomIDQuery string
+
+//go:embed sql/allocation/allocation_insert.sql
+var SQLAllocationPostCreateQuery string
+
+//go:embed sql/allocation/allocation_delete_by_expense_id.sql
+var SQLAllocationDeleteByExpenseIDQuery string
+
+type sqlShareRepo struct {
+	db *sql.DB
+}
+
+func (r sqlShareRepo) ByExpenseID(ctx context.Context, expenseID int64) (ExpenseShare, error) {
+	s, err := sqlGet(ctx, r.db, shareScanRow, SQLShareGetByExpenseIDQuery, expenseID)
+	if err != nil {
+		return s, err
+	}
+
+	as, err := sqlList(ctx, r.db, allocationScanRows, SQLAllocationGetByExpenseIDQuery, expenseID)
+	s.Allocations = append([]ExpenseAllocation{}, as...)
+	return s, err
+}
+
+func (r sqlShareRepo) AllocationsByRoomID(ctx context.Context, roomID int64) ([]ExpenseAllocation, error) {
+	return sqlList(ctx, r.db, allocationScanRows, SQLAllocationGetByRoomIDQuery, roomID)
+}
+
+func (r sqlShareRepo) Set(ctx context.Context, s *ExpenseShare, ifMatch string) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	// Locking the expense keeps its amount and date until the allocations
+	// are written, see sqlExpenseRepo.Update.
+	e, err := expenseAudit.get(tx, s.ExpenseID, true)
+	if err != nil {
+		return err
+	}
+	if e == nil || !e.active() {
+		return ErrNotFound
+	}
+	if !etagMatch(ifMatch, *e) {
+		return ErrPreconditionFailed
+	}
+
+	before, err := shareAudit.get(tx, s.ExpenseID, true)
+	if err != nil {
+		return err
+	}
+
+	if _, err := tx.Exec(SQLAllocationDeleteByExpenseIDQuery, s.ExpenseID); err != nil {
+		return err
+	}
+
+	for _, a := range s.Allocations {
+		if _, err := tx.Exec(SQLAllocationPostCreateQuery, s.ExpenseID, a.RoomID, a.Amount); err != nil {
+			return sqlWriteError(err)
+		}
+	}
+
+	if _, err := tx.Exec(SQLSharePostCreateQuery, s.ExpenseID, s.Rule, s.Remainder); err != nil {
+		return sqlWriteError(err)
+	}
+
+	after, err := shareAudit.get(tx, s.ExpenseID, false)
+	if err != nil {
+		return err
+	}
+
+	if before == nil {
+		err = writeAudit(ctx, tx, AuditCreate, shareAudit.name, s.ExpenseID, nil, after)
+	} else {
+		err = writeAudit(ctx, tx, AuditUpdate, shareAudit.name, s.ExpenseID, before, after)
+	}
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func (r sqlShareRepo) Delete(ctx context.Context, expenseID int64) error {
+	_, err := auditTx(ctx, r.db, shareAudit, AuditDelete, expenseID, func(tx *sql.Tx, before *ExpenseShare) (bool, error) {
+		if before == nil {
+			return false, ErrNotFound
+		}
+
+		if _, err := tx.Exec(SQLAllocationDeleteByExpenseIDQuery, expenseID); err != nil {
+			return false, err
+		}
+
+		_, err := tx.Exec(SQLShareDeleteQuery, expenseID)
+		return err == nil, err
+	})
+	return err
+}
+
+// Balance
+
+func balanceScanRows(bs *[]balanceRow, rows *sql.Rows) error {
+	if bs == nil {
+		return errors.New("*[]balanceRow is nil")
+	}
+
+	_bs := *bs
+	for rows.Next() {
+		var b balanceRow
+
+		if err := rows.Scan((*textTime)(&b.Period), &b.Charges, &b.Expenses, &b.Payments); err != nil {
+			return err
+		}
+
+		_bs = append(_bs, b)
+	}
+
+	*bs = _bs
+	return nil
+}
+
+//go:embed sql/balance/balance_get_by_room_id.sql
+var SQLBalanceGetByRoomIDQuery string
+
+type sqlBalanceRepo struct {
+	db *sql.DB
+}
+
+func (r sqlBalanceRepo) RoomMonths(ctx context.Context, roomID int64) ([]balanceRow, error) {
+	return sqlList(ctx, r.db, balanceScanRows, SQLBalanceGetByRoomIDQuery, roomID, roomID, roomID)
+}
+
+// Access
+
+//go:embed sql/auth/principal_get_by_token_hash.sql
+var SQLPrincipalGetByTokenHashQuery string
+
+//go:embed sql/auth/room_is_owned.sql
+var SQLRoomIsOwnedQuery string
+
+//go:embed sql/auth/payment_is_owned.sql
+var SQLPaymentIsOwnedQuery string
+
+type sqlAccessRepo struct {
+	db *sql.DB
+}
+
+func (r sqlAccessRepo) Principal(ctx context.Context, tokenHash string) (Principal, error) {
+	var (
+		p        Principal
+		is_admin bool
+	)
+
+	err := r.db.QueryRowContext(ctx, SQLPrincipalGetByTokenHashQuery, tokenHash).Scan(&p.ClientID, &is_admin)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			err = ErrNotFound
+		}
+		return p, err
+	}
+
+	p.Role = RoleResident
+	if is_admin {
+		p.Role = RoleAdmin
+	}
+	return p, nil
+}
+
+func (r sqlAccessRepo) OwnsRoom(ctx context.Context, clientID, roomID int64, day time.Time) (ok bool, err error) {
+	err = r.db.QueryRowContext(ctx, SQLRoomIsOwnedQuery, roomID, clientID, clientID, day, day).Scan(&ok)
+	return ok, err
+}
+
+func (r sqlAccessRepo) OwnsPayment(ctx context.Context, clientID, paymentID int64, day time.Time) (ok bool, err error) {
+	err = r.db.QueryRowContext(ctx, SQLPaymentIsOwnedQuery, paymentID, clientID, clientID, clientID, day, day).Scan(&ok)
+	return ok, err
+}
@@ -0,0 +1,231 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"reflect"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	api_errors "github.com/snakehunterr/hacs_db_types/errors"
+	validators "github.com/snakehunterr/hacs_db_types/validators"
+)
+
+// Create and patch routes read their parameters from a form or, with
+// Content-Type application/json, from a JSON object with the same field
+// names. Patch routes also take a JSON Merge Patch (RFC 7396), Content-Type
+// application/merge-patch+json. The body is bound to a request struct whose
+// fields are named by their json tag and parsed by the validator of their
+// type, so every encoding accepts the same values: JSON numbers and bools may
+// also be sent as strings, and null is the same as a missing field, except in
+// a merge patch, where it clears the field.
+//
+// The bind tag of a field holds comma-separated options:
+//
+//	required   the field must be set
+//	keepempty  an empty string is a value, not a missing field
+//	period     a time.Time in PERIOD_FORMAT instead of DATE_FORMAT
+//	day        a time.Time in DAY_FORMAT instead of DATE_FORMAT
+//	currency   a string holding an ISO 4217 currency code
+//	nullable   a null of a merge patch sets the field to its zero value; a
+//	           null for any other field is rejected
+//
+// Pointer fields are left nil when the field is missing, so patch routes can
+// tell it from a zero value.
+
+// ValidationResponse lists every invalid parameter of a request. Error is the
+// first of them, for clients that only read one.
+type ValidationResponse struct {
+	Error  *api_errors.APIError   `json:"error"`
+	Errors []*api_errors.APIError `json:"errors"`
+}
+
+// paramErrors collects the invalid parameters of a request.
+type paramErrors []*api_errors.APIError
+
+func (e *paramErrors) add(apierrs ...*api_errors.APIError) {
+	for _, apierr := range apierrs {
+		if apierr != nil {
+			*e = append(*e, apierr)
+		}
+	}
+}
+
+// respond answers with the collected errors and reports whether there were
+// any.
+func (e paramErrors) respond(g *gin.Context) bool {
+	if len(e) == 0 {
+		return false
+	}
+
+	g.JSON(http.StatusBadRequest, ValidationResponse{Error: e[0], Errors: e})
+	return true
+}
+
+const MIMEMergePatch = "application/merge-patch+json"
+
+var (
+	timeType  = reflect.TypeFor[time.Time]()
+	moneyType = reflect.TypeFor[Money]()
+	rateType  = reflect.TypeFor[ExchangeRate]()
+	priceType = reflect.TypeFor[UnitPrice]()
+	areaType  = reflect.TypeFor[Area]()
+	meterType = reflect.TypeFor[MeterValue]()
+	shareType = reflect.TypeFor[MemberShare]()
+)
+
+// bindRequest fills the request struct pointed to by req from the body of g
+// and returns the errors of every invalid field.
+func bindRequest(g *gin.Context, req any) (apierrs []*api_errors.APIError) {
+	var (
+		fields     map[string]json.RawMessage
+		mergePatch = g.ContentType() == MIMEMergePatch
+		isJSON     = g.ContentType() == binding.MIMEJSON || mergePatch
+	)
+
+	if isJSON {
+		err := json.NewDecoder(g.Request.Body).Decode(&fields)
+		if err != nil && !errors.Is(err, io.EOF) {
+			return []*api_errors.APIError{api_errors.NewErrIncorrectParam("body: not a JSON object")}
+		}
+	}
+
+	v := reflect.ValueOf(req).Elem()
+	for i := 0; i < v.NumField(); i++ {
+		var (
+			f          = v.Type().Field(i)
+			name, _, _ = strings.Cut(f.Tag.Get("json"), ",")
+			opts       = strings.Split(f.Tag.Get("bind"), ",")
+			t          = f.Type
+			value      string
+			ok         bool
+			apierr     *api_errors.APIError
+		)
+
+		if t.Kind() == reflect.Pointer {
+			t = t.Elem()
+		}
+
+		if mergePatch && string(fields[name]) == "null" {
+			if !slices.Contains(opts, "nullable") {
+				apierrs = append(apierrs, api_errors.NewErrIncorrectParam(name+": can't be cleared"))
+				continue
+			}
+
+			if f.Type.Kind() == reflect.Pointer {
+				v.Field(i).Set(reflect.New(t))
+			}
+			continue
+		}
+
+		if isJSON {
+			value, ok, apierr = jsonField(fields, name, t.Kind() == reflect.String)
+		} else {
+			value, ok = g.GetPostForm(name)
+		}
+		if apierr != nil {
+			apierrs = append(apierrs, apierr)
+			continue
+		}
+
+		if value == "" && !slices.Contains(opts, "keepempty") {
+			ok = false
+		}
+		if !ok {
+			if slices.Contains(opts, "required") {
+				apierrs = append(apierrs, api_errors.NewErrEmptyParam(name))
+			}
+			continue
+		}
+
+		parsed, apierr := parseField(name, value, t, opts)
+		if apierr != nil {
+			apierrs = append(apierrs, apierr)
+			continue
+		}
+
+		if f.Type.Kind() == reflect.Pointer {
+			p := reflect.New(t)
+			p.Elem().Set(parsed)
+			parsed = p
+		}
+		v.Field(i).Set(parsed)
+	}
+
+	return apierrs
+}
+
+// jsonField returns a field of a JSON object as the text its validator
+// parses. Strings are unquoted; numbers and bools are kept as they are, and
+// only fit a field of type string when sent as strings.
+func jsonField(fields map[string]json.RawMessage, name string, isString bool) (string, bool, *api_errors.APIError) {
+	raw, ok := fields[name]
+	if !ok || string(raw) == "null" {
+		return "", false, nil
+	}
+
+	if raw[0] == '"' {
+		var s string
+		if err := json.Unmarshal(raw, &s); err != nil {
+			return "", false, api_errors.NewErrIncorrectParam(name)
+		}
+		return s, true, nil
+	}
+
+	if isString {
+		return "", false, api_errors.NewErrIncorrectParam(name)
+	}
+	return string(raw), true, nil
+}
+
+func parseField(name, value string, t reflect.Type, opts []string) (reflect.Value, *api_errors.APIError) {
+	var (
+		v      any
+		apierr *api_errors.APIError
+	)
+
+	switch {
+	case t == timeType && slices.Contains(opts, "period"):
+		v, apierr = validatePeriod(name, value, false)
+	case t == timeType && slices.Contains(opts, "day"):
+		v, apierr = validateDay(name, value, false)
+	case t == timeType:
+		v, apierr = validators.Date(name, value, false)
+	case t == moneyType:
+		v, apierr = validateMoney(name, value, false)
+	case t == rateType:
+		v, apierr = validateExchangeRate(name, value, false)
+	case t == priceType:
+		v, apierr = validateUnitPrice(name, value, false)
+	case t == areaType:
+		v, apierr = validateArea(name, value, false)
+	case t == meterType:
+		v, apierr = validateMeterValue(name, value, false)
+	case t == shareType:
+		v, apierr = validateMemberShare(name, value, false)
+	case t.Kind() == reflect.String && slices.Contains(opts, "currency"):
+		v, apierr = validateCurrency(name, value, false)
+	case t.Kind() == reflect.String:
+		v = value
+	case t.Kind() == reflect.Bool:
+		v, apierr = validators.Bool(name, value, false)
+	case t.Kind() == reflect.Int64:
+		v, apierr = validators.Int64(name, value, false)
+	case t.Kind() == reflect.Uint8:
+		v, apierr = validators.Uint8(name, value, false)
+	case t.Kind() == reflect.Float64:
+		v, apierr = validators.Float64(name, value, false)
+	default:
+		panic("bindRequest: unsupported field type " + t.String() + " of " + name)
+	}
+
+	if apierr != nil {
+		return reflect.Value{}, apierr
+	}
+	return reflect.ValueOf(v).Convert(t), nil
+}
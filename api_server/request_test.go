@@ -0,0 +1,192 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+)
+
+// bindTestRequest has a field of each bind option.
+type bindTestRequest struct {
+	Name     string    `json:"name" bind:"required"`
+	Note     *string   `json:"note" bind:"keepempty,nullable"`
+	Amount   Money     `json:"amount" bind:"required"`
+	Period   time.Time `json:"period" bind:"period"`
+	Day      time.Time `json:"day" bind:"day,required"`
+	Currency string    `json:"currency" bind:"currency"`
+	Count    *int64    `json:"count"`
+}
+
+// bind binds body, of Content-Type contentType, to a bindTestRequest and
+// answers with its errors like a route does.
+func bind(contentType, body string) (bindTestRequest, *httptest.ResponseRecorder) {
+	w := httptest.NewRecorder()
+	g, _ := gin.CreateTestContext(w)
+	g.Request = httptest.NewRequest("POST", "/", strings.NewReader(body))
+	g.Request.Header.Set("Content-Type", contentType)
+
+	var (
+		errs paramErrors
+		req  bindTestRequest
+	)
+	errs.add(bindRequest(g, &req)...)
+	if !errs.respond(g) {
+		g.Status(http.StatusOK)
+	}
+	return req, w
+}
+
+func TestBindRequest(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	const form = binding.MIMEPOSTForm
+
+	tests := []struct {
+		name        string
+		contentType string
+		body        string
+		errs        []string
+	}{
+		{
+			"valid form", form,
+			"name=a&amount=1.50&period=2025-02&day=2025-02-10&currency=EUR&count=3",
+			nil,
+		},
+		{
+			"valid JSON", binding.MIMEJSON,
+			`{"name": "a", "amount": 1.50, "period": "2025-02", "day": "2025-02-10", "currency": "EUR", "count": "3"}`,
+			nil,
+		},
+		{
+			"missing", form,
+			"period=2025-02",
+			[]string{"missing param: name", "missing param: amount", "missing param: day"},
+		},
+		{
+			"null is missing", binding.MIMEJSON,
+			`{"name": null, "amount": null, "day": null}`,
+			[]string{"missing param: name", "missing param: amount", "missing param: day"},
+		},
+		{
+			"invalid form", form,
+			"amount=1.505&period=2025-02-10&day=2025-02&currency=euro&count=x",
+			[]string{
+				"missing param: name",
+				"incorrect param: amount: not a non-negative amount with at most 2 decimals",
+				"incorrect param: period",
+				"incorrect param: day",
+				"incorrect param: currency: not an ISO 4217 currency code",
+				"incorrect param: count",
+			},
+		},
+		{
+			"invalid JSON", binding.MIMEJSON,
+			`{"name": 5, "amount": "x", "period": "2025-13", "day": "2025-02-30", "currency": 978}`,
+			[]string{
+				"incorrect param: name",
+				"incorrect param: amount: not a non-negative amount with at most 2 decimals",
+				"incorrect param: period",
+				"incorrect param: day",
+				"incorrect param: currency",
+			},
+		},
+		{
+			"not an object", binding.MIMEJSON,
+			`["name"]`,
+			[]string{"incorrect param: body: not a JSON object"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, w := bind(tt.contentType, tt.body)
+			if tt.errs == nil {
+				if w.Code != http.StatusOK {
+					t.Fatalf("got %d, want 200: %s", w.Code, w.Body)
+				}
+				return
+			}
+
+			if w.Code != http.StatusBadRequest {
+				t.Fatalf("got %d, want 400: %s", w.Code, w.Body)
+			}
+
+			var res ValidationResponse
+			decode(t, w, &res)
+
+			var errs []string
+			for _, apierr := range res.Errors {
+				errs = append(errs, apierr.Err)
+			}
+			if !slices.Equal(errs, tt.errs) {
+				t.Fatalf("errors = %q, want %q", errs, tt.errs)
+			}
+			if res.Error == nil || res.Error.Err != tt.errs[0] {
+				t.Fatalf("error = %+v, want the first of errors", res.Error)
+			}
+		})
+	}
+}
+
+func TestBindRequestValues(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	req, w := bind(binding.MIMEPOSTForm, url.Values{
+		"name":     {"a"},
+		"note":     {""},
+		"amount":   {"1.50"},
+		"period":   {"2025-02"},
+		"day":      {"2025-02-10"},
+		"currency": {"EUR"},
+	}.Encode())
+	if w.Code != http.StatusOK {
+		t.Fatalf("got %d, want 200: %s", w.Code, w.Body)
+	}
+
+	if req.Note == nil || *req.Note != "" {
+		t.Errorf("note = %v, want the empty string kept", req.Note)
+	}
+	if req.Amount.String() != "1.50" {
+		t.Errorf("amount = %s, want 1.50", req.Amount)
+	}
+	if !req.Period.Equal(time.Date(2025, 2, 1, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("period = %s, want 2025-02", req.Period)
+	}
+	if !req.Day.Equal(time.Date(2025, 2, 10, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("day = %s, want 2025-02-10", req.Day)
+	}
+	if req.Count != nil {
+		t.Errorf("count = %d, want nil", *req.Count)
+	}
+}
+
+func TestValidationResponse(t *testing.T) {
+	testBackends(t, func(t *testing.T, h http.Handler) {
+		var res ValidationResponse
+		call(t, h, "POST", "/api/tariff/new", url.Values{
+			"tariff_area_rate":      {"x"},
+			"tariff_fixed_fee":      {"10"},
+			"tariff_effective_from": {"2025-01-01"},
+		}, http.StatusBadRequest, &res)
+
+		var errs []string
+		for _, apierr := range res.Errors {
+			errs = append(errs, apierr.Err)
+		}
+		want := []string{
+			"incorrect param: tariff_area_rate: not a non-negative price with at most 4 decimals",
+			"missing param: tariff_people_rate",
+			"incorrect param: tariff_effective_from",
+		}
+		if !slices.Equal(errs, want) {
+			t.Fatalf("errors = %q, want %q", errs, want)
+		}
+	})
+}
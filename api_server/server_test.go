@@ -0,0 +1,856 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"maps"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	types "github.com/snakehunterr/hacs_db_types"
+)
+
+const testServiceToken = "test-service-token"
+
+// serve serves the routes of s, accepting testServiceToken as a service
+// token.
+func serve(t *testing.T, s *Server) http.Handler {
+	t.Helper()
+
+	gin.SetMode(gin.TestMode)
+
+	tokens := serviceTokens
+	serviceTokens = []string{testServiceToken}
+	t.Cleanup(func() { serviceTokens = tokens })
+
+	e := gin.New()
+	s.Routes(e.Group("/api"))
+	return e
+}
+
+// testBackends runs test against a memory server and against a SQL server
+// on a new SQLite database.
+func testBackends(t *testing.T, test func(t *testing.T, h http.Handler)) {
+	t.Run("memory", func(t *testing.T) { test(t, serve(t, NewMemoryServer())) })
+	t.Run("sqlite", func(t *testing.T) { test(t, serve(t, NewSQLServer(newSQLiteDB(t)))) })
+}
+
+// request sends a form to h with the bearer token and returns the response.
+// An empty token sends no Authorization header.
+func request(h http.Handler, token, method, target string, form url.Values) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, target, strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, req)
+	return w
+}
+
+// decode decodes the answer w into v.
+func decode(t *testing.T, w *httptest.ResponseRecorder, v any) {
+	t.Helper()
+
+	if err := json.Unmarshal(w.Body.Bytes(), v); err != nil {
+		t.Fatalf("%v: %s", err, w.Body)
+	}
+}
+
+// call sends a request as a service, fails t unless it is answered with
+// status and decodes the answer into v, if not nil.
+func call(t *testing.T, h http.Handler, method, target string, form url.Values, status int, v any) {
+	t.Helper()
+
+	w := request(h, testServiceToken, method, target, form)
+	if w.Code != status {
+		t.Fatalf("%s %s: got %d, want %d: %s", method, target, w.Code, status, w.Body)
+	}
+	if v != nil {
+		decode(t, w, v)
+	}
+}
+
+// seedRoom creates building 1 with room 10 of 50.5 m² and 2 people,
+// registered to client 1.
+func seedRoom(t *testing.T, h http.Handler) {
+	t.Helper()
+
+	call(t, h, "POST", "/api/building/new", url.Values{"building_name": {"Main"}}, http.StatusCreated, nil)
+	call(t, h, "POST", "/api/client/id/1", url.Values{
+		"client_name": {"Resident"},
+		"is_admin":    {"false"},
+	}, http.StatusCreated, nil)
+	call(t, h, "POST", "/api/room/id/10", url.Values{
+		"building_id":          {"1"},
+		"client_id":            {"1"},
+		"room_people_count":    {"2"},
+		"room_area":            {"50.50"},
+		"room_attributes_from": {"2025-01-01"},
+	}, http.StatusCreated, nil)
+}
+
+func TestRoomBalance(t *testing.T) {
+	testBackends(t, func(t *testing.T, h http.Handler) {
+		seedRoom(t, h)
+
+		call(t, h, "POST", "/api/tariff/new", url.Values{
+			"tariff_area_rate":      {"1.5"},
+			"tariff_people_rate":    {"100"},
+			"tariff_fixed_fee":      {"10"},
+			"tariff_effective_from": {"2025-01"},
+		}, http.StatusCreated, nil)
+
+		var generated ChargeGenerateResult
+		call(t, h, "POST", "/api/charge/generate", url.Values{"charge_period": {"2025-02"}}, http.StatusCreated, &generated)
+		if generated.Created != 1 {
+			t.Fatalf("generated %d charges, want 1", generated.Created)
+		}
+
+		// Generating a period again skips the rooms already charged.
+		call(t, h, "POST", "/api/charge/generate", url.Values{"charge_period": {"2025-02"}}, http.StatusCreated, &generated)
+		if generated.Created != 0 {
+			t.Fatalf("generated %d charges again, want 0", generated.Created)
+		}
+
+		var charges []Charge
+		call(t, h, "GET", "/api/charge/room/id/10", nil, http.StatusOK, &charges)
+		if len(charges) != 1 || charges[0].Amount.String() != "285.75" {
+			t.Fatalf("charges = %+v, want one of 285.75", charges)
+		}
+
+		call(t, h, "POST", "/api/payment/new", url.Values{
+			"client_id":      {"1"},
+			"room_id":        {"10"},
+			"payment_date":   {"2025-02-10 10:00:00"},
+			"payment_amount": {"100.00"},
+		}, http.StatusCreated, nil)
+
+		var b Balance
+		call(t, h, "GET", "/api/room/id/10/balance", nil, http.StatusOK, &b)
+		if len(b.Months) != 1 || b.Months[0].Period != "2025-02" {
+			t.Fatalf("months = %+v, want 2025-02 only", b.Months)
+		}
+		if got := b.ClosingBalance.String(); got != "185.75" {
+			t.Fatalf("closing balance = %s, want 185.75", got)
+		}
+	})
+}
+
+func TestExpenseShare(t *testing.T) {
+	testBackends(t, func(t *testing.T, h http.Handler) {
+		seedRoom(t, h)
+
+		call(t, h, "POST", "/api/expense/new", url.Values{
+			"building_id":    {"1"},
+			"expense_date":   {"2025-03-05 00:00:00"},
+			"expense_amount": {"30.00"},
+		}, http.StatusCreated, nil)
+
+		var share ExpenseShare
+		call(t, h, "POST", "/api/expense/id/1/share", url.Values{"share_rule": {"equal"}}, http.StatusCreated, &share)
+		if len(share.Allocations) != 1 || share.Allocations[0].Amount.String() != "30.00" {
+			t.Fatalf("allocations = %+v, want 30.00 to room 10", share.Allocations)
+		}
+		if share.LastEdited.IsZero() || share.Allocations[0].ID == 0 {
+			t.Fatalf("share = %+v, want the stored rows", share)
+		}
+
+		// The allocations hold for the amount and date only.
+		call(t, h, "PATCH", "/api/expense/id/1", url.Values{"expense_amount": {"40.00"}}, http.StatusConflict, nil)
+		call(t, h, "PATCH", "/api/expense/id/1", url.Values{"expense_date": {"2025-04-01 00:00:00"}}, http.StatusConflict, nil)
+		call(t, h, "PATCH", "/api/expense/id/1", url.Values{"expense_vendor": {"Water Co"}}, http.StatusOK, nil)
+
+		var as []ExpenseAllocation
+		call(t, h, "GET", "/api/expense/allocation/room/id/10", nil, http.StatusOK, &as)
+		if len(as) != 1 {
+			t.Fatalf("room allocations = %+v, want one", as)
+		}
+
+		call(t, h, "DELETE", "/api/expense/id/1/share", nil, http.StatusOK, nil)
+		call(t, h, "GET", "/api/expense/id/1/share", nil, http.StatusNotFound, nil)
+		call(t, h, "PATCH", "/api/expense/id/1", url.Values{"expense_amount": {"40.00"}}, http.StatusOK, nil)
+	})
+}
+
+func TestMeterReadings(t *testing.T) {
+	testBackends(t, func(t *testing.T, h http.Handler) {
+		seedRoom(t, h)
+
+		meter := url.Values{"room_id": {"10"}, "meter_kind": {"cold_water"}, "meter_serial": {"CW-1"}}
+		call(t, h, "POST", "/api/meter/new", meter, http.StatusCreated, nil)
+		call(t, h, "POST", "/api/meter/new", meter, http.StatusConflict, nil)
+
+		reading := func(date, value string, status int) {
+			t.Helper()
+			call(t, h, "POST", "/api/meter/id/1/reading", url.Values{
+				"reading_date":  {date},
+				"reading_value": {value},
+			}, status, nil)
+		}
+		reading("2025-01-10 00:00:00", "10", http.StatusCreated)
+		reading("2025-02-10 00:00:00", "12.5", http.StatusCreated)
+		reading("2025-03-10 00:00:00", "5", http.StatusBadRequest)
+		reading("2025-02-01 00:00:00", "20", http.StatusBadRequest)
+
+		// The last reading repeated on the same day is not stored again.
+		reading("2025-02-10 00:00:00", "12.5", http.StatusOK)
+		reading("2025-02-10 18:30:00", "12.500", http.StatusOK)
+
+		var rs []MeterReading
+		call(t, h, "GET", "/api/meter/id/1/readings", nil, http.StatusOK, &rs)
+		if len(rs) != 2 || rs[1].Consumption.String() != "2.500" {
+			t.Fatalf("readings = %+v, want 2 with a consumption of 2.5", rs)
+		}
+
+		call(t, h, "DELETE", "/api/meter/id/1", nil, http.StatusOK, nil)
+		call(t, h, "GET", "/api/meter/id/1/readings", nil, http.StatusNotFound, nil)
+	})
+}
+
+func TestMeterValues(t *testing.T) {
+	testBackends(t, func(t *testing.T, h http.Handler) {
+		seedRoom(t, h)
+		call(t, h, "POST", "/api/meter/new", url.Values{"room_id": {"10"}, "meter_kind": {"cold_water"}, "meter_serial": {"CW-1"}}, http.StatusCreated, nil)
+
+		tests := []struct {
+			date        string
+			value       string
+			consumption string
+			abnormal    bool
+		}{
+			{"2025-01-01 00:00:00", "10.1", "0.000", false},
+			// Not 0.20000000000000107 as in floats.
+			{"2025-01-11 00:00:00", "10.3", "0.200", false},
+			// Exactly MeterAbnormalFactor times the average of 0.02 a day.
+			{"2025-01-21 00:00:00", "10.9", "0.600", false},
+			// Just over 3 times the average of 0.04 a day.
+			{"2025-01-31 00:00:00", "12.101", "1.201", true},
+		}
+		for _, tt := range tests {
+			var r MeterReading
+			call(t, h, "POST", "/api/meter/id/1/reading", url.Values{
+				"reading_date":  {tt.date},
+				"reading_value": {tt.value},
+			}, http.StatusCreated, &r)
+			if r.Consumption.String() != tt.consumption || r.Abnormal != tt.abnormal {
+				t.Fatalf("reading %s: consumption %s, abnormal %t, want %s, %t", tt.value, r.Consumption, r.Abnormal, tt.consumption, tt.abnormal)
+			}
+		}
+
+		call(t, h, "POST", "/api/meter/id/1/reading", url.Values{
+			"reading_date":  {"2025-02-10 00:00:00"},
+			"reading_value": {"12.1015"},
+		}, http.StatusBadRequest, nil)
+
+		var rs []MeterReading
+		call(t, h, "GET", "/api/meter/id/1/readings", nil, http.StatusOK, &rs)
+		if len(rs) != 4 || rs[1].Value.String() != "10.300" || rs[1].Consumption.String() != "0.200" {
+			t.Fatalf("readings = %+v, want 10.300 stored with a consumption of 0.200", rs)
+		}
+
+		var c MeterConsumption
+		call(t, h, "GET", "/api/meter/id/1/consumption/2025-01", nil, http.StatusOK, &c)
+		if c.StartValue.String() != "10.100" || c.EndValue.String() != "12.101" || c.Consumption.String() != "2.001" {
+			t.Fatalf("consumption = %+v, want 2.001 from 10.100 to 12.101", c)
+		}
+	})
+}
+
+func TestRates(t *testing.T) {
+	testBackends(t, func(t *testing.T, h http.Handler) {
+		rate := url.Values{"rate_currency": {"USD"}, "rate_date": {"2025-01-01"}, "rate_value": {"90.5"}}
+		call(t, h, "POST", "/api/rate/new", rate, http.StatusCreated, nil)
+		call(t, h, "POST", "/api/rate/new", rate, http.StatusConflict, nil)
+
+		var r Rate
+		call(t, h, "GET", "/api/rate/currency/USD/day/2025-06-01", nil, http.StatusOK, &r)
+		if r.Value.String() != "90.500000" {
+			t.Fatalf("rate = %s, want 90.500000", r.Value)
+		}
+		call(t, h, "GET", "/api/rate/currency/USD/day/2024-12-31", nil, http.StatusNotFound, nil)
+	})
+}
+
+func TestBotState(t *testing.T) {
+	testBackends(t, func(t *testing.T, h http.Handler) {
+		call(t, h, "POST", "/api/bot/state/chat-1", url.Values{"state_value": {"menu"}}, http.StatusOK, nil)
+
+		var state BotState
+		call(t, h, "GET", "/api/bot/state/chat-1", nil, http.StatusOK, &state)
+		if state.Value != "menu" || state.Expires != nil {
+			t.Fatalf("state = %+v, want menu without expiry", state)
+		}
+
+		call(t, h, "DELETE", "/api/bot/state/chat-1", nil, http.StatusOK, nil)
+		call(t, h, "GET", "/api/bot/state/chat-1", nil, http.StatusNotFound, nil)
+	})
+}
+
+func TestClientToken(t *testing.T) {
+	testBackends(t, func(t *testing.T, h http.Handler) {
+		seedRoom(t, h)
+
+		var token APIToken
+		call(t, h, "POST", "/api/token/new", url.Values{"client_id": {"1"}}, http.StatusCreated, &token)
+		if token.Token == "" {
+			t.Fatal("token value not returned on create")
+		}
+
+		var tokens []APIToken
+		call(t, h, "GET", "/api/token/client/id/1", nil, http.StatusOK, &tokens)
+		if len(tokens) != 1 || tokens[0].Token != "" {
+			t.Fatalf("tokens = %+v, want one without its value", tokens)
+		}
+
+		if w := request(h, token.Token, "GET", "/api/room/id/10", nil); w.Code != http.StatusOK {
+			t.Fatalf("resident GET own room: got %d: %s", w.Code, w.Body)
+		}
+
+		call(t, h, "DELETE", "/api/token/id/1", nil, http.StatusOK, nil)
+		if w := request(h, token.Token, "GET", "/api/room/id/10", nil); w.Code != http.StatusUnauthorized {
+			t.Fatalf("deleted token: got %d, want 401", w.Code)
+		}
+	})
+}
+
+func TestCurrency(t *testing.T) {
+	testBackends(t, func(t *testing.T, h http.Handler) {
+		call(t, h, "POST", "/api/building/new", url.Values{"building_name": {"Test"}, "building_currency": {"XXX"}}, http.StatusBadRequest, nil)
+		call(t, h, "POST", "/api/rate/new", url.Values{"rate_currency": {"XTS"}, "rate_date": {"2025-01-01"}, "rate_value": {"1"}}, http.StatusBadRequest, nil)
+
+		call(t, h, "POST", "/api/building/new", url.Values{"building_name": {"Main"}, "building_currency": {"eur"}}, http.StatusCreated, nil)
+		call(t, h, "PATCH", "/api/building/id/1", url.Values{"building_currency": {"USD"}}, http.StatusConflict, nil)
+
+		var b Building
+		call(t, h, "PATCH", "/api/building/id/1", url.Values{"building_name": {"Lenina 5"}, "building_currency": {"EUR"}}, http.StatusOK, &b)
+		if b.Name != "Lenina 5" || b.Currency != "EUR" {
+			t.Fatalf("building = %+v, want Lenina 5 in EUR", b)
+		}
+
+		call(t, h, "POST", "/api/client/id/1", url.Values{"client_name": {"Resident"}, "is_admin": {"false"}}, http.StatusCreated, nil)
+		call(t, h, "POST", "/api/room/id/10", url.Values{
+			"building_id":       {"1"},
+			"client_id":         {"1"},
+			"room_people_count": {"1"},
+			"room_area":         {"30"},
+		}, http.StatusCreated, nil)
+		for currency, value := range map[string]string{"USD": "90", "EUR": "100"} {
+			call(t, h, "POST", "/api/rate/new", url.Values{"rate_currency": {currency}, "rate_date": {"2025-01-01"}, "rate_value": {value}}, http.StatusCreated, nil)
+		}
+
+		var p Payment
+		call(t, h, "POST", "/api/payment/new", url.Values{
+			"client_id":        {"1"},
+			"room_id":          {"10"},
+			"payment_date":     {"2025-02-10 10:00:00"},
+			"payment_amount":   {"10"},
+			"payment_currency": {"USD"},
+		}, http.StatusCreated, nil)
+		call(t, h, "GET", "/api/payment/id/1", nil, http.StatusOK, &p)
+		if p.Amount.String() != "9.00" || p.LedgerCurrency != "EUR" || p.Currency != "USD" || p.received().String() != "10.00" {
+			t.Fatalf("payment = %+v, want 9.00 EUR received as 10.00 USD", p)
+		}
+
+		// The payment only moves to rooms of buildings kept in EUR.
+		call(t, h, "POST", "/api/building/new", url.Values{"building_name": {"Rubles"}}, http.StatusCreated, nil)
+		call(t, h, "POST", "/api/building/new", url.Values{"building_name": {"Euros"}, "building_currency": {"EUR"}}, http.StatusCreated, nil)
+		for room, building := range map[string]string{"20": "2", "30": "3"} {
+			call(t, h, "POST", "/api/room/id/"+room, url.Values{
+				"building_id":       {building},
+				"client_id":         {"1"},
+				"room_people_count": {"1"},
+				"room_area":         {"30"},
+			}, http.StatusCreated, nil)
+		}
+		call(t, h, "PATCH", "/api/payment/id/1", url.Values{"room_id": {"20"}}, http.StatusConflict, nil)
+		call(t, h, "PATCH", "/api/payment/id/1", url.Values{"room_id": {"30"}}, http.StatusOK, &p)
+		if p.RoomID != 30 || p.Amount.String() != "9.00" {
+			t.Fatalf("payment = %+v, want 9.00 in room 30", p)
+		}
+	})
+}
+
+func TestLocation(t *testing.T) {
+	testBackends(t, func(t *testing.T, h http.Handler) {
+		creates := []struct {
+			target string
+			form   url.Values
+		}{
+			{"/api/building/new", url.Values{"building_name": {"Main"}}},
+			{"/api/client/id/1", url.Values{"client_name": {"Resident"}, "is_admin": {"false"}}},
+			{"/api/room/id/10", url.Values{"building_id": {"1"}, "client_id": {"1"}, "room_people_count": {"2"}, "room_area": {"50"}, "room_attributes_from": {"2025-01-01"}}},
+			{"/api/room/id/10/members", url.Values{"client_id": {"1"}, "member_role": {"tenant"}, "member_from": {"2025-01-01"}}},
+			{"/api/payment/new", url.Values{"client_id": {"1"}, "room_id": {"10"}, "payment_date": {"2025-02-10 10:00:00"}, "payment_amount": {"10"}}},
+			{"/api/expense/new", url.Values{"building_id": {"1"}, "expense_date": {"2025-02-05 00:00:00"}, "expense_amount": {"30"}}},
+			{"/api/expense/id/1/share", url.Values{"share_rule": {"equal"}}},
+			{"/api/tariff/new", url.Values{"tariff_area_rate": {"1"}, "tariff_people_rate": {"0"}, "tariff_fixed_fee": {"0"}, "tariff_effective_from": {"2025-01"}}},
+			{"/api/charge/generate", url.Values{"charge_period": {"2025-02"}}},
+			{"/api/meter/new", url.Values{"room_id": {"10"}, "meter_kind": {"cold_water"}, "meter_serial": {"CW-1"}}},
+			{"/api/meter/id/1/reading", url.Values{"reading_date": {"2025-01-10 00:00:00"}, "reading_value": {"10"}}},
+			{"/api/rate/new", url.Values{"rate_currency": {"USD"}, "rate_date": {"2025-01-01"}, "rate_value": {"90"}}},
+			{"/api/token/new", url.Values{"client_id": {"1"}}},
+		}
+
+		for _, c := range creates {
+			w := request(h, testServiceToken, "POST", c.target, c.form)
+			if w.Code != http.StatusCreated {
+				t.Fatalf("POST %s: got %d, want 201: %s", c.target, w.Code, w.Body)
+			}
+			call(t, h, "GET", w.Header().Get("Location"), nil, http.StatusOK, nil)
+		}
+	})
+}
+
+func TestPreconditionFailed(t *testing.T) {
+	testBackends(t, func(t *testing.T, h http.Handler) {
+		call(t, h, "POST", "/api/building/new", url.Values{"building_name": {"Main"}}, http.StatusCreated, nil)
+
+		req := httptest.NewRequest("PATCH", "/api/building/id/1", strings.NewReader("building_name=Other"))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		req.Header.Set("Authorization", "Bearer "+testServiceToken)
+		req.Header.Set("If-Match", `"stale"`)
+
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, req)
+		if w.Code != http.StatusPreconditionFailed {
+			t.Fatalf("got %d, want 412: %s", w.Code, w.Body)
+		}
+
+		var res types.APIResponse
+		decode(t, w, &res)
+		if res.Error == nil || res.Error.Code != ErrCodePreconditionFailed {
+			t.Fatalf("error = %+v, want code %d", res.Error, ErrCodePreconditionFailed)
+		}
+	})
+}
+
+func TestSoftDelete(t *testing.T) {
+	testBackends(t, func(t *testing.T, h http.Handler) {
+		seedRoom(t, h)
+		call(t, h, "POST", "/api/room/id/11", url.Values{
+			"building_id":       {"1"},
+			"client_id":         {"1"},
+			"room_people_count": {"1"},
+			"room_area":         {"30"},
+		}, http.StatusCreated, nil)
+		call(t, h, "POST", "/api/payment/new", url.Values{
+			"client_id":      {"1"},
+			"room_id":        {"10"},
+			"payment_date":   {"2025-02-10 10:00:00"},
+			"payment_amount": {"10"},
+		}, http.StatusCreated, nil)
+		call(t, h, "POST", "/api/expense/new", url.Values{
+			"building_id":    {"1"},
+			"expense_date":   {"2025-02-05 00:00:00"},
+			"expense_amount": {"30"},
+		}, http.StatusCreated, nil)
+
+		// rooms returns the IDs of the listed rooms.
+		rooms := func(query string) []int64 {
+			t.Helper()
+
+			var rs []Room
+			call(t, h, "GET", "/api/room/all"+query, nil, http.StatusOK, &rs)
+			ids := []int64{}
+			for _, r := range rs {
+				ids = append(ids, r.ID)
+			}
+			return ids
+		}
+
+		// Deleting a client deletes its rooms with it.
+		call(t, h, "DELETE", "/api/client/id/1", nil, http.StatusOK, nil)
+		call(t, h, "DELETE", "/api/client/id/1", nil, http.StatusNotFound, nil)
+		call(t, h, "GET", "/api/client/id/1", nil, http.StatusNotFound, nil)
+		call(t, h, "GET", "/api/room/id/10", nil, http.StatusNotFound, nil)
+		if got := rooms(""); len(got) != 0 {
+			t.Fatalf("rooms = %v, want none", got)
+		}
+		if got := rooms("?include_deleted=true"); len(got) != 2 {
+			t.Fatalf("rooms with deleted = %v, want 10 and 11", got)
+		}
+
+		var c Client
+		call(t, h, "GET", "/api/client/id/1?include_deleted=true", nil, http.StatusOK, &c)
+		if c.DeletedAt == nil {
+			t.Fatalf("client = %+v, want deleted_at", c)
+		}
+		var cs []Client
+		call(t, h, "GET", "/api/client/all", nil, http.StatusOK, &cs)
+		if len(cs) != 0 {
+			t.Fatalf("clients = %+v, want none", cs)
+		}
+
+		// A room is not restored without its client.
+		call(t, h, "POST", "/api/room/id/10/restore", nil, http.StatusNotFound, nil)
+
+		// Restoring the client restores the rooms deleted with it.
+		call(t, h, "POST", "/api/client/id/1/restore", nil, http.StatusOK, nil)
+		call(t, h, "POST", "/api/client/id/1/restore", nil, http.StatusNotFound, nil)
+		call(t, h, "GET", "/api/client/id/1", nil, http.StatusOK, nil)
+		if got := rooms(""); len(got) != 2 {
+			t.Fatalf("restored rooms = %v, want 10 and 11", got)
+		}
+
+		// A room is deleted and restored on its own.
+		call(t, h, "DELETE", "/api/room/id/11", nil, http.StatusOK, nil)
+		if got := rooms(""); len(got) != 1 || got[0] != 10 {
+			t.Fatalf("rooms = %v, want 10", got)
+		}
+		call(t, h, "POST", "/api/room/id/11/restore", nil, http.StatusOK, nil)
+		call(t, h, "GET", "/api/room/id/11", nil, http.StatusOK, nil)
+
+		for _, entity := range []string{"payment", "expense"} {
+			call(t, h, "DELETE", "/api/"+entity+"/id/1", nil, http.StatusOK, nil)
+			call(t, h, "GET", "/api/"+entity+"/id/1", nil, http.StatusNotFound, nil)
+			call(t, h, "GET", "/api/"+entity+"/id/1?include_deleted=true", nil, http.StatusOK, nil)
+
+			var rows []json.RawMessage
+			call(t, h, "GET", "/api/"+entity+"/all", nil, http.StatusOK, &rows)
+			if len(rows) != 0 {
+				t.Fatalf("%s list = %s, want none", entity, rows)
+			}
+			call(t, h, "GET", "/api/"+entity+"/all?include_deleted=true", nil, http.StatusOK, &rows)
+			if len(rows) != 1 {
+				t.Fatalf("%s list with deleted = %s, want one", entity, rows)
+			}
+
+			call(t, h, "POST", "/api/"+entity+"/id/1/restore", nil, http.StatusOK, nil)
+			call(t, h, "POST", "/api/"+entity+"/id/1/restore", nil, http.StatusNotFound, nil)
+			call(t, h, "GET", "/api/"+entity+"/id/1", nil, http.StatusOK, nil)
+		}
+	})
+}
+
+func TestPaymentIdempotency(t *testing.T) {
+	testBackends(t, func(t *testing.T, h http.Handler) {
+		seedRoom(t, h)
+
+		// post sends form with the Idempotency-Key key.
+		post := func(key string, form url.Values) *httptest.ResponseRecorder {
+			req := httptest.NewRequest("POST", "/api/payment/new", strings.NewReader(form.Encode()))
+			req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+			req.Header.Set("Authorization", "Bearer "+testServiceToken)
+			req.Header.Set("Idempotency-Key", key)
+
+			w := httptest.NewRecorder()
+			h.ServeHTTP(w, req)
+			return w
+		}
+
+		form := url.Values{
+			"client_id":      {"1"},
+			"room_id":        {"10"},
+			"payment_date":   {"2025-02-10 10:00:00"},
+			"payment_amount": {"10.00"},
+		}
+
+		w := post("tg-1", form)
+		if w.Code != http.StatusCreated {
+			t.Fatalf("got %d, want 201: %s", w.Code, w.Body)
+		}
+		var p Payment
+		decode(t, w, &p)
+
+		// Replaying the key answers with the stored payment.
+		w = post("tg-1", form)
+		if w.Code != http.StatusOK {
+			t.Fatalf("replay: got %d, want 200: %s", w.Code, w.Body)
+		}
+		var replayed Payment
+		decode(t, w, &replayed)
+		if replayed.ID != p.ID || replayed.ExternalID != "tg-1" || replayed.Amount.String() != "10.00" {
+			t.Fatalf("replayed = %+v, want %+v", replayed, p)
+		}
+
+		// So does the key sent as payment_external_id.
+		replay := url.Values{"payment_external_id": {"tg-1"}}
+		for k, v := range form {
+			replay[k] = v
+		}
+		call(t, h, "POST", "/api/payment/new", replay, http.StatusOK, &replayed)
+		if replayed.ID != p.ID {
+			t.Fatalf("replayed payment %d, want %d", replayed.ID, p.ID)
+		}
+
+		var ps []Payment
+		call(t, h, "GET", "/api/payment/all", nil, http.StatusOK, &ps)
+		if len(ps) != 1 {
+			t.Fatalf("payments = %+v, want one", ps)
+		}
+
+		// The same key with a different body is a conflict.
+		form.Set("payment_amount", "20.00")
+		w = post("tg-1", form)
+		if w.Code != http.StatusConflict {
+			t.Fatalf("different body: got %d, want 409: %s", w.Code, w.Body)
+		}
+		var res types.APIResponse
+		decode(t, w, &res)
+		if res.Error == nil || res.Error.Code != ErrCodeConflict {
+			t.Fatalf("error = %+v, want code %d", res.Error, ErrCodeConflict)
+		}
+
+		// The key and payment_external_id must agree.
+		form.Set("payment_external_id", "tg-2")
+		if w := post("tg-1", form); w.Code != http.StatusBadRequest {
+			t.Fatalf("different external ID: got %d, want 400: %s", w.Code, w.Body)
+		}
+	})
+}
+
+func TestMergePatch(t *testing.T) {
+	testBackends(t, func(t *testing.T, h http.Handler) {
+		seedRoom(t, h)
+		call(t, h, "POST", "/api/building/new", url.Values{
+			"building_name":    {"Other"},
+			"building_address": {"1 Main St"},
+			"building_contact": {"+1 555 0100"},
+		}, http.StatusCreated, nil)
+		call(t, h, "POST", "/api/client/id/2", url.Values{"client_name": {"Tenant"}, "is_admin": {"false"}}, http.StatusCreated, nil)
+
+		var m RoomMember
+		call(t, h, "POST", "/api/room/id/10/members", url.Values{
+			"client_id":    {"2"},
+			"member_role":  {"tenant"},
+			"member_from":  {"2025-01-01"},
+			"member_until": {"2025-07-01"},
+		}, http.StatusCreated, &m)
+
+		// patch sends body as a merge patch.
+		patch := func(target, body string) *httptest.ResponseRecorder {
+			req := httptest.NewRequest("PATCH", target, strings.NewReader(body))
+			req.Header.Set("Content-Type", MIMEMergePatch)
+			req.Header.Set("Authorization", "Bearer "+testServiceToken)
+
+			w := httptest.NewRecorder()
+			h.ServeHTTP(w, req)
+			return w
+		}
+
+		// null clears a nullable field and absent fields are kept.
+		w := patch("/api/building/id/2", `{"building_address": null}`)
+		if w.Code != http.StatusOK {
+			t.Fatalf("got %d, want 200: %s", w.Code, w.Body)
+		}
+		var b Building
+		decode(t, w, &b)
+		if b.Name != "Other" || b.Address != "" || b.Contact != "+1 555 0100" {
+			t.Fatalf("building = %+v, want the address cleared only", b)
+		}
+
+		w = patch(fmt.Sprintf("/api/room/member/id/%d", m.ID), `{"member_until": null}`)
+		if w.Code != http.StatusOK {
+			t.Fatalf("got %d, want 200: %s", w.Code, w.Body)
+		}
+		var patched RoomMember
+		decode(t, w, &patched)
+		if patched.Until != nil || patched.From == nil || patched.Role != "tenant" {
+			t.Fatalf("member = %+v, want member_until cleared only", patched)
+		}
+
+		// null is rejected for a field that can't be cleared, and the row
+		// is left unchanged.
+		for _, tt := range []struct{ target, body, field string }{
+			{"/api/building/id/2", `{"building_name": null, "building_contact": null}`, "building_name"},
+			{fmt.Sprintf("/api/room/member/id/%d", m.ID), `{"member_role": null}`, "member_role"},
+			{"/api/payment/id/1", `{"payment_amount": null}`, "payment_amount"},
+		} {
+			w := patch(tt.target, tt.body)
+			if w.Code != http.StatusBadRequest {
+				t.Fatalf("%s %s: got %d, want 400: %s", tt.target, tt.body, w.Code, w.Body)
+			}
+
+			var res ValidationResponse
+			decode(t, w, &res)
+			if want := "incorrect param: " + tt.field + ": can't be cleared"; len(res.Errors) != 1 || res.Errors[0].Err != want {
+				t.Fatalf("%s %s: errors = %+v, want %q", tt.target, tt.body, res.Errors, want)
+			}
+		}
+
+		call(t, h, "GET", "/api/building/id/2", nil, http.StatusOK, &b)
+		if b.Name != "Other" || b.Contact != "+1 555 0100" {
+			t.Fatalf("building = %+v, want it unchanged", b)
+		}
+
+		// Outside a merge patch, null is the same as an absent field.
+		req := httptest.NewRequest("PATCH", "/api/building/id/2", strings.NewReader(`{"building_contact": null}`))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Authorization", "Bearer "+testServiceToken)
+		w = httptest.NewRecorder()
+		h.ServeHTTP(w, req)
+		if w.Code != http.StatusOK {
+			t.Fatalf("got %d, want 200: %s", w.Code, w.Body)
+		}
+		decode(t, w, &b)
+		if b.Contact != "+1 555 0100" {
+			t.Fatalf("building = %+v, want the contact kept", b)
+		}
+	})
+}
+
+func TestMemberShares(t *testing.T) {
+	testBackends(t, func(t *testing.T, h http.Handler) {
+		seedRoom(t, h)
+		call(t, h, "POST", "/api/client/id/2", url.Values{"client_name": {"Second"}, "is_admin": {"false"}}, http.StatusCreated, nil)
+		call(t, h, "POST", "/api/client/id/3", url.Values{"client_name": {"Third"}, "is_admin": {"false"}}, http.StatusCreated, nil)
+
+		add := func(form url.Values, status int) (m RoomMember) {
+			t.Helper()
+			call(t, h, "POST", "/api/room/id/10/members", form, status, &m)
+			return m
+		}
+
+		second := add(url.Values{"client_id": {"2"}, "member_role": {"co-owner"}, "member_share": {"0.5"}}, http.StatusCreated)
+		if second.Share == nil || second.Share.String() != "0.5000" {
+			t.Fatalf("member_share = %v, want 0.5000", second.Share)
+		}
+		add(url.Values{"client_id": {"3"}, "member_role": {"co-owner"}, "member_share": {"0.5"}, "member_from": {"2025-01-01"}}, http.StatusCreated)
+
+		// The shares can't add up to more than 1 on any day.
+		add(url.Values{"client_id": {"3"}, "member_role": {"owner"}, "member_share": {"0.25"}, "member_from": {"2026-01-01"}}, http.StatusBadRequest)
+		add(url.Values{"client_id": {"1"}, "member_role": {"co-owner"}, "member_share": {"0.0001"}, "member_until": {"2025-01-01"}}, http.StatusCreated)
+
+		// A share freed by the end of a membership can be taken.
+		target := fmt.Sprintf("/api/room/member/id/%d", second.ID)
+		call(t, h, "PATCH", target, url.Values{"member_until": {"2026-01-01"}}, http.StatusOK, nil)
+		add(url.Values{"client_id": {"3"}, "member_role": {"owner"}, "member_share": {"0.25"}, "member_from": {"2026-01-01"}}, http.StatusCreated)
+
+		// Neither can a patch make them add up to more than 1.
+		call(t, h, "PATCH", target, url.Values{"member_until": {"2027-01-01"}}, http.StatusBadRequest, nil)
+		call(t, h, "PATCH", target, url.Values{"member_share": {"0.25"}, "member_until": {"2027-01-01"}}, http.StatusOK, nil)
+
+		// A client can't hold a role twice on a day.
+		add(url.Values{"client_id": {"2"}, "member_role": {"co-owner"}, "member_from": {"2026-06-01"}}, http.StatusConflict)
+
+		add(url.Values{"client_id": {"2"}, "member_role": {"owner"}, "member_share": {"0.00001"}}, http.StatusBadRequest)
+		add(url.Values{"client_id": {"2"}, "member_role": {"owner"}, "member_share": {"1.5"}}, http.StatusBadRequest)
+
+		var ms []RoomMember
+		call(t, h, "GET", "/api/room/id/10/members?day=2026-06-01", nil, http.StatusOK, &ms)
+		var total MemberShare
+		for _, m := range ms {
+			if m.Share != nil {
+				total += *m.Share
+			}
+		}
+		if total != memberShareWhole {
+			t.Fatalf("shares on 2026-06-01 add up to %s, want 1.0000", total)
+		}
+	})
+}
+
+// chargeAmounts generates the charges of period and returns their amounts
+// by room.
+func chargeAmounts(t *testing.T, h http.Handler, period string) map[int64]string {
+	t.Helper()
+
+	call(t, h, "POST", "/api/charge/generate", url.Values{"charge_period": {period}}, http.StatusCreated, nil)
+
+	var charges []Charge
+	w := request(h, testServiceToken, "GET", "/api/charge/period/"+period, nil)
+	switch w.Code {
+	case http.StatusOK:
+		decode(t, w, &charges)
+	case http.StatusNotFound:
+	default:
+		t.Fatalf("GET charges of %s: got %d: %s", period, w.Code, w.Body)
+	}
+
+	amounts := map[int64]string{}
+	for _, c := range charges {
+		amounts[c.RoomID] = c.Amount.String()
+	}
+	return amounts
+}
+
+func TestChargeAttributeHistory(t *testing.T) {
+	testBackends(t, func(t *testing.T, h http.Handler) {
+		seedRoom(t, h)
+		call(t, h, "POST", "/api/tariff/new", url.Values{
+			"tariff_area_rate":      {"1.5"},
+			"tariff_people_rate":    {"100"},
+			"tariff_fixed_fee":      {"10"},
+			"tariff_effective_from": {"2024-01"},
+		}, http.StatusCreated, nil)
+
+		// The room grows to 80 m² and 3 people from March, and a correction
+		// from mid February only applies from the next period on.
+		call(t, h, "PATCH", "/api/room/id/10", url.Values{
+			"room_area":            {"80"},
+			"room_people_count":    {"3"},
+			"room_attributes_from": {"2025-03-01"},
+		}, http.StatusOK, nil)
+		call(t, h, "PATCH", "/api/room/id/10", url.Values{
+			"room_area":            {"60"},
+			"room_attributes_from": {"2025-02-15"},
+		}, http.StatusOK, nil)
+
+		var r Room
+		call(t, h, "GET", "/api/room/id/10", nil, http.StatusOK, &r)
+		if r.Area.String() != "80.00" || r.PeopleCount != 3 {
+			t.Fatalf("room = %+v, want the latest 80 m² and 3 people", r)
+		}
+
+		tests := []struct {
+			period string
+			amount string
+		}{
+			{"2024-12", ""},
+			{"2025-04", "430.00"},
+			{"2025-01", "285.75"},
+			{"2025-02", "285.75"},
+			{"2025-03", "430.00"},
+		}
+		for _, tt := range tests {
+			if got := chargeAmounts(t, h, tt.period)[10]; got != tt.amount {
+				t.Errorf("charge of %s = %q, want %q", tt.period, got, tt.amount)
+			}
+		}
+	})
+}
+
+func TestBuildingTariff(t *testing.T) {
+	testBackends(t, func(t *testing.T, h http.Handler) {
+		seedRoom(t, h)
+		call(t, h, "POST", "/api/building/new", url.Values{"building_name": {"Other"}}, http.StatusCreated, nil)
+		call(t, h, "POST", "/api/room/id/20", url.Values{
+			"building_id":          {"2"},
+			"client_id":            {"1"},
+			"room_people_count":    {"2"},
+			"room_area":            {"50.50"},
+			"room_attributes_from": {"2025-01-01"},
+		}, http.StatusCreated, nil)
+
+		for _, form := range []url.Values{
+			// The fallback of both buildings.
+			{"tariff_area_rate": {"1.5"}, "tariff_people_rate": {"100"}, "tariff_fixed_fee": {"10"}, "tariff_effective_from": {"2025-01"}},
+			// The tariff of building 2 only.
+			{"building_id": {"2"}, "tariff_area_rate": {"2"}, "tariff_people_rate": {"0"}, "tariff_fixed_fee": {"0"}, "tariff_effective_from": {"2025-02"}},
+			// A newer fallback doesn't replace the tariff of building 2.
+			{"tariff_area_rate": {"1"}, "tariff_people_rate": {"0"}, "tariff_fixed_fee": {"0"}, "tariff_effective_from": {"2025-03"}},
+		} {
+			call(t, h, "POST", "/api/tariff/new", form, http.StatusCreated, nil)
+		}
+
+		tests := []struct {
+			period string
+			want   map[int64]string
+		}{
+			{"2025-01", map[int64]string{10: "285.75", 20: "285.75"}},
+			{"2025-02", map[int64]string{10: "285.75", 20: "101.00"}},
+			{"2025-03", map[int64]string{10: "50.50", 20: "101.00"}},
+		}
+		for _, tt := range tests {
+			if got := chargeAmounts(t, h, tt.period); !maps.Equal(got, tt.want) {
+				t.Errorf("charges of %s = %v, want %v", tt.period, got, tt.want)
+			}
+		}
+	})
+}
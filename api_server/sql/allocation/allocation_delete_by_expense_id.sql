@@ -0,0 +1,4 @@
+delete from
+    expense_allocation
+where
+    expense_id = ?
@@ -0,0 +1,6 @@
+select
+    *
+from
+    expense_allocation
+where
+    expense_id = ?
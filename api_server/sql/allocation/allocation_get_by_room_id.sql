@@ -0,0 +1,9 @@
+select
+    a.*
+from
+    expense_allocation as a
+    join expense as e on e.expense_id = a.expense_id
+where
+    a.room_id = ?
+    and
+    e.deleted_at is null
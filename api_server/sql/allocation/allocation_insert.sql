@@ -0,0 +1,4 @@
+insert into expense_allocation
+(expense_id, room_id, allocation_amount)
+values
+(?, ?, ?)
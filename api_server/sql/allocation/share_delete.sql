@@ -0,0 +1,4 @@
+delete from
+    expense_share
+where
+    expense_id = ?
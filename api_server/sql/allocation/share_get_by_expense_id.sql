@@ -0,0 +1,6 @@
+select
+    *
+from
+    expense_share
+where
+    expense_id = ?
@@ -0,0 +1,8 @@
+insert into expense_share
+(expense_id, share_rule, share_remainder)
+values
+(?, ?, ?)
+on duplicate key update
+    share_rule = values(share_rule),
+    share_remainder = values(share_remainder),
+    last_edited = now()
@@ -0,0 +1,23 @@
+select
+    *
+from
+    audit_log
+where
+    (? = '' or audit_entity = ?)
+    and
+    (? = '' or audit_entity_id = ?)
+    and
+    (? = '' or audit_action = ?)
+    and
+    (? = '' or actor_role = ?)
+    and
+    (? = 0 or actor_client_id = ?)
+    and
+    (? = '' or request_id = ?)
+    and
+    (? is null or audit_date >= ?)
+    and
+    (? is null or audit_date < ?)
+order by
+    audit_id desc
+limit ?
@@ -0,0 +1,10 @@
+insert into audit_log (
+    actor_role,
+    actor_client_id,
+    audit_action,
+    audit_entity,
+    audit_entity_id,
+    audit_before,
+    audit_after,
+    request_id
+) values (?, ?, ?, ?, ?, ?, ?, ?)
@@ -0,0 +1,23 @@
+select
+    count(*) > 0
+from
+    payment p
+    join room r on r.room_id = p.room_id
+where
+    p.payment_id = ?
+    and (
+        p.client_id = ?
+        or r.client_id = ?
+        or exists (
+            select
+                1
+            from
+                room_member m
+            where
+                m.room_id = r.room_id
+                and m.client_id = ?
+                and (m.member_from is null or m.member_from <= ?)
+                and (m.member_until is null or m.member_until > ?)
+        )
+    )
+    and p.deleted_at is null
@@ -0,0 +1,9 @@
+select
+    t.client_id,
+    c.is_admin
+from
+    api_token t
+    join client c on c.client_id = t.client_id
+where
+    t.token_hash = ?
+    and c.deleted_at is null
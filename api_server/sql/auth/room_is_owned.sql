@@ -0,0 +1,21 @@
+select
+    count(*) > 0
+from
+    room r
+where
+    r.room_id = ?
+    and r.deleted_at is null
+    and (
+        r.client_id = ?
+        or exists (
+            select
+                1
+            from
+                room_member m
+            where
+                m.room_id = r.room_id
+                and m.client_id = ?
+                and (m.member_from is null or m.member_from <= ?)
+                and (m.member_until is null or m.member_until > ?)
+        )
+    )
@@ -0,0 +1,46 @@
+select
+    b.period,
+    round(sum(b.charges), 2),
+    round(sum(b.expenses), 2),
+    round(sum(b.payments), 2)
+from
+    (
+        select
+            c.charge_period as period,
+            c.charge_amount as charges,
+            0 as expenses,
+            0 as payments
+        from
+            charge as c
+        where
+            c.room_id = ?
+        union all
+        select
+            cast(date_format(p.payment_date, '%Y-%m-01') as date) as period,
+            0 as charges,
+            0 as expenses,
+            p.payment_amount as payments
+        from
+            payment as p
+        where
+            p.room_id = ?
+            and
+            p.deleted_at is null
+        union all
+        select
+            cast(date_format(e.expense_date, '%Y-%m-01') as date) as period,
+            0 as charges,
+            a.allocation_amount as expenses,
+            0 as payments
+        from
+            expense_allocation as a
+            join expense as e on e.expense_id = a.expense_id
+        where
+            a.room_id = ?
+            and
+            e.deleted_at is null
+    ) as b
+group by
+    b.period
+order by
+    b.period
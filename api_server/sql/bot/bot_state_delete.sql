@@ -0,0 +1,4 @@
+delete from
+    bot_state
+where
+    state_key = ?
@@ -0,0 +1,8 @@
+select
+    *
+from
+    bot_state
+where
+    state_key = ?
+    and
+    (state_expires is null or state_expires > now())
@@ -0,0 +1,8 @@
+insert into bot_state
+(state_key, state_value, state_expires)
+values
+(?, ?, if(? > 0, date_add(now(), interval ? second), null))
+on duplicate key update
+    state_value = values(state_value),
+    state_expires = values(state_expires),
+    last_edited = now()
@@ -0,0 +1,4 @@
+delete from
+    building
+where
+    building_id = ?
@@ -0,0 +1,6 @@
+select
+    *
+from
+    building
+order by
+    building_id
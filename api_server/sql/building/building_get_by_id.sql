@@ -0,0 +1,6 @@
+select
+    *
+from
+    building
+where
+    building_id = ?
@@ -0,0 +1,4 @@
+insert into building
+(building_name, building_address, building_contact, building_currency)
+values
+(?, ?, ?, ?)
@@ -0,0 +1,6 @@
+select
+    exists (select 1 from room where building_id = ?)
+    or
+    exists (select 1 from expense where building_id = ?)
+    or
+    exists (select 1 from tariff where building_id = ?)
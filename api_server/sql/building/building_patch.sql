@@ -0,0 +1,9 @@
+update
+    building
+set
+    building_name = ?,
+    building_address = ?,
+    building_contact = ?,
+    last_edited = now()
+where
+    building_id = ?
@@ -0,0 +1,4 @@
+delete from
+    charge
+where
+    charge_id = ?
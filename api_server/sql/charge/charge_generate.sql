@@ -0,0 +1,47 @@
+insert into charge
+(room_id, tariff_id, charge_period, charge_area_amount, charge_people_amount, charge_fixed_amount, charge_amount)
+select
+    r.room_id,
+    t.tariff_id,
+    ?,
+    round(a.room_area * t.tariff_area_rate, 2),
+    round(a.room_people_count * t.tariff_people_rate, 2),
+    round(t.tariff_fixed_fee, 2),
+    round(a.room_area * t.tariff_area_rate, 2)
+        + round(a.room_people_count * t.tariff_people_rate, 2)
+        + round(t.tariff_fixed_fee, 2)
+from
+    room as r
+    join room_attribute as a on a.room_id = r.room_id and a.attribute_from = (
+        select
+            max(v.attribute_from)
+        from
+            room_attribute as v
+        where
+            v.room_id = r.room_id
+            and
+            v.attribute_from <= ?
+    ),
+    tariff as t
+where
+    t.tariff_id = coalesce(?, (
+        select
+            e.tariff_id
+        from
+            tariff as e
+        where
+            e.tariff_effective_from <= ?
+            and
+            (e.building_id is null or e.building_id = r.building_id)
+        order by
+            e.building_id is null,
+            e.tariff_effective_from desc,
+            e.tariff_id desc
+        limit 1
+    ))
+    and
+    (? = 0 or r.building_id = ?)
+    and
+    r.deleted_at is null
+on duplicate key update
+    charge_id = charge_id
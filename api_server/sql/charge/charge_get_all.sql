@@ -0,0 +1,6 @@
+select
+    *
+from
+    charge
+where
+    (? = 0 or room_id in (select room_id from room where building_id = ?))
@@ -0,0 +1,6 @@
+select
+    *
+from
+    charge
+where
+    charge_id = ?
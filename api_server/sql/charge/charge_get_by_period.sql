@@ -0,0 +1,8 @@
+select
+    *
+from
+    charge
+where
+    charge_period = ?
+    and
+    (? = 0 or room_id in (select room_id from room where building_id = ?))
@@ -1,4 +1,9 @@
-delete from
+update
     client
+set
+    deleted_at = ?,
+    last_edited = now()
 where
     client_id = ?
+    and
+    deleted_at is null
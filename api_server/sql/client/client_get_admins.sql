@@ -4,3 +4,5 @@ from
     client as c
 where
     c.is_admin = 1
+    and
+    (? or c.deleted_at is null)
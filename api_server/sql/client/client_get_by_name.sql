@@ -3,4 +3,6 @@ select
 from
     client
 where
-    client_name like concat('%', ?, '%');
+    client_name like concat('%', ?, '%')
+    and
+    (? or deleted_at is null)
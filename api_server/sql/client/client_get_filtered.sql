@@ -0,0 +1,12 @@
+select
+    *
+from
+    client
+where
+    (? = '' or client_name like concat('%', ?, '%'))
+    and
+    (? is null or is_admin = ?)
+    and
+    (? = 0 or client_id in (select client_id from room where building_id = ? and deleted_at is null))
+    and
+    (? or deleted_at is null)
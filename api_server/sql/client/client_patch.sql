@@ -6,3 +6,5 @@ set
     last_edited = now()
 where
     client_id = ?
+    and
+    deleted_at is null
@@ -1,4 +1,9 @@
-delete from
+update
     expense
+set
+    deleted_at = now(),
+    last_edited = now()
 where
     expense_id = ?
+    and
+    deleted_at is null
@@ -1,10 +0,0 @@
-select
-    *
-from
-    expense
-where
-    year(expense_date) = ?
-    and
-    month(expense_date) = ?
-    and
-    day(expense_date) = ?
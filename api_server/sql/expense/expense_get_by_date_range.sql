@@ -0,0 +1,10 @@
+select
+    *
+from
+    expense
+where
+    expense_date >= ?
+    and
+    expense_date < ?
+    and
+    (? or deleted_at is null)
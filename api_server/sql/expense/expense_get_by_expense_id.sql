@@ -4,3 +4,5 @@ from
     expense
 where
     expense_id = ?
+    and
+    (? or deleted_at is null)
@@ -0,0 +1,19 @@
+select
+    e.building_id,
+    b.building_currency,
+    e.expense_category,
+    count(*),
+    round(sum(e.expense_amount), 2)
+from
+    expense as e
+    join building as b on b.building_id = e.building_id
+where
+    (? = 0 or e.building_id = ?)
+    and
+    (? = '' or e.expense_vendor = ?)
+    and
+    e.deleted_at is null
+group by
+    e.building_id, b.building_currency, e.expense_category
+order by
+    e.building_id, e.expense_category
@@ -0,0 +1,16 @@
+select
+    *
+from
+    expense
+where
+    (? = 0 or building_id = ?)
+    and
+    (? = '' or expense_category = ?)
+    and
+    (? = '' or expense_vendor = ?)
+    and
+    (? is null or expense_amount >= ?)
+    and
+    (? is null or expense_amount <= ?)
+    and
+    (? or deleted_at is null)
@@ -1,4 +1,4 @@
 insert into expense
-(expense_date, expense_amount)
+(expense_date, expense_amount, expense_category, expense_vendor, expense_description, expense_document_ref, building_id)
 values
-(?, ?)
+(?, ?, ?, ?, ?, ?, ?)
@@ -2,6 +2,13 @@ update
     expense
 set
     expense_date = ?,
-    expense_amount = ?
+    expense_amount = ?,
+    expense_category = ?,
+    expense_vendor = ?,
+    expense_description = ?,
+    expense_document_ref = ?,
+    last_edited = now()
 where
     expense_id = ?
+    and
+    deleted_at is null
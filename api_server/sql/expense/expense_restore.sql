@@ -0,0 +1,9 @@
+update
+    expense
+set
+    deleted_at = null,
+    last_edited = now()
+where
+    expense_id = ?
+    and
+    deleted_at is not null
@@ -0,0 +1,4 @@
+delete from
+    room_member
+where
+    member_id = ?
@@ -0,0 +1,8 @@
+select
+    *
+from
+    room_member
+where
+    client_id = ?
+order by
+    room_id, member_id
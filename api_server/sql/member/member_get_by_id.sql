@@ -0,0 +1,6 @@
+select
+    *
+from
+    room_member
+where
+    member_id = ?
@@ -0,0 +1,8 @@
+select
+    *
+from
+    room_member
+where
+    room_id = ?
+order by
+    member_id
@@ -0,0 +1,9 @@
+select
+    *
+from
+    room_member
+where
+    room_id = ?
+order by
+    member_id
+for update
@@ -0,0 +1,4 @@
+insert into room_member
+(room_id, client_id, member_role, member_share, member_from, member_until)
+values
+(?, ?, ?, ?, ?, ?)
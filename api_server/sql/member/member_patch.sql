@@ -0,0 +1,10 @@
+update
+    room_member
+set
+    member_role = ?,
+    member_share = ?,
+    member_from = ?,
+    member_until = ?,
+    last_edited = now()
+where
+    member_id = ?
@@ -0,0 +1,4 @@
+delete from
+    meter
+where
+    meter_id = ?
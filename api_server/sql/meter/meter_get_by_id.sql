@@ -0,0 +1,6 @@
+select
+    *
+from
+    meter
+where
+    meter_id = ?
@@ -0,0 +1,7 @@
+select
+    *
+from
+    meter
+where
+    meter_id = ?
+for update
@@ -0,0 +1,4 @@
+insert into meter
+(room_id, meter_kind, meter_serial)
+values
+(?, ?, ?)
@@ -0,0 +1,4 @@
+delete from
+    meter_reading
+where
+    reading_id = ?
@@ -0,0 +1,6 @@
+select
+    *
+from
+    meter_reading
+where
+    reading_id = ?
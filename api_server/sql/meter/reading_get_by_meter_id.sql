@@ -0,0 +1,9 @@
+select
+    *
+from
+    meter_reading
+where
+    meter_id = ?
+order by
+    reading_date,
+    reading_id
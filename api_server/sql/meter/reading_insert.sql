@@ -0,0 +1,4 @@
+insert into meter_reading
+(meter_id, reading_date, reading_value, reading_consumption, reading_abnormal)
+values
+(?, ?, ?, ?, ?)
@@ -1,4 +1,9 @@
-delete from
+update
     payment
+set
+    deleted_at = now(),
+    last_edited = now()
 where
     payment_id = ?
+    and
+    deleted_at is null
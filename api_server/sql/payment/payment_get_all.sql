@@ -1,4 +0,0 @@
-select
-    *
-from
-    payment
@@ -1,10 +0,0 @@
-select
-    *
-from
-    payment
-where
-    year(payment_date) = ?
-    and
-    month(payment_date) = ?
-    and 
-    day(payment_date) = ?
@@ -0,0 +1,10 @@
+select
+    *
+from
+    payment
+where
+    payment_date >= ?
+    and
+    payment_date < ?
+    and
+    (? or deleted_at is null)
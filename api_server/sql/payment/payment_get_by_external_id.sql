@@ -0,0 +1,6 @@
+select
+    *
+from
+    payment
+where
+    payment_external_id = ?
@@ -4,3 +4,5 @@ from
     payment
 where
     payment_id = ?
+    and
+    (? or deleted_at is null)
@@ -0,0 +1,16 @@
+select
+    *
+from
+    payment
+where
+    (? = 0 or room_id in (select room_id from room where building_id = ?))
+    and
+    (? = 0 or client_id = ?)
+    and
+    (? = 0 or room_id = ?)
+    and
+    (? is null or payment_amount >= ?)
+    and
+    (? is null or payment_amount <= ?)
+    and
+    (? or deleted_at is null)
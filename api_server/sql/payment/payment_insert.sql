@@ -1,4 +1,4 @@
 insert into payment
-(client_id, room_id, payment_date, payment_amount)
+(client_id, room_id, payment_date, payment_amount, payment_provider_charge_id, payment_external_id, payment_currency, payment_original_amount, payment_exchange_rate, payment_ledger_currency)
 values
-(?, ?, ?, ?)
+(?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
@@ -5,6 +5,9 @@ set
     room_id = ?,
     payment_date = ?,
     payment_amount = ?,
+    payment_original_amount = ?,
     last_edited = now()
 where
     payment_id = ?
+    and
+    deleted_at is null
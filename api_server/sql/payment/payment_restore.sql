@@ -0,0 +1,9 @@
+update
+    payment
+set
+    deleted_at = null,
+    last_edited = now()
+where
+    payment_id = ?
+    and
+    deleted_at is not null
@@ -0,0 +1,4 @@
+delete from
+    exchange_rate
+where
+    rate_id = ?
@@ -0,0 +1,9 @@
+select
+    *
+from
+    exchange_rate
+where
+    (? = '' or rate_currency = ?)
+order by
+    rate_currency,
+    rate_date
@@ -0,0 +1,6 @@
+select
+    *
+from
+    exchange_rate
+where
+    rate_id = ?
@@ -0,0 +1,11 @@
+select
+    *
+from
+    exchange_rate
+where
+    rate_currency = ?
+    and
+    rate_date <= ?
+order by
+    rate_date desc
+limit 1
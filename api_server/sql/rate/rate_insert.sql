@@ -0,0 +1,4 @@
+insert into exchange_rate
+(rate_currency, rate_date, rate_value)
+values
+(?, ?, ?)
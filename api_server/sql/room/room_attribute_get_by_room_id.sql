@@ -0,0 +1,8 @@
+select
+    *
+from
+    room_attribute
+where
+    room_id = ?
+order by
+    attribute_from
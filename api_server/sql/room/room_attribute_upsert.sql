@@ -0,0 +1,8 @@
+insert into room_attribute
+(room_id, attribute_from, room_people_count, room_area)
+values
+(?, ?, ?, ?)
+on duplicate key update
+    room_people_count = values(room_people_count),
+    room_area = values(room_area),
+    last_edited = now()
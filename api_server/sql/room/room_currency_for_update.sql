@@ -0,0 +1,8 @@
+select
+    b.building_currency
+from
+    room r
+    join building b on b.building_id = r.building_id
+where
+    r.room_id = ?
+for update
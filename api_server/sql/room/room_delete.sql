@@ -1,4 +1,9 @@
-delete from
+update
     room
+set
+    deleted_at = now(),
+    last_edited = now()
 where
     room_id = ?
+    and
+    deleted_at is null
@@ -0,0 +1,9 @@
+update
+    room
+set
+    deleted_at = ?,
+    last_edited = now()
+where
+    client_id = ?
+    and
+    deleted_at is null
@@ -0,0 +1,8 @@
+select
+    *
+from
+    room
+where
+    building_id = ?
+    and
+    (? or deleted_at is null)
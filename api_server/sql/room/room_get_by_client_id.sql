@@ -4,3 +4,5 @@ from
     room
 where
     client_id = ?
+    and
+    (? or deleted_at is null)
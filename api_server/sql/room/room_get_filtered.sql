@@ -0,0 +1,14 @@
+select
+    *
+from
+    room
+where
+    (? = 0 or building_id = ?)
+    and
+    (? = 0 or client_id = ?)
+    and
+    (? is null or room_area >= ?)
+    and
+    (? is null or room_area <= ?)
+    and
+    (? or deleted_at is null)
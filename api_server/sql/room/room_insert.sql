@@ -1,4 +1,4 @@
 insert into room
-(room_id, client_id, room_people_count, room_area)
+(room_id, client_id, room_people_count, room_area, building_id, room_number, room_attributes_from)
 values
-(?, ?, ?, ?)
+(?, ?, ?, ?, ?, ?, ?)
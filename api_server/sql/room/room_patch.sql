@@ -2,8 +2,39 @@ update
     room
 set
     client_id = ?,
-    room_people_count = ?,
-    room_area = ?,
+    room_number = ?,
+    room_people_count = (
+        select
+            a.room_people_count
+        from
+            room_attribute as a
+        where
+            a.room_id = room.room_id
+        order by
+            a.attribute_from desc
+        limit 1
+    ),
+    room_area = (
+        select
+            a.room_area
+        from
+            room_attribute as a
+        where
+            a.room_id = room.room_id
+        order by
+            a.attribute_from desc
+        limit 1
+    ),
+    room_attributes_from = (
+        select
+            max(a.attribute_from)
+        from
+            room_attribute as a
+        where
+            a.room_id = room.room_id
+    ),
     last_edited = now()
 where
     room_id = ?
+    and
+    deleted_at is null
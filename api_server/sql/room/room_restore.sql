@@ -0,0 +1,11 @@
+update
+    room
+set
+    deleted_at = null,
+    last_edited = now()
+where
+    room_id = ?
+    and
+    deleted_at is not null
+    and
+    client_id in (select client_id from client where deleted_at is null)
@@ -0,0 +1,6 @@
+select
+    *
+from
+    tariff
+where
+    (? = 0 or building_id is null or building_id = ?)
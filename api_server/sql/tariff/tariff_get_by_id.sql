@@ -0,0 +1,6 @@
+select
+    *
+from
+    tariff
+where
+    tariff_id = ?
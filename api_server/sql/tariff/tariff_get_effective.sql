@@ -0,0 +1,13 @@
+select
+    *
+from
+    tariff
+where
+    tariff_effective_from <= ?
+    and
+    (building_id is null or building_id = ?)
+order by
+    building_id is null,
+    tariff_effective_from desc,
+    tariff_id desc
+limit 1
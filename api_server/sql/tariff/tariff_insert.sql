@@ -0,0 +1,4 @@
+insert into tariff
+(tariff_area_rate, tariff_people_rate, tariff_fixed_fee, tariff_effective_from, building_id)
+values
+(?, ?, ?, ?, ?)
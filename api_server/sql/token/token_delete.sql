@@ -0,0 +1 @@
+delete from api_token where token_id = ?
@@ -0,0 +1,9 @@
+select
+    token_id,
+    client_id,
+    token_name,
+    last_edited
+from
+    api_token
+where
+    token_id = ?
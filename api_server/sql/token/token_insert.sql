@@ -0,0 +1 @@
+insert into api_token (client_id, token_name, token_hash) values (?, ?, ?)
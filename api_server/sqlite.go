@@ -0,0 +1,302 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"modernc.org/sqlite"
+	sqlite3 "modernc.org/sqlite/lib"
+)
+
+// The SQLite backend serves local development and tests from a single
+// database file. It runs the same embedded queries and migrations as MySQL:
+// the driver below rewrites the MySQL-only syntax they use before SQLite
+// sees it, and registers the MySQL functions SQLite lacks. Times are stored
+// as UTC text in the format of current_timestamp, so they compare as strings.
+
+const sqliteDriverName = "hacs_sqlite"
+
+const sqliteTimeFormat = "2006-01-02 15:04:05"
+
+// SQLite extended result codes handled by the API.
+const (
+	sqliteErrConstraintUnique     = sqlite3.SQLITE_CONSTRAINT_UNIQUE
+	sqliteErrConstraintPrimaryKey = sqlite3.SQLITE_CONSTRAINT_PRIMARYKEY
+	sqliteErrConstraintForeignKey = sqlite3.SQLITE_CONSTRAINT_FOREIGNKEY
+)
+
+func init() {
+	// sql.Open does not connect: it only looks the driver up. Registered
+	// functions live in the modernc.org/sqlite driver instance.
+	base, err := sql.Open("sqlite", "")
+	if err != nil {
+		panic(err)
+	}
+	sql.Register(sqliteDriverName, sqliteDriver{base.Driver()})
+
+	sqlite.MustRegisterScalarFunction("now", 0, func(_ *sqlite.FunctionContext, _ []driver.Value) (driver.Value, error) {
+		return time.Now().UTC().Format(sqliteTimeFormat), nil
+	})
+
+	// A database file is served by one api_server, and SQLite serializes
+	// writers by itself: the named locks always succeed.
+	lock := func(_ *sqlite.FunctionContext, _ []driver.Value) (driver.Value, error) {
+		return int64(1), nil
+	}
+	sqlite.MustRegisterScalarFunction("get_lock", 2, lock)
+	sqlite.MustRegisterScalarFunction("release_lock", 1, lock)
+}
+
+func sqliteTime(v driver.Value) (time.Time, bool) {
+	switch v := v.(type) {
+	case time.Time:
+		return v, true
+	case string:
+		for _, layout := range []string{sqliteTimeFormat, "2006-01-02"} {
+			if len(v) >= len(layout) {
+				if t, err := time.Parse(layout, v[:len(layout)]); err == nil {
+					return t, true
+				}
+			}
+		}
+	}
+	return time.Time{}, false
+}
+
+// textTime scans a time that a query computes rather than reads from a
+// column. SQLite only knows the type of columns, so it returns those as text.
+type textTime time.Time
+
+func (t *textTime) Scan(v any) error {
+	if b, ok := v.([]byte); ok {
+		v = string(b)
+	}
+
+	tt, ok := sqliteTime(v)
+	if !ok {
+		return fmt.Errorf("unsupported time value: %v", v)
+	}
+	*t = textTime(tt)
+	return nil
+}
+
+// openSQLite opens the database file at path, creating it if needed.
+func openSQLite(path string) (*sql.DB, error) {
+	params := url.Values{}
+	params.Add("_pragma", "foreign_keys(1)")
+	params.Add("_pragma", "busy_timeout(5000)")
+	params.Add("_pragma", "journal_mode(wal)")
+	params.Set("_txlock", "immediate")
+
+	db, err := sql.Open(sqliteDriverName, fmt.Sprintf("file:%s?%s", path, params.Encode()))
+	if err != nil {
+		return nil, err
+	}
+
+	if err := db.Ping(); err != nil {
+		db.Close()
+		return nil, err
+	}
+	return db, nil
+}
+
+func sqlitePath() string {
+	if path := os.Getenv("DBAPI_SQLITE_PATH"); path != "" {
+		return path
+	}
+	return "hacs.db"
+}
+
+var (
+	sqliteForUpdateRe   = regexp.MustCompile(`(?i)\s+for\s+update\s*$`)
+	sqliteIfRe          = regexp.MustCompile(`(?i)\bif\(`)
+	sqliteDateAddRe     = regexp.MustCompile(`(?i)date_add\((.+?),\s*interval\s+\?\s+second\)`)
+	sqliteDateFormatRe  = regexp.MustCompile(`(?i)date_format\(([^,]+),\s*('[^']*')\)`)
+	sqliteCastDateRe    = regexp.MustCompile(`(?i)cast\((.+?)\s+as\s+date\)`)
+	sqliteUpsertRe      = regexp.MustCompile(`(?is)on\s+duplicate\s+key\s+update\s+(.*)$`)
+	sqliteUpsertNoopRe  = regexp.MustCompile(`^(\w+)\s*=\s*(\w+)\s*$`)
+	sqliteValuesRe      = regexp.MustCompile(`(?i)\bvalues\((\w+)\)`)
+	sqliteCreateTableRe = regexp.MustCompile(`(?is)^\s*create\s+table\s+(?:if\s+not\s+exists\s+)?(\w+)`)
+	sqliteAutoIncRe     = regexp.MustCompile(`(?i)\b(?:big)?int\s+not\s+null\s+auto_increment\b`)
+	sqliteEnumRe        = regexp.MustCompile(`(?i)\benum\([^)]*\)`)
+	sqliteUniqueKeyRe   = regexp.MustCompile(`(?i)\bunique\s+key\s*\(`)
+	sqliteKeyRe         = regexp.MustCompile(`(?i)^\s*key\s*\(([^)]*)\)\s*,?\s*$`)
+	sqliteForeignKeyRe  = regexp.MustCompile(`(?i)^\s*foreign\s+key\s*\(([^)]*)\)`)
+	sqliteDropIndexRe   = regexp.MustCompile(`(?is)^(\s*drop\s+index\s+\w+)\s+on\s+\w+\s*$`)
+	sqliteAlterSkipRe   = regexp.MustCompile(`(?is)^\s*alter\s+table\s+\w+\s+(?:modify|drop\s+foreign\s+key)\s`)
+	sqliteAddForeignRe  = regexp.MustCompile(`(?is)^(\s*alter\s+table\s+\w+\s+add\s+column\s+.+?),\s*add\s+constraint\s+\w+\s+foreign\s+key\s*\(\s*\w+\s*\)\s*(references\s.+)$`)
+)
+
+var sqliteQueries sync.Map
+
+// sqliteQuery translates a MySQL query of this repository to SQLite.
+func sqliteQuery(query string) string {
+	if q, ok := sqliteQueries.Load(query); ok {
+		return q.(string)
+	}
+
+	q := query
+	// SQLite only knows the affinity of a column, which a changed MySQL type
+	// doesn't need to follow. Its foreign keys are part of their column, so
+	// they are dropped with it and added inline with it.
+	if sqliteAlterSkipRe.MatchString(q) {
+		q = "select 1"
+	}
+	q = sqliteAddForeignRe.ReplaceAllString(q, "$1 $2")
+
+	if m := sqliteCreateTableRe.FindStringSubmatch(q); m != nil {
+		q = sqliteCreateTable(m[1], q)
+	}
+
+	q = sqliteDropIndexRe.ReplaceAllString(q, "$1")
+	q = sqliteForUpdateRe.ReplaceAllString(q, "")
+	q = sqliteIfRe.ReplaceAllString(q, "iif(")
+	q = sqliteDateAddRe.ReplaceAllString(q, "datetime($1, ? || ' seconds')")
+	q = sqliteDateFormatRe.ReplaceAllString(q, "strftime($2, $1)")
+	q = sqliteCastDateRe.ReplaceAllString(q, "datetime($1)")
+
+	if m := sqliteUpsertRe.FindStringSubmatchIndex(q); m != nil {
+		set := q[m[2]:m[3]]
+		if n := sqliteUpsertNoopRe.FindStringSubmatch(set); n != nil && n[1] == n[2] {
+			set = "on conflict do nothing"
+		} else {
+			set = "on conflict do update set " + sqliteValuesRe.ReplaceAllString(set, "excluded.$1")
+		}
+		q = q[:m[0]] + set
+	}
+
+	sqliteQueries.Store(query, q)
+	return q
+}
+
+// sqliteCreateTable rewrites the column types and keys of a create table
+// statement. Secondary keys become create index statements after it, and so
+// do foreign keys, which MySQL indexes by itself.
+func sqliteCreateTable(table, query string) string {
+	query = sqliteAutoIncRe.ReplaceAllString(query, "integer not null")
+	query = sqliteEnumRe.ReplaceAllString(query, "text")
+	query = sqliteUniqueKeyRe.ReplaceAllString(query, "unique (")
+
+	var (
+		lines   []string
+		indexes []string
+	)
+	index := func(keys string) {
+		var cols []string
+		for _, col := range strings.Split(keys, ",") {
+			cols = append(cols, strings.TrimSpace(col))
+		}
+		indexes = append(indexes, fmt.Sprintf(
+			"create index if not exists %s_%s_idx on %s (%s)",
+			table, strings.Join(cols, "_"), table, strings.Join(cols, ", "),
+		))
+	}
+
+	for _, line := range strings.Split(query, "\n") {
+		if m := sqliteKeyRe.FindStringSubmatch(line); m != nil {
+			index(m[1])
+			continue
+		}
+		if m := sqliteForeignKeyRe.FindStringSubmatch(line); m != nil {
+			index(m[1])
+		}
+		lines = append(lines, line)
+	}
+
+	// The last line before ")" may have lost the line that followed its comma.
+	for i := len(lines) - 1; i > 0; i-- {
+		if strings.HasPrefix(strings.TrimSpace(lines[i]), ")") {
+			lines[i-1] = strings.TrimSuffix(strings.TrimRight(lines[i-1], " \t"), ",")
+			break
+		}
+	}
+
+	return strings.Join(append([]string{strings.Join(lines, "\n")}, indexes...), ";\n")
+}
+
+func isSQLiteError(err error, codes ...int) bool {
+	var e *sqlite.Error
+	if !errors.As(err, &e) {
+		return false
+	}
+	for _, code := range codes {
+		if e.Code() == code {
+			return true
+		}
+	}
+	return false
+}
+
+// sqliteDriver wraps the modernc.org/sqlite driver with sqliteQuery and the
+// time format of the backend.
+type sqliteDriver struct {
+	base driver.Driver
+}
+
+func (d sqliteDriver) Open(name string) (driver.Conn, error) {
+	c, err := d.base.Open(name)
+	if err != nil {
+		return nil, err
+	}
+
+	inner, ok := c.(sqliteInnerConn)
+	if !ok {
+		c.Close()
+		return nil, errors.New("sqlite: unsupported connection type")
+	}
+	return sqliteConn{inner}, nil
+}
+
+type sqliteInnerConn interface {
+	driver.Conn
+	driver.ConnBeginTx
+	driver.ConnPrepareContext
+	driver.ExecerContext
+	driver.QueryerContext
+	driver.Pinger
+	driver.SessionResetter
+	driver.Validator
+}
+
+type sqliteConn struct {
+	sqliteInnerConn
+}
+
+func (c sqliteConn) Prepare(query string) (driver.Stmt, error) {
+	return c.sqliteInnerConn.Prepare(sqliteQuery(query))
+}
+
+func (c sqliteConn) PrepareContext(ctx context.Context, query string) (driver.Stmt, error) {
+	return c.sqliteInnerConn.PrepareContext(ctx, sqliteQuery(query))
+}
+
+func (c sqliteConn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	return c.sqliteInnerConn.ExecContext(ctx, sqliteQuery(query), args)
+}
+
+func (c sqliteConn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	return c.sqliteInnerConn.QueryContext(ctx, sqliteQuery(query), args)
+}
+
+// CheckNamedValue stores times as UTC text in sqliteTimeFormat.
+func (c sqliteConn) CheckNamedValue(nv *driver.NamedValue) error {
+	v, err := driver.DefaultParameterConverter.ConvertValue(nv.Value)
+	if err != nil {
+		return err
+	}
+
+	if t, ok := v.(time.Time); ok {
+		v = t.UTC().Format(sqliteTimeFormat)
+	}
+	nv.Value = v
+	return nil
+}
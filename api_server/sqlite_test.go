@@ -0,0 +1,222 @@
+package main
+
+import (
+	"database/sql"
+	"net/http"
+	"net/url"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// newSQLiteDB opens a new SQLite database with every migration applied.
+func newSQLiteDB(t *testing.T) *sql.DB {
+	t.Helper()
+
+	db, err := openSQLite(filepath.Join(t.TempDir(), "hacs.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	if err := autoMigrate(db); err != nil {
+		t.Fatal(err)
+	}
+	return db
+}
+
+func TestSQLiteQuery(t *testing.T) {
+	tests := []struct {
+		mysql  string
+		sqlite string
+	}{
+		{
+			"select * from meter where meter_id = ? for update",
+			"select * from meter where meter_id = ?",
+		},
+		{
+			"select if(? > 0, date_add(now(), interval ? second), null)",
+			"select iif(? > 0, datetime(now(), ? || ' seconds'), null)",
+		},
+		{
+			"select cast(date_format(p.payment_date, '%Y-%m-01') as date)",
+			"select datetime(strftime('%Y-%m-01', p.payment_date))",
+		},
+		{
+			"insert into t (k, v) values (?, ?) on duplicate key update v = values(v), last_edited = now()",
+			"insert into t (k, v) values (?, ?) on conflict do update set v = excluded.v, last_edited = now()",
+		},
+		{
+			"insert into t (k) values (?) on duplicate key update k = k",
+			"insert into t (k) values (?) on conflict do nothing",
+		},
+	}
+
+	for _, tt := range tests {
+		if got := sqliteQuery(tt.mysql); got != tt.sqlite {
+			t.Errorf("sqliteQuery(%q)\n got %q\nwant %q", tt.mysql, got, tt.sqlite)
+		}
+	}
+}
+
+// TestSQLiteUpsert covers the upserts of bot_state and expense_share, and
+// date_add, if and now of the bot state TTL.
+func TestSQLiteUpsert(t *testing.T) {
+	h := serve(t, NewSQLServer(newSQLiteDB(t)))
+
+	call(t, h, "POST", "/api/bot/state/chat-1", url.Values{"state_value": {"menu"}}, http.StatusOK, nil)
+	call(t, h, "POST", "/api/bot/state/chat-1", url.Values{
+		"state_value": {"payment"},
+		"state_ttl":   {"3600"},
+	}, http.StatusOK, nil)
+
+	var state BotState
+	call(t, h, "GET", "/api/bot/state/chat-1", nil, http.StatusOK, &state)
+	if state.Value != "payment" || state.Expires == nil {
+		t.Fatalf("state = %+v, want payment with an expiry", state)
+	}
+	if d := time.Until(*state.Expires); d < 3590*time.Second || d > 3600*time.Second {
+		t.Fatalf("state expires in %s, want an hour", d)
+	}
+
+	// A negative TTL is rejected.
+	call(t, h, "POST", "/api/bot/state/chat-1", url.Values{
+		"state_value": {"stale"},
+		"state_ttl":   {"-60"},
+	}, http.StatusBadRequest, nil)
+
+	seedRoom(t, h)
+	call(t, h, "POST", "/api/expense/new", url.Values{
+		"building_id":    {"1"},
+		"expense_date":   {"2025-03-05 00:00:00"},
+		"expense_amount": {"30.00"},
+	}, http.StatusCreated, nil)
+
+	for _, rule := range []string{"area", "equal"} {
+		var share ExpenseShare
+		call(t, h, "POST", "/api/expense/id/1/share", url.Values{"share_rule": {rule}}, http.StatusCreated, &share)
+		if share.Rule != rule || len(share.Allocations) != 1 {
+			t.Fatalf("share = %+v, want %s with one allocation", share, rule)
+		}
+	}
+}
+
+// TestSQLiteDates covers date_format and cast as date of the balance, which
+// sum payments and expenses per month of their date.
+func TestSQLiteDates(t *testing.T) {
+	h := serve(t, NewSQLServer(newSQLiteDB(t)))
+	seedRoom(t, h)
+
+	for _, date := range []string{"2025-01-31 23:59:59", "2025-02-01 00:00:00", "2025-02-28 12:00:00"} {
+		call(t, h, "POST", "/api/payment/new", url.Values{
+			"client_id":      {"1"},
+			"room_id":        {"10"},
+			"payment_date":   {date},
+			"payment_amount": {"10.00"},
+		}, http.StatusCreated, nil)
+	}
+
+	call(t, h, "POST", "/api/expense/new", url.Values{
+		"building_id":    {"1"},
+		"expense_date":   {"2025-02-15 08:00:00"},
+		"expense_amount": {"5.00"},
+	}, http.StatusCreated, nil)
+	call(t, h, "POST", "/api/expense/id/1/share", url.Values{"share_rule": {"equal"}}, http.StatusCreated, nil)
+
+	var b Balance
+	call(t, h, "GET", "/api/room/id/10/balance", nil, http.StatusOK, &b)
+
+	want := []BalanceMonth{
+		{Period: "2025-01", Payments: 1000, ClosingBalance: -1000},
+		{Period: "2025-02", OpeningBalance: -1000, Expenses: 500, Payments: 2000, ClosingBalance: -2500},
+	}
+	if len(b.Months) != len(want) {
+		t.Fatalf("months = %+v, want %+v", b.Months, want)
+	}
+	for i := range want {
+		if b.Months[i] != want[i] {
+			t.Errorf("month %d = %+v, want %+v", i, b.Months[i], want[i])
+		}
+	}
+}
+
+// TestSQLiteLimit covers limit in the pages of a list, the effective tariff
+// and rate, and the audit log.
+func TestSQLiteLimit(t *testing.T) {
+	h := serve(t, NewSQLServer(newSQLiteDB(t)))
+	seedRoom(t, h)
+
+	for _, date := range []string{"2025-01-10 00:00:00", "2025-02-10 00:00:00", "2025-03-10 00:00:00"} {
+		call(t, h, "POST", "/api/payment/new", url.Values{
+			"client_id":      {"1"},
+			"room_id":        {"10"},
+			"payment_date":   {date},
+			"payment_amount": {"10.00"},
+		}, http.StatusCreated, nil)
+	}
+
+	var (
+		ids    []int64
+		cursor string
+	)
+	for range 3 {
+		w := request(h, testServiceToken, "GET", "/api/payment/all?limit=2&sort=payment_date&order=desc&cursor="+cursor, nil)
+		if w.Code != http.StatusOK {
+			t.Fatalf("payment page: got %d: %s", w.Code, w.Body)
+		}
+
+		var ps []Payment
+		decode(t, w, &ps)
+		for _, p := range ps {
+			ids = append(ids, p.ID)
+		}
+		if w.Header().Get("X-Total-Count") != "3" {
+			t.Fatalf("X-Total-Count = %q, want 3", w.Header().Get("X-Total-Count"))
+		}
+
+		if cursor = w.Header().Get("X-Next-Cursor"); cursor == "" {
+			break
+		}
+	}
+	if len(ids) != 3 || ids[0] != 3 || ids[1] != 2 || ids[2] != 1 {
+		t.Fatalf("paged payment ids = %v, want [3 2 1]", ids)
+	}
+
+	for _, tariff := range []url.Values{
+		{"tariff_area_rate": {"1"}, "tariff_people_rate": {"0"}, "tariff_fixed_fee": {"0"}, "tariff_effective_from": {"2025-01"}},
+		{"tariff_area_rate": {"2"}, "tariff_people_rate": {"0"}, "tariff_fixed_fee": {"0"}, "tariff_effective_from": {"2025-03"}},
+		{"tariff_area_rate": {"3"}, "tariff_people_rate": {"0"}, "tariff_fixed_fee": {"0"}, "tariff_effective_from": {"2025-02"}, "building_id": {"1"}},
+	} {
+		call(t, h, "POST", "/api/tariff/new", tariff, http.StatusCreated, nil)
+	}
+
+	var tariff Tariff
+	call(t, h, "GET", "/api/tariff/period/2025-04?building_id=1", nil, http.StatusOK, &tariff)
+	if tariff.ID != 3 {
+		t.Fatalf("tariff of building 1 = %d, want its own tariff 3", tariff.ID)
+	}
+	call(t, h, "GET", "/api/tariff/period/2025-04", nil, http.StatusOK, &tariff)
+	if tariff.ID != 2 {
+		t.Fatalf("tariff of every building = %d, want the latest, 2", tariff.ID)
+	}
+
+	for _, date := range []string{"2025-01-01", "2025-02-01"} {
+		call(t, h, "POST", "/api/rate/new", url.Values{
+			"rate_currency": {"USD"},
+			"rate_date":     {date},
+			"rate_value":    {"90"},
+		}, http.StatusCreated, nil)
+	}
+
+	var rate Rate
+	call(t, h, "GET", "/api/rate/currency/USD/day/2025-01-31", nil, http.StatusOK, &rate)
+	if rate.ID != 1 {
+		t.Fatalf("rate on 2025-01-31 = %d, want 1", rate.ID)
+	}
+
+	var records []AuditRecord
+	call(t, h, "GET", "/api/audit?entity=payment&limit=2", nil, http.StatusOK, &records)
+	if len(records) != 2 || records[0].EntityID != "3" {
+		t.Fatalf("audit records = %+v, want the last 2 payments", records)
+	}
+}
@@ -0,0 +1,168 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	types "github.com/snakehunterr/hacs_dbapi_types"
+)
+
+// Routes of the DB API that api_client does not wrap yet are called directly.
+
+var dbapiURL = fmt.Sprintf(
+	"http://%s:%s/api",
+	os.Getenv("DBAPI_SERVER_HOST"),
+	os.Getenv("DBAPI_SERVER_PORT"),
+)
+
+// dbapiTransport signs requests to the DB API with DBAPI_TOKEN. It wraps
+// http.DefaultTransport, so requests made by api_client are signed as well.
+type dbapiTransport struct {
+	base  http.RoundTripper
+	host  string
+	token string
+}
+
+func (t dbapiTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.URL.Host == t.host && req.Header.Get("Authorization") == "" {
+		req = req.Clone(req.Context())
+		req.Header.Set("Authorization", "Bearer "+t.token)
+	}
+	return t.base.RoundTrip(req)
+}
+
+func useDBAPIToken(token string) {
+	http.DefaultTransport = dbapiTransport{
+		base:  http.DefaultTransport,
+		host:  net.JoinHostPort(os.Getenv("DBAPI_SERVER_HOST"), os.Getenv("DBAPI_SERVER_PORT")),
+		token: token,
+	}
+}
+
+type Meter struct {
+	ID     int64  `json:"meter_id"`
+	RoomID int64  `json:"room_id"`
+	Kind   string `json:"meter_kind"`
+	Serial string `json:"meter_serial"`
+}
+
+type MeterReading struct {
+	ID          int64      `json:"reading_id"`
+	MeterID     int64      `json:"meter_id"`
+	Date        time.Time  `json:"reading_date"`
+	Value       meterValue `json:"reading_value"`
+	Consumption meterValue `json:"reading_consumption"`
+	Abnormal    bool       `json:"reading_abnormal"`
+}
+
+// dbapiDo sends a form-encoded request and decodes the JSON answer into dst.
+// Error answers are returned as *api_errors.APIError.
+func dbapiDo(method, path string, form url.Values, dst any) error {
+	var body io.Reader
+	if form != nil {
+		body = strings.NewReader(form.Encode())
+	}
+
+	req, err := http.NewRequest(method, dbapiURL+path, body)
+	if err != nil {
+		return err
+	}
+	if form != nil {
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= http.StatusBadRequest {
+		var r types.APIResponse
+		if err := json.NewDecoder(resp.Body).Decode(&r); err != nil {
+			return fmt.Errorf("%s %s: %s", method, path, resp.Status)
+		}
+		if r.Error != nil {
+			return r.Error
+		}
+		return fmt.Errorf("%s %s: %s", method, path, resp.Status)
+	}
+
+	if dst == nil {
+		return nil
+	}
+	return json.NewDecoder(resp.Body).Decode(dst)
+}
+
+// ClientRoom is a room the client is a member of, with the roles of the
+// client in it. Pays is set for the rooms the client pays for. Only the
+// fields the bot needs are read.
+type ClientRoom struct {
+	ID    int64    `json:"room_id"`
+	Roles []string `json:"member_roles"`
+	Pays  bool     `json:"member_pays"`
+}
+
+func RoomGetByClientID(id int64) (rs []ClientRoom, err error) {
+	err = dbapiDo(http.MethodGet, fmt.Sprintf("/room/client/id/%d", id), nil, &rs)
+	return rs, err
+}
+
+func MeterGetByID(id int64) (m Meter, err error) {
+	err = dbapiDo(http.MethodGet, fmt.Sprintf("/meter/id/%d", id), nil, &m)
+	return m, err
+}
+
+func MeterGetByRoomID(id int64) (ms []Meter, err error) {
+	err = dbapiDo(http.MethodGet, fmt.Sprintf("/meter/room/id/%d", id), nil, &ms)
+	return ms, err
+}
+
+func MeterReadingGetAll(meterID int64) (rs []MeterReading, err error) {
+	err = dbapiDo(http.MethodGet, fmt.Sprintf("/meter/id/%d/readings", meterID), nil, &rs)
+	return rs, err
+}
+
+func MeterReadingCreate(meterID int64, value meterValue) (r MeterReading, err error) {
+	form := url.Values{}
+	form.Set("reading_value", value.String())
+
+	err = dbapiDo(http.MethodPost, fmt.Sprintf("/meter/id/%d/reading", meterID), form, &r)
+	return r, err
+}
+
+// Balance amounts are decimal strings with two fraction digits, e.g. "120.50",
+// in Currency, the currency of the building of the room.
+type Balance struct {
+	RoomID         int64       `json:"room_id"`
+	Currency       string      `json:"currency"`
+	ClosingBalance json.Number `json:"closing_balance"`
+}
+
+func RoomGetBalance(roomID int64) (b Balance, err error) {
+	err = dbapiDo(http.MethodGet, fmt.Sprintf("/room/id/%d/balance", roomID), nil, &b)
+	return b, err
+}
+
+// PaymentCreate records a payment of amount minor units of currency once per
+// externalID: the DB API answers a repeated request with the payment already
+// stored.
+func PaymentCreate(clientID, roomID int64, date time.Time, amount int, currency, providerChargeID, externalID string) error {
+	form := url.Values{}
+	form.Set("client_id", fmt.Sprint(clientID))
+	form.Set("room_id", fmt.Sprint(roomID))
+	form.Set("payment_date", date.UTC().Format("2006-01-02 15:04:05"))
+	form.Set("payment_amount", formatMinorUnits(amount, currency))
+	form.Set("payment_currency", currency)
+	form.Set("payment_provider_charge_id", providerChargeID)
+	form.Set("payment_external_id", externalID)
+
+	return dbapiDo(http.MethodPost, "/payment/new", form, nil)
+}
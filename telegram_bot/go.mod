@@ -1,4 +1,4 @@
-module main
+module github.com/snakehunterr/hacs_app/telegram_bot
 
 go 1.24.0
 
@@ -9,29 +9,54 @@ import (
 	telebot "github.com/go-telegram/bot"
 	"github.com/go-telegram/bot/models"
 	api_client "github.com/snakehunterr/hacs_dbapi_client"
-	types "github.com/snakehunterr/hacs_dbapi_types"
 	api_errors "github.com/snakehunterr/hacs_dbapi_types/errors"
 )
 
 var (
 	client *api_client.APIClient
-	csh    = make(ClientStateHandler)
-	ch     = make(map[int64]*types.Client)
+	csh    ClientStateHandler
+	ch     ClientCache
 )
 
 func main() {
 	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt, os.Kill)
 	defer cancel()
 
+	bot := prepare(botOptions())
+	bot.Start(ctx)
+}
+
+// botOptions returns the handlers of the bot and the Bot API server it talks
+// to.
+func botOptions() []telebot.Option {
 	opts := []telebot.Option{
 		telebot.WithDebug(),
+		telebot.WithDefaultHandler(DefaultHandler),
+		telebot.WithMessageTextHandler("/meter", telebot.MatchTypeExact, MeterCommandHandler),
+		telebot.WithCallbackQueryDataHandler(meterCallbackPrefix, telebot.MatchTypePrefix, MeterCallbackHandler),
+		telebot.WithMessageTextHandler("/pay", telebot.MatchTypeExact, PayCommandHandler),
+		telebot.WithCallbackQueryDataHandler(payCallbackPrefix, telebot.MatchTypePrefix, PayCallbackHandler),
 	}
 
-	bot := prepare(opts)
-	bot.Start(ctx)
+	// TELEBOT_SERVER_URL points the bot at another Bot API server, e.g. a
+	// fake one when testing payments.
+	if url := os.Getenv("TELEBOT_SERVER_URL"); url != "" {
+		opts = append(opts, telebot.WithServerURL(url))
+	}
+	return opts
 }
 
 func DefaultHandler(ctx context.Context, bot *telebot.Bot, update *models.Update) {
+	switch {
+	case update.PreCheckoutQuery != nil:
+		PreCheckoutHandler(ctx, bot, update)
+		return
+
+	case update.Message != nil && update.Message.SuccessfulPayment != nil:
+		SuccessfulPaymentHandler(ctx, bot, update)
+		return
+	}
+
 	var id int64
 	switch {
 	case update.Message != nil:
@@ -46,15 +71,26 @@ func DefaultHandler(ctx context.Context, bot *telebot.Bot, update *models.Update
 		EndClientRegistration(ctx, bot, id)
 	}
 
-	c, err := client.ClientGetByID(id)
-	if err != nil {
-		if api_errors.IsChildErr(err, api_errors.ErrSQLNoRows) {
-			StartClientRegistration(ctx, bot, id)
+	c, ok := ch.Get(id)
+	if !ok {
+		var err error
+
+		c, err = client.ClientGetByID(id)
+		if err != nil {
+			if api_errors.IsChildErr(err, api_errors.ErrSQLNoRows) {
+				StartClientRegistration(ctx, bot, id)
+				return
+			}
+
+			log.Println("ClientGetByID() err:", err)
+			SendError(ctx, bot, id)
 			return
 		}
+		ch.Set(id, c)
+	}
 
-		log.Println("ClientGetByID() err:", err)
-		SendError(ctx, bot, id)
+	if meterID, ok := MeterPromptID(update.Message); ok {
+		MeterValueEntered(ctx, bot, id, meterID, update.Message.Text)
 		return
 	}
 
@@ -76,13 +112,30 @@ func DefaultHandler(ctx context.Context, bot *telebot.Bot, update *models.Update
 	}
 }
 
+func sendText(ctx context.Context, bot *telebot.Bot, chatID int64, text string, markup models.ReplyMarkup) {
+	_, err := bot.SendMessage(ctx, &telebot.SendMessageParams{
+		ChatID:      chatID,
+		Text:        text,
+		ReplyMarkup: markup,
+	})
+	if err != nil {
+		log.Println("bot.SendMessage() err:", err)
+	}
+}
+
 func prepare(opts []telebot.Option) *telebot.Bot {
+	useDBAPIToken(os.Getenv("DBAPI_TOKEN"))
+
 	c := api_client.New(
 		os.Getenv("DBAPI_SERVER_HOST"),
 		os.Getenv("DBAPI_SERVER_PORT"),
 	)
 	client = &c
 
+	store := NewStateStore(os.Getenv("BOT_STATE_STORE"))
+	csh = ClientStateHandler{store: store}
+	ch = ClientCache{store: store}
+
 	bot, err := telebot.New(
 		os.Getenv("TELEBOT_KEY"),
 		opts...,
@@ -100,18 +153,3 @@ const (
 	StateRegisterClient
 	StateMainMenu
 )
-
-type ClientStateHandler map[int64]ClientState
-
-func (csh ClientStateHandler) Set(id int64, state ClientState) {
-	csh[id] = state
-}
-
-func (csh ClientStateHandler) Get(id int64) ClientState {
-	s, ok := csh[id]
-	if !ok {
-		csh[id] = NoState
-		return NoState
-	}
-	return s
-}
@@ -0,0 +1,410 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"regexp"
+	"strconv"
+	"strings"
+
+	telebot "github.com/go-telegram/bot"
+	"github.com/go-telegram/bot/models"
+	api_errors "github.com/snakehunterr/hacs_dbapi_types/errors"
+)
+
+// Meter reading submission keeps no state in the bot: every step carries the
+// room or meter it is about in its callback data, and the value prompt is a
+// force reply whose text names the meter. A flow started before a restart can
+// therefore be continued from any of its messages.
+
+const (
+	meterCallbackPrefix  = "meter:"
+	meterCallbackStart   = "meter:start"
+	meterCallbackRoom    = "meter:room:"
+	meterCallbackMeter   = "meter:meter:"
+	meterCallbackConfirm = "meter:confirm:"
+	meterCallbackCancel  = "meter:cancel"
+)
+
+var meterPromptRe = regexp.MustCompile(`^Meter #(\d+)`)
+
+var meterKindNames = map[string]string{
+	"cold_water":  "Cold water",
+	"hot_water":   "Hot water",
+	"electricity": "Electricity",
+	"gas":         "Gas",
+}
+
+func meterTitle(m Meter) string {
+	name, ok := meterKindNames[m.Kind]
+	if !ok {
+		name = m.Kind
+	}
+	return fmt.Sprintf("%s %s", name, m.Serial)
+}
+
+// meterValue is a meter value in thousandths, the precision the DB API keeps
+// meter values in.
+type meterValue int64
+
+// parseMeterValue parses a non-negative value with at most three fraction
+// digits, typed with a decimal point or comma.
+func parseMeterValue(s string) (meterValue, bool) {
+	whole, frac, _ := strings.Cut(strings.ReplaceAll(strings.TrimSpace(s), ",", "."), ".")
+	if whole == "" || len(frac) > 3 || strings.Trim(whole+frac, "0123456789") != "" {
+		return 0, false
+	}
+
+	v, err := strconv.ParseInt(whole+frac+strings.Repeat("0", 3-len(frac)), 10, 64)
+	return meterValue(v), err == nil
+}
+
+// String formats v without trailing zeros, e.g. "10.3" or "12".
+func (v meterValue) String() string {
+	s := fmt.Sprintf("%d.%03d", v/1000, v%1000)
+	return strings.TrimSuffix(strings.TrimRight(s, "0"), ".")
+}
+
+// UnmarshalJSON reads a value the DB API sends as a decimal string.
+func (v *meterValue) UnmarshalJSON(b []byte) error {
+	s := string(b)
+	if unquoted, err := strconv.Unquote(s); err == nil {
+		s = unquoted
+	}
+
+	parsed, ok := parseMeterValue(s)
+	if !ok {
+		return fmt.Errorf("invalid meter value: %s", b)
+	}
+	*v = parsed
+	return nil
+}
+
+func meterCancelButton() []models.InlineKeyboardButton {
+	return []models.InlineKeyboardButton{{Text: "Cancel", CallbackData: meterCallbackCancel}}
+}
+
+// meterOwned returns the meter if it belongs to one of the client's rooms.
+func meterOwned(clientID, meterID int64) (m Meter, ok bool, err error) {
+	m, err = MeterGetByID(meterID)
+	if err != nil {
+		if api_errors.IsChildErr(err, api_errors.ErrSQLNoRows) {
+			return m, false, nil
+		}
+		return m, false, err
+	}
+
+	ok, err = roomOwned(clientID, m.RoomID)
+	return m, ok, err
+}
+
+// clientRoom returns the room roomID if the client is a member of it.
+func clientRoom(clientID, roomID int64) (r ClientRoom, ok bool, err error) {
+	rs, err := RoomGetByClientID(clientID)
+	if err != nil {
+		if api_errors.IsChildErr(err, api_errors.ErrSQLNoRows) {
+			return r, false, nil
+		}
+		return r, false, err
+	}
+
+	for _, r := range rs {
+		if r.ID == roomID {
+			return r, true, nil
+		}
+	}
+	return r, false, nil
+}
+
+// roomOwned reports whether the client is a member of the room in any role,
+// e.g. a tenant who submits meter readings.
+func roomOwned(clientID, roomID int64) (bool, error) {
+	_, ok, err := clientRoom(clientID, roomID)
+	return ok, err
+}
+
+// roomPaid reports whether the client pays for the room.
+func roomPaid(clientID, roomID int64) (bool, error) {
+	r, ok, err := clientRoom(clientID, roomID)
+	return ok && r.Pays, err
+}
+
+func lastMeterReading(meterID int64) (r MeterReading, ok bool, err error) {
+	rs, err := MeterReadingGetAll(meterID)
+	if err != nil {
+		if api_errors.IsChildErr(err, api_errors.ErrSQLNoRows) {
+			return r, false, nil
+		}
+		return r, false, err
+	}
+	return rs[len(rs)-1], true, nil
+}
+
+// MeterCommandHandler starts the flow on /meter.
+func MeterCommandHandler(ctx context.Context, bot *telebot.Bot, update *models.Update) {
+	MeterStart(ctx, bot, update.Message.From.ID)
+}
+
+// MeterStart asks the client to pick one of their rooms.
+func MeterStart(ctx context.Context, bot *telebot.Bot, id int64) {
+	rs, err := RoomGetByClientID(id)
+	if err != nil {
+		if api_errors.IsChildErr(err, api_errors.ErrSQLNoRows) {
+			sendText(ctx, bot, id, "You have no rooms registered.", nil)
+			return
+		}
+
+		log.Println("RoomGetByClientID() err:", err)
+		SendError(ctx, bot, id)
+		return
+	}
+
+	var kb [][]models.InlineKeyboardButton
+	for _, r := range rs {
+		kb = append(kb, []models.InlineKeyboardButton{{
+			Text:         fmt.Sprintf("Room %d", r.ID),
+			CallbackData: fmt.Sprintf("%s%d", meterCallbackRoom, r.ID),
+		}})
+	}
+	kb = append(kb, meterCancelButton())
+
+	sendText(ctx, bot, id, "Choose a room:", &models.InlineKeyboardMarkup{InlineKeyboard: kb})
+}
+
+// MeterCallbackHandler dispatches the inline buttons of the flow.
+func MeterCallbackHandler(ctx context.Context, bot *telebot.Bot, update *models.Update) {
+	q := update.CallbackQuery
+	id := q.From.ID
+
+	if _, err := bot.AnswerCallbackQuery(ctx, &telebot.AnswerCallbackQueryParams{
+		CallbackQueryID: q.ID,
+	}); err != nil {
+		log.Println("bot.AnswerCallbackQuery() err:", err)
+	}
+
+	switch data := q.Data; {
+	case data == meterCallbackStart:
+		MeterStart(ctx, bot, id)
+
+	case data == meterCallbackCancel:
+		sendText(ctx, bot, id, "Meter reading cancelled.", nil)
+
+	case strings.HasPrefix(data, meterCallbackRoom):
+		roomID, err := strconv.ParseInt(strings.TrimPrefix(data, meterCallbackRoom), 10, 64)
+		if err != nil {
+			return
+		}
+		meterChooseMeter(ctx, bot, id, roomID)
+
+	case strings.HasPrefix(data, meterCallbackMeter):
+		meterID, err := strconv.ParseInt(strings.TrimPrefix(data, meterCallbackMeter), 10, 64)
+		if err != nil {
+			return
+		}
+		meterAskValue(ctx, bot, id, meterID)
+
+	case strings.HasPrefix(data, meterCallbackConfirm):
+		parts := strings.SplitN(strings.TrimPrefix(data, meterCallbackConfirm), ":", 2)
+		if len(parts) != 2 {
+			return
+		}
+		meterID, err := strconv.ParseInt(parts[0], 10, 64)
+		if err != nil {
+			return
+		}
+		value, ok := parseMeterValue(parts[1])
+		if !ok {
+			return
+		}
+
+		// The buttons go first, so a second tap can't submit the reading
+		// again; a tap that gets through anyway is deduplicated by the DB
+		// API.
+		removeKeyboard(ctx, bot, q.Message)
+		meterSubmit(ctx, bot, id, meterID, value)
+	}
+}
+
+// removeKeyboard removes the inline keyboard of msg.
+func removeKeyboard(ctx context.Context, bot *telebot.Bot, msg models.MaybeInaccessibleMessage) {
+	if msg.Message == nil {
+		return
+	}
+
+	if _, err := bot.EditMessageReplyMarkup(ctx, &telebot.EditMessageReplyMarkupParams{
+		ChatID:    msg.Message.Chat.ID,
+		MessageID: msg.Message.ID,
+	}); err != nil {
+		log.Println("bot.EditMessageReplyMarkup() err:", err)
+	}
+}
+
+func meterChooseMeter(ctx context.Context, bot *telebot.Bot, id, roomID int64) {
+	ok, err := roomOwned(id, roomID)
+	if err != nil {
+		log.Println("roomOwned() err:", err)
+		SendError(ctx, bot, id)
+		return
+	}
+	if !ok {
+		sendText(ctx, bot, id, "This room is not yours.", nil)
+		return
+	}
+
+	ms, err := MeterGetByRoomID(roomID)
+	if err != nil {
+		if api_errors.IsChildErr(err, api_errors.ErrSQLNoRows) {
+			sendText(ctx, bot, id, fmt.Sprintf("Room %d has no meters.", roomID), nil)
+			return
+		}
+
+		log.Println("MeterGetByRoomID() err:", err)
+		SendError(ctx, bot, id)
+		return
+	}
+
+	var kb [][]models.InlineKeyboardButton
+	for _, m := range ms {
+		kb = append(kb, []models.InlineKeyboardButton{{
+			Text:         meterTitle(m),
+			CallbackData: fmt.Sprintf("%s%d", meterCallbackMeter, m.ID),
+		}})
+	}
+	kb = append(kb, meterCancelButton())
+
+	sendText(ctx, bot, id, fmt.Sprintf("Room %d. Choose a meter:", roomID), &models.InlineKeyboardMarkup{InlineKeyboard: kb})
+}
+
+func meterAskValue(ctx context.Context, bot *telebot.Bot, id, meterID int64) {
+	m, ok, err := meterOwned(id, meterID)
+	if err != nil {
+		log.Println("meterOwned() err:", err)
+		SendError(ctx, bot, id)
+		return
+	}
+	if !ok {
+		sendText(ctx, bot, id, "This meter is not yours.", nil)
+		return
+	}
+
+	last, hasLast, err := lastMeterReading(meterID)
+	if err != nil {
+		log.Println("lastMeterReading() err:", err)
+		SendError(ctx, bot, id)
+		return
+	}
+
+	text := fmt.Sprintf("Meter #%d (%s)\n", m.ID, meterTitle(m))
+	if hasLast {
+		text += fmt.Sprintf("Previous reading: %s on %s\n", last.Value, last.Date.Format("2006-01-02"))
+	}
+	text += "Reply with the current value or send /cancel."
+
+	sendText(ctx, bot, id, text, &models.ForceReply{
+		ForceReply:            true,
+		InputFieldPlaceholder: "Current value",
+	})
+}
+
+// MeterPromptID returns the meter a message replies to, if it replies to a
+// value prompt of the flow.
+func MeterPromptID(msg *models.Message) (int64, bool) {
+	if msg == nil || msg.ReplyToMessage == nil {
+		return 0, false
+	}
+
+	match := meterPromptRe.FindStringSubmatch(msg.ReplyToMessage.Text)
+	if match == nil {
+		return 0, false
+	}
+
+	id, err := strconv.ParseInt(match[1], 10, 64)
+	return id, err == nil
+}
+
+// MeterValueEntered checks a typed value and asks to confirm it.
+func MeterValueEntered(ctx context.Context, bot *telebot.Bot, id, meterID int64, text string) {
+	text = strings.TrimSpace(text)
+	if text == "/cancel" {
+		sendText(ctx, bot, id, "Meter reading cancelled.", nil)
+		return
+	}
+
+	value, ok := parseMeterValue(text)
+	if !ok {
+		sendText(ctx, bot, id, "That is not a meter value with at most 3 decimals, try again.", nil)
+		meterAskValue(ctx, bot, id, meterID)
+		return
+	}
+
+	m, ok, err := meterOwned(id, meterID)
+	if err != nil {
+		log.Println("meterOwned() err:", err)
+		SendError(ctx, bot, id)
+		return
+	}
+	if !ok {
+		sendText(ctx, bot, id, "This meter is not yours.", nil)
+		return
+	}
+
+	last, hasLast, err := lastMeterReading(meterID)
+	if err != nil {
+		log.Println("lastMeterReading() err:", err)
+		SendError(ctx, bot, id)
+		return
+	}
+
+	confirm := fmt.Sprintf("%s (#%d)\nNew value: %s\n", meterTitle(m), m.ID, value)
+	if hasLast {
+		if value < last.Value {
+			sendText(ctx, bot, id, fmt.Sprintf("The value can't be less than the previous reading %s, try again.", last.Value), nil)
+			meterAskValue(ctx, bot, id, meterID)
+			return
+		}
+		confirm += fmt.Sprintf("Previous value: %s\nConsumption: %s\n", last.Value, value-last.Value)
+	}
+	confirm += "Submit?"
+
+	sendText(ctx, bot, id, confirm, &models.InlineKeyboardMarkup{
+		InlineKeyboard: [][]models.InlineKeyboardButton{
+			{{
+				Text:         "Confirm",
+				CallbackData: fmt.Sprintf("%s%d:%s", meterCallbackConfirm, meterID, value),
+			}},
+			meterCancelButton(),
+		},
+	})
+}
+
+func meterSubmit(ctx context.Context, bot *telebot.Bot, id, meterID int64, value meterValue) {
+	m, ok, err := meterOwned(id, meterID)
+	if err != nil {
+		log.Println("meterOwned() err:", err)
+		SendError(ctx, bot, id)
+		return
+	}
+	if !ok {
+		sendText(ctx, bot, id, "This meter is not yours.", nil)
+		return
+	}
+
+	r, err := MeterReadingCreate(meterID, value)
+	if err != nil {
+		if api_errors.IsChildErr(err, api_errors.ErrIncorrectParam) {
+			sendText(ctx, bot, id, "The reading was rejected: "+err.Error(), nil)
+			return
+		}
+
+		log.Println("MeterReadingCreate() err:", err)
+		SendError(ctx, bot, id)
+		return
+	}
+
+	text := fmt.Sprintf("%s: reading %s saved, consumption %s.", meterTitle(m), r.Value, r.Consumption)
+	if r.Abnormal {
+		text += "\nThe consumption is much higher than usual, please double-check the value."
+	}
+	sendText(ctx, bot, id, text, nil)
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/go-telegram/bot/models"
+)
+
+func TestMeterValue(t *testing.T) {
+	tests := []struct {
+		text   string
+		value  meterValue
+		format string
+	}{
+		{"10.3", 10300, "10.3"},
+		{"10,125", 10125, "10.125"},
+		{" 12 ", 12000, "12"},
+		{"0.200", 200, "0.2"},
+	}
+
+	for _, tt := range tests {
+		v, ok := parseMeterValue(tt.text)
+		if !ok || v != tt.value {
+			t.Errorf("parseMeterValue(%q) = %d, %t, want %d", tt.text, v, ok, tt.value)
+		}
+		if got := v.String(); got != tt.format {
+			t.Errorf("%d.String() = %s, want %s", v, got, tt.format)
+		}
+	}
+
+	for _, text := range []string{"", "-1", "1.0001", "1e3", ".5", "ten"} {
+		if v, ok := parseMeterValue(text); ok {
+			t.Errorf("parseMeterValue(%q) = %d, want an error", text, v)
+		}
+	}
+
+	// The consumption of 10.1 to 10.3 is exact.
+	var r MeterReading
+	if err := json.Unmarshal([]byte(`{"reading_value": "10.300", "reading_consumption": "0.200"}`), &r); err != nil {
+		t.Fatal(err)
+	}
+	if got := (r.Value - 10100).String(); got != "0.2" || r.Consumption.String() != "0.2" {
+		t.Errorf("consumption = %s and %s, want 0.2", got, r.Consumption)
+	}
+}
+
+func TestMeterSubmit(t *testing.T) {
+	api := &botAPI{}
+	bot := newTestBot(t, api, nil)
+
+	bot.ProcessUpdate(context.Background(), &models.Update{
+		CallbackQuery: &models.CallbackQuery{
+			ID:   "q1",
+			From: models.User{ID: 1},
+			Message: models.MaybeInaccessibleMessage{
+				Message: &models.Message{ID: 7, Chat: models.Chat{ID: 1, Type: models.ChatTypePrivate}},
+			},
+			Data: meterCallbackConfirm + "1:12.5",
+		},
+	})
+
+	edit := api.call(t, "editMessageReplyMarkup")
+	if edit.Get("message_id") != "7" || edit.Get("reply_markup") != "" {
+		t.Errorf("edit = %v, want the keyboard of message 7 removed", edit)
+	}
+
+	if got := api.call(t, "sendMessage").Get("text"); !strings.Contains(got, "reading 12.5 saved, consumption 0.2.") {
+		t.Errorf("message = %q, want the reading and its consumption", got)
+	}
+}
@@ -0,0 +1,315 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	telebot "github.com/go-telegram/bot"
+	"github.com/go-telegram/bot/models"
+)
+
+// Debts are paid with Telegram Payments through the provider set by
+// PAYMENT_KEY. The invoice payload names the room and the amount in minor
+// units, so pre-checkout and successful payment updates need no bot state.
+
+const (
+	payCallbackPrefix = "pay:"
+	payCallbackStart  = "pay:start"
+	payCallbackRoom   = "pay:room:"
+)
+
+// currencyExponents are the ISO 4217 minor unit exponents of the currencies
+// without two decimals. Telegram Payments takes amounts in minor units of the
+// currency, e.g. yen for JPY.
+var currencyExponents = map[string]int{
+	"BIF": 0, "CLP": 0, "DJF": 0, "GNF": 0, "ISK": 0, "JPY": 0, "KMF": 0, "KRW": 0,
+	"PYG": 0, "RWF": 0, "UGX": 0, "VND": 0, "VUV": 0, "XAF": 0, "XOF": 0, "XPF": 0,
+	"BHD": 3, "IQD": 3, "JOD": 3, "KWD": 3, "LYD": 3, "OMR": 3, "TND": 3,
+}
+
+func currencyExponent(currency string) int {
+	if exp, ok := currencyExponents[currency]; ok {
+		return exp
+	}
+	return 2
+}
+
+// toMinorUnits converts an amount of the DB API in currency to minor units.
+func toMinorUnits(v json.Number, currency string) (int, error) {
+	exp := currencyExponent(currency)
+
+	whole, frac, _ := strings.Cut(strings.TrimPrefix(v.String(), "-"), ".")
+	if len(frac) > exp {
+		if strings.Trim(frac[exp:], "0") != "" {
+			return 0, fmt.Errorf("amount %s is not in minor units of %s", v, currency)
+		}
+		frac = frac[:exp]
+	}
+
+	amount, err := strconv.Atoi(whole + frac + strings.Repeat("0", exp-len(frac)))
+	if err != nil {
+		return 0, err
+	}
+	if strings.HasPrefix(v.String(), "-") {
+		amount = -amount
+	}
+	return amount, nil
+}
+
+// formatMinorUnits formats an amount in minor units of currency as a decimal
+// the DB API accepts. It keeps amounts with two fraction digits, so zeros
+// past them are left out, e.g. "1.230" KWD is "1.23".
+func formatMinorUnits(amount int, currency string) string {
+	sign := ""
+	if amount < 0 {
+		sign, amount = "-", -amount
+	}
+
+	exp := currencyExponent(currency)
+	if exp == 0 {
+		return fmt.Sprintf("%s%d", sign, amount)
+	}
+
+	digits := fmt.Sprintf("%0*d", exp+1, amount)
+	whole, frac := digits[:len(digits)-exp], digits[len(digits)-exp:]
+	if len(frac) > 2 {
+		frac = frac[:2] + strings.TrimRight(frac[2:], "0")
+	}
+	return sign + whole + "." + frac
+}
+
+func invoicePayload(roomID int64, amount int) string {
+	return fmt.Sprintf("room:%d:%d", roomID, amount)
+}
+
+func parseInvoicePayload(payload string) (roomID int64, amount int, ok bool) {
+	parts := strings.Split(payload, ":")
+	if len(parts) != 3 || parts[0] != "room" {
+		return 0, 0, false
+	}
+
+	roomID, err := strconv.ParseInt(parts[1], 10, 64)
+	if err != nil {
+		return 0, 0, false
+	}
+
+	amount, err = strconv.Atoi(parts[2])
+	if err != nil {
+		return 0, 0, false
+	}
+
+	return roomID, amount, true
+}
+
+// PayCommandHandler starts the payment flow on /pay.
+func PayCommandHandler(ctx context.Context, bot *telebot.Bot, update *models.Update) {
+	PayStart(ctx, bot, update.Message.From.ID)
+}
+
+// PayStart lists the rooms the client pays for that have a debt.
+func PayStart(ctx context.Context, bot *telebot.Bot, id int64) {
+	rs, err := RoomGetByClientID(id)
+	if err != nil {
+		log.Println("RoomGetByClientID() err:", err)
+		SendError(ctx, bot, id)
+		return
+	}
+
+	var kb [][]models.InlineKeyboardButton
+	for _, r := range rs {
+		if !r.Pays {
+			continue
+		}
+
+		b, err := RoomGetBalance(r.ID)
+		if err != nil {
+			log.Println("RoomGetBalance() err:", err)
+			SendError(ctx, bot, id)
+			return
+		}
+
+		debt, err := toMinorUnits(b.ClosingBalance, b.Currency)
+		if err != nil {
+			log.Println("toMinorUnits() err:", err)
+			SendError(ctx, bot, id)
+			return
+		}
+		if debt <= 0 {
+			continue
+		}
+
+		kb = append(kb, []models.InlineKeyboardButton{{
+			Text:         fmt.Sprintf("Room %d: %s %s", r.ID, formatMinorUnits(debt, b.Currency), b.Currency),
+			CallbackData: fmt.Sprintf("%s%d", payCallbackRoom, r.ID),
+		}})
+	}
+
+	if len(kb) == 0 {
+		sendText(ctx, bot, id, "You have no debts.", nil)
+		return
+	}
+
+	sendText(ctx, bot, id, "Choose a room to pay for:", &models.InlineKeyboardMarkup{InlineKeyboard: kb})
+}
+
+// PayCallbackHandler dispatches the inline buttons of the payment flow.
+func PayCallbackHandler(ctx context.Context, bot *telebot.Bot, update *models.Update) {
+	q := update.CallbackQuery
+	id := q.From.ID
+
+	if _, err := bot.AnswerCallbackQuery(ctx, &telebot.AnswerCallbackQueryParams{
+		CallbackQueryID: q.ID,
+	}); err != nil {
+		log.Println("bot.AnswerCallbackQuery() err:", err)
+	}
+
+	switch data := q.Data; {
+	case data == payCallbackStart:
+		PayStart(ctx, bot, id)
+
+	case strings.HasPrefix(data, payCallbackRoom):
+		roomID, err := strconv.ParseInt(strings.TrimPrefix(data, payCallbackRoom), 10, 64)
+		if err != nil {
+			return
+		}
+		paySendInvoice(ctx, bot, id, roomID)
+	}
+}
+
+func paySendInvoice(ctx context.Context, bot *telebot.Bot, id, roomID int64) {
+	ok, err := roomPaid(id, roomID)
+	if err != nil {
+		log.Println("roomPaid() err:", err)
+		SendError(ctx, bot, id)
+		return
+	}
+	if !ok {
+		sendText(ctx, bot, id, "You don't pay for this room.", nil)
+		return
+	}
+
+	b, err := RoomGetBalance(roomID)
+	if err != nil {
+		log.Println("RoomGetBalance() err:", err)
+		SendError(ctx, bot, id)
+		return
+	}
+
+	amount, err := toMinorUnits(b.ClosingBalance, b.Currency)
+	if err != nil {
+		log.Println("toMinorUnits() err:", err)
+		SendError(ctx, bot, id)
+		return
+	}
+	if amount <= 0 {
+		sendText(ctx, bot, id, fmt.Sprintf("Room %d has no debt.", roomID), nil)
+		return
+	}
+
+	_, err = bot.SendInvoice(ctx, &telebot.SendInvoiceParams{
+		ChatID:        id,
+		Title:         fmt.Sprintf("Room %d", roomID),
+		Description:   fmt.Sprintf("Outstanding balance of room %d", roomID),
+		Payload:       invoicePayload(roomID, amount),
+		ProviderToken: os.Getenv("PAYMENT_KEY"),
+		Currency:      b.Currency,
+		Prices: []models.LabeledPrice{{
+			Label:  "Debt",
+			Amount: amount,
+		}},
+	})
+	if err != nil {
+		log.Println("bot.SendInvoice() err:", err)
+		SendError(ctx, bot, id)
+	}
+}
+
+// PreCheckoutHandler accepts a payment only if the invoice still matches the
+// room's debt according to the DB API.
+func PreCheckoutHandler(ctx context.Context, bot *telebot.Bot, update *models.Update) {
+	q := update.PreCheckoutQuery
+	reason := payCheck(q.From.ID, q.InvoicePayload, q.Currency, q.TotalAmount)
+
+	_, err := bot.AnswerPreCheckoutQuery(ctx, &telebot.AnswerPreCheckoutQueryParams{
+		PreCheckoutQueryID: q.ID,
+		OK:                 reason == "",
+		ErrorMessage:       reason,
+	})
+	if err != nil {
+		log.Println("bot.AnswerPreCheckoutQuery() err:", err)
+	}
+}
+
+// payCheck returns why a payment must be refused, or an empty string.
+func payCheck(id int64, payload, currency string, total int) string {
+	roomID, amount, ok := parseInvoicePayload(payload)
+	if !ok || amount != total {
+		return "The invoice is not valid."
+	}
+
+	paid, err := roomPaid(id, roomID)
+	if err != nil {
+		log.Println("roomPaid() err:", err)
+		return "Payments are unavailable now, try again later."
+	}
+	if !paid {
+		return "You don't pay for this room."
+	}
+
+	b, err := RoomGetBalance(roomID)
+	if err != nil {
+		log.Println("RoomGetBalance() err:", err)
+		return "Payments are unavailable now, try again later."
+	}
+
+	debt, err := toMinorUnits(b.ClosingBalance, b.Currency)
+	if err != nil {
+		log.Println("toMinorUnits() err:", err)
+		return "Payments are unavailable now, try again later."
+	}
+
+	if currency != b.Currency {
+		return "The invoice is not valid."
+	}
+	if amount > debt {
+		return "The debt has changed, please request a new invoice."
+	}
+
+	return ""
+}
+
+// SuccessfulPaymentHandler records a completed Telegram payment in the DB API.
+// The payment is keyed by its Telegram charge ID and dated by the message, so
+// a redelivered update does not record it twice.
+func SuccessfulPaymentHandler(ctx context.Context, bot *telebot.Bot, update *models.Update) {
+	id := update.Message.From.ID
+	p := update.Message.SuccessfulPayment
+
+	roomID, _, ok := parseInvoicePayload(p.InvoicePayload)
+	if !ok {
+		log.Println("SuccessfulPaymentHandler() bad payload:", p.InvoicePayload)
+		SendError(ctx, bot, id)
+		return
+	}
+
+	amount := p.TotalAmount
+	date := time.Unix(int64(update.Message.Date), 0)
+	externalID := "telegram:" + p.TelegramPaymentChargeID
+
+	if err := PaymentCreate(id, roomID, date, amount, p.Currency, p.ProviderPaymentChargeID, externalID); err != nil {
+		log.Printf(
+			"PaymentCreate() err: %v, client_id: %d, room_id: %d, amount: %s, provider charge: %s",
+			err, id, roomID, formatMinorUnits(amount, p.Currency), p.ProviderPaymentChargeID,
+		)
+		SendError(ctx, bot, id)
+		return
+	}
+
+	sendText(ctx, bot, id, fmt.Sprintf("Payment of %s %s for room %d received, thank you!", formatMinorUnits(amount, p.Currency), p.Currency, roomID), nil)
+}
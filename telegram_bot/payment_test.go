@@ -0,0 +1,233 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+
+	telebot "github.com/go-telegram/bot"
+	"github.com/go-telegram/bot/models"
+)
+
+func TestMinorUnits(t *testing.T) {
+	tests := []struct {
+		amount   string
+		currency string
+		minor    int
+		format   string
+	}{
+		{"120.50", "RUB", 12050, "120.50"},
+		{"-0.05", "USD", -5, "-0.05"},
+		{"1200.00", "JPY", 1200, "1200"},
+		{"1200", "JPY", 1200, "1200"},
+		{"1.23", "KWD", 1230, "1.23"},
+	}
+
+	for _, tt := range tests {
+		minor, err := toMinorUnits(json.Number(tt.amount), tt.currency)
+		if err != nil || minor != tt.minor {
+			t.Errorf("toMinorUnits(%s %s) = %d, %v, want %d", tt.amount, tt.currency, minor, err, tt.minor)
+		}
+		if got := formatMinorUnits(tt.minor, tt.currency); got != tt.format {
+			t.Errorf("formatMinorUnits(%d %s) = %s, want %s", tt.minor, tt.currency, got, tt.format)
+		}
+	}
+
+	if _, err := toMinorUnits("1200.50", "JPY"); err == nil {
+		t.Error("toMinorUnits(1200.50 JPY) succeeded, want an error")
+	}
+}
+
+// botCall is a request of the bot to the Bot API.
+type botCall struct {
+	method string
+	form   url.Values
+}
+
+// botAPI is a fake Bot API server that records the calls of the bot.
+type botAPI struct {
+	mu    sync.Mutex
+	calls []botCall
+}
+
+func (api *botAPI) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	method := r.URL.Path[strings.LastIndex(r.URL.Path, "/")+1:]
+
+	form := url.Values{}
+	if err := r.ParseMultipartForm(1 << 20); err == nil {
+		form = r.MultipartForm.Value
+	}
+
+	api.mu.Lock()
+	api.calls = append(api.calls, botCall{method, form})
+	api.mu.Unlock()
+
+	result := `{"message_id": 1, "date": 0, "chat": {"id": 1, "type": "private"}}`
+	if strings.HasPrefix(method, "answer") {
+		result = "true"
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Write([]byte(`{"ok": true, "result": ` + result + `}`))
+}
+
+// call returns the last call of method.
+func (api *botAPI) call(t *testing.T, method string) url.Values {
+	t.Helper()
+
+	api.mu.Lock()
+	defer api.mu.Unlock()
+
+	for i := len(api.calls) - 1; i >= 0; i-- {
+		if api.calls[i].method == method {
+			return api.calls[i].form
+		}
+	}
+	t.Fatalf("no %s call, calls: %+v", method, api.calls)
+	return nil
+}
+
+// newTestBot returns a bot of the fake Bot API server api, set through
+// TELEBOT_SERVER_URL, and of a fake DB API in which client 1 pays for room
+// 10 with a debt of 1200 JPY and meter 1. Payments posted to the DB API are
+// sent to payments; meter readings are stored as 12.5 after 12.3.
+func newTestBot(t *testing.T, api *botAPI, payments chan<- url.Values) *telebot.Bot {
+	t.Helper()
+
+	dbapi := http.NewServeMux()
+	dbapi.HandleFunc("GET /api/room/client/id/1", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`[{"room_id": 10, "member_roles": ["owner"], "member_pays": true}]`))
+	})
+	dbapi.HandleFunc("GET /api/room/id/10/balance", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"room_id": 10, "currency": "JPY", "closing_balance": "1200.00"}`))
+	})
+	dbapi.HandleFunc("GET /api/meter/id/1", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"meter_id": 1, "room_id": 10, "meter_kind": "cold_water", "meter_serial": "CW-1"}`))
+	})
+	dbapi.HandleFunc("POST /api/meter/id/1/reading", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusCreated)
+		w.Write([]byte(`{"reading_id": 2, "meter_id": 1, "reading_value": "12.500", "reading_consumption": "0.200"}`))
+	})
+	dbapi.HandleFunc("POST /api/payment/new", func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		payments <- r.PostForm
+		w.WriteHeader(http.StatusCreated)
+		w.Write([]byte(`{}`))
+	})
+
+	dbapiServer := httptest.NewServer(dbapi)
+	t.Cleanup(dbapiServer.Close)
+
+	orig := dbapiURL
+	dbapiURL = dbapiServer.URL + "/api"
+	t.Cleanup(func() { dbapiURL = orig })
+
+	botServer := httptest.NewServer(api)
+	t.Cleanup(botServer.Close)
+	t.Setenv("TELEBOT_SERVER_URL", botServer.URL)
+
+	bot, err := telebot.New("1:test", append(botOptions(), telebot.WithSkipGetMe(), telebot.WithNotAsyncHandlers())...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return bot
+}
+
+func TestPayInvoice(t *testing.T) {
+	api := &botAPI{}
+	bot := newTestBot(t, api, nil)
+
+	bot.ProcessUpdate(context.Background(), &models.Update{
+		CallbackQuery: &models.CallbackQuery{
+			ID:   "q1",
+			From: models.User{ID: 1},
+			Data: payCallbackRoom + "10",
+		},
+	})
+
+	invoice := api.call(t, "sendInvoice")
+	if got := invoice.Get("currency"); got != "JPY" {
+		t.Errorf("currency = %s, want JPY", got)
+	}
+	if got := invoice.Get("payload"); got != invoicePayload(10, 1200) {
+		t.Errorf("payload = %s, want %s", got, invoicePayload(10, 1200))
+	}
+
+	var prices []models.LabeledPrice
+	if err := json.Unmarshal([]byte(invoice.Get("prices")), &prices); err != nil || len(prices) != 1 || prices[0].Amount != 1200 {
+		t.Errorf("prices = %s, want one of 1200 yen", invoice.Get("prices"))
+	}
+}
+
+func TestPreCheckout(t *testing.T) {
+	api := &botAPI{}
+	bot := newTestBot(t, api, nil)
+
+	tests := []struct {
+		amount int
+		ok     string
+	}{
+		{1200, "true"},
+		{1300, "false"},
+	}
+
+	for _, tt := range tests {
+		bot.ProcessUpdate(context.Background(), &models.Update{
+			PreCheckoutQuery: &models.PreCheckoutQuery{
+				ID:             "q1",
+				From:           &models.User{ID: 1},
+				Currency:       "JPY",
+				TotalAmount:    tt.amount,
+				InvoicePayload: invoicePayload(10, tt.amount),
+			},
+		})
+
+		if got := api.call(t, "answerPreCheckoutQuery").Get("ok"); got != tt.ok {
+			t.Errorf("pre-checkout of %d yen: ok = %s, want %s", tt.amount, got, tt.ok)
+		}
+	}
+}
+
+func TestSuccessfulPayment(t *testing.T) {
+	api := &botAPI{}
+	payments := make(chan url.Values, 1)
+	bot := newTestBot(t, api, payments)
+
+	bot.ProcessUpdate(context.Background(), &models.Update{
+		Message: &models.Message{
+			ID:   2,
+			From: &models.User{ID: 1},
+			Chat: models.Chat{ID: 1, Type: models.ChatTypePrivate},
+			Date: 1739181600,
+			SuccessfulPayment: &models.SuccessfulPayment{
+				Currency:                "JPY",
+				TotalAmount:             1200,
+				InvoicePayload:          invoicePayload(10, 1200),
+				TelegramPaymentChargeID: "tg-1",
+				ProviderPaymentChargeID: "provider-1",
+			},
+		},
+	})
+
+	form := <-payments
+	want := url.Values{
+		"client_id":                  {"1"},
+		"room_id":                    {"10"},
+		"payment_date":               {"2025-02-10 10:00:00"},
+		"payment_amount":             {"1200"},
+		"payment_currency":           {"JPY"},
+		"payment_provider_charge_id": {"provider-1"},
+		"payment_external_id":        {"telegram:tg-1"},
+	}
+	if form.Encode() != want.Encode() {
+		t.Errorf("payment = %v, want %v", form, want)
+	}
+
+	if got := api.call(t, "sendMessage").Get("text"); !strings.Contains(got, "1200 JPY") {
+		t.Errorf("message = %q, want the amount of 1200 JPY", got)
+	}
+}
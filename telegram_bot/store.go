@@ -0,0 +1,205 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+	"sync"
+	"time"
+
+	types "github.com/snakehunterr/hacs_dbapi_types"
+	api_errors "github.com/snakehunterr/hacs_dbapi_types/errors"
+)
+
+const (
+	StateTTL  = 24 * time.Hour
+	ClientTTL = 10 * time.Minute
+)
+
+// StateStore keeps per-user bot data between updates. A zero ttl means the
+// value never expires. Implementations must be safe for concurrent use.
+type StateStore interface {
+	Get(key string) (value string, ok bool, err error)
+	Set(key, value string, ttl time.Duration) error
+	Delete(key string) error
+}
+
+// NewStateStore returns the store selected by BOT_STATE_STORE: "memory" keeps
+// values in the bot process, "api" (the default) keeps them in the DB API so
+// they survive restarts.
+func NewStateStore(kind string) StateStore {
+	switch kind {
+	case "memory":
+		return NewMemoryStore()
+	case "", "api":
+		return APIStore{}
+	}
+	panic(fmt.Sprintf("unknown BOT_STATE_STORE: %q", kind))
+}
+
+type memoryItem struct {
+	value   string
+	expires time.Time
+}
+
+type MemoryStore struct {
+	mu    sync.Mutex
+	items map[string]memoryItem
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{items: make(map[string]memoryItem)}
+}
+
+func (s *MemoryStore) Get(key string) (string, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	item, ok := s.items[key]
+	if !ok {
+		return "", false, nil
+	}
+	if !item.expires.IsZero() && time.Now().After(item.expires) {
+		delete(s.items, key)
+		return "", false, nil
+	}
+	return item.value, true, nil
+}
+
+func (s *MemoryStore) Set(key, value string, ttl time.Duration) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	item := memoryItem{value: value}
+	if ttl > 0 {
+		item.expires = time.Now().Add(ttl)
+	}
+	s.items[key] = item
+	return nil
+}
+
+func (s *MemoryStore) Delete(key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.items, key)
+	return nil
+}
+
+// APIStore keeps values in the bot_state table of the DB API.
+type APIStore struct{}
+
+func (APIStore) Get(key string) (string, bool, error) {
+	var s struct {
+		Value string `json:"state_value"`
+	}
+
+	err := dbapiDo(http.MethodGet, "/bot/state/"+url.PathEscape(key), nil, &s)
+	if err != nil {
+		if api_errors.IsChildErr(err, api_errors.ErrSQLNoRows) {
+			return "", false, nil
+		}
+		return "", false, err
+	}
+	return s.Value, true, nil
+}
+
+func (APIStore) Set(key, value string, ttl time.Duration) error {
+	form := url.Values{}
+	form.Set("state_value", value)
+	form.Set("state_ttl", strconv.FormatInt(int64(ttl/time.Second), 10))
+
+	return dbapiDo(http.MethodPost, "/bot/state/"+url.PathEscape(key), form, nil)
+}
+
+func (APIStore) Delete(key string) error {
+	return dbapiDo(http.MethodDelete, "/bot/state/"+url.PathEscape(key), nil, nil)
+}
+
+// ClientStateHandler keeps the conversation state of every user.
+type ClientStateHandler struct {
+	store StateStore
+}
+
+func stateKey(id int64) string {
+	return fmt.Sprintf("state:%d", id)
+}
+
+func (csh ClientStateHandler) Set(id int64, state ClientState) {
+	if state == NoState {
+		if err := csh.store.Delete(stateKey(id)); err != nil {
+			log.Println("StateStore.Delete() err:", err)
+		}
+		return
+	}
+
+	if err := csh.store.Set(stateKey(id), strconv.Itoa(int(state)), StateTTL); err != nil {
+		log.Println("StateStore.Set() err:", err)
+	}
+}
+
+func (csh ClientStateHandler) Get(id int64) ClientState {
+	v, ok, err := csh.store.Get(stateKey(id))
+	if err != nil {
+		log.Println("StateStore.Get() err:", err)
+		return NoState
+	}
+	if !ok {
+		return NoState
+	}
+
+	s, err := strconv.ParseUint(v, 10, 8)
+	if err != nil {
+		log.Println("ClientStateHandler.Get() bad state:", v)
+		return NoState
+	}
+	return ClientState(s)
+}
+
+// ClientCache keeps clients fetched from the DB API for a short time.
+type ClientCache struct {
+	store StateStore
+}
+
+func clientKey(id int64) string {
+	return fmt.Sprintf("client:%d", id)
+}
+
+func (ch ClientCache) Get(id int64) (*types.Client, bool) {
+	v, ok, err := ch.store.Get(clientKey(id))
+	if err != nil {
+		log.Println("StateStore.Get() err:", err)
+		return nil, false
+	}
+	if !ok {
+		return nil, false
+	}
+
+	var c types.Client
+	if err := json.Unmarshal([]byte(v), &c); err != nil {
+		log.Println("ClientCache.Get() json.Unmarshal() err:", err)
+		return nil, false
+	}
+	return &c, true
+}
+
+func (ch ClientCache) Set(id int64, c *types.Client) {
+	bs, err := json.Marshal(c)
+	if err != nil {
+		log.Println("ClientCache.Set() json.Marshal() err:", err)
+		return
+	}
+
+	if err := ch.store.Set(clientKey(id), string(bs), ClientTTL); err != nil {
+		log.Println("StateStore.Set() err:", err)
+	}
+}
+
+func (ch ClientCache) Delete(id int64) {
+	if err := ch.store.Delete(clientKey(id)); err != nil {
+		log.Println("StateStore.Delete() err:", err)
+	}
+}